@@ -2,16 +2,27 @@ package main
 
 import (
 	"GeekReward/inernal/app/controllers"
+	"GeekReward/inernal/app/middlewares"
+	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
 	"GeekReward/inernal/app/routes"
 	"GeekReward/inernal/app/services"
 	utils "GeekReward/inernal/app/validators"
 	"GeekReward/pkg/database"
 	"GeekReward/pkg/logger"
+	"GeekReward/pkg/mailer"
+	"GeekReward/pkg/pubsub"
+	"GeekReward/pkg/storage"
+	jwtutils "GeekReward/pkg/utils"
+	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 	"log"
+	"time"
+	_ "time/tzdata" // 摘要邮件按用户时区计算周期，容器镜像中可能没有时区数据库
 )
 
 func main() {
@@ -30,6 +41,10 @@ func main() {
 		log.Fatalf("Error loading config.yaml file: %s", err)
 	}
 
+	// 签名密钥不写入配置文件，只从环境变量读取
+	_ = viper.BindEnv("jwt.secret", "GEEKREWARD_JWT_SECRET")
+	_ = viper.BindEnv("storage.secret", "GEEKREWARD_STORAGE_SECRET")
+
 	// 通过 Viper 获取配置信息
 	dbHost := viper.GetString("database.host")
 	dbPort := viper.GetInt("database.port")
@@ -64,27 +79,281 @@ func main() {
 	notificationRepo := repositories.NewNotificationRepository(database.DB)
 	applicationRepo := repositories.NewApplicationRepository(database.DB)
 	invitationRepo := repositories.NewInvitationRepository(database.DB)
+	ledgerRepo := repositories.NewLedgerRepository(database.DB)
+	txManager := repositories.NewTransactionManager(database.DB)
+	sessionRepo := repositories.NewSessionRepository(database.DB)
+	recoveryCodeRepo := repositories.NewRecoveryCodeRepository(database.DB)
+	userTokenRepo := repositories.NewUserTokenRepository(database.DB)
+	notificationPreferenceRepo := repositories.NewNotificationPreferenceRepository(database.DB)
+	notificationDigestRepo := repositories.NewNotificationDigestRepository(database.DB)
+	webhookRepo := repositories.NewWebhookRepository(database.DB)
+	outboxRepo := repositories.NewOutboxRepository(database.DB)
+	teamRepo := repositories.NewTeamRepository(database.DB)
+	settlementRepo := repositories.NewSettlementRepository(database.DB)
+	attachmentRepo := repositories.NewAttachmentRepository(database.DB)
+
+	// 邮件发送器：smtp 用于生产，file 将邮件写入目录便于本地开发
+	viper.SetDefault("mail.driver", "file")
+	viper.SetDefault("mail.dir", "./mail")
+	viper.SetDefault("mail.from", "GeekReward <no-reply@geekreward.local>")
+	mailSender, err := mailer.New(mailer.Config{
+		Driver:   viper.GetString("mail.driver"),
+		Host:     viper.GetString("mail.host"),
+		Port:     viper.GetInt("mail.port"),
+		Username: viper.GetString("mail.username"),
+		Password: viper.GetString("mail.password"),
+		From:     viper.GetString("mail.from"),
+		Dir:      viper.GetString("mail.dir"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize mailer: %v", err)
+	}
+
+	// JWT 签名密钥与令牌有效期
+	viper.SetDefault("jwt.access_token_ttl", "15m")
+	viper.SetDefault("jwt.refresh_token_ttl", "720h")
+	if err := jwtutils.InitJWT(viper.GetString("jwt.secret"), viper.GetDuration("jwt.access_token_ttl")); err != nil {
+		log.Fatalf("Failed to initialize JWT: %v", err)
+	}
+	refreshTokenTTL := viper.GetDuration("jwt.refresh_token_ttl")
+	if refreshTokenTTL <= 0 {
+		refreshTokenTTL = 30 * 24 * time.Hour
+	}
+
+	// 取消清算时的违约金比例
+	viper.SetDefault("ledger.publisher_penalty_rate", 0.1)
+	viper.SetDefault("ledger.receiver_penalty_rate", 0.05)
+	penaltyRates := services.PenaltyRates{
+		Publisher: viper.GetFloat64("ledger.publisher_penalty_rate"),
+		Receiver:  viper.GetFloat64("ledger.receiver_penalty_rate"),
+	}
+
+	// 实时推送的消息代理：memory 仅支持单实例，多实例部署时使用 redis
+	// 对象存储：附件与头像，local 驱动保存在 storage.dir，s3 驱动兼容 MinIO 等服务
+	viper.SetDefault("storage.driver", "local")
+	viper.SetDefault("storage.dir", "./uploads")
+	viper.SetDefault("storage.base_url", "/storage")
+	viper.SetDefault("storage.presign_ttl", "15m")
+	viper.SetDefault("storage.s3.path_style", true)
+	// 预签名密钥必须单独配置，泄露其中一个不应影响另一个
+	storageSecret := viper.GetString("storage.secret")
+	if storageSecret != "" && storageSecret == viper.GetString("jwt.secret") {
+		log.Fatalf("storage.secret must differ from jwt.secret")
+	}
+	blob, err := storage.New(storage.Config{
+		Driver:    viper.GetString("storage.driver"),
+		Dir:       viper.GetString("storage.dir"),
+		BaseURL:   viper.GetString("storage.base_url"),
+		Secret:    storageSecret,
+		Endpoint:  viper.GetString("storage.s3.endpoint"),
+		Region:    viper.GetString("storage.s3.region"),
+		Bucket:    viper.GetString("storage.s3.bucket"),
+		AccessKey: viper.GetString("storage.s3.access_key"),
+		SecretKey: viper.GetString("storage.s3.secret_key"),
+		PathStyle: viper.GetBool("storage.s3.path_style"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize storage: %v", err)
+	}
+
+	viper.SetDefault("realtime.broker", "memory")
+	broker, err := pubsub.New(pubsub.Config{
+		Driver:   viper.GetString("realtime.broker"),
+		Addr:     fmt.Sprintf("%s:%d", redisHost, redisPort),
+		Password: viper.GetString("redis.password"),
+		DB:       viper.GetInt("redis.db"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to initialize pubsub broker: %v", err)
+	}
+	defer broker.Close()
+	notificationHub := services.NewNotificationHub(broker)
+	go func() {
+		if err := notificationHub.Run(context.Background()); err != nil {
+			logger.ErrorLogger.Errorf("Realtime hub stopped: %v", err)
+		}
+	}()
 
 	// 初始化服务
-	authService := services.NewAuthService(userRepo)
-	bountyService := services.NewBountyService(userRepo, bountyRepo, applicationRepo, notificationRepo, milestoneRepo)
-	geekService := services.NewGeekService(geekRepo, invitationRepo)
+	viper.SetDefault("app.frontend_url", "http://localhost:3000")
+	viper.SetDefault("account.verification_ttl", "24h")
+	viper.SetDefault("account.password_reset_ttl", "1h")
+	accountService := services.NewAccountService(userRepo, userTokenRepo, sessionRepo, mailSender, services.AccountSettings{
+		FrontendURL:      viper.GetString("app.frontend_url"),
+		VerificationTTL:  viper.GetDuration("account.verification_ttl"),
+		PasswordResetTTL: viper.GetDuration("account.password_reset_ttl"),
+	})
+	viper.SetDefault("two_factor.issuer", "GeekReward")
+	twoFactorService := services.NewTwoFactorService(userRepo, recoveryCodeRepo, viper.GetString("two_factor.issuer"))
+
+	// 登录失败锁定策略：按账号与按 IP 分别统计
+	viper.SetDefault("login.max_attempts", 5)
+	viper.SetDefault("login.lock_duration", "1m")
+	viper.SetDefault("login.max_lock_duration", "1h")
+	viper.SetDefault("login.ip_max_attempts", 20)
+	viper.SetDefault("login.ip_window", "15m")
+	accountLockout := services.LockoutPolicy{
+		MaxAttempts: viper.GetInt("login.max_attempts"),
+		BaseLock:    viper.GetDuration("login.lock_duration"),
+		MaxLock:     viper.GetDuration("login.max_lock_duration"),
+	}
+	ipLimiter := services.NewMemoryLoginLimiter(services.LockoutPolicy{
+		MaxAttempts: viper.GetInt("login.ip_max_attempts"),
+		BaseLock:    viper.GetDuration("login.lock_duration"),
+		MaxLock:     viper.GetDuration("login.max_lock_duration"),
+		Window:      viper.GetDuration("login.ip_window"),
+	})
+	// 上传校验：文件类型以内容嗅探为准，max_size 为单个附件上限，user_quota 为每个用户附件总量上限
+	viper.SetDefault("upload.max_size", "20MB")
+	viper.SetDefault("upload.avatar_max_size", "5MB")
+	viper.SetDefault("upload.avatar_max_pixels", 16_000_000)
+	viper.SetDefault("upload.user_quota", "1GB")
+	viper.SetDefault("upload.avatar_sizes", []int{64, 128, 256})
+	uploadSettings := services.UploadSettings{
+		MaxSize:         int64(viper.GetSizeInBytes("upload.max_size")),
+		AvatarMaxSize:   int64(viper.GetSizeInBytes("upload.avatar_max_size")),
+		AvatarMaxPixels: viper.GetInt64("upload.avatar_max_pixels"),
+		UserQuota:       int64(viper.GetSizeInBytes("upload.user_quota")),
+		AllowedTypes:    viper.GetStringSlice("upload.allowed_types"),
+		AvatarSizes:     viper.GetIntSlice("upload.avatar_sizes"),
+	}
+	avatarService := services.NewAvatarService(userRepo, blob, uploadSettings)
+	authService := services.NewAuthService(userRepo, sessionRepo, twoFactorService, ipLimiter, avatarService, services.AuthSettings{
+		RefreshTokenTTL: refreshTokenTTL,
+		AccountLockout:  accountLockout,
+	})
+	// Webhook 投递：失败后按 base_backoff * 2^(n-1) 重试，最多 max_attempts 次
+	viper.SetDefault("webhook.timeout", "10s")
+	viper.SetDefault("webhook.max_attempts", 8)
+	viper.SetDefault("webhook.base_backoff", "1m")
+	viper.SetDefault("webhook.max_backoff", "6h")
+	webhookService := services.NewWebhookService(webhookRepo, services.WebhookSettings{
+		Timeout:     viper.GetDuration("webhook.timeout"),
+		MaxAttempts: viper.GetInt("webhook.max_attempts"),
+		BaseBackoff: viper.GetDuration("webhook.base_backoff"),
+		MaxBackoff:  viper.GetDuration("webhook.max_backoff"),
+	})
+	go func() {
+		if err := webhookService.Run(context.Background()); err != nil {
+			logger.ErrorLogger.Errorf("Webhook worker stopped: %v", err)
+		}
+	}()
+
+	notificationService := services.NewNotificationService(notificationRepo, notificationPreferenceRepo, userRepo, notificationHub, map[tables.NotificationChannel]services.NotificationSender{
+		tables.NotificationChannelEmail:   services.NewEmailNotificationSender(mailSender, viper.GetString("app.frontend_url")),
+		tables.NotificationChannelWebhook: services.NewWebhookNotificationSender(webhookService),
+	})
+	bountyService := services.NewBountyService(userRepo, bountyRepo, applicationRepo, milestoneRepo, ledgerRepo, teamRepo, settlementRepo, attachmentRepo, outboxRepo, txManager, penaltyRates, notificationHub)
+	geekService := services.NewGeekService(geekRepo)
 	userService := services.NewUserService(userRepo)
-	milestoneService := services.NewMilestoneService(milestoneRepo, bountyRepo)
-	notificationService := services.NewNotificationService(notificationRepo)
-	applicationService := services.NewApplicationService(applicationRepo, bountyRepo)
-	invitationService := services.NewInvitationService(invitationRepo, userRepo)
+	milestoneService := services.NewMilestoneService(milestoneRepo, bountyRepo, applicationRepo, teamRepo, ledgerRepo, settlementRepo, attachmentRepo, outboxRepo, txManager)
+	applicationService := services.NewApplicationService(applicationRepo, bountyRepo, teamRepo, outboxRepo, txManager, notificationHub)
+	teamService := services.NewTeamService(teamRepo, bountyRepo, txManager)
+	ledgerService := services.NewLedgerService(ledgerRepo)
+
+	// 组队邀请：ttl 内未处理的邀请由后台任务每 sweep_interval 标记为过期
+	viper.SetDefault("invitation.ttl", "168h")
+	viper.SetDefault("invitation.sweep_interval", "10m")
+	invitationService := services.NewInvitationService(invitationRepo, userRepo, teamRepo, bountyRepo, outboxRepo, txManager, services.InvitationSettings{
+		TTL:           viper.GetDuration("invitation.ttl"),
+		SweepInterval: viper.GetDuration("invitation.sweep_interval"),
+	})
+	go func() {
+		if err := invitationService.Run(context.Background()); err != nil {
+			logger.ErrorLogger.Errorf("Invitation sweeper stopped: %v", err)
+		}
+	}()
+
+	// 附件：上传后 orphan_ttl 内仍未关联悬赏令或未完成直传的文件由后台任务每 gc_interval 回收
+	viper.SetDefault("attachment.orphan_ttl", "24h")
+	viper.SetDefault("attachment.gc_interval", "1h")
+	attachmentService := services.NewAttachmentService(attachmentRepo, bountyRepo, milestoneRepo, teamRepo, applicationRepo, blob, services.AttachmentSettings{
+		PresignTTL:    viper.GetDuration("storage.presign_ttl"),
+		OrphanTTL:     viper.GetDuration("attachment.orphan_ttl"),
+		SweepInterval: viper.GetDuration("attachment.gc_interval"),
+		Upload:        uploadSettings,
+	})
+	go func() {
+		if err := attachmentService.Run(context.Background()); err != nil {
+			logger.ErrorLogger.Errorf("Attachment collector stopped: %v", err)
+		}
+	}()
+	adminService := services.NewAdminService(userRepo, sessionRepo, notificationRepo, bountyService, notificationHub)
+
+	// 领域事件发件箱：状态变更时在同一事务中写入事件，由后台分发给通知与 Webhook 消费者，至少投递一次
+	viper.SetDefault("outbox.poll_interval", "1s")
+	viper.SetDefault("outbox.max_attempts", 10)
+	viper.SetDefault("outbox.base_backoff", "5s")
+	viper.SetDefault("outbox.max_backoff", "1h")
+	viper.SetDefault("outbox.retention", "168h")
+	eventDispatcher := services.NewEventDispatcher(outboxRepo, services.OutboxSettings{
+		PollInterval: viper.GetDuration("outbox.poll_interval"),
+		MaxAttempts:  viper.GetInt("outbox.max_attempts"),
+		BaseBackoff:  viper.GetDuration("outbox.base_backoff"),
+		MaxBackoff:   viper.GetDuration("outbox.max_backoff"),
+		Retention:    viper.GetDuration("outbox.retention"),
+	},
+		services.NewNotificationEventConsumer(notificationService),
+		services.NewWebhookEventConsumer(webhookService),
+	)
+	go func() {
+		if err := eventDispatcher.Run(context.Background()); err != nil {
+			logger.ErrorLogger.Errorf("Outbox dispatcher stopped: %v", err)
+		}
+	}()
+
+	// 未读通知摘要邮件：按用户时区在 send_hour 之后发送上一天或上一周的摘要
+	viper.SetDefault("digest.enabled", true)
+	viper.SetDefault("digest.send_hour", 8)
+	viper.SetDefault("digest.interval", "15m")
+	if viper.GetBool("digest.enabled") {
+		digestService := services.NewNotificationDigestService(notificationDigestRepo, notificationRepo, mailSender, services.DigestSettings{
+			FrontendURL: viper.GetString("app.frontend_url"),
+			SendHour:    viper.GetInt("digest.send_hour"),
+			Interval:    viper.GetDuration("digest.interval"),
+		})
+		go func() {
+			if err := digestService.Run(context.Background()); err != nil {
+				logger.ErrorLogger.Errorf("Notification digest worker stopped: %v", err)
+			}
+		}()
+	}
+
+	// 认证中间件通过会话校验已注销的令牌
+	middlewares.SetSessionValidator(authService.IsSessionActive)
+
+	// 角色中间件从数据库读取最新的角色与权限
+	middlewares.SetUserLoader(userRepo.FindByUserID)
+
+	// 启动时将配置中的用户提升为管理员，用于初始化第一个管理员账号
+	for _, idStr := range viper.GetStringSlice("admin.user_ids") {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			log.Fatalf("Invalid admin user id %q: %v", idStr, err)
+		}
+		if err := userRepo.UpdateRole(id, tables.RoleAdmin); err != nil {
+			logger.ErrorLogger.Errorf("Failed to grant admin role to %s: %v", id, err)
+		}
+	}
 
 	// 初始化控制器
-	authController := controllers.NewAuthController(authService, notificationService)
+	authController := controllers.NewAuthController(authService, accountService, notificationService, avatarService)
 	bountyController := controllers.NewBountyController(bountyService, milestoneService, notificationService)
-	geekController := controllers.NewGeekController(geekService, notificationService)
-	userController := controllers.NewUserController(userService, notificationService)
+	geekController := controllers.NewGeekController(geekService, invitationService, notificationService)
+	userController := controllers.NewUserController(userService, notificationService, avatarService)
 	notificationController := controllers.NewNotificationController(notificationService)
 	applicationController := controllers.NewApplicationController(applicationService, bountyService, notificationService)
 	milestoneController := controllers.NewMilestoneController(milestoneService, notificationService)
 	invitationController := controllers.NewInvitationController(invitationService, notificationService)
-	attachmentController := controllers.NewAttachmentController(notificationService)
+	attachmentController := controllers.NewAttachmentController(attachmentService, blob, viper.GetDuration("storage.presign_ttl"), notificationService)
+	walletController := controllers.NewWalletController(ledgerService)
+	twoFactorController := controllers.NewTwoFactorController(twoFactorService)
+	adminController := controllers.NewAdminController(authService, adminService)
+	// WebSocket 只接受来自前端的浏览器连接，未配置时仅允许 app.frontend_url
+	viper.SetDefault("realtime.allowed_origins", []string{viper.GetString("app.frontend_url")})
+	realtimeController := controllers.NewRealtimeController(notificationHub, notificationService, authService, viper.GetStringSlice("realtime.allowed_origins"))
+	webhookController := controllers.NewWebhookController(webhookService)
+	teamController := controllers.NewTeamController(teamService)
 
 	// 初始化验证器
 	validatorInstance, err := utils.NewValidator()
@@ -103,6 +372,12 @@ func main() {
 		milestoneController,
 		invitationController,
 		attachmentController,
+		walletController,
+		twoFactorController,
+		adminController,
+		realtimeController,
+		webhookController,
+		teamController,
 	)
 
 	// 传递给需要的组件或通过中间件设置到上下文中
@@ -0,0 +1,123 @@
+package authz
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"strings"
+)
+
+// Action 受所有权保护的操作，格式为 "资源:动作"
+type Action string
+
+const (
+	ActionBountyUpdate Action = "bounty:update"
+	ActionBountyDelete Action = "bounty:delete"
+
+	ActionMilestoneCreate  Action = "milestone:create"
+	ActionMilestoneUpdate  Action = "milestone:update"
+	ActionMilestoneDelete  Action = "milestone:delete"
+	ActionMilestoneSubmit  Action = "milestone:submit"  // 接收者提交里程碑成果
+	ActionMilestoneReview  Action = "milestone:review"  // 发布者验收或驳回里程碑成果
+	ActionMilestoneReward  Action = "milestone:reward"  // 发布者分配里程碑的阶段赏金
+	ActionMilestoneConfirm Action = "milestone:confirm" // 接收者确认提交所有里程碑
+	ActionMilestoneVerify  Action = "milestone:verify"  // 发布者审核并确认所有里程碑
+
+	ActionApplicationList    Action = "application:list" // 查看包含待处理申请在内的全部申请
+	ActionApplicationApprove Action = "application:approve"
+	ActionApplicationReject  Action = "application:reject"
+
+	ActionSettlementSplit           Action = "settlement:split"            // 接收者设置赏金在团队成员之间的分配方式
+	ActionSettlementApply           Action = "settlement:apply"            // 接收者申请清算
+	ActionSettlementRelease         Action = "settlement:release"          // 发布者确认结算并放款
+	ActionSettlementCancelPublisher Action = "settlement:cancel-publisher" // 发布者取消清算
+	ActionSettlementCancelReceiver  Action = "settlement:cancel-receiver"  // 接收者取消清算
+)
+
+// Relation 用户与悬赏令之间的关系
+type Relation string
+
+const (
+	RelationPublisher Relation = "publisher"
+	RelationReceiver  Relation = "receiver"
+)
+
+// policies 每个操作要求调用者与悬赏令之间具备的关系
+var policies = map[Action]Relation{
+	ActionBountyUpdate:              RelationPublisher,
+	ActionBountyDelete:              RelationPublisher,
+	ActionMilestoneCreate:           RelationPublisher,
+	ActionMilestoneUpdate:           RelationPublisher,
+	ActionMilestoneDelete:           RelationPublisher,
+	ActionMilestoneSubmit:           RelationReceiver,
+	ActionMilestoneReview:           RelationPublisher,
+	ActionMilestoneReward:           RelationPublisher,
+	ActionMilestoneConfirm:          RelationReceiver,
+	ActionMilestoneVerify:           RelationPublisher,
+	ActionApplicationList:           RelationPublisher,
+	ActionApplicationApprove:        RelationPublisher,
+	ActionApplicationReject:         RelationPublisher,
+	ActionSettlementSplit:           RelationReceiver,
+	ActionSettlementApply:           RelationReceiver,
+	ActionSettlementRelease:         RelationPublisher,
+	ActionSettlementCancelPublisher: RelationPublisher,
+	ActionSettlementCancelReceiver:  RelationReceiver,
+}
+
+// ErrForbidden 所有授权错误均可通过 errors.Is(err, authz.ErrForbidden) 识别
+var ErrForbidden = errors.New("forbidden")
+
+// Error 调用者无权执行某项操作
+type Error struct {
+	Action   Action
+	BountyID uuid.UUID
+	Required Relation
+}
+
+func (e *Error) Error() string {
+	if e.Required == "" {
+		return fmt.Sprintf("未定义的操作 %s", e.Action)
+	}
+	return fmt.Sprintf("只有悬赏令的%s才能执行 %s", relationNames[e.Required], e.Action)
+}
+
+// Is 使 errors.Is(err, ErrForbidden) 成立
+func (e *Error) Is(target error) bool {
+	return target == ErrForbidden
+}
+
+// Resource 返回操作针对的资源类型
+func (e *Error) Resource() string {
+	resource, _, _ := strings.Cut(string(e.Action), ":")
+	return resource
+}
+
+var relationNames = map[Relation]string{
+	RelationPublisher: "发布者",
+	RelationReceiver:  "接收者",
+}
+
+// Can 判断用户能否对悬赏令及其里程碑、申请执行操作，不允许时返回 *Error
+// 里程碑与申请都归属于某个悬赏令，统一以所属悬赏令判断发布者与接收者
+func Can(userID uuid.UUID, action Action, bounty *tables.Bounty) error {
+	required, ok := policies[action]
+	if !ok {
+		// 未声明策略的操作一律拒绝
+		return &Error{Action: action, BountyID: bounty.ID}
+	}
+	if !hasRelation(userID, bounty, required) {
+		return &Error{Action: action, BountyID: bounty.ID, Required: required}
+	}
+	return nil
+}
+
+func hasRelation(userID uuid.UUID, bounty *tables.Bounty, relation Relation) bool {
+	switch relation {
+	case RelationPublisher:
+		return bounty.UserID == userID
+	case RelationReceiver:
+		return bounty.ReceiverID != nil && *bounty.ReceiverID == userID
+	}
+	return false
+}
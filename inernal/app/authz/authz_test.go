@@ -0,0 +1,105 @@
+package authz
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"errors"
+	"github.com/google/uuid"
+	"testing"
+)
+
+func TestCan(t *testing.T) {
+	publisher, receiver, stranger := uuid.New(), uuid.New(), uuid.New()
+	bounty := &tables.Bounty{UserID: publisher, ReceiverID: &receiver}
+	bounty.ID = uuid.New()
+
+	// 每个操作期望放行的关系，其余身份均应被拒绝
+	cases := []struct {
+		action   Action
+		required Relation
+	}{
+		{ActionBountyUpdate, RelationPublisher},
+		{ActionBountyDelete, RelationPublisher},
+		{ActionMilestoneCreate, RelationPublisher},
+		{ActionMilestoneUpdate, RelationPublisher},
+		{ActionMilestoneDelete, RelationPublisher},
+		{ActionMilestoneSubmit, RelationReceiver},
+		{ActionMilestoneReview, RelationPublisher},
+		{ActionMilestoneReward, RelationPublisher},
+		{ActionMilestoneConfirm, RelationReceiver},
+		{ActionMilestoneVerify, RelationPublisher},
+		{ActionApplicationList, RelationPublisher},
+		{ActionApplicationApprove, RelationPublisher},
+		{ActionApplicationReject, RelationPublisher},
+		{ActionSettlementSplit, RelationReceiver},
+		{ActionSettlementApply, RelationReceiver},
+		{ActionSettlementRelease, RelationPublisher},
+		{ActionSettlementCancelPublisher, RelationPublisher},
+		{ActionSettlementCancelReceiver, RelationReceiver},
+	}
+	if len(cases) != len(policies) {
+		t.Fatalf("table covers %d actions, policies declares %d", len(cases), len(policies))
+	}
+
+	users := map[string]uuid.UUID{
+		"publisher": publisher,
+		"receiver":  receiver,
+		"stranger":  stranger,
+	}
+	for _, c := range cases {
+		for name, userID := range users {
+			t.Run(string(c.action)+"/"+name, func(t *testing.T) {
+				err := Can(userID, c.action, bounty)
+				if name == string(c.required) {
+					if err != nil {
+						t.Fatalf("Can = %v, want nil", err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrForbidden) {
+					t.Fatalf("Can = %v, want ErrForbidden", err)
+				}
+				var authzErr *Error
+				if !errors.As(err, &authzErr) {
+					t.Fatalf("Can = %T, want *Error", err)
+				}
+				if authzErr.Action != c.action || authzErr.BountyID != bounty.ID || authzErr.Required != c.required {
+					t.Fatalf("unexpected error %+v", authzErr)
+				}
+			})
+		}
+	}
+}
+
+func TestCanWithoutReceiver(t *testing.T) {
+	publisher := uuid.New()
+	bounty := &tables.Bounty{UserID: publisher}
+	bounty.ID = uuid.New()
+
+	// 尚未指派接收者时，任何人（包括发布者）都不具备接收者关系
+	for _, userID := range []uuid.UUID{publisher, uuid.New(), uuid.Nil} {
+		if err := Can(userID, ActionMilestoneSubmit, bounty); !errors.Is(err, ErrForbidden) {
+			t.Errorf("Can(%s, submit) = %v, want ErrForbidden", userID, err)
+		}
+	}
+	if err := Can(publisher, ActionBountyUpdate, bounty); err != nil {
+		t.Errorf("Can(publisher, update) = %v, want nil", err)
+	}
+}
+
+func TestCanUndefinedAction(t *testing.T) {
+	publisher := uuid.New()
+	bounty := &tables.Bounty{UserID: publisher, ReceiverID: &publisher}
+	bounty.ID = uuid.New()
+
+	err := Can(publisher, Action("bounty:transfer"), bounty)
+	var authzErr *Error
+	if !errors.As(err, &authzErr) || !errors.Is(err, ErrForbidden) {
+		t.Fatalf("Can = %v, want *Error matching ErrForbidden", err)
+	}
+	if authzErr.Required != "" {
+		t.Errorf("Required = %q, want empty for undefined action", authzErr.Required)
+	}
+	if got := authzErr.Resource(); got != "bounty" {
+		t.Errorf("Resource = %q, want bounty", got)
+	}
+}
@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/http"
+)
+
+// AdminController 处理管理员与版主的后台操作
+type AdminController struct {
+	authService  services.AuthService
+	adminService services.AdminService
+}
+
+// NewAdminController 创建新的 AdminController 实例
+func NewAdminController(authService services.AuthService, adminService services.AdminService) *AdminController {
+	return &AdminController{authService: authService, adminService: adminService}
+}
+
+// UnlockAccount 解除用户因登录失败次数过多导致的锁定
+// POST /admin/users/:user_id/unlock
+func (ctl *AdminController) UnlockAccount(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.authService.UnlockAccount(userID); err != nil {
+		respondAdminError(c, err, "解除锁定失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已解除账号锁定"})
+}
+
+// SuspendUser 封禁用户，其所有会话立即失效
+// POST /admin/users/:user_id/suspend
+func (ctl *AdminController) SuspendUser(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.adminService.SuspendUser(actorID, userID); err != nil {
+		respondAdminError(c, err, "封禁用户失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "用户已被封禁"})
+}
+
+// ReactivateUser 解除用户封禁
+// POST /admin/users/:user_id/reactivate
+func (ctl *AdminController) ReactivateUser(c *gin.Context) {
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.adminService.ReactivateUser(userID); err != nil {
+		respondAdminError(c, err, "解除封禁失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已解除用户封禁"})
+}
+
+// UpdateUserRole 修改用户角色
+// PUT /admin/users/:user_id/role
+func (ctl *AdminController) UpdateUserRole(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.UpdateUserRoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	if err := ctl.adminService.UpdateUserRole(actorID, userID, tables.Role(input.Role)); err != nil {
+		respondAdminError(c, err, "修改用户角色失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "用户角色已更新", "role": input.Role})
+}
+
+// ForceCancelBounty 以系统身份强制取消悬赏令，托管赏金全额退还发布者
+// POST /admin/bounties/:bounty_id/cancel
+func (ctl *AdminController) ForceCancelBounty(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, err := uuid.Parse(c.Param("bounty_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		return
+	}
+
+	var input dtos.ForceCancelBountyInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	if err := ctl.adminService.ForceCancelBounty(actorID, bountyID, input.Reason); err != nil {
+		if respondTransitionError(c, err) {
+			return
+		}
+		respondAdminError(c, err, "取消悬赏令失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "悬赏令已取消"})
+}
+
+// DeleteComment 删除任意评论
+// DELETE /admin/comments/:comment_id
+func (ctl *AdminController) DeleteComment(c *gin.Context) {
+	commentID, err := uuid.Parse(c.Param("comment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的评论ID"})
+		return
+	}
+
+	if err := ctl.adminService.DeleteComment(commentID); err != nil {
+		respondAdminError(c, err, "删除评论失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "评论已删除"})
+}
+
+// BroadcastNotification 向所有用户广播通知
+// POST /admin/notifications/broadcast
+func (ctl *AdminController) BroadcastNotification(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.BroadcastNotificationInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	count, err := ctl.adminService.BroadcastNotification(actorID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "广播通知失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "通知已广播", "recipients": count})
+}
+
+// parseUserIDParam 解析路径中的 user_id，失败时已写入响应
+func parseUserIDParam(c *gin.Context) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// respondAdminError 将后台操作的业务错误转换为对应的响应
+func respondAdminError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "资源不存在"})
+	case errors.Is(err, services.ErrCannotModerateSelf), errors.Is(err, services.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
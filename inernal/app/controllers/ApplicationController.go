@@ -2,8 +2,10 @@ package controllers
 
 import (
 	"GeekReward/inernal/app/services"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"net/http"
 )
 
@@ -49,9 +51,10 @@ func (ctl *ApplicationController) CreateApplication(c *gin.Context) {
 		return
 	}
 
-	// 解析可选的 note
+	// 解析可选的 note 与 team_id（以团队身份申请）
 	var input struct {
-		Note string `json:"note"`
+		Note   string     `json:"note"`
+		TeamID *uuid.UUID `json:"team_id"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		// note不是必填，所以如果解析失败也许只是json不对
@@ -82,7 +85,15 @@ func (ctl *ApplicationController) CreateApplication(c *gin.Context) {
 	}
 
 	// 创建申请
-	if err := ctl.applicationService.CreateApplication(bountyID, uid, input.Note); err != nil {
+	if err := ctl.applicationService.CreateApplication(bountyID, uid, input.Note, input.TeamID); err != nil {
+		if errors.Is(err, services.ErrTeamNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrNotTeamLeader) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -103,13 +114,28 @@ func (ctl *ApplicationController) GetApplications(c *gin.Context) {
 		return
 	}
 
-	// 这里要不要验证 "是否当前用户就是发布者"? 视业务需求
-	// 例如先 getBounty(bountyID), 如果 bounty.UserID != currentUser => 403
+	// 只有发布者可以查看全部申请，由服务层校验
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
 	// 调用控制器对象所绑定的 applicationService 模块的方法的引用
-	applications, err := ctl.applicationService.GetApplications(bountyID)
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	applications, err := ctl.applicationService.GetApplications(bountyID, userID, page)
 	// 同样
 	if err != nil {
+		if respondForbidden(c, err) || respondPageError(c, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "悬赏令未找到"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取悬赏令的申请信息失败"})
 		return
 	}
@@ -126,10 +152,22 @@ func (ctl *ApplicationController) ApproveApplication(c *gin.Context) {
 		return
 	}
 
-	// 可能需要添加发布者身份验证，确保只有悬赏令的发布者才能批准申请
-	// 例如，通过JWT解析当前用户ID，并检查是否为悬赏令的发布者
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return
+	}
 
-	if err := ctl.applicationService.ApproveApplication(applicationID); err != nil {
+	// 只有悬赏令的发布者才能批准申请
+	if err := ctl.applicationService.ApproveApplication(applicationID, userID); err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "批准申请失败"})
 		return
 	}
@@ -146,9 +184,16 @@ func (ctl *ApplicationController) RejectApplication(c *gin.Context) {
 		return
 	}
 
-	// 可能需要添加发布者身份验证，确保只有悬赏令的发布者才能拒绝申请
+	// 只有悬赏令的发布者才能拒绝申请
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
 
-	if err := ctl.applicationService.RejectApplication(applicationID); err != nil {
+	if err := ctl.applicationService.RejectApplication(applicationID, userID); err != nil {
+		if respondForbidden(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "拒绝申请失败"})
 		return
 	}
@@ -1,64 +1,388 @@
 package controllers
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/services"
-	"fmt"
+	"GeekReward/pkg/storage"
+	"errors"
+	"log"
+	"mime"
+	"mime/multipart"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
-// AttachmentController 处理附件相关请求
+// AttachmentController 处理附件相关请求，文件统一通过 storage.Blob 读写，
+// 附件的记录与访问控制由 AttachmentService 负责
 type AttachmentController struct {
+	attachmentService   services.AttachmentService
+	blob                storage.Blob
+	presignTTL          time.Duration
 	notificationService services.NotificationService
 }
 
 // NewAttachmentController 创建新的 AttachmentController
 func NewAttachmentController(
+	attachmentService services.AttachmentService,
+	blob storage.Blob,
+	presignTTL time.Duration,
 	notificationService services.NotificationService,
 ) *AttachmentController {
 	return &AttachmentController{
+		attachmentService:   attachmentService,
+		blob:                blob,
+		presignTTL:          presignTTL,
 		notificationService: notificationService,
 	}
 }
 
-// UploadAttachment 处理单个文件上传的示例
+// UploadAttachment 处理单个文件上传，表单可选 bounty_id 或 milestone_id 指定关联的悬赏令或里程碑
+// POST /attachment
 func (ctl *AttachmentController) UploadAttachment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	// 从表单中获取文件
 	file, err := c.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no file is received"})
 		return
 	}
+	input, ok := bindAttachmentLink(c, c.PostForm("bounty_id"), c.PostForm("milestone_id"))
+	if !ok {
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败"})
+		return
+	}
+	defer src.Close()
 
-	// 可选：指定存储目录
-	uploadDir := "./uploads"
-	// 确保目录存在
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create upload directory"})
+	input.Filename = file.Filename
+	input.Size = file.Size
+	input.Content = src
+	attachment, err := ctl.attachmentService.Upload(c.Request.Context(), userID, input)
+	if err != nil {
+		respondAttachmentError(c, err, "failed to save file")
 		return
 	}
 
-	// 使用UUID重命名文件，避免重名
-	ext := filepath.Ext(file.Filename)                // 如 .jpg, .png
-	newFilename := uuid.New().String() + ext          // 形如 123e4567-e89b-12d3-a456-426655440000.png
-	filePath := filepath.Join(uploadDir, newFilename) // ./uploads/xxx.png
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "upload success",
+		"file_url":   services.AttachmentURL(attachment.ID),
+		"attachment": attachment,
+	})
+}
 
-	// 保存到本地
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save file"})
+// PresignUpload 记录附件并生成直传存储后端的上传地址，客户端用 PUT 上传文件内容后
+// 调用 POST /attachments/:attachment_id/complete 确认
+// POST /attachment/presign  {"filename": "design.pdf", "size": 102400, "bounty_id": "..."}
+func (ctl *AttachmentController) PresignUpload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
 		return
 	}
 
-	// 生成可访问的URL (视情况添加域名/ip)
-	// 假设后端已配置: router.Static("/uploads", "./uploads") => /uploads 可以访问本地 ./uploads
-	fileURL := fmt.Sprintf("/uploads/%s", newFilename)
+	var req struct {
+		Filename    string `json:"filename" binding:"required"`
+		Size        int64  `json:"size"` // 文件字节数，用于提前检查大小限制与配额
+		BountyID    string `json:"bounty_id"`
+		MilestoneID string `json:"milestone_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+	input, ok := bindAttachmentLink(c, req.BountyID, req.MilestoneID)
+	if !ok {
+		return
+	}
+	input.Filename = req.Filename
+	input.Size = req.Size
+
+	attachment, uploadURL, err := ctl.attachmentService.PresignUpload(userID, input)
+	if err != nil {
+		respondAttachmentError(c, err, "生成上传地址失败")
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message":  "upload success",
-		"file_url": fileURL,
+		"method":        http.MethodPut,
+		"upload_url":    uploadURL,
+		"file_url":      services.AttachmentURL(attachment.ID),
+		"attachment_id": attachment.ID,
+		"expires_at":    time.Now().Add(ctl.presignTTL),
 	})
 }
+
+// CompleteUpload 确认直传完成，补全附件的大小与 SHA-256
+// POST /attachments/:attachment_id/complete
+func (ctl *AttachmentController) CompleteUpload(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := bindAttachmentID(c)
+	if !ok {
+		return
+	}
+
+	attachment, err := ctl.attachmentService.CompleteUpload(c.Request.Context(), attachmentID, userID)
+	if err != nil {
+		respondAttachmentError(c, err, "确认上传失败")
+		return
+	}
+	c.JSON(http.StatusOK, attachment)
+}
+
+// DownloadAttachment 校验访问权限后重定向到附件的预签名下载地址
+// GET /attachments/:attachment_id/download
+func (ctl *AttachmentController) DownloadAttachment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := bindAttachmentID(c)
+	if !ok {
+		return
+	}
+
+	url, err := ctl.attachmentService.DownloadURL(attachmentID, userID)
+	if err != nil {
+		respondAttachmentError(c, err, "生成下载地址失败")
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// DeleteAttachment 上传者删除附件
+// DELETE /attachments/:attachment_id
+func (ctl *AttachmentController) DeleteAttachment(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	attachmentID, ok := bindAttachmentID(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.attachmentService.Delete(c.Request.Context(), attachmentID, userID); err != nil {
+		respondAttachmentError(c, err, "删除附件失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "附件已删除"})
+}
+
+// AcceptNDA 接受悬赏令的保密协议，之后可以下载该悬赏令的附件
+// POST /bounties/:bounty_id/nda
+func (ctl *AttachmentController) AcceptNDA(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, err := uuid.Parse(c.Param("bounty_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		return
+	}
+
+	if err := ctl.attachmentService.AcceptNDA(bountyID, userID); err != nil {
+		respondAttachmentError(c, err, "接受保密协议失败")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "已接受保密协议"})
+}
+
+// DownloadFile 将 /uploads 下的公开文件（头像）重定向到存储后端的预签名下载地址，
+// 附件需通过 /attachments/:attachment_id/download 校验权限后下载
+// GET /uploads/*key
+func (ctl *AttachmentController) DownloadFile(c *gin.Context) {
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if storage.CleanKey(key) != nil || !strings.HasPrefix(key, services.AvatarPrefix+"/") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+
+	url, err := ctl.blob.PresignGet(key, ctl.presignTTL)
+	if err != nil {
+		log.Println("生成下载地址失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成下载地址失败"})
+		return
+	}
+	c.Redirect(http.StatusFound, url)
+}
+
+// ServeLocalFile 本地存储的预签名地址，校验签名后读取或写入文件；写入仅限尚未完成的直传且不超过附件大小上限，
+// 使用 S3 存储时不可用
+// GET /storage/*key?expires=...&signature=...
+// PUT /storage/*key?expires=...&signature=...
+func (ctl *AttachmentController) ServeLocalFile(c *gin.Context) {
+	local, ok := ctl.blob.(*storage.Local)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if err := local.Verify(c.Request.Method, key, c.Query("expires"), c.Query("signature")); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	if c.Request.Method == http.MethodPut {
+		// 只接受尚未完成的直传，完成后文件已移动到正式的对象键，原地址随之失效
+		limit, err := ctl.attachmentService.UploadLimit(key)
+		if errors.Is(err, services.ErrUploadClosed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			log.Println("校验上传地址失败:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+			return
+		}
+		if c.Request.ContentLength > limit {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrFileTooLarge.Error()})
+			return
+		}
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		if err := local.Put(c.Request.Context(), key, body, c.Request.ContentLength, c.ContentType()); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": services.ErrFileTooLarge.Error()})
+				return
+			}
+			if errors.Is(err, storage.ErrInvalidKey) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			log.Println("保存文件失败:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "保存文件失败"})
+			return
+		}
+		c.Status(http.StatusOK)
+		return
+	}
+
+	reader, err := local.Open(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) || errors.Is(err, storage.ErrInvalidKey) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "文件不存在"})
+			return
+		}
+		log.Println("读取文件失败:", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取文件失败"})
+		return
+	}
+	defer reader.Close()
+
+	// 对象键的扩展名由上传时嗅探出的类型决定，禁止浏览器再次猜测类型
+	contentType := mime.TypeByExtension(filepath.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, map[string]string{"X-Content-Type-Options": "nosniff"})
+}
+
+// bindAttachmentID 解析路径中的附件 ID
+func bindAttachmentID(c *gin.Context) (uuid.UUID, bool) {
+	attachmentID, err := uuid.Parse(c.Param("attachment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的附件ID"})
+		return uuid.Nil, false
+	}
+	return attachmentID, true
+}
+
+// bindAttachmentLink 解析附件关联的悬赏令与里程碑 ID，均为可选
+func bindAttachmentLink(c *gin.Context, bountyID, milestoneID string) (dtos.AttachmentUpload, bool) {
+	var input dtos.AttachmentUpload
+	if bountyID != "" {
+		id, err := uuid.Parse(bountyID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+			return input, false
+		}
+		input.BountyID = &id
+	}
+	if milestoneID != "" {
+		id, err := uuid.Parse(milestoneID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的里程碑ID"})
+			return input, false
+		}
+		input.MilestoneID = &id
+	}
+	return input, true
+}
+
+// uploadAvatar 保存表单上传的头像，校验失败时写入错误响应
+func uploadAvatar(c *gin.Context, avatarService services.AvatarService, file *multipart.FileHeader) (*dtos.Avatar, bool) {
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败"})
+		return nil, false
+	}
+	defer src.Close()
+
+	avatar, err := avatarService.Upload(c.Request.Context(), src, file.Size)
+	if err != nil {
+		if !respondUploadError(c, err) {
+			log.Println("头像保存失败:", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "头像保存失败"})
+		}
+		return nil, false
+	}
+	return avatar, true
+}
+
+// respondUploadError 处理文件校验失败的错误，不是校验错误时返回 false
+func respondUploadError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrFileTooLarge),
+		errors.Is(err, services.ErrStorageQuotaExceeded):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFileTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidImage):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
+
+func respondAttachmentError(c *gin.Context, err error, fallback string) {
+	if respondUploadError(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrAttachmentNotFound),
+		errors.Is(err, services.ErrAttachmentLinkNotFound),
+		errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrAttachmentForbidden),
+		errors.Is(err, services.ErrNotAttachmentOwner),
+		errors.Is(err, services.ErrAttachmentLinkDenied):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNDANotAccepted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "nda_required"})
+	case errors.Is(err, services.ErrNDANotRequired),
+		errors.Is(err, services.ErrAttachmentNotUploaded),
+		errors.Is(err, storage.ErrInvalidKey):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Println(fallback+":", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
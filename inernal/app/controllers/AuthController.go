@@ -4,28 +4,37 @@ import (
 	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/services"
+	"GeekReward/pkg/utils"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"log"
+	"math"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
+	"time"
 )
 
 // AuthController 结构体
 type AuthController struct {
 	authService         services.AuthService
+	accountService      services.AccountService
 	notificationService services.NotificationService
+	avatarService       services.AvatarService
 }
 
 // NewAuthController 创建新的 AuthController 实例
 func NewAuthController(
 	authService services.AuthService,
+	accountService services.AccountService,
 	notificationService services.NotificationService,
+	avatarService services.AvatarService,
 ) *AuthController {
 	return &AuthController{
 		authService:         authService,
+		accountService:      accountService,
 		notificationService: notificationService,
+		avatarService:       avatarService,
 	}
 }
 
@@ -50,31 +59,20 @@ func (ctl *AuthController) Register(c *gin.Context) {
 		file = nil
 	}
 
-	// 3. 如果上传了头像, 效仿 attachment 做随机命名 & 保存
-	var avatarURL string
+	// 3. 如果上传了头像, 校验后写入存储并生成缩略图
+	var avatar dtos.Avatar
 	if file != nil {
-		uploadDir := "./uploads/avatars"
-		// 确保目录存在
-		if err := os.MkdirAll(uploadDir, 0755); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "无法创建上传目录"})
+		saved, ok := uploadAvatar(c, ctl.avatarService, file)
+		if !ok {
 			return
 		}
-		ext := filepath.Ext(file.Filename)
-		newFilename := uuid.New().String() + ext
-		filePath := filepath.Join(uploadDir, newFilename)
 
-		// 保存文件
-		if err := c.SaveUploadedFile(file, filePath); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "头像保存失败", "details": err.Error()})
-			return
-		}
-
-		// 生成可访问URL, 例如 "/uploads/avatars/xxx.jpg"
-		avatarURL = "/uploads/avatars/" + newFilename
+		// 可访问URL, 例如 "/uploads/avatars/xxx.jpg"
+		avatar = *saved
 	}
 
 	// 4. 将头像URL放入 input.ProfilePicture
-	input.ProfilePicture = avatarURL
+	input.ProfilePicture = avatar.URL
 
 	// 5. 调用 Service 层
 	user, serviceErr := ctl.authService.Register(input)
@@ -86,7 +84,7 @@ func (ctl *AuthController) Register(c *gin.Context) {
 	// 创建通用通知
 	notification := &tables.Notification{
 		UserID:      user.ID,
-		Type:        "Welcome",
+		Type:        tables.NotificationTypeWelcome,
 		Title:       "欢迎加入 GeekReward!",
 		Description: "感谢您注册 GeekReward 平台，祝您使用愉快！",
 		IsRead:      false,
@@ -97,11 +95,17 @@ func (ctl *AuthController) Register(c *gin.Context) {
 		log.Println("发送欢迎通知失败:", err)
 	}
 
+	// 发送邮箱验证邮件，失败时用户可稍后重新发送
+	if err := ctl.accountService.SendEmailVerification(user.ID); err != nil {
+		log.Println("发送邮箱验证邮件失败:", err)
+	}
+
 	// 6. 返回成功信息
 	c.JSON(http.StatusOK, gin.H{
-		"message":         "用户注册成功",
-		"user_id":         user.ID.String(),
-		"profile_picture": user.ProfilePicture,
+		"message":                  "用户注册成功",
+		"user_id":                  user.ID.String(),
+		"profile_picture":          user.ProfilePicture,
+		"profile_picture_variants": avatar.Variants,
 	})
 }
 
@@ -113,16 +117,53 @@ func (ctl *AuthController) Login(c *gin.Context) {
 		return
 	}
 
-	token, user, err := ctl.authService.Login(input)
+	client := dtos.ClientInfo{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+	result, err := ctl.authService.Login(input, client)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的认证"})
+		respondLoginError(c, err)
+		return
+	}
+
+	// 启用了两步验证，需要再调用 /login/2fa 提交验证码
+	if result.TwoFactorRequired {
+		c.JSON(http.StatusOK, gin.H{
+			"two_factor_required": true,
+			"challenge_token":     result.ChallengeToken,
+			"expires_in":          int64(utils.ChallengeTokenTTL.Seconds()),
+		})
 		return
 	}
 
+	ctl.respondLoggedIn(c, result)
+}
+
+// LoginTwoFactor 两步登录的第二步，提交挑战令牌与 TOTP 验证码（或恢复码）
+func (ctl *AuthController) LoginTwoFactor(c *gin.Context) {
+	var input dtos.TwoFactorLoginInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	client := dtos.ClientInfo{UserAgent: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+	result, err := ctl.authService.VerifyTwoFactorLogin(input, client)
+	if err != nil {
+		respondLoginError(c, err)
+		return
+	}
+
+	ctl.respondLoggedIn(c, result)
+}
+
+// respondLoggedIn 登录成功后发送登录通知并返回令牌
+func (ctl *AuthController) respondLoggedIn(c *gin.Context, result *dtos.LoginResult) {
+	user := result.User
+	tokens := result.Tokens
+
 	// 创建通用通知
 	notification := &tables.Notification{
 		UserID:      user.ID,
-		Type:        "Welcome",
+		Type:        tables.NotificationTypeNewLogin,
 		Title:       "欢迎登录 GeekReward!",
 		Description: "您已成功登录 GeekReward！",
 		IsRead:      false,
@@ -135,7 +176,180 @@ func (ctl *AuthController) Login(c *gin.Context) {
 
 	// 返回 token & user 信息给前端
 	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"user":  user,
+		"token":         tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          user,
 	})
 }
+
+// RefreshToken 使用刷新令牌换取新的访问令牌
+func (ctl *AuthController) RefreshToken(c *gin.Context) {
+	var input dtos.RefreshTokenInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	tokens, err := ctl.authService.RefreshToken(input.RefreshToken)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout 注销当前会话，当前的访问令牌与刷新令牌随即失效
+func (ctl *AuthController) Logout(c *gin.Context) {
+	sessionIDInterface, exists := c.Get("session_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	sessionID, ok := sessionIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的会话ID类型"})
+		return
+	}
+
+	if err := ctl.authService.Logout(sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已注销"})
+}
+
+// LogoutAll 注销当前用户在所有设备上的会话
+func (ctl *AuthController) LogoutAll(c *gin.Context) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	// 断言 userID 为 uuid.UUID 类型
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return
+	}
+
+	if err := ctl.authService.LogoutAll(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "注销失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已注销所有设备"})
+}
+
+// VerifyEmail 使用邮件中的令牌完成邮箱验证
+func (ctl *AuthController) VerifyEmail(c *gin.Context) {
+	var input dtos.VerifyEmailInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	if err := ctl.accountService.VerifyEmail(input.Token); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "邮箱验证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "邮箱验证成功"})
+}
+
+// ResendVerificationEmail 重新发送邮箱验证邮件
+func (ctl *AuthController) ResendVerificationEmail(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.accountService.SendEmailVerification(userID); err != nil {
+		if errors.Is(err, services.ErrEmailAlreadyVerified) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "发送验证邮件失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "验证邮件已发送"})
+}
+
+// ForgotPassword 发送重置密码邮件
+func (ctl *AuthController) ForgotPassword(c *gin.Context) {
+	var input dtos.ForgotPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	if err := ctl.accountService.RequestPasswordReset(input.Email); err != nil {
+		log.Println("发送重置密码邮件失败:", err)
+	}
+
+	// 无论邮箱是否注册都返回相同的结果
+	c.JSON(http.StatusOK, gin.H{"message": "如果该邮箱已注册，重置密码的邮件已发送"})
+}
+
+// ResetPassword 使用邮件中的令牌设置新密码
+func (ctl *AuthController) ResetPassword(c *gin.Context) {
+	var input dtos.ResetPasswordInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "存在无效的 input 字段", "details": err.Error()})
+		return
+	}
+
+	if err := ctl.accountService.ResetPassword(input.Token, input.NewPassword); err != nil {
+		if errors.Is(err, services.ErrInvalidUserToken) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "重置密码失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密码已重置，请重新登录"})
+}
+
+// respondLoginError 将登录失败的原因转换为带错误码的响应，便于前端区分提示
+func respondLoginError(c *gin.Context, err error) {
+	var locked *services.LoginLockedError
+	switch {
+	case errors.As(err, &locked):
+		retryAfter := int64(math.Ceil(time.Until(locked.Until).Seconds()))
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+		code, status := "account_locked", http.StatusLocked
+		if locked.Scope == services.LockScopeIP {
+			code, status = "too_many_attempts", http.StatusTooManyRequests
+		}
+		c.JSON(status, gin.H{"error": locked.Error(), "code": code, "retry_after": retryAfter})
+	case errors.Is(err, services.ErrAccountSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "account_suspended"})
+	case errors.Is(err, services.ErrAccountDeleted):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "account_deleted"})
+	case errors.Is(err, services.ErrInvalidTwoFactorCode):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "invalid_two_factor_code"})
+	case errors.Is(err, services.ErrInvalidChallengeToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "invalid_challenge_token"})
+	case errors.Is(err, services.ErrInvalidCredentials):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "invalid_credentials"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登录失败"})
+	}
+}
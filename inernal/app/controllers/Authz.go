@@ -0,0 +1,25 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/authz"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// respondForbidden 将授权错误转换为 403 响应，err 不是授权错误时返回 false
+func respondForbidden(c *gin.Context, err error) bool {
+	var authzErr *authz.Error
+	if !errors.As(err, &authzErr) {
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{
+		"error":       authzErr.Error(),
+		"code":        "forbidden",
+		"action":      authzErr.Action,
+		"resource":    authzErr.Resource(),
+		"bounty_id":   authzErr.BountyID,
+		"required_as": authzErr.Required,
+	})
+	return true
+}
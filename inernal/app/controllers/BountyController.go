@@ -3,15 +3,16 @@ package controllers
 import (
 	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
 	"GeekReward/inernal/app/services"
 	"errors"
-	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"log"
 	"net/http"
-	"strconv"
+	"strings"
+	"time"
 )
 
 // BountyController 结构体
@@ -60,27 +61,18 @@ func (ctl *BountyController) CreateBounty(c *gin.Context) {
 
 	bounty, err := ctl.bountyService.CreateBounty(input, uid)
 	if err != nil {
+		if errors.Is(err, services.ErrEmailNotVerified) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error(), "code": "email_not_verified"})
+			return
+		}
+		if errors.Is(err, repositories.ErrInsufficientBalance) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "钱包余额不足以锁定赏金", "details": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建悬赏令失败"})
 		return
 	}
 
-	// 发送通知给发布者（自己）
-	err = ctl.notificationService.CreateNotification(&tables.Notification{
-		UserID:      bounty.UserID,
-		Type:        "BountyCreated",
-		Title:       "悬赏令已创建",
-		Description: fmt.Sprintf("您已成功创建悬赏令 '%s'。", bounty.Title),
-		Metadata: map[string]interface{}{
-			"bounty_id":    bounty.ID.String(),
-			"bounty_title": bounty.Title,
-		},
-		IsRead: false,
-	})
-	if err != nil {
-		// 记录错误但不影响主流程
-		fmt.Println("发送通知失败:", err)
-	}
-
 	c.JSON(http.StatusOK, gin.H{"message": "悬赏令创建成功", "bounty": bounty})
 }
 
@@ -89,6 +81,7 @@ func (ctl *BountyController) CreateBounty(c *gin.Context) {
 // const (
 //
 //	悬赏令已创建并处于发布状态  BountyStatusCreated             BountyStatus = "Created"
+//	悬赏令已指定接收者  BountyStatusAssigned            BountyStatus = "Assigned"
 //	悬赏令的里程碑被确认  BountyStatusMilestonesConfirmed BountyStatus = "MilestonesConfirmed"
 //	悬赏令里程碑确认  BountyStatusMilestonesVerified  BountyStatus = "MilestonesVerified"
 //	悬赏令被接收中  BountyStatusSettling            BountyStatus = "Settling"
@@ -97,17 +90,11 @@ func (ctl *BountyController) CreateBounty(c *gin.Context) {
 //
 // )
 // 需要实现通过提取查询参数来获取相应状态的悬赏令
-// GetBounties GET /bounties?status=Settled&publisher_id=...&receiver_id=...&limit=10&offset=0
+// GetBounties GET /bounties?status=Settled&publisher_id=...&receiver_id=...&sort=reward&limit=10&cursor=...
 func (ctl *BountyController) GetBounties(c *gin.Context) {
-	// 1. 解析分页
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
-	if err != nil || limit < 1 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页限制（limit）"})
-		return
-	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	if err != nil || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页限制（offset）"})
+	// 1. 解析分页与排序
+	page, ok := bindPageQuery(c)
+	if !ok {
 		return
 	}
 
@@ -148,20 +135,98 @@ func (ctl *BountyController) GetBounties(c *gin.Context) {
 		Status:      status,
 		PublisherID: publisherID,
 		ReceiverID:  receiverID,
-		Limit:       limit,
-		Offset:      offset,
 	}
 
 	// 6. 调用服务层
-	bounties, err := ctl.bountyService.FindBounties(filters)
+	bounties, err := ctl.bountyService.FindBounties(filters, page)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		if respondPageError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取悬赏令失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, bounties)
 }
 
+// SearchBounties 全文检索与分面筛选悬赏令
+// GET /bounties/search?q=...&tags=go,web&skills=...&category=...&difficulty_level=...
+// &min_reward=100&max_reward=500&deadline_after=2025-01-01&deadline_before=2025-06-30&limit=20&offset=0
+func (ctl *BountyController) SearchBounties(c *gin.Context) {
+	var query dtos.BountySearchQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询参数", "details": err.Error()})
+		return
+	}
+
+	// 1. 分页
+	if query.Limit == 0 {
+		query.Limit = 20
+	}
+	if query.Limit < 1 || query.Limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页限制（limit）"})
+		return
+	}
+	if query.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页限制（offset）"})
+		return
+	}
+
+	// 2. 多值参数支持逗号分隔
+	query.Q = strings.TrimSpace(query.Q)
+	query.Tags = splitQueryValues(query.Tags)
+	query.Skills = splitQueryValues(query.Skills)
+	query.Categories = splitQueryValues(query.Categories)
+	query.DifficultyLevel = splitQueryValues(query.DifficultyLevel)
+
+	// 3. 赏金区间
+	if query.MinReward != nil && query.MaxReward != nil && *query.MinReward > *query.MaxReward {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_reward 不能大于 max_reward"})
+		return
+	}
+
+	// 4. 截止日期区间，deadline_before 包含当天
+	if query.DeadlineAfter != "" {
+		from, err := time.Parse("2006-01-02", query.DeadlineAfter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 deadline_after，格式应为 YYYY-MM-DD"})
+			return
+		}
+		query.DeadlineFrom = &from
+	}
+	if query.DeadlineBefore != "" {
+		to, err := time.Parse("2006-01-02", query.DeadlineBefore)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 deadline_before，格式应为 YYYY-MM-DD"})
+			return
+		}
+		to = to.AddDate(0, 0, 1)
+		query.DeadlineTo = &to
+	}
+
+	result, err := ctl.bountyService.SearchBounties(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "搜索悬赏令失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// splitQueryValues 展开逗号分隔的多值查询参数并去掉空值
+func splitQueryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				result = append(result, part)
+			}
+		}
+	}
+	return result
+}
+
 // GetBounty 获取指定悬赏令的处理函数
 func (ctl *BountyController) GetBounty(c *gin.Context) {
 	idParam := c.Param("bounty_id")
@@ -193,7 +258,7 @@ func (ctl *BountyController) UpdateBounty(c *gin.Context) {
 	}
 
 	// 断言 userID 为 uuid.UUID 类型
-	_, ok := userID.(uuid.UUID)
+	uid, ok := userID.(uuid.UUID)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
 		return
@@ -215,8 +280,23 @@ func (ctl *BountyController) UpdateBounty(c *gin.Context) {
 		return
 	}
 
-	bounty, err := ctl.bountyService.UpdateBounty(id, input)
+	bounty, err := ctl.bountyService.UpdateBounty(id, uid, input)
 	if err != nil {
+		if respondForbidden(c, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "悬赏令未找到"})
+			return
+		}
+		if errors.Is(err, repositories.ErrInsufficientBalance) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "钱包余额不足以补缴赏金", "details": err.Error()})
+			return
+		}
+		if errors.Is(err, services.ErrMilestoneRewardsOutdated) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		log.Printf("更新悬赏令时发生错误: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "更新悬赏令失败"})
 		return
@@ -227,6 +307,11 @@ func (ctl *BountyController) UpdateBounty(c *gin.Context) {
 
 // DeleteBounty 删除悬赏令处理函数
 func (ctl *BountyController) DeleteBounty(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	idParam := c.Param("bounty_id")
 	id, err := uuid.Parse(idParam)
 	if err != nil {
@@ -234,8 +319,15 @@ func (ctl *BountyController) DeleteBounty(c *gin.Context) {
 		return
 	}
 
-	err = ctl.bountyService.DeleteBounty(id)
+	err = ctl.bountyService.DeleteBounty(id, userID)
 	if err != nil {
+		if respondForbidden(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrBountyDeleteLocked) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "悬赏令未找到"})
 		} else {
@@ -396,8 +488,16 @@ func (ctl *BountyController) GetBountyComments(c *gin.Context) {
 		return
 	}
 
-	comments, err := ctl.bountyService.GetCommentsByBountyID(bountyID)
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	comments, err := ctl.bountyService.GetCommentsByBountyID(bountyID, page)
 	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取评论失败"})
 		return
 	}
@@ -407,6 +507,28 @@ func (ctl *BountyController) GetBountyComments(c *gin.Context) {
 	c.JSON(http.StatusOK, comments)
 }
 
+// GetBountyTimeline 获取悬赏令的时间线（状态变更、里程碑、申请与评论）
+func (ctl *BountyController) GetBountyTimeline(c *gin.Context) {
+	bountyIDStr := c.Param("bounty_id")
+	bountyID, err := uuid.Parse(bountyIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		return
+	}
+
+	events, err := ctl.bountyService.GetBountyTimeline(bountyID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "未找到悬赏令"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "获取悬赏令时间线失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, events)
+}
+
 // GetBountiesByUser 获取指定用户发布的悬赏令
 func (ctl *BountyController) GetBountiesByUser(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -422,8 +544,16 @@ func (ctl *BountyController) GetBountiesByUser(c *gin.Context) {
 		return
 	}
 
-	bounties, err := ctl.bountyService.GetBountiesByUserID(uid)
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	bounties, err := ctl.bountyService.GetBountiesByUserID(uid, page)
 	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取悬赏令失败"})
 		return
 	}
@@ -446,8 +576,16 @@ func (ctl *BountyController) GetReceivedBounties(c *gin.Context) {
 		return
 	}
 
-	bounties, err := ctl.bountyService.GetReceivedBounties(uid)
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	bounties, err := ctl.bountyService.GetReceivedBounties(uid, page)
 	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取用户已接受悬赏令失败"})
 		return
 	}
@@ -534,18 +672,21 @@ func (ctl *BountyController) CancelSettlementByPublisher(c *gin.Context) {
 		return
 	}
 
-	userIDStr, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
 		return
 	}
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户ID"})
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
 		return
 	}
 
 	if err := ctl.bountyService.CancelSettlementByPublisher(bountyID, userID); err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -563,25 +704,28 @@ func (ctl *BountyController) CancelSettlementByReceiver(c *gin.Context) {
 		return
 	}
 
-	userIDStr, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
 		return
 	}
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户ID"})
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
 		return
 	}
 
 	if err := ctl.bountyService.CancelSettlementByReceiver(bountyID, userID); err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "接收方取消清算成功 (Cancelled with penalty)"})
 }
 
-// SettleBountyAccounts 结算悬赏令账户
+// SettleBountyAccounts 发布者确认结算，释放托管赏金
 func (ctl *BountyController) SettleBountyAccounts(c *gin.Context) {
 	// 获取悬赏令ID从URL参数
 	bountyIDStr := c.Param("bounty_id")
@@ -591,9 +735,23 @@ func (ctl *BountyController) SettleBountyAccounts(c *gin.Context) {
 		return
 	}
 
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return
+	}
+
 	// 调用服务层进行结算
-	err = ctl.bountyService.SettleBountyAccounts(bountyID)
+	err = ctl.bountyService.SettleBountyAccounts(bountyID, userID)
 	if err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -611,20 +769,23 @@ func (ctl *BountyController) ConfirmMilestones(c *gin.Context) {
 	}
 
 	// 从上下文中获取当前用户的 user_id
-	userIDStr, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
 		return
 	}
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户ID"})
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
 		return
 	}
 
 	// 调用服务层方法确认里程碑
 	err = ctl.bountyService.ConfirmMilestones(bountyID, userID)
 	if err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -640,19 +801,22 @@ func (ctl *BountyController) VerifyMilestones(c *gin.Context) {
 		return
 	}
 
-	userIDStr, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
 		return
 	}
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户ID"})
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
 		return
 	}
 
 	err = ctl.bountyService.VerifyMilestones(bountyID, userID)
 	if err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -669,22 +833,118 @@ func (ctl *BountyController) ApplySettlement(c *gin.Context) {
 		return
 	}
 
-	userIDStr, exists := c.Get("user_id")
+	userIDInterface, exists := c.Get("user_id")
 	if !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
 		return
 	}
-	userID, err := uuid.Parse(userIDStr.(string))
-	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "无效的用户ID"})
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
 		return
 	}
 
 	err = ctl.bountyService.ApplySettlement(bountyID, userID)
 	if err != nil {
+		if respondForbidden(c, err) || respondTransitionError(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "悬赏令清算申请成功"})
 }
+
+// GetSettlement 查看结算明细；结算前返回按当前分配方式计算的预览，接收者可在确认结算前核对
+// GET /bounties/:bounty_id/settlement
+func (ctl *BountyController) GetSettlement(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, err := uuid.Parse(c.Param("bounty_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		return
+	}
+
+	view, err := ctl.bountyService.GetSettlement(bountyID, userID)
+	if err != nil {
+		respondSettlementError(c, err, "获取结算明细失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// UpdateSplitPlan 接收者在开工前设置赏金的分配方式
+// PUT /bounties/:bounty_id/split  {"rule": "percentage", "shares": [{"user_id": "...", "percentage": 60}, ...]}
+func (ctl *BountyController) UpdateSplitPlan(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, err := uuid.Parse(c.Param("bounty_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		return
+	}
+
+	var input dtos.UpdateSplitPlanInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	view, err := ctl.bountyService.UpdateSplitPlan(bountyID, userID, input)
+	if err != nil {
+		respondSettlementError(c, err, "设置赏金分配方式失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, view)
+}
+
+// respondSettlementError 将赏金分配相关的业务错误转换为对应的响应
+func respondSettlementError(c *gin.Context, err error, fallback string) {
+	if respondForbidden(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrInvalidSplit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrInvalidSplit.Error(), "details": err.Error()})
+	case errors.Is(err, services.ErrNotSettlementParticipant),
+		errors.Is(err, services.ErrNotTeamLeader):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrSplitLocked):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "悬赏令未找到"})
+	case errors.Is(err, services.ErrTeamNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		log.Printf("%s: %v", fallback, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// respondTransitionError 若错误为非法的悬赏令状态转换，返回 409 及转换详情并返回 true；
+// 状态已被并发的操作修改时同样返回 409
+func respondTransitionError(c *gin.Context, err error) bool {
+	if errors.Is(err, services.ErrBountyStatusConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return true
+	}
+	var transitionErr *services.TransitionError
+	if !errors.As(err, &transitionErr) {
+		return false
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"error": transitionErr.Error(),
+		"from":  transitionErr.From,
+		"to":    transitionErr.To,
+		"actor": transitionErr.Actor,
+	})
+	return true
+}
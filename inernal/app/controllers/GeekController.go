@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/services"
 	"errors"
 	"github.com/gin-gonic/gin"
@@ -13,16 +14,19 @@ import (
 // GeekController 结构体
 type GeekController struct {
 	geekService         services.GeekService
+	invitationService   services.InvitationService
 	notificationService services.NotificationService
 }
 
 // NewGeekController 创建新的 GeekController 实例
 func NewGeekController(
 	geekService services.GeekService,
+	invitationService services.InvitationService,
 	notificationService services.NotificationService,
 ) *GeekController {
 	return &GeekController{
 		geekService:         geekService,
+		invitationService:   invitationService,
 		notificationService: notificationService,
 	}
 }
@@ -122,12 +126,21 @@ func (ctl *GeekController) SendInvitation(c *gin.Context) {
 		return
 	}
 
+	// 可选的 team_id、bounty_id 与附言
+	var input dtos.SendInvitationInput
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+			return
+		}
+	}
+
 	// 创建发送邀请的服务调用
-	err = ctl.geekService.SendInvitation(geekID, userID)
+	invitation, err := ctl.invitationService.SendInvitation(userID, geekID, input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvitationError(c, err, "发送邀请失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "邀请发送成功"})
+	c.JSON(http.StatusOK, gin.H{"message": "邀请发送成功", "invitation": invitation})
 }
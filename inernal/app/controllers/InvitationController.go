@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/services"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
@@ -46,7 +49,7 @@ func (ctl *InvitationController) AcceptInvitation(c *gin.Context) {
 	}
 
 	if err := ctl.invitationService.AcceptInvitation(invitationID, userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvitationError(c, err, "接受邀请失败")
 		return
 	}
 
@@ -77,9 +80,97 @@ func (ctl *InvitationController) RejectInvitation(c *gin.Context) {
 	}
 
 	if err := ctl.invitationService.RejectInvitation(invitationID, userID); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondInvitationError(c, err, "拒绝邀请失败")
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "邀请被拒绝"})
 }
+
+// CancelInvitation 邀请者撤回尚未处理的组队邀请
+// PUT /invitation/:invitation_id/cancel
+func (ctl *InvitationController) CancelInvitation(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	invitationID, err := uuid.Parse(c.Param("invitation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的邀请ID"})
+		return
+	}
+
+	if err := ctl.invitationService.CancelInvitation(invitationID, userID); err != nil {
+		respondInvitationError(c, err, "撤回邀请失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "邀请已撤回"})
+}
+
+// GetSentInvitations 获取当前用户发出的邀请
+// GET /invitations/sent?status=Pending&limit=20&cursor=...
+func (ctl *InvitationController) GetSentInvitations(c *gin.Context) {
+	ctl.listInvitations(c, ctl.invitationService.GetSentInvitations)
+}
+
+// GetReceivedInvitations 获取当前用户收到的邀请
+// GET /invitations/received?status=Pending&limit=20&cursor=...
+func (ctl *InvitationController) GetReceivedInvitations(c *gin.Context) {
+	ctl.listInvitations(c, ctl.invitationService.GetReceivedInvitations)
+}
+
+func (ctl *InvitationController) listInvitations(
+	c *gin.Context,
+	list func(uuid.UUID, dtos.InvitationFilter, dtos.PageQuery) (*dtos.Page[tables.Invitation], error),
+) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	var filter dtos.InvitationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询参数", "details": err.Error()})
+		return
+	}
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	invitations, err := list(userID, filter, page)
+	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
+		respondInvitationError(c, err, "获取邀请失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// respondInvitationError 将组队邀请的业务错误转换为对应的响应
+func respondInvitationError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrInvitationNotFound),
+		errors.Is(err, services.ErrInviteeNotFound),
+		errors.Is(err, services.ErrInvitationBountyMissing),
+		errors.Is(err, services.ErrTeamNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotInvitee),
+		errors.Is(err, services.ErrNotInviter),
+		errors.Is(err, services.ErrNotTeamLeader):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvitationNotPending),
+		errors.Is(err, services.ErrInvitationExists),
+		errors.Is(err, services.ErrAlreadyTeamMember),
+		errors.Is(err, services.ErrTeamHasActiveBounty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrCannotInviteSelf),
+		errors.Is(err, services.ErrInvalidInvitationStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -2,7 +2,10 @@ package controllers
 
 import (
 	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
 	"GeekReward/inernal/app/services"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
@@ -26,6 +29,11 @@ func NewMilestoneController(
 
 // CreateMilestone 创建新的里程碑
 func (ctl *MilestoneController) CreateMilestone(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	bountyIDStr := c.Param("bounty_id")
 	bountyID, err := uuid.Parse(bountyIDStr)
 	if err != nil {
@@ -39,8 +47,11 @@ func (ctl *MilestoneController) CreateMilestone(c *gin.Context) {
 		return
 	}
 
-	milestone, err := ctl.milestoneService.CreateMilestone(bountyID, input)
+	milestone, err := ctl.milestoneService.CreateMilestone(bountyID, userID, input)
 	if err != nil {
+		if respondForbidden(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -50,6 +61,11 @@ func (ctl *MilestoneController) CreateMilestone(c *gin.Context) {
 
 // UpdateMilestone 更新里程碑
 func (ctl *MilestoneController) UpdateMilestone(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	milestoneIDStr := c.Param("milestone_id")
 	milestoneID, err := uuid.Parse(milestoneIDStr)
 	if err != nil {
@@ -63,8 +79,11 @@ func (ctl *MilestoneController) UpdateMilestone(c *gin.Context) {
 		return
 	}
 
-	err = ctl.milestoneService.UpdateMilestone(milestoneID, input)
+	err = ctl.milestoneService.UpdateMilestone(milestoneID, userID, input)
 	if err != nil {
+		if respondForbidden(c, err) {
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -74,6 +93,11 @@ func (ctl *MilestoneController) UpdateMilestone(c *gin.Context) {
 
 // DeleteMilestone 删除里程碑
 func (ctl *MilestoneController) DeleteMilestone(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	milestoneIDStr := c.Param("milestone_id")
 	milestoneID, err := uuid.Parse(milestoneIDStr)
 	if err != nil {
@@ -81,8 +105,15 @@ func (ctl *MilestoneController) DeleteMilestone(c *gin.Context) {
 		return
 	}
 
-	err = ctl.milestoneService.DeleteMilestone(milestoneID)
+	err = ctl.milestoneService.DeleteMilestone(milestoneID, userID)
 	if err != nil {
+		if respondForbidden(c, err) {
+			return
+		}
+		if errors.Is(err, services.ErrMilestoneDeleteLocked) || errors.Is(err, services.ErrMilestoneHasReward) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -111,44 +142,162 @@ func (ctl *MilestoneController) GetMilestonesByBountyID(c *gin.Context) {
 	c.JSON(http.StatusOK, milestones)
 }
 
-// UpdateMilestoneByReceiver 悬赏零接收者更新里程碑
-func (ctl *MilestoneController) UpdateMilestoneByReceiver(c *gin.Context) {
-	bountyIDStr := c.Param("bounty_id")
-	bountyID, err := uuid.Parse(bountyIDStr)
+// SubmitMilestone 接收者提交里程碑成果
+func (ctl *MilestoneController) SubmitMilestone(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, milestoneID, ok := bindMilestonePath(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.SubmitMilestoneInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型"})
+		return
+	}
+
+	submission, err := ctl.milestoneService.SubmitMilestone(bountyID, milestoneID, userID, input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		respondSubmissionError(c, err, "提交里程碑成果失败")
 		return
 	}
 
-	milestoneIDStr := c.Param("milestone_id")
-	milestoneID, err := uuid.Parse(milestoneIDStr)
+	c.JSON(http.StatusCreated, submission)
+}
+
+// GetSubmissions 获取里程碑的全部成果提交
+func (ctl *MilestoneController) GetSubmissions(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, milestoneID, ok := bindMilestonePath(c)
+	if !ok {
+		return
+	}
+
+	submissions, err := ctl.milestoneService.GetSubmissions(bountyID, milestoneID, userID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的里程碑ID"})
+		respondSubmissionError(c, err, "获取里程碑成果失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, submissions)
+}
+
+// AcceptSubmission 发布者验收通过里程碑成果
+func (ctl *MilestoneController) AcceptSubmission(c *gin.Context) {
+	ctl.reviewSubmission(c, ctl.milestoneService.AcceptSubmission, "验收里程碑成果失败")
+}
+
+// RejectSubmission 发布者驳回里程碑成果
+func (ctl *MilestoneController) RejectSubmission(c *gin.Context) {
+	ctl.reviewSubmission(c, ctl.milestoneService.RejectSubmission, "驳回里程碑成果失败")
+}
+
+type reviewFunc func(bountyID, milestoneID, submissionID, userID uuid.UUID, input dtos.ReviewSubmissionInput) (*tables.MilestoneSubmission, error)
+
+func (ctl *MilestoneController) reviewSubmission(c *gin.Context, review reviewFunc, fallback string) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	bountyID, milestoneID, ok := bindMilestonePath(c)
+	if !ok {
 		return
 	}
+	submissionID, err := uuid.Parse(c.Param("submission_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的成果提交ID"})
+		return
+	}
+
+	// 验收时请求体可以为空
+	var input dtos.ReviewSubmissionInput
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型"})
+			return
+		}
+	}
 
-	userIDInterface, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+	submission, err := review(bountyID, milestoneID, submissionID, userID, input)
+	if err != nil {
+		respondSubmissionError(c, err, fallback)
 		return
 	}
-	userID, ok := userIDInterface.(uuid.UUID)
+
+	c.JSON(http.StatusOK, submission)
+}
+
+// UpdateMilestoneRewards 发布者为各里程碑分配阶段赏金
+func (ctl *MilestoneController) UpdateMilestoneRewards(c *gin.Context) {
+	userID, ok := currentUserID(c)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return
+	}
+	bountyID, err := uuid.Parse(c.Param("bounty_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
 		return
 	}
 
-	var input dtos.MilestoneUpdateDTO
+	var input dtos.UpdateMilestoneRewardsInput
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型"})
 		return
 	}
 
-	err = ctl.milestoneService.UpdateMilestoneByReceiver(bountyID, milestoneID, userID, input)
+	milestones, err := ctl.milestoneService.UpdateMilestoneRewards(bountyID, userID, input)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		respondSubmissionError(c, err, "分配里程碑赏金失败")
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "里程碑更新成功"})
+	c.JSON(http.StatusOK, milestones)
+}
+
+// bindMilestonePath 解析路径中的悬赏令ID与里程碑ID，失败时写入 400 响应
+func bindMilestonePath(c *gin.Context) (uuid.UUID, uuid.UUID, bool) {
+	bountyID, err := uuid.Parse(c.Param("bounty_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的悬赏令ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	milestoneID, err := uuid.Parse(c.Param("milestone_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的里程碑ID"})
+		return uuid.Nil, uuid.Nil, false
+	}
+	return bountyID, milestoneID, true
+}
+
+// respondSubmissionError 将里程碑成果与阶段赏金相关的业务错误转换为对应的响应
+func respondSubmissionError(c *gin.Context, err error, fallback string) {
+	if respondForbidden(c, err) {
+		return
+	}
+	switch {
+	case errors.Is(err, services.ErrMilestoneNotFound),
+		errors.Is(err, services.ErrSubmissionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotMilestoneParticipant):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrMilestoneNotInProgress),
+		errors.Is(err, services.ErrMilestoneAlreadyAccepted),
+		errors.Is(err, services.ErrSubmissionPending),
+		errors.Is(err, services.ErrSubmissionNotPending),
+		errors.Is(err, services.ErrMilestoneRewardsLocked),
+		errors.Is(err, services.ErrInvalidSplit),
+		errors.Is(err, repositories.ErrInsufficientBalance):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrFeedbackRequired),
+		errors.Is(err, services.ErrInvalidMilestoneRewards):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
 }
@@ -1,11 +1,14 @@
 package controllers
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/services"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"net/http"
+	"time"
 )
 
 // NotificationController 结构体
@@ -18,18 +21,18 @@ func NewNotificationController(notificationService services.NotificationService)
 	return &NotificationController{notificationService: notificationService}
 }
 
-// CreateNotification (可选) - 公开接口，用于测试或管理员操作，
+// CreateNotification 管理员手动创建通知，用于测试或运营操作，
 // 实际上生产环境中，通知多由业务逻辑内部调用
 func (ctl *NotificationController) CreateNotification(c *gin.Context) {
 	var input struct {
-		UserID      uuid.UUID      `json:"user_id" binding:"required"`
-		ActorID     *uuid.UUID     `json:"actor_id"`
-		Type        string         `json:"type" binding:"required"`
-		Title       string         `json:"title" binding:"required"`
-		Description string         `json:"description" binding:"required"`
-		RelatedID   *uuid.UUID     `json:"related_id"`
-		RelatedType string         `json:"related_type"`
-		Metadata    map[string]any `json:"metadata"`
+		UserID      uuid.UUID               `json:"user_id" binding:"required"`
+		ActorID     *uuid.UUID              `json:"actor_id"`
+		Type        tables.NotificationType `json:"type" binding:"required"`
+		Title       string                  `json:"title" binding:"required"`
+		Description string                  `json:"description" binding:"required"`
+		RelatedID   *uuid.UUID              `json:"related_id"`
+		RelatedType string                  `json:"related_type"`
+		Metadata    map[string]any          `json:"metadata"`
 	}
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
@@ -55,6 +58,37 @@ func (ctl *NotificationController) CreateNotification(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "通知创建成功"})
 }
 
+// bindNotificationFilter 解析通知列表的筛选参数，参数无效时写入 400 响应并返回 false
+// GET ...?type=BountyLiked,BountyCommented&related_type=Bounty&is_read=false&created_after=2025-01-01&created_before=2025-01-31
+func bindNotificationFilter(c *gin.Context) (dtos.NotificationFilter, bool) {
+	var filter dtos.NotificationFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的查询参数", "details": err.Error()})
+		return filter, false
+	}
+	filter.Types = splitQueryValues(filter.Types)
+
+	// created_before 包含当天
+	if filter.CreatedAfter != "" {
+		from, err := time.Parse("2006-01-02", filter.CreatedAfter)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 created_after，格式应为 YYYY-MM-DD"})
+			return filter, false
+		}
+		filter.CreatedFrom = &from
+	}
+	if filter.CreatedBefore != "" {
+		to, err := time.Parse("2006-01-02", filter.CreatedBefore)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 created_before，格式应为 YYYY-MM-DD"})
+			return filter, false
+		}
+		to = to.AddDate(0, 0, 1)
+		filter.CreatedTo = &to
+	}
+	return filter, true
+}
+
 // GetUserNotifications 获取用户的所有通知
 func (ctl *NotificationController) GetUserNotifications(c *gin.Context) {
 	// 获取当前登录用户的ID从上下文
@@ -71,8 +105,20 @@ func (ctl *NotificationController) GetUserNotifications(c *gin.Context) {
 		return
 	}
 
-	notifications, err := ctl.notificationService.GetUserNotifications(userID)
+	filter, ok := bindNotificationFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	notifications, err := ctl.notificationService.GetUserNotifications(userID, filter, page)
 	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通知失败"})
 		return
 	}
@@ -82,6 +128,11 @@ func (ctl *NotificationController) GetUserNotifications(c *gin.Context) {
 
 // MarkNotificationAsRead 标记通知为已读
 func (ctl *NotificationController) MarkNotificationAsRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	notificationIDStr := c.Param("id")
 	notificationID, err := uuid.Parse(notificationIDStr)
 	if err != nil {
@@ -89,7 +140,11 @@ func (ctl *NotificationController) MarkNotificationAsRead(c *gin.Context) {
 		return
 	}
 
-	if err := ctl.notificationService.MarkNotificationAsRead(notificationID); err != nil {
+	if err := ctl.notificationService.MarkNotificationAsRead(userID, notificationID); err != nil {
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "将通知标记为已读失败"})
 		return
 	}
@@ -99,6 +154,11 @@ func (ctl *NotificationController) MarkNotificationAsRead(c *gin.Context) {
 
 // DeleteNotification 删除通知
 func (ctl *NotificationController) DeleteNotification(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
 	notificationIDStr := c.Param("id")
 	notificationID, err := uuid.Parse(notificationIDStr)
 	if err != nil {
@@ -106,10 +166,126 @@ func (ctl *NotificationController) DeleteNotification(c *gin.Context) {
 		return
 	}
 
-	if err := ctl.notificationService.DeleteNotification(notificationID); err != nil {
+	if err := ctl.notificationService.DeleteNotification(userID, notificationID); err != nil {
+		if errors.Is(err, services.ErrNotificationNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除通知失败"})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "通知删除成功"})
 }
+
+// GetGroupedNotifications 获取折叠重复事件后的通知列表
+// GET /notifications/grouped，筛选与分页参数同 GET /notifications
+func (ctl *NotificationController) GetGroupedNotifications(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	filter, ok := bindNotificationFilter(c)
+	if !ok {
+		return
+	}
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	groups, err := ctl.notificationService.GetGroupedNotifications(userID, filter, page)
+	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通知失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, groups)
+}
+
+// GetUnreadCount 获取未读通知总数及各类型的未读数
+// GET /notifications/unread-count
+func (ctl *NotificationController) GetUnreadCount(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	count, err := ctl.notificationService.GetUnreadCountByType(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取未读通知数失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, count)
+}
+
+// MarkAllAsRead 将所有未读通知标记为已读，可用与列表相同的筛选参数限定范围
+// PUT /notifications/read-all?type=BountyLiked
+func (ctl *NotificationController) MarkAllAsRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	filter, ok := bindNotificationFilter(c)
+	if !ok {
+		return
+	}
+
+	affected, err := ctl.notificationService.MarkAllAsRead(userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "将通知标记为已读失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.NotificationBulkResult{Affected: affected})
+}
+
+// MarkNotificationsAsRead 批量将通知标记为已读
+// PUT /notifications/read  {"ids": ["...", "..."]}
+func (ctl *NotificationController) MarkNotificationsAsRead(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.NotificationIDsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	affected, err := ctl.notificationService.MarkNotificationsAsRead(userID, input.IDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "将通知标记为已读失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.NotificationBulkResult{Affected: affected})
+}
+
+// DeleteNotifications 批量删除通知
+// DELETE /notifications  {"ids": ["...", "..."]}
+func (ctl *NotificationController) DeleteNotifications(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.NotificationIDsInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	affected, err := ctl.notificationService.DeleteNotifications(userID, input.IDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "删除通知失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dtos.NotificationBulkResult{Affected: affected})
+}
@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/repositories"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// bindPageQuery 解析列表接口的 cursor、limit、sort 参数，参数无效时写入 400 响应并返回 false
+// GET ...?cursor=<上一页的 next_cursor>&limit=20&sort=newest
+func bindPageQuery(c *gin.Context) (dtos.PageQuery, bool) {
+	var page dtos.PageQuery
+	if err := c.ShouldBindQuery(&page); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页参数", "details": err.Error()})
+		return page, false
+	}
+	if page.Limit == 0 {
+		page.Limit = 20
+	}
+	if page.Limit < 1 || page.Limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的分页限制（limit），取值范围为 1-100"})
+		return page, false
+	}
+	return page, true
+}
+
+// respondPageError 将无效的游标或排序方式转换为 400 响应，其他错误返回 false 交由调用方处理
+func respondPageError(c *gin.Context, err error) bool {
+	if errors.Is(err, repositories.ErrInvalidCursor) || errors.Is(err, repositories.ErrInvalidSort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return true
+	}
+	return false
+}
@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/services"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// realtimeHeartbeat 心跳间隔，同时用于检查会话是否已被注销
+	realtimeHeartbeat = 30 * time.Second
+	// wsWriteTimeout WebSocket 单次写入的超时时间
+	wsWriteTimeout = 10 * time.Second
+)
+
+// RealtimeController 通过 SSE 与 WebSocket 推送实时事件
+type RealtimeController struct {
+	hub                 services.NotificationHub
+	notificationService services.NotificationService
+	authService         services.AuthService
+	upgrader            websocket.Upgrader
+}
+
+// NewRealtimeController 创建新的 RealtimeController 实例，allowedOrigins 为允许建立 WebSocket 连接的来源
+func NewRealtimeController(
+	hub services.NotificationHub,
+	notificationService services.NotificationService,
+	authService services.AuthService,
+	allowedOrigins []string,
+) *RealtimeController {
+	return &RealtimeController{
+		hub:                 hub,
+		notificationService: notificationService,
+		authService:         authService,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     originChecker(allowedOrigins),
+		},
+	}
+}
+
+// originChecker 浏览器发起的连接必须来自允许的来源，防止其他站点借用户的令牌建立连接；
+// 没有 Origin 请求头的非浏览器客户端不受限制
+func originChecker(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		return slices.ContainsFunc(allowedOrigins, func(allowed string) bool {
+			return strings.EqualFold(strings.TrimRight(allowed, "/"), origin)
+		})
+	}
+}
+
+// Stream 以 Server-Sent Events 推送实时事件
+// GET /notifications/stream
+func (ctl *RealtimeController) Stream(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	sessionID, _ := c.Get("session_id")
+
+	events, unsubscribe := ctl.hub.Subscribe(userID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 关闭 Nginx 缓冲
+
+	if event, err := ctl.unreadCountEvent(userID); err == nil {
+		c.SSEvent(event.Type, event)
+		c.Writer.Flush()
+	}
+
+	heartbeat := time.NewTicker(realtimeHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			c.SSEvent(event.Type, event)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if !ctl.sessionActive(sessionID, userID) {
+				return
+			}
+			// SSE 注释行，保持连接不被代理断开
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// WebSocket 以 WebSocket 推送实时事件，客户端发送的消息会被忽略
+// GET /ws
+func (ctl *RealtimeController) WebSocket(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	sessionID, _ := c.Get("session_id")
+
+	conn, err := ctl.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 失败时已写入错误响应
+		log.Println("WebSocket 握手失败:", err)
+		return
+	}
+	defer conn.Close()
+
+	events, unsubscribe := ctl.hub.Subscribe(userID)
+	defer unsubscribe()
+
+	// 读循环：处理 pong 与关闭帧，连接断开时通知写循环退出
+	closed := make(chan struct{})
+	conn.SetReadLimit(512)
+	_ = conn.SetReadDeadline(time.Now().Add(2 * realtimeHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * realtimeHeartbeat))
+	})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if event, err := ctl.unreadCountEvent(userID); err == nil {
+		if err := writeWSEvent(conn, event); err != nil {
+			return
+		}
+	}
+
+	heartbeat := time.NewTicker(realtimeHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if err := writeWSEvent(conn, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if !ctl.sessionActive(sessionID, userID) {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked"),
+					time.Now().Add(wsWriteTimeout))
+				return
+			}
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// unreadCountEvent 连接建立时推送当前的未读数
+func (ctl *RealtimeController) unreadCountEvent(userID uuid.UUID) (dtos.RealtimeEvent, error) {
+	count, err := ctl.notificationService.GetUnreadCount(userID)
+	if err != nil {
+		return dtos.RealtimeEvent{}, err
+	}
+	data, err := json.Marshal(map[string]int64{"count": count})
+	if err != nil {
+		return dtos.RealtimeEvent{}, err
+	}
+	return dtos.RealtimeEvent{Type: dtos.RealtimeUnreadCount, Data: data, CreatedAt: time.Now()}, nil
+}
+
+// sessionActive 长连接期间定期确认会话未被注销或吊销
+func (ctl *RealtimeController) sessionActive(sessionID any, userID uuid.UUID) bool {
+	sid, ok := sessionID.(uuid.UUID)
+	if !ok {
+		return false
+	}
+	return ctl.authService.IsSessionActive(sid, userID)
+}
+
+func writeWSEvent(conn *websocket.Conn, event dtos.RealtimeEvent) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return conn.WriteJSON(event)
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// TeamController 团队的创建、查看与成员管理
+type TeamController struct {
+	teamService services.TeamService
+}
+
+// NewTeamController 创建新的 TeamController 实例
+func NewTeamController(teamService services.TeamService) *TeamController {
+	return &TeamController{teamService: teamService}
+}
+
+// CreateTeam 创建团队，当前用户成为队长
+// POST /teams  {"name": "...", "description": "..."}
+func (ctl *TeamController) CreateTeam(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.CreateTeamInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	team, err := ctl.teamService.CreateTeam(userID, input)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "创建团队失败"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, team)
+}
+
+// GetTeams 获取当前用户所在的所有团队
+// GET /teams
+func (ctl *TeamController) GetTeams(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	teams, err := ctl.teamService.GetTeams(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取团队失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, teams)
+}
+
+// GetTeam 获取团队信息及其成员
+// GET /teams/:team_id
+func (ctl *TeamController) GetTeam(c *gin.Context) {
+	teamID, ok := parseTeamIDParam(c)
+	if !ok {
+		return
+	}
+
+	team, err := ctl.teamService.GetTeam(teamID)
+	if err != nil {
+		respondTeamError(c, err, "获取团队失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// UpdateTeam 队长修改团队名称或简介
+// PUT /teams/:team_id
+func (ctl *TeamController) UpdateTeam(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	teamID, ok := parseTeamIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.UpdateTeamInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	team, err := ctl.teamService.UpdateTeam(teamID, userID, input)
+	if err != nil {
+		respondTeamError(c, err, "修改团队失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeam 队长解散团队
+// DELETE /teams/:team_id
+func (ctl *TeamController) DeleteTeam(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	teamID, ok := parseTeamIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.teamService.DeleteTeam(teamID, userID); err != nil {
+		respondTeamError(c, err, "解散团队失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "团队已解散"})
+}
+
+// GetMembers 获取团队成员列表
+// GET /teams/:team_id/members
+func (ctl *TeamController) GetMembers(c *gin.Context) {
+	teamID, ok := parseTeamIDParam(c)
+	if !ok {
+		return
+	}
+
+	members, err := ctl.teamService.GetMembers(teamID)
+	if err != nil {
+		respondTeamError(c, err, "获取团队成员失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// LeaveTeam 当前用户退出团队
+// POST /teams/:team_id/leave
+func (ctl *TeamController) LeaveTeam(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	teamID, ok := parseTeamIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.teamService.LeaveTeam(teamID, userID); err != nil {
+		respondTeamError(c, err, "退出团队失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已退出团队"})
+}
+
+// KickMember 队长将成员移出团队
+// DELETE /teams/:team_id/members/:user_id
+func (ctl *TeamController) KickMember(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	teamID, ok := parseTeamIDParam(c)
+	if !ok {
+		return
+	}
+	memberID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的用户ID"})
+		return
+	}
+
+	if err := ctl.teamService.KickMember(teamID, userID, memberID); err != nil {
+		respondTeamError(c, err, "移除成员失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "成员已移出团队"})
+}
+
+func parseTeamIDParam(c *gin.Context) (uuid.UUID, bool) {
+	teamID, err := uuid.Parse(c.Param("team_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的团队ID"})
+		return uuid.Nil, false
+	}
+	return teamID, true
+}
+
+// respondTeamError 将团队的业务错误转换为对应的响应
+func respondTeamError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrTeamNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotTeamLeader):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrNotTeamMember),
+		errors.Is(err, services.ErrTeamLeaderCannotLeave),
+		errors.Is(err, services.ErrCannotKickSelf):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTeamHasActiveBounty):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -0,0 +1,162 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// TwoFactorController 处理两步验证的绑定、关闭与恢复码管理
+type TwoFactorController struct {
+	twoFactorService services.TwoFactorService
+}
+
+// NewTwoFactorController 创建新的 TwoFactorController 实例
+func NewTwoFactorController(twoFactorService services.TwoFactorService) *TwoFactorController {
+	return &TwoFactorController{twoFactorService: twoFactorService}
+}
+
+// GetStatus 获取当前用户的两步验证状态
+func (ctl *TwoFactorController) GetStatus(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	status, err := ctl.twoFactorService.GetStatus(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取两步验证状态失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Setup 生成 TOTP 密钥，返回密钥与 otpauth URI 供验证器应用扫码
+func (ctl *TwoFactorController) Setup(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	setup, err := ctl.twoFactorService.Setup(userID)
+	if err != nil {
+		if respondTwoFactorError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成两步验证密钥失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, setup)
+}
+
+// Enable 提交验证器生成的验证码，确认绑定并启用两步验证
+func (ctl *TwoFactorController) Enable(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	codes, err := ctl.twoFactorService.Enable(userID, input.Code)
+	if err != nil {
+		if respondTwoFactorError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "启用两步验证失败"})
+		return
+	}
+
+	// 恢复码只在此时返回一次，提示用户妥善保存
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已启用", "recovery_codes": codes})
+}
+
+// Disable 校验密码与验证码后关闭两步验证
+func (ctl *TwoFactorController) Disable(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.TwoFactorDisableInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	if err := ctl.twoFactorService.Disable(userID, input.Password, input.Code); err != nil {
+		if respondTwoFactorError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "关闭两步验证失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "两步验证已关闭"})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，原有恢复码全部作废
+func (ctl *TwoFactorController) RegenerateRecoveryCodes(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.TwoFactorCodeInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	codes, err := ctl.twoFactorService.RegenerateRecoveryCodes(userID, input.Code)
+	if err != nil {
+		if respondTwoFactorError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成恢复码失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// currentUserID 从上下文中读取当前用户ID，失败时写入错误响应并返回 false
+func currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return uuid.Nil, false
+	}
+
+	// 断言 userID 为 uuid.UUID 类型
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+// respondTwoFactorError 将两步验证的业务错误转换为对应的响应
+func respondTwoFactorError(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrInvalidTwoFactorCode), errors.Is(err, services.ErrIncorrectPassword):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrTwoFactorAlreadyEnabled),
+		errors.Is(err, services.ErrTwoFactorNotEnabled),
+		errors.Is(err, services.ErrTwoFactorNotSetup):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		return false
+	}
+	return true
+}
@@ -14,16 +14,19 @@ import (
 type UserController struct {
 	userService         services.UserService
 	notificationService services.NotificationService
+	avatarService       services.AvatarService
 }
 
 // NewUserController 创建新的 UserController 实例
 func NewUserController(
 	userService services.UserService,
 	notificationService services.NotificationService,
+	avatarService services.AvatarService,
 ) *UserController {
 	return &UserController{
 		userService:         userService,
 		notificationService: notificationService,
+		avatarService:       avatarService,
 	}
 }
 
@@ -55,6 +58,40 @@ func (ctl *UserController) GetUserInfo(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// UpdateAvatar 上传新头像，返回原图与各尺寸缩略图的地址
+// PUT /user/avatar  multipart 字段 avatar
+func (ctl *UserController) UpdateAvatar(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	file, err := c.FormFile("avatar")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未收到头像文件"})
+		return
+	}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "读取上传文件失败"})
+		return
+	}
+	defer src.Close()
+
+	avatar, err := ctl.avatarService.SetUserAvatar(c.Request.Context(), userID, src, file.Size)
+	if err != nil {
+		if respondUploadError(c, err) {
+			return
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "用户未找到"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "头像保存失败"})
+		return
+	}
+	c.JSON(http.StatusOK, avatar)
+}
+
 // UpdateUserInfo 更新用户信息
 func (ctl *UserController) UpdateUserInfo(c *gin.Context) {
 	userID, exists := c.Get("user_id")
@@ -88,3 +125,51 @@ func (ctl *UserController) UpdateUserInfo(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// GetNotificationPreferences 获取通知偏好设置
+// GET /user/notification-preferences
+func (ctl *UserController) GetNotificationPreferences(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	preferences, err := ctl.notificationService.GetPreferences(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取通知偏好失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
+
+// UpdateNotificationPreferences 修改通知偏好设置
+// PUT /user/notification-preferences
+func (ctl *UserController) UpdateNotificationPreferences(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.UpdateNotificationPreferencesInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	preferences, err := ctl.notificationService.UpdatePreferences(userID, input)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownNotificationType),
+			errors.Is(err, services.ErrUnknownNotificationChannel),
+			errors.Is(err, services.ErrNotificationTypeNotConfigurable),
+			errors.Is(err, services.ErrInvalidDigestFrequency):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "修改通知偏好失败"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, preferences)
+}
@@ -0,0 +1,103 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/services"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// WalletController 处理用户钱包相关请求
+type WalletController struct {
+	ledgerService services.LedgerService
+}
+
+// NewWalletController 创建新的 WalletController 实例
+func NewWalletController(ledgerService services.LedgerService) *WalletController {
+	return &WalletController{ledgerService: ledgerService}
+}
+
+// GetWallet 获取当前用户的钱包余额
+func (ctl *WalletController) GetWallet(c *gin.Context) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	// 断言 userID 为 uuid.UUID 类型
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return
+	}
+
+	wallet, err := ctl.ledgerService.GetWallet(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取钱包信息失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, wallet)
+}
+
+// GetTransactions 获取当前用户的钱包流水
+// GET /user/wallet/transactions?limit=20&cursor=...
+func (ctl *WalletController) GetTransactions(c *gin.Context) {
+	userIDInterface, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		return
+	}
+
+	// 断言 userID 为 uuid.UUID 类型
+	userID, ok := userIDInterface.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		return
+	}
+
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	transactions, err := ctl.ledgerService.GetTransactions(userID, page)
+	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取钱包流水失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, transactions)
+}
+
+// Deposit 管理员确认支付到账后为指定用户的钱包入账
+// POST /admin/users/:user_id/wallet/deposit
+func (ctl *WalletController) Deposit(c *gin.Context) {
+	actorID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	userID, ok := parseUserIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.DepositInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	wallet, err := ctl.ledgerService.Deposit(actorID, userID, input.Amount, input.Reference)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "充值成功", "wallet": wallet})
+}
@@ -0,0 +1,201 @@
+package controllers
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/services"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// WebhookController 用户管理自己的 Webhook
+type WebhookController struct {
+	webhookService services.WebhookService
+}
+
+// NewWebhookController 创建新的 WebhookController 实例
+func NewWebhookController(webhookService services.WebhookService) *WebhookController {
+	return &WebhookController{webhookService: webhookService}
+}
+
+// CreateWebhook 注册 Webhook，响应中的 secret 只返回这一次
+// POST /webhooks  {"url": "https://...", "events": ["ApplicationApproved", "bounty.status_changed"], "description": "..."}
+func (ctl *WebhookController) CreateWebhook(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.CreateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	webhook, err := ctl.webhookService.CreateWebhook(userID, input)
+	if err != nil {
+		respondWebhookError(c, err, "创建 Webhook 失败")
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// GetWebhooks 获取当前用户的所有 Webhook
+// GET /webhooks
+func (ctl *WebhookController) GetWebhooks(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := ctl.webhookService.GetWebhooks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "获取 Webhook 失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// GetWebhook 获取单个 Webhook
+// GET /webhooks/:id
+func (ctl *WebhookController) GetWebhook(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	webhook, err := ctl.webhookService.GetWebhook(userID, webhookID)
+	if err != nil {
+		respondWebhookError(c, err, "获取 Webhook 失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// UpdateWebhook 修改 Webhook 的地址、订阅的事件、说明或启用状态
+// PUT /webhooks/:id
+func (ctl *WebhookController) UpdateWebhook(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	var input dtos.UpdateWebhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的传输模型", "details": err.Error()})
+		return
+	}
+
+	webhook, err := ctl.webhookService.UpdateWebhook(userID, webhookID, input)
+	if err != nil {
+		respondWebhookError(c, err, "修改 Webhook 失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook 删除 Webhook，尚未完成的重试会被放弃
+// DELETE /webhooks/:id
+func (ctl *WebhookController) DeleteWebhook(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := ctl.webhookService.DeleteWebhook(userID, webhookID); err != nil {
+		respondWebhookError(c, err, "删除 Webhook 失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook 删除成功"})
+}
+
+// GetDeliveries 分页获取 Webhook 的投递日志
+// GET /webhooks/:id/deliveries?cursor=&limit=20&sort=newest
+func (ctl *WebhookController) GetDeliveries(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookIDParam(c)
+	if !ok {
+		return
+	}
+	page, ok := bindPageQuery(c)
+	if !ok {
+		return
+	}
+
+	deliveries, err := ctl.webhookService.GetDeliveries(userID, webhookID, page)
+	if err != nil {
+		if respondPageError(c, err) {
+			return
+		}
+		respondWebhookError(c, err, "获取投递日志失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// TestWebhook 立即发送一次 ping 事件并返回投递结果
+// POST /webhooks/:id/test
+func (ctl *WebhookController) TestWebhook(c *gin.Context) {
+	userID, ok := currentUserID(c)
+	if !ok {
+		return
+	}
+	webhookID, ok := parseWebhookIDParam(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := ctl.webhookService.Ping(userID, webhookID)
+	if err != nil {
+		respondWebhookError(c, err, "发送测试事件失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+func parseWebhookIDParam(c *gin.Context) (uuid.UUID, bool) {
+	webhookID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的 Webhook ID"})
+		return uuid.Nil, false
+	}
+	return webhookID, true
+}
+
+// respondWebhookError 将 Webhook 的业务错误转换为对应的响应
+func respondWebhookError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidWebhookURL),
+		errors.Is(err, services.ErrWebhookURLForbidden),
+		errors.Is(err, services.ErrUnknownNotificationType),
+		errors.Is(err, services.ErrTooManyWebhooks):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
@@ -8,6 +8,17 @@ import (
 	"strings"  // 导入strings包
 )
 
+// SessionValidator 判断访问令牌所属的会话是否仍然有效（未注销、未过期）
+type SessionValidator func(sessionID, userID uuid.UUID) bool
+
+// sessionValidator 启动时通过 SetSessionValidator 注入，未设置时不做吊销检查
+var sessionValidator SessionValidator
+
+// SetSessionValidator 设置认证中间件使用的会话校验函数
+func SetSessionValidator(validator SessionValidator) {
+	sessionValidator = validator
+}
+
 // JWTAuthMiddleware 验证JWT的中间件
 func JWTAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -27,28 +38,51 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// 提取Token字符串
-		tokenString := parts[1]
+		// 提取Token字符串并验证
+		authenticate(c, parts[1])
+	}
+}
 
-		// 使用utils包中的ValidateJWT函数来验证Token
-		userID, err := utils.ValidateJWT(tokenString)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+// JWTStreamAuthMiddleware 用于 SSE 与 WebSocket 的认证中间件
+// 浏览器的 EventSource 与 WebSocket 无法设置请求头，因此也接受 access_token 查询参数
+func JWTStreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" {
+			authenticate(c, token)
 			return
 		}
+		JWTAuthMiddleware()(c)
+	}
+}
 
-		// 确保userID为uuid.UUID类型（如果ValidateJWT返回的是uuid.UUID，无需断言）
-		if userID == uuid.Nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
-			c.Abort()
-			return
-		}
+// authenticate 验证访问令牌，成功时设置上下文并继续处理请求
+func authenticate(c *gin.Context, tokenString string) {
+	// 使用utils包中的ValidateJWT函数来验证Token
+	claims, err := utils.ValidateJWT(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
 
-		// 将user_id设置到上下文中供后续使用
-		c.Set("user_id", userID)
+	// 确保令牌中包含有效的用户ID
+	if claims.UserID == uuid.Nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user ID in token"})
+		c.Abort()
+		return
+	}
 
-		// 继续处理请求
-		c.Next()
+	// 会话已注销或被吊销时，即使令牌尚未过期也拒绝访问
+	if sessionValidator != nil && !sessionValidator(claims.SessionID, claims.UserID) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		c.Abort()
+		return
 	}
+
+	// 将user_id与session_id设置到上下文中供后续使用
+	c.Set("user_id", claims.UserID)
+	c.Set("session_id", claims.SessionID)
+
+	// 继续处理请求
+	c.Next()
 }
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/url"
+	"strings"
+)
+
+// redactedQueryParams 请求日志中需要隐去取值的查询参数
+var redactedQueryParams = []string{"access_token"}
+
+// Logger 请求日志中间件，格式与 gin.Logger 相同，但隐去查询参数中的访问令牌，
+// 避免 SSE 与 WebSocket 使用 access_token 查询参数认证时令牌被写入日志
+func Logger() gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{Formatter: redactedLogFormatter})
+}
+
+func redactedLogFormatter(param gin.LogFormatterParams) string {
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		param.Method,
+		redactPath(param.Path),
+		param.ErrorMessage,
+	)
+}
+
+// redactPath 将路径中需要隐去的查询参数替换为 REDACTED
+func redactPath(path string) string {
+	p, rawQuery, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		// 无法解析时整体隐去查询串
+		return p + "?REDACTED"
+	}
+	redacted := false
+	for _, name := range redactedQueryParams {
+		if query.Has(name) {
+			query.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return path
+	}
+	return p + "?" + query.Encode()
+}
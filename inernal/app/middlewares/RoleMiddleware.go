@@ -0,0 +1,104 @@
+package middlewares
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// UserLoader 根据用户ID加载用户，用于读取最新的角色与权限
+type UserLoader func(userID uuid.UUID) (*tables.User, error)
+
+// userLoader 启动时通过 SetUserLoader 注入
+var userLoader UserLoader
+
+// SetUserLoader 设置角色中间件使用的用户加载函数
+func SetUserLoader(loader UserLoader) {
+	userLoader = loader
+}
+
+// RequireRole 只允许指定角色的用户访问，必须放在 JWTAuthMiddleware 之后
+func RequireRole(roles ...tables.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadCurrentUser(c)
+		if !ok {
+			return
+		}
+
+		for _, role := range roles {
+			if user.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足", "role": user.Role, "required_roles": roles})
+		c.Abort()
+	}
+}
+
+// RequirePermission 只允许拥有指定权限的用户访问，必须放在 JWTAuthMiddleware 之后
+func RequirePermission(permission tables.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := loadCurrentUser(c)
+		if !ok {
+			return
+		}
+
+		if !user.HasPermission(permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足", "required_permission": permission})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// loadCurrentUser 加载当前登录用户并缓存到上下文，失败时已写入响应
+func loadCurrentUser(c *gin.Context) (*tables.User, bool) {
+	if cached, ok := c.Get("current_user"); ok {
+		if user, ok := cached.(*tables.User); ok {
+			return user, true
+		}
+	}
+
+	userID, ok := c.Get("user_id")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "未认证"})
+		c.Abort()
+		return nil, false
+	}
+
+	uid, ok := userID.(uuid.UUID)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "无效的用户ID类型"})
+		c.Abort()
+		return nil, false
+	}
+
+	if userLoader == nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足"})
+		c.Abort()
+		return nil, false
+	}
+
+	user, err := userLoader(uid)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "用户不存在"})
+		c.Abort()
+		return nil, false
+	}
+
+	// 被封禁或注销的账号即使持有有效令牌也不能执行特权操作
+	if user.AccountStatus != tables.AccountStatusActive {
+		c.JSON(http.StatusForbidden, gin.H{"error": "账号不可用", "code": "account_" + user.AccountStatus})
+		c.Abort()
+		return nil, false
+	}
+
+	c.Set("current_user", user)
+	c.Set("user_role", user.Role)
+	return user, true
+}
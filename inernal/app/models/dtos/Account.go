@@ -0,0 +1,17 @@
+package dtos
+
+// VerifyEmailInput 提交邮件中的验证令牌
+type VerifyEmailInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ForgotPasswordInput 申请重置密码
+type ForgotPasswordInput struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordInput 使用邮件中的令牌设置新密码
+type ResetPasswordInput struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
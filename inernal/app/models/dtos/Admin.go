@@ -0,0 +1,22 @@
+package dtos
+
+import "github.com/google/uuid"
+
+// UpdateUserRoleInput 修改用户角色
+type UpdateUserRoleInput struct {
+	Role string `json:"role" binding:"required,oneof=user moderator admin"`
+}
+
+// ForceCancelBountyInput 强制取消悬赏令
+type ForceCancelBountyInput struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// BroadcastNotificationInput 向所有用户广播的通知
+type BroadcastNotificationInput struct {
+	Title       string         `json:"title" binding:"required,max=255"`
+	Description string         `json:"description" binding:"required"`
+	RelatedID   *uuid.UUID     `json:"related_id"`
+	RelatedType string         `json:"related_type"`
+	Metadata    map[string]any `json:"metadata"`
+}
@@ -0,0 +1,16 @@
+package dtos
+
+import (
+	"github.com/google/uuid"
+	"io"
+)
+
+// AttachmentUpload 上传附件的文件内容与关联对象，BountyID 与 MilestoneID 均为可选。
+// 文件类型由服务端根据内容判断，不使用客户端声明的类型
+type AttachmentUpload struct {
+	Filename    string
+	Size        int64 // 客户端声明的大小，-1 表示未知，实际大小以读取到的内容为准
+	Content     io.Reader
+	BountyID    *uuid.UUID
+	MilestoneID *uuid.UUID // 指定里程碑时附件同时关联到里程碑所属的悬赏令
+}
@@ -0,0 +1,7 @@
+package dtos
+
+// Avatar 头像原图与各尺寸缩略图的地址
+type Avatar struct {
+	URL      string            `json:"url"`
+	Variants map[string]string `json:"variants"` // 键为缩略图边长，如 "64"
+}
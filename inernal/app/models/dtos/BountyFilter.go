@@ -7,11 +7,9 @@ import (
 
 // BountyFilter Status, PublisherID, ReceiverID 都是指针，以便分辨 “有没有传” 与 “传了空” 的区别。
 // 若只想查看某一方的悬赏令，可以设置 PublisherID=xxxx 或 ReceiverID=xxxx。
-// 若您只想要 status + 分页，可以不定义 PublisherID, ReceiverID。
+// 若您只想按 status 筛选，可以不定义 PublisherID, ReceiverID。分页参数见 PageQuery。
 type BountyFilter struct {
 	Status      *tables.BountyStatus // 可选状态
 	PublisherID *uuid.UUID           // 可选发布者ID
 	ReceiverID  *uuid.UUID           // 可选接收者ID
-	Limit       int
-	Offset      int
 }
@@ -0,0 +1,47 @@
+package dtos
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"time"
+)
+
+// BountySearchQuery GET /bounties/search 的查询参数
+// 多值参数既可以重复传递（tags=a&tags=b），也可以用逗号分隔（tags=a,b）
+type BountySearchQuery struct {
+	Q               string   `form:"q"`                // 全文检索关键词（标题与描述）
+	Tags            []string `form:"tags"`             // 与悬赏令标签有交集即命中
+	Skills          []string `form:"skills"`           // 与悬赏令所需技能有交集即命中
+	Categories      []string `form:"category"`         // 类别，多个之间为“或”
+	DifficultyLevel []string `form:"difficulty_level"` // 难度，多个之间为“或”
+	Status          string   `form:"status"`
+	Location        string   `form:"location"`
+	MinReward       *float64 `form:"min_reward"`
+	MaxReward       *float64 `form:"max_reward"`
+	DeadlineAfter   string   `form:"deadline_after"`  // 格式: YYYY-MM-DD
+	DeadlineBefore  string   `form:"deadline_before"` // 格式: YYYY-MM-DD
+	Limit           int      `form:"limit"`
+	Offset          int      `form:"offset"`
+
+	// 由服务层解析 DeadlineAfter / DeadlineBefore 后填充
+	DeadlineFrom *time.Time `form:"-"`
+	DeadlineTo   *time.Time `form:"-"`
+}
+
+// FacetCount 某个取值及其命中的悬赏令数量
+type FacetCount struct {
+	Value string `json:"value"`
+	Count int64  `json:"count"`
+}
+
+// BountySearchFacets 侧边栏筛选项的计数
+type BountySearchFacets struct {
+	Category        []FacetCount `json:"category"`
+	DifficultyLevel []FacetCount `json:"difficulty_level"`
+}
+
+// BountySearchResult 搜索结果
+type BountySearchResult struct {
+	Items  []tables.Bounty    `json:"items"`
+	Total  int64              `json:"total"`
+	Facets BountySearchFacets `json:"facets"`
+}
@@ -0,0 +1,26 @@
+package dtos
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// 时间线事件类型
+const (
+	TimelineStatusChanged        = "status_changed"
+	TimelineMilestoneCreated     = "milestone_created"
+	TimelineMilestoneUpdated     = "milestone_updated"
+	TimelineApplicationSubmitted = "application_submitted"
+	TimelineApplicationReviewed  = "application_reviewed"
+	TimelineCommentPosted        = "comment_posted"
+)
+
+// BountyTimelineEvent 悬赏令详情页时间线中的一条事件
+type BountyTimelineEvent struct {
+	Type      string         `json:"type"`
+	Timestamp time.Time      `json:"timestamp"`
+	ActorID   *uuid.UUID     `json:"actor_id"`
+	ActorName string         `json:"actor_name"`
+	Summary   string         `json:"summary"`
+	Data      map[string]any `json:"data"` // 事件相关的字段（状态、里程碑、申请或评论的摘要）
+}
@@ -0,0 +1,180 @@
+package dtos
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+)
+
+// DomainEvent 领域事件的内容，序列化后保存在 tables.OutboxEvent.Payload 中
+type DomainEvent interface {
+	// Participants 与事件相关的用户，Webhook 消费者会投递给这些用户订阅了该事件的 Webhook
+	Participants() []uuid.UUID
+}
+
+// BountyCreatedEvent bounty.created
+type BountyCreatedEvent struct {
+	BountyID    uuid.UUID `json:"bounty_id"`
+	PublisherID uuid.UUID `json:"publisher_id"`
+	Title       string    `json:"title"`
+	Reward      float64   `json:"reward"`
+}
+
+func (e *BountyCreatedEvent) Participants() []uuid.UUID {
+	return []uuid.UUID{e.PublisherID}
+}
+
+// BountyStatusChangedEvent bounty.status_changed
+type BountyStatusChangedEvent struct {
+	BountyID    uuid.UUID           `json:"bounty_id"`
+	PublisherID uuid.UUID           `json:"publisher_id"`
+	ReceiverID  *uuid.UUID          `json:"receiver_id"`
+	Title       string              `json:"title"`
+	FromStatus  tables.BountyStatus `json:"from_status"`
+	ToStatus    tables.BountyStatus `json:"to_status"`
+	ActorID     *uuid.UUID          `json:"actor_id"`
+	ActorRole   string              `json:"actor_role"`
+	Reason      string              `json:"reason"`
+}
+
+func (e *BountyStatusChangedEvent) Participants() []uuid.UUID {
+	return bountyParticipants(e.PublisherID, e.ReceiverID)
+}
+
+// BountyPayout 结算时发放给某个接收者的赏金
+type BountyPayout struct {
+	UserID uuid.UUID `json:"user_id"`
+	Amount float64   `json:"amount"`
+}
+
+// BountySettledEvent bounty.settled
+type BountySettledEvent struct {
+	BountyID    uuid.UUID      `json:"bounty_id"`
+	PublisherID uuid.UUID      `json:"publisher_id"`
+	Title       string         `json:"title"`
+	Payouts     []BountyPayout `json:"payouts"`
+}
+
+func (e *BountySettledEvent) Participants() []uuid.UUID {
+	participants := []uuid.UUID{e.PublisherID}
+	for _, payout := range e.Payouts {
+		if payout.UserID != e.PublisherID {
+			participants = append(participants, payout.UserID)
+		}
+	}
+	return participants
+}
+
+// BountyForceCancelledEvent bounty.force_cancelled
+type BountyForceCancelledEvent struct {
+	BountyID    uuid.UUID  `json:"bounty_id"`
+	PublisherID uuid.UUID  `json:"publisher_id"`
+	ReceiverID  *uuid.UUID `json:"receiver_id"`
+	Title       string     `json:"title"`
+	ActorID     uuid.UUID  `json:"actor_id"`
+	Reason      string     `json:"reason"`
+}
+
+func (e *BountyForceCancelledEvent) Participants() []uuid.UUID {
+	return bountyParticipants(e.PublisherID, e.ReceiverID)
+}
+
+// ApplicationReviewedEvent application.approved 与 application.rejected
+type ApplicationReviewedEvent struct {
+	ApplicationID uuid.UUID `json:"application_id"`
+	BountyID      uuid.UUID `json:"bounty_id"`
+	PublisherID   uuid.UUID `json:"publisher_id"`
+	ApplicantID   uuid.UUID `json:"applicant_id"`
+	Title         string    `json:"title"`
+}
+
+func (e *ApplicationReviewedEvent) Participants() []uuid.UUID {
+	return []uuid.UUID{e.PublisherID, e.ApplicantID}
+}
+
+// InvitationEvent invitation.sent、invitation.accepted、invitation.rejected、invitation.cancelled 与 invitation.expired
+type InvitationEvent struct {
+	InvitationID uuid.UUID  `json:"invitation_id"`
+	InviterID    uuid.UUID  `json:"inviter_id"`
+	InviterName  string     `json:"inviter_name"`
+	InviteeID    uuid.UUID  `json:"invitee_id"`
+	InviteeName  string     `json:"invitee_name"`
+	TeamID       *uuid.UUID `json:"team_id"`
+	BountyID     *uuid.UUID `json:"bounty_id"`
+	Message      string     `json:"message"`
+}
+
+func (e *InvitationEvent) Participants() []uuid.UUID {
+	return []uuid.UUID{e.InviterID, e.InviteeID}
+}
+
+// MilestoneSubmissionEvent milestone.submitted、milestone.submission_accepted 与 milestone.submission_rejected
+type MilestoneSubmissionEvent struct {
+	SubmissionID   uuid.UUID `json:"submission_id"`
+	MilestoneID    uuid.UUID `json:"milestone_id"`
+	MilestoneTitle string    `json:"milestone_title"`
+	BountyID       uuid.UUID `json:"bounty_id"`
+	BountyTitle    string    `json:"bounty_title"`
+	PublisherID    uuid.UUID `json:"publisher_id"`
+	SubmitterID    uuid.UUID `json:"submitter_id"`
+	Round          int       `json:"round"`
+	Feedback       string    `json:"feedback"`
+}
+
+func (e *MilestoneSubmissionEvent) Participants() []uuid.UUID {
+	return bountyParticipants(e.PublisherID, &e.SubmitterID)
+}
+
+// MilestoneRewardReleasedEvent milestone.reward_released，里程碑验收通过后发放了阶段赏金
+type MilestoneRewardReleasedEvent struct {
+	MilestoneID    uuid.UUID      `json:"milestone_id"`
+	MilestoneTitle string         `json:"milestone_title"`
+	BountyID       uuid.UUID      `json:"bounty_id"`
+	BountyTitle    string         `json:"bounty_title"`
+	PublisherID    uuid.UUID      `json:"publisher_id"`
+	Payouts        []BountyPayout `json:"payouts"`
+}
+
+func (e *MilestoneRewardReleasedEvent) Participants() []uuid.UUID {
+	participants := []uuid.UUID{e.PublisherID}
+	for _, payout := range e.Payouts {
+		if payout.UserID != e.PublisherID {
+			participants = append(participants, payout.UserID)
+		}
+	}
+	return participants
+}
+
+// SplitPlanShare 分配方案中某个团队成员的比例与按当前托管余额预估的金额
+type SplitPlanShare struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Percentage float64   `json:"percentage"`
+	Amount     float64   `json:"amount"`
+}
+
+// SplitPlanUpdatedEvent bounty.split_updated，队长修改了赏金在团队成员之间的分配方式
+type SplitPlanUpdatedEvent struct {
+	BountyID uuid.UUID        `json:"bounty_id"`
+	Title    string           `json:"title"`
+	LeaderID uuid.UUID        `json:"leader_id"`
+	Rule     tables.SplitRule `json:"rule"`
+	Shares   []SplitPlanShare `json:"shares"`
+}
+
+func (e *SplitPlanUpdatedEvent) Participants() []uuid.UUID {
+	participants := []uuid.UUID{e.LeaderID}
+	for _, share := range e.Shares {
+		if share.UserID != e.LeaderID {
+			participants = append(participants, share.UserID)
+		}
+	}
+	return participants
+}
+
+// bountyParticipants 发布者与接收者（如果有）
+func bountyParticipants(publisherID uuid.UUID, receiverID *uuid.UUID) []uuid.UUID {
+	participants := []uuid.UUID{publisherID}
+	if receiverID != nil && *receiverID != publisherID {
+		participants = append(participants, *receiverID)
+	}
+	return participants
+}
@@ -0,0 +1,17 @@
+package dtos
+
+import (
+	"github.com/google/uuid"
+)
+
+// SendInvitationInput POST /geeks/:id/invitation 的请求体，所有字段均为可选
+type SendInvitationInput struct {
+	TeamID   *uuid.UUID `json:"team_id"`   // 邀请加入的团队，邀请者必须是该团队的队长
+	BountyID *uuid.UUID `json:"bounty_id"` // 邀请一起完成的悬赏令
+	Message  string     `json:"message" binding:"max=500"`
+}
+
+// InvitationFilter GET /invitations/sent 与 GET /invitations/received 的筛选参数
+type InvitationFilter struct {
+	Status string `form:"status"` // Pending, Accepted, Rejected, Cancelled, Expired，不传时返回全部
+}
@@ -0,0 +1,22 @@
+package dtos
+
+import "github.com/google/uuid"
+
+// 阶段赏金的分配单位
+const (
+	MilestoneRewardUnitAmount     = "amount"     // 按金额分配，之和等于悬赏令的赏金
+	MilestoneRewardUnitPercentage = "percentage" // 按比例分配，之和为 100
+)
+
+// MilestoneRewardInput 某个里程碑的阶段赏金，单位由 UpdateMilestoneRewardsInput.Unit 决定
+type MilestoneRewardInput struct {
+	MilestoneID uuid.UUID `json:"milestone_id" binding:"required"`
+	Value       float64   `json:"value" binding:"gte=0"`
+}
+
+// UpdateMilestoneRewardsInput PUT /bounties/:bounty_id/milestones/rewards 的请求体
+// 未列出的里程碑没有阶段赏金；items 为空时取消阶段赏金，全部赏金在最终结算时发放
+type UpdateMilestoneRewardsInput struct {
+	Unit  string                 `json:"unit" binding:"omitempty,oneof=amount percentage"` // 默认为 amount
+	Items []MilestoneRewardInput `json:"items" binding:"dive"`
+}
@@ -0,0 +1,14 @@
+package dtos
+
+// SubmitMilestoneInput POST /bounties/:bounty_id/milestones/:milestone_id/submissions 的请求体
+type SubmitMilestoneInput struct {
+	Content        string   `json:"content" binding:"required,max=20000"`           // 成果说明
+	AttachmentURLs []string `json:"attachment_urls" binding:"max=20,dive,max=2048"` // 通过 POST /attachment 上传后得到的地址
+	Links          []string `json:"links" binding:"max=20,dive,url"`                // 演示地址、文档等外部链接
+	CommitURLs     []string `json:"commit_urls" binding:"max=50,dive,url"`          // 代码提交或合并请求的地址
+}
+
+// ReviewSubmissionInput 验收或驳回里程碑成果的请求体，驳回时 Feedback 必填
+type ReviewSubmissionInput struct {
+	Feedback string `json:"feedback" binding:"max=5000"`
+}
@@ -6,5 +6,4 @@ type MilestoneUpdateDTO struct {
 	Title       string    `json:"title"`
 	Description string    `json:"description"`
 	DueDate     time.Time `json:"due_date"`
-	IsCompleted bool      `json:"is_completed"`
 }
@@ -0,0 +1,56 @@
+package dtos
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// NotificationFilter GET /notifications 与 GET /notifications/grouped 的筛选参数，均为可选
+// type 既可以重复传递（type=a&type=b），也可以用逗号分隔（type=a,b）
+type NotificationFilter struct {
+	Types         []string `form:"type"`           // 通知类型，多个之间为“或”
+	RelatedType   string   `form:"related_type"`   // 关联资源类型，如 Bounty
+	IsRead        *bool    `form:"is_read"`        // 不传时已读与未读都返回
+	CreatedAfter  string   `form:"created_after"`  // 格式: YYYY-MM-DD
+	CreatedBefore string   `form:"created_before"` // 格式: YYYY-MM-DD，包含当天
+
+	// 由控制器解析 CreatedAfter / CreatedBefore 后填充
+	CreatedFrom *time.Time `form:"-"`
+	CreatedTo   *time.Time `form:"-"`
+}
+
+// NotificationGroup 折叠后的一组通知
+// 同一用户收到的、类型与关联资源都相同的通知合并为一组，没有关联资源的通知各自成组
+type NotificationGroup struct {
+	ID            uuid.UUID               `json:"id"` // 组内最新一条通知的ID
+	Type          tables.NotificationType `json:"type"`
+	RelatedID     *uuid.UUID              `json:"related_id"`
+	RelatedType   string                  `json:"related_type"`
+	Title         string                  `json:"title"`       // 组内最新一条通知的标题
+	Description   string                  `json:"description"` // 组内最新一条通知的内容
+	Summary       string                  `json:"summary"`     // 折叠后的摘要，如“5 人点赞了你的悬赏令”
+	Count         int64                   `json:"count"`
+	UnreadCount   int64                   `json:"unread_count"`
+	ActorCount    int64                   `json:"actor_count"` // 不同触发者的数量
+	LatestActorID *uuid.UUID              `json:"latest_actor_id"`
+	IDs           pq.StringArray          `json:"ids" gorm:"column:ids"` // 组内全部通知的ID，便于批量标记已读或删除
+	CreatedAt     time.Time               `json:"created_at"`            // 组内最新一条通知的时间
+}
+
+// NotificationUnreadCount GET /notifications/unread-count 的返回结构
+type NotificationUnreadCount struct {
+	Total  int64                             `json:"total"`
+	ByType map[tables.NotificationType]int64 `json:"by_type"`
+}
+
+// NotificationIDsInput 批量操作通知时提交的ID列表
+type NotificationIDsInput struct {
+	IDs []uuid.UUID `json:"ids" binding:"required,min=1,max=100"`
+}
+
+// NotificationBulkResult 批量操作影响的通知数量
+type NotificationBulkResult struct {
+	Affected int64 `json:"affected"`
+}
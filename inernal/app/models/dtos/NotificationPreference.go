@@ -0,0 +1,26 @@
+package dtos
+
+import "GeekReward/inernal/app/models/tables"
+
+// NotificationPreferenceItem 某类通知在各渠道上的开关
+type NotificationPreferenceItem struct {
+	Type         tables.NotificationType             `json:"type"`
+	Description  string                              `json:"description"`
+	Configurable bool                                `json:"configurable"`
+	Channels     map[tables.NotificationChannel]bool `json:"channels"`
+}
+
+// NotificationPreferences GET /user/notification-preferences 的响应
+type NotificationPreferences struct {
+	Enabled         bool                         `json:"enabled"`          // 总开关，关闭后只投递不可配置的通知
+	DigestFrequency tables.DigestFrequency       `json:"digest_frequency"` // 未读通知摘要邮件：off, daily, weekly
+	Types           []NotificationPreferenceItem `json:"types"`
+}
+
+// UpdateNotificationPreferencesInput PUT /user/notification-preferences 的请求体
+// 只需传入要修改的类型与渠道，例如 {"types": {"BountyLiked": {"in_app": false}}}
+type UpdateNotificationPreferencesInput struct {
+	Enabled         *bool                                                           `json:"enabled"`
+	DigestFrequency *tables.DigestFrequency                                         `json:"digest_frequency"`
+	Types           map[tables.NotificationType]map[tables.NotificationChannel]bool `json:"types"`
+}
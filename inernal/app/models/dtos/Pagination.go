@@ -0,0 +1,16 @@
+package dtos
+
+// PageQuery 列表接口通用的分页参数
+// 首页不传 cursor，之后每次把上一页返回的 next_cursor 原样带回
+type PageQuery struct {
+	Cursor string `form:"cursor"`
+	Limit  int    `form:"limit"`
+	Sort   string `form:"sort"` // newest, oldest, reward, deadline, likes, views，可用取值因接口而异
+}
+
+// Page 列表接口通用的返回结构
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor"` // 没有下一页时为空
+	Total      int64  `json:"total"`
+}
@@ -0,0 +1,20 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 实时事件类型
+const (
+	RealtimeNotificationCreated = "notification.created"      // 新通知，data 为通知内容
+	RealtimeUnreadCount         = "notification.unread_count" // 未读数变化，data 为 {"count": n}
+	RealtimeBountyStatusChanged = "bounty.status_changed"     // 参与的悬赏令状态变化
+)
+
+// RealtimeEvent 通过 SSE / WebSocket 推送给客户端的事件
+type RealtimeEvent struct {
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
@@ -0,0 +1,39 @@
+package dtos
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+)
+
+// SplitShareInput 按比例分配时某个接收者的比例（百分比）
+type SplitShareInput struct {
+	UserID     uuid.UUID `json:"user_id" binding:"required"`
+	Percentage float64   `json:"percentage" binding:"gt=0,lte=100"`
+}
+
+// MilestoneOwnerInput 按里程碑归属分配时某个里程碑的负责人
+type MilestoneOwnerInput struct {
+	MilestoneID uuid.UUID `json:"milestone_id" binding:"required"`
+	UserID      uuid.UUID `json:"user_id" binding:"required"`
+}
+
+// UpdateSplitPlanInput PUT /bounties/:bounty_id/split 的请求体
+// rule 为 percentage 时 shares 之和必须为 100；为 milestone 时每个里程碑都必须有负责人
+type UpdateSplitPlanInput struct {
+	Rule            tables.SplitRule      `json:"rule" binding:"required"`
+	Shares          []SplitShareInput     `json:"shares" binding:"dive"`
+	MilestoneOwners []MilestoneOwnerInput `json:"milestone_owners" binding:"dive"`
+}
+
+// SettlementView 悬赏令的结算明细；结算前为按当前分配方式与托管余额计算的预览
+type SettlementView struct {
+	BountyID uuid.UUID                   `json:"bounty_id"`
+	Rule     tables.SplitRule            `json:"rule"`
+	Total    float64                     `json:"total"`
+	Settled  bool                        `json:"settled"` // 为 true 时 Items 为实际发放的明细，包括各里程碑的阶段赏金
+	Items    []tables.SettlementLineItem `json:"items"`
+
+	// 结算前已随里程碑验收发放的阶段赏金，不计入 Total
+	Released      float64                     `json:"released"`
+	ReleasedItems []tables.SettlementLineItem `json:"released_items"`
+}
@@ -0,0 +1,13 @@
+package dtos
+
+// CreateTeamInput POST /teams 的请求体，创建者成为队长
+type CreateTeamInput struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=1000"`
+}
+
+// UpdateTeamInput PUT /teams/:team_id 的请求体，只修改传入的字段
+type UpdateTeamInput struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Description *string `json:"description" binding:"omitempty,max=1000"`
+}
@@ -0,0 +1,30 @@
+package dtos
+
+import "GeekReward/inernal/app/models/tables"
+
+// RefreshTokenInput 刷新访问令牌的请求
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// TokenPair 登录或刷新后返回给客户端的一组令牌
+type TokenPair struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"` // 每次刷新都会轮换，旧的刷新令牌随即失效
+	ExpiresIn    int64  `json:"expires_in"`    // 访问令牌有效期（秒）
+}
+
+// ClientInfo 发起登录的客户端信息，记录在会话中
+type ClientInfo struct {
+	UserAgent string
+	IPAddress string
+}
+
+// LoginResult 登录结果
+// 用户启用两步验证时只返回挑战令牌，提交验证码后才签发访问令牌
+type LoginResult struct {
+	User              *tables.User
+	Tokens            *TokenPair
+	TwoFactorRequired bool
+	ChallengeToken    string
+}
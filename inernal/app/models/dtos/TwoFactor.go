@@ -0,0 +1,30 @@
+package dtos
+
+// TwoFactorSetup 开始绑定两步验证时返回的密钥，客户端可将 otpauth_uri 渲染为二维码
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorStatus 两步验证的启用状态
+type TwoFactorStatus struct {
+	Enabled                bool  `json:"enabled"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// TwoFactorCodeInput 提交验证器生成的验证码（部分接口也接受恢复码）
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorDisableInput 关闭两步验证需要同时校验密码与验证码
+type TwoFactorDisableInput struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
+
+// TwoFactorLoginInput 两步登录的第二步，用挑战令牌与验证码换取访问令牌
+type TwoFactorLoginInput struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // TOTP 验证码或恢复码
+}
@@ -34,7 +34,6 @@ type UpdateUserProfile struct {
 	EmailPreferences   map[string]bool   `json:"email_preferences"`
 	ContactPreferences map[string]bool   `json:"contact_preferences"`
 	SecurityQuestions  map[string]string `json:"security_questions"`
-	TwoFactorEnabled   bool              `json:"two_factor_enabled"`
 	Timezone           string            `json:"timezone"`
 	PreferredLanguage  string            `json:"preferred_language"`
 	SolvedCount        int               `json:"solved_count"`
@@ -0,0 +1,29 @@
+package dtos
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// WalletDTO 用户钱包余额
+type WalletDTO struct {
+	AccountID uuid.UUID `json:"account_id"`
+	Balance   float64   `json:"balance"`
+	Escrowed  float64   `json:"escrowed"` // 该用户发布的悬赏令中仍处于托管状态的赏金总额
+}
+
+// WalletTransactionDTO 钱包流水中的一条记录
+type WalletTransactionDTO struct {
+	EntryID   uuid.UUID  `json:"entry_id"`
+	Type      string     `json:"type"`
+	BountyID  *uuid.UUID `json:"bounty_id"`
+	Amount    float64    `json:"amount"` // 正数为入账，负数为出账
+	Memo      string     `json:"memo"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// DepositInput 管理员确认支付到账后为用户钱包入账的请求
+type DepositInput struct {
+	Amount    float64 `json:"amount" binding:"required,gt=0"`
+	Reference string  `json:"reference" binding:"required,max=200"` // 支付凭证号，记入分录备注便于对账
+}
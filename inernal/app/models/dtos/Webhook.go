@@ -0,0 +1,50 @@
+package dtos
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"time"
+)
+
+// CreateWebhookInput POST /webhooks 的请求体，events 为空时订阅全部事件
+// 事件可以是通知类型（如 ApplicationApproved），也可以是领域事件（如 bounty.status_changed）
+type CreateWebhookInput struct {
+	URL         string   `json:"url" binding:"required,url"`
+	Events      []string `json:"events"`
+	Description string   `json:"description" binding:"max=255"`
+}
+
+// UpdateWebhookInput PUT /webhooks/:id 的请求体，只修改传入的字段
+type UpdateWebhookInput struct {
+	URL         *string   `json:"url" binding:"omitempty,url"`
+	Events      *[]string `json:"events"`
+	Description *string   `json:"description" binding:"omitempty,max=255"`
+	Active      *bool     `json:"active"`
+}
+
+// WebhookCreated 创建 Webhook 的响应，签名密钥只在此时返回
+type WebhookCreated struct {
+	tables.Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookPayload 投递给 Webhook 的请求体
+// 请求头 X-GeekReward-Signature 为 "sha256=" + hex(HMAC-SHA256(secret, X-GeekReward-Timestamp + "." + 请求体))
+type WebhookPayload struct {
+	ID        uuid.UUID `json:"id"` // 事件ID，同一事件投递给多个 Webhook 或重试时保持不变，可用于去重
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// WebhookNotificationData 由通知触发的事件携带的数据，领域事件则直接携带 DomainEvent 的内容
+type WebhookNotificationData struct {
+	NotificationID *uuid.UUID     `json:"notification_id"` // 用户关闭了该类站内信时为空
+	UserID         uuid.UUID      `json:"user_id"`
+	ActorID        *uuid.UUID     `json:"actor_id"`
+	Title          string         `json:"title"`
+	Description    string         `json:"description"`
+	RelatedID      *uuid.UUID     `json:"related_id"`
+	RelatedType    string         `json:"related_type"`
+	Metadata       map[string]any `json:"metadata"`
+}
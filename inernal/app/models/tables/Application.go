@@ -7,11 +7,13 @@ import (
 // Application 模型，用于存储用户对悬赏令的申请
 type Application struct {
 	BaseModel
-	BountyID uuid.UUID `gorm:"type:uuid;not null;index"` // 关联的悬赏令ID
-	UserID   uuid.UUID `gorm:"type:uuid;not null;index"` // 申请用户的ID
-	Status   string    `gorm:"default:'pending'"`        // "pending", "approved", "rejected"
-	Note     string    // 申请备注
+	BountyID uuid.UUID  `gorm:"type:uuid;not null;index"` // 关联的悬赏令ID
+	UserID   uuid.UUID  `gorm:"type:uuid;not null;index"` // 申请用户的ID
+	TeamID   *uuid.UUID `gorm:"type:uuid;index"`          // 以团队身份申请时的团队ID，申请用户为队长
+	Status   string     `gorm:"default:'pending'"`        // "pending", "approved", "rejected"
+	Note     string     // 申请备注
 	// 关联
 	Bounty Bounty `gorm:"foreignKey:BountyID;references:ID"`
 	User   User   `gorm:"foreignKey:UserID;references:ID"`
+	Team   *Team  `gorm:"foreignKey:TeamID;references:ID"`
 }
@@ -0,0 +1,28 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Attachment 上传的附件，文件内容保存在对象存储中，Key 为对象键
+// 关联了悬赏令的附件按悬赏令的可见性与保密协议控制访问，未关联的附件只有上传者可以访问
+type Attachment struct {
+	BaseModel
+	OwnerID     uuid.UUID  `gorm:"type:uuid;not null;index" json:"owner_id"`
+	Key         string     `gorm:"not null;uniqueIndex" json:"-"`
+	Filename    string     `gorm:"not null" json:"filename"` // 上传时的原始文件名
+	Size        int64      `gorm:"not null" json:"size"`
+	MimeType    string     `gorm:"size:255" json:"mime_type"`
+	SHA256      string     `gorm:"size:64;index" json:"sha256"`
+	BountyID    *uuid.UUID `gorm:"type:uuid;index" json:"bounty_id"`
+	MilestoneID *uuid.UUID `gorm:"type:uuid;index" json:"milestone_id"` // 关联里程碑时 BountyID 为里程碑所属的悬赏令
+}
+
+// BountyNDAAcceptance 用户接受悬赏令保密协议的记录，NDARequired 的悬赏令附件只对接受过协议的用户开放
+type BountyNDAAcceptance struct {
+	BaseModel
+	BountyID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bounty_nda_acceptance" json:"bounty_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bounty_nda_acceptance" json:"user_id"`
+	AcceptedAt time.Time `gorm:"not null" json:"accepted_at"`
+}
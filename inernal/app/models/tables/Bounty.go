@@ -8,8 +8,10 @@ import (
 
 type Bounty struct {
 	BaseModel
-	UserID     uuid.UUID  `gorm:"type:uuid;not null;index"`
-	ReceiverID *uuid.UUID `gorm:"type:uuid;index"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null;index"`
+	ReceiverID     *uuid.UUID `gorm:"type:uuid;index"`
+	ReceiverTeamID *uuid.UUID `gorm:"type:uuid;index"`                           // 以团队身份被批准时的团队，ReceiverID 为提交申请的队长
+	SplitRule      SplitRule  `gorm:"type:varchar(20);not null;default:'equal'"` // 结算时赏金在接收者之间的分配方式
 
 	Title           string         `gorm:"not null"`
 	Description     string         `gorm:"not null"`
@@ -24,7 +26,7 @@ type Bounty struct {
 
 	Anonymous               bool           `gorm:"default:false"`
 	Priority                string         `gorm:"default:'normal'"` // "low", "normal", "high"
-	PaymentStatus           string         `gorm:"default:'unpaid'"` // "unpaid", "paid"
+	PaymentStatus           string         `gorm:"default:'unpaid'"` // "unpaid", "escrowed", "paid", "refunded"
 	PreferredSolutionType   string         // "document", "code", etc.
 	RequiredSkills          pq.StringArray `gorm:"type:text[]"`
 	RequiredExperience      int            // 可选，年限
@@ -59,13 +61,26 @@ type Bounty struct {
 	Ratings      []Rating      `gorm:"foreignKey:BountyID;references:ID"`
 }
 
+// BountySearchVector 悬赏令全文检索使用的 tsvector 表达式
+// 迁移中按同一表达式建立 GIN 索引，查询时必须保持完全一致才能命中索引
+const BountySearchVector = "to_tsvector('simple', coalesce(title, '') || ' ' || coalesce(description, ''))"
+
 type BountyStatus string
 
 const (
 	BountyStatusCreated             BountyStatus = "Created"
+	BountyStatusAssigned            BountyStatus = "Assigned"
 	BountyStatusMilestonesConfirmed BountyStatus = "MilestonesConfirmed"
 	BountyStatusMilestonesVerified  BountyStatus = "MilestonesVerified"
 	BountyStatusSettling            BountyStatus = "Settling"
 	BountyStatusSettled             BountyStatus = "Settled"
 	BountyStatusCancelled           BountyStatus = "Cancelled"
 )
+
+// 悬赏令的资金状态，由账本在锁定、结算、退还赏金时维护
+const (
+	PaymentStatusUnpaid   = "unpaid"   // 尚未锁定赏金
+	PaymentStatusEscrowed = "escrowed" // 赏金已锁定在托管账户中
+	PaymentStatusPaid     = "paid"     // 赏金已释放给接收者
+	PaymentStatusRefunded = "refunded" // 赏金已退还（取消或删除）
+)
@@ -0,0 +1,19 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+)
+
+// BountyStatusChange 悬赏令状态变更记录，每次状态转换写入一条，CreatedAt 即变更时间
+type BountyStatusChange struct {
+	BaseModel
+	BountyID   uuid.UUID    `gorm:"type:uuid;not null;index" json:"bounty_id"`
+	FromStatus BountyStatus `gorm:"type:varchar(50)" json:"from_status"` // 创建悬赏令时为空
+	ToStatus   BountyStatus `gorm:"type:varchar(50);not null" json:"to_status"`
+	ActorID    *uuid.UUID   `gorm:"type:uuid;index" json:"actor_id"`    // 触发者（系统触发时为空）
+	ActorRole  string       `gorm:"type:varchar(20)" json:"actor_role"` // publisher, receiver, system
+	Reason     string       `gorm:"type:text" json:"reason"`
+
+	// 关联
+	Actor *User `gorm:"foreignKey:ActorID;references:ID" json:"actor,omitempty"`
+}
@@ -5,11 +5,40 @@ import (
 	"time"
 )
 
+// 组队邀请的状态，只有 Pending 状态的邀请可以被接受、拒绝、取消或过期
+const (
+	InvitationStatusPending   = "Pending"
+	InvitationStatusAccepted  = "Accepted"
+	InvitationStatusRejected  = "Rejected"
+	InvitationStatusCancelled = "Cancelled" // 邀请者撤回
+	InvitationStatusExpired   = "Expired"   // 超过 ExpiresAt 仍未处理，由后台清理任务标记
+)
+
+// InvitationStatuses 所有邀请状态，用于校验列表接口的筛选参数
+var InvitationStatuses = []string{
+	InvitationStatusPending,
+	InvitationStatusAccepted,
+	InvitationStatusRejected,
+	InvitationStatusCancelled,
+	InvitationStatusExpired,
+}
+
 type Invitation struct {
-	ID        uuid.UUID `gorm:"type:uuid;primary_key;" json:"id"`
-	InviterID uuid.UUID `gorm:"type:uuid;" json:"inviter_id"`
-	InviteeID uuid.UUID `gorm:"type:uuid;" json:"invitee_id"`
-	Status    string    `json:"status"` // Pending, Accepted, Rejected
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;" json:"id"`
+	InviterID   uuid.UUID  `gorm:"type:uuid;index" json:"inviter_id"`
+	InviteeID   uuid.UUID  `gorm:"type:uuid;index" json:"invitee_id"`
+	TeamID      *uuid.UUID `gorm:"type:uuid;" json:"team_id"`   // 邀请加入的团队，为空时加入邀请者担任队长的团队
+	BountyID    *uuid.UUID `gorm:"type:uuid;" json:"bounty_id"` // 邀请一起完成的悬赏令，仅作为说明
+	Message     string     `gorm:"type:text" json:"message"`
+	Status      string     `gorm:"index" json:"status"` // Pending, Accepted, Rejected, Cancelled, Expired
+	ExpiresAt   time.Time  `gorm:"index" json:"expires_at"`
+	RespondedAt *time.Time `json:"responded_at"` // 离开 Pending 状态的时间
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// 关联，列表接口预加载
+	Inviter *User   `gorm:"foreignKey:InviterID;references:ID" json:"inviter,omitempty"`
+	Invitee *User   `gorm:"foreignKey:InviteeID;references:ID" json:"invitee,omitempty"`
+	Team    *Team   `gorm:"foreignKey:TeamID;references:ID" json:"team,omitempty"`
+	Bounty  *Bounty `gorm:"foreignKey:BountyID;references:ID" json:"bounty,omitempty"`
 }
@@ -0,0 +1,61 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+)
+
+// LedgerAccountType 账户类型
+type LedgerAccountType string
+
+const (
+	LedgerAccountUser     LedgerAccountType = "user"     // 用户钱包，OwnerID 为用户ID
+	LedgerAccountEscrow   LedgerAccountType = "escrow"   // 悬赏令托管账户，OwnerID 为悬赏令ID
+	LedgerAccountPlatform LedgerAccountType = "platform" // 平台账户，OwnerID 为 uuid.Nil
+	LedgerAccountExternal LedgerAccountType = "external" // 外部资金（充值的对手方），OwnerID 为 uuid.Nil，允许为负
+)
+
+// LedgerAccount 复式记账中的账户，余额由分录行累加而来
+type LedgerAccount struct {
+	BaseModel
+	Type    LedgerAccountType `gorm:"type:varchar(20);not null;uniqueIndex:idx_ledger_account_owner" json:"type"`
+	OwnerID uuid.UUID         `gorm:"type:uuid;not null;uniqueIndex:idx_ledger_account_owner" json:"owner_id"`
+	Balance float64           `gorm:"type:numeric(18,2);not null;default:0" json:"balance"`
+}
+
+// JournalEntryType 记账分录类型
+type JournalEntryType string
+
+const (
+	JournalEntryDeposit           JournalEntryType = "deposit"             // 用户充值
+	JournalEntryEscrowLock        JournalEntryType = "escrow_lock"         // 创建悬赏令时锁定赏金
+	JournalEntryEscrowAdjust      JournalEntryType = "escrow_adjust"       // 修改赏金时补缴或退还差额
+	JournalEntryEscrowRelease     JournalEntryType = "escrow_release"      // 结算时向接收者释放赏金
+	JournalEntryMilestoneRelease  JournalEntryType = "milestone_release"   // 里程碑验收通过时向接收者发放阶段赏金
+	JournalEntryEscrowRefund      JournalEntryType = "escrow_refund"       // 删除悬赏令时退还赏金
+	JournalEntryCancelByPublisher JournalEntryType = "cancel_by_publisher" // 发布方取消清算（含违约金）
+	JournalEntryCancelByReceiver  JournalEntryType = "cancel_by_receiver"  // 接收方取消清算（含违约金）
+	JournalEntryForceCancel       JournalEntryType = "force_cancel"        // 管理员强制取消，全额退还发布者
+)
+
+// JournalEntry 一笔记账分录，其下所有分录行金额之和必须为 0
+type JournalEntry struct {
+	BaseModel
+	Type     JournalEntryType `gorm:"type:varchar(50);not null;index" json:"type"`
+	BountyID *uuid.UUID       `gorm:"type:uuid;index" json:"bounty_id"` // 关联的悬赏令（可选）
+	Memo     string           `gorm:"type:text" json:"memo"`
+
+	// 关联
+	Lines []JournalLine `gorm:"foreignKey:EntryID;references:ID" json:"lines"`
+}
+
+// JournalLine 分录行，Amount 为对应账户余额的变动（正数为增加，负数为减少）
+type JournalLine struct {
+	BaseModel
+	EntryID   uuid.UUID `gorm:"type:uuid;not null;index" json:"entry_id"`
+	AccountID uuid.UUID `gorm:"type:uuid;not null;index" json:"account_id"`
+	Amount    float64   `gorm:"type:numeric(18,2);not null" json:"amount"`
+
+	// 关联
+	Entry   *JournalEntry  `gorm:"foreignKey:EntryID;references:ID" json:"entry,omitempty"`
+	Account *LedgerAccount `gorm:"foreignKey:AccountID;references:ID" json:"account,omitempty"`
+}
@@ -19,4 +19,13 @@ type Milestone struct {
 
 	// 是否完成，由里程碑接收者更新
 	IsCompleted bool `json:"is_completed"`
+
+	// 负责该里程碑的团队成员，按里程碑归属分配赏金时使用
+	OwnerID *uuid.UUID `gorm:"type:uuid;index" json:"owner_id"`
+
+	// 阶段赏金，由发布者分配，所有里程碑之和等于悬赏令的赏金；成果验收通过时自动从托管账户发放，
+	// 未分配阶段赏金的部分在最终结算时发放
+	RewardAmount     float64    `gorm:"type:numeric(18,2);not null;default:0" json:"reward_amount"`
+	RewardPercentage float64    `gorm:"not null;default:0" json:"reward_percentage"` // 占悬赏令赏金的比例（百分比）
+	RewardReleasedAt *time.Time `json:"reward_released_at"`                          // 阶段赏金的发放时间，未发放时为空
 }
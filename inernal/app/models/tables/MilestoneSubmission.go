@@ -0,0 +1,38 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// 里程碑成果提交的状态，只有 Pending 状态的提交可以被验收或驳回
+const (
+	SubmissionStatusPending  = "Pending"
+	SubmissionStatusAccepted = "Accepted"
+	SubmissionStatusRejected = "Rejected"
+)
+
+// MilestoneSubmission 接收者为里程碑提交的成果，每次提交为一轮，发布者驳回后接收者可修改后再次提交，
+// 发布者验收通过时里程碑才标记为完成
+type MilestoneSubmission struct {
+	BaseModel
+	MilestoneID uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_milestone_submission_round" json:"milestone_id"`
+	BountyID    uuid.UUID `gorm:"type:uuid;not null;index" json:"bounty_id"`
+	SubmitterID uuid.UUID `gorm:"type:uuid;not null;index" json:"submitter_id"`
+	Round       int       `gorm:"not null;uniqueIndex:idx_milestone_submission_round" json:"round"` // 第几轮提交，从 1 开始
+
+	Content        string         `gorm:"type:text" json:"content"`           // 成果说明
+	AttachmentURLs pq.StringArray `gorm:"type:text[]" json:"attachment_urls"` // 附件下载地址
+	Links          pq.StringArray `gorm:"type:text[]" json:"links"`           // 演示地址、文档等外部链接
+	CommitURLs     pq.StringArray `gorm:"type:text[]" json:"commit_urls"`     // 代码提交或合并请求的地址
+
+	Status     string     `gorm:"size:20;not null;default:'Pending';index" json:"status"` // Pending, Accepted, Rejected
+	Feedback   string     `gorm:"type:text" json:"feedback"`                              // 发布者的验收意见，驳回时必填
+	ReviewerID *uuid.UUID `gorm:"type:uuid" json:"reviewer_id"`
+	ReviewedAt *time.Time `json:"reviewed_at"`
+
+	// 关联，列表接口预加载
+	Submitter *User `gorm:"foreignKey:SubmitterID;references:ID" json:"submitter,omitempty"`
+	Reviewer  *User `gorm:"foreignKey:ReviewerID;references:ID" json:"reviewer,omitempty"`
+}
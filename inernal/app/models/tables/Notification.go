@@ -8,17 +8,21 @@ import (
 // Notification 模型，用于存储用户通知信息
 type Notification struct {
 	BaseModel
-	UserID      uuid.UUID  `gorm:"type:uuid;index"` // 与用户表的外键关系
-	ActorID     *uuid.UUID `gorm:"type:uuid;index"` // 触发者（可选）
-	Type        string     `gorm:"size:100;not null"`
-	Title       string     `gorm:"size:255;not null"`
-	Description string     `gorm:"type:text"`
+	UserID      uuid.UUID        `gorm:"type:uuid;index;uniqueIndex:idx_notification_event_recipient"` // 与用户表的外键关系
+	ActorID     *uuid.UUID       `gorm:"type:uuid;index"`                                              // 触发者（可选）
+	Type        NotificationType `gorm:"size:100;not null"`
+	Title       string           `gorm:"size:255;not null"`
+	Description string           `gorm:"type:text"`
 
 	// 扩展字段
 	RelatedID   *uuid.UUID     `gorm:"type:uuid;index"` // 关联资源(可选)
 	RelatedType string         `gorm:"size:100"`        // 关联资源类型(Bounty, Invitation, etc.)
 	Metadata    map[string]any `gorm:"type:jsonb"`      // JSON字段(需Postgres或自行序列化)
 
+	// EventID 生成该通知的发件箱事件，与 UserID 组成唯一索引，事件重试时不会重复通知同一接收者；
+	// 直接创建的通知为空，不受唯一索引约束
+	EventID *uuid.UUID `gorm:"type:uuid;uniqueIndex:idx_notification_event_recipient"`
+
 	IsRead    bool           `gorm:"default:false"`
 	DeletedAt gorm.DeletedAt `gorm:"index"` // 软删除字段
 
@@ -0,0 +1,37 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// DigestFrequency 未读通知摘要邮件的发送频率
+type DigestFrequency string
+
+const (
+	DigestFrequencyOff    DigestFrequency = "off"
+	DigestFrequencyDaily  DigestFrequency = "daily"  // 每天汇总前一天（用户所在时区）的未读通知
+	DigestFrequencyWeekly DigestFrequency = "weekly" // 每周一汇总上一周的未读通知
+)
+
+// Valid 判断是否为已定义的发送频率
+func (f DigestFrequency) Valid() bool {
+	switch f {
+	case DigestFrequencyOff, DigestFrequencyDaily, DigestFrequencyWeekly:
+		return true
+	}
+	return false
+}
+
+// NotificationDigest 已发送的摘要邮件，(用户, 频率, 周期起点) 唯一，保证同一周期只发送一次
+// 发送前先写入记录占位，SentAt 为空表示正在发送，发送失败时删除记录以便下次重试
+type NotificationDigest struct {
+	BaseModel
+	UserID          uuid.UUID       `gorm:"type:uuid;not null;uniqueIndex:idx_notification_digest_period" json:"user_id"`
+	Frequency       DigestFrequency `gorm:"type:varchar(10);not null;uniqueIndex:idx_notification_digest_period" json:"frequency"`
+	PeriodStart     time.Time       `gorm:"not null;uniqueIndex:idx_notification_digest_period" json:"period_start"`
+	PeriodEnd       time.Time       `gorm:"not null" json:"period_end"`
+	NotificationIDs pq.StringArray  `gorm:"type:text[]" json:"notification_ids"` // 摘要中包含的通知
+	SentAt          *time.Time      `json:"sent_at"`
+}
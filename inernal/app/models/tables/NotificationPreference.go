@@ -0,0 +1,12 @@
+package tables
+
+import "github.com/google/uuid"
+
+// NotificationPreference 用户对某类通知在某个渠道上的开关，未设置时使用通知类型的默认值
+type NotificationPreference struct {
+	BaseModel
+	UserID  uuid.UUID           `gorm:"type:uuid;not null;uniqueIndex:idx_notification_preference" json:"user_id"`
+	Type    NotificationType    `gorm:"type:varchar(100);not null;uniqueIndex:idx_notification_preference" json:"type"`
+	Channel NotificationChannel `gorm:"type:varchar(20);not null;uniqueIndex:idx_notification_preference" json:"channel"`
+	Enabled bool                `gorm:"not null" json:"enabled"`
+}
@@ -0,0 +1,107 @@
+package tables
+
+// NotificationType 通知类型
+type NotificationType string
+
+const (
+	NotificationTypeWelcome             NotificationType = "Welcome"
+	NotificationTypeNewLogin            NotificationType = "NewLogin"
+	NotificationTypeAnnouncement        NotificationType = "Announcement"
+	NotificationTypeBountyCreated       NotificationType = "BountyCreated"
+	NotificationTypeBountyApplied       NotificationType = "BountyApplied"
+	NotificationTypeApplicationApproved NotificationType = "ApplicationApproved"
+	NotificationTypeApplicationRejected NotificationType = "ApplicationRejected"
+	NotificationTypeMilestoneSubmitted  NotificationType = "MilestoneSubmitted"
+	NotificationTypeMilestoneConfirmed  NotificationType = "MilestoneConfirmed"
+	NotificationTypeSettlementApplied   NotificationType = "SettlementApplied"
+	NotificationTypeSettlementCompleted NotificationType = "SettlementCompleted"
+	NotificationTypeBountySettled       NotificationType = "BountySettled"
+	NotificationTypeBountyCancelled     NotificationType = "BountyCancelled"
+	NotificationTypeBountyLiked         NotificationType = "BountyLiked"
+	NotificationTypeBountyCommented     NotificationType = "BountyCommented"
+	NotificationTypeUserRated           NotificationType = "UserRated"
+	NotificationTypeInvitationReceived  NotificationType = "InvitationReceived"
+	NotificationTypeInvitationAccepted  NotificationType = "InvitationAccepted"
+	NotificationTypeInvitationRejected  NotificationType = "InvitationRejected"
+	NotificationTypeInvitationCancelled NotificationType = "InvitationCancelled"
+	NotificationTypeInvitationExpired   NotificationType = "InvitationExpired"
+	NotificationTypeSubmissionRejected  NotificationType = "SubmissionRejected"
+	NotificationTypeSplitPlanUpdated    NotificationType = "SplitPlanUpdated"
+)
+
+// NotificationChannel 通知的投递渠道
+type NotificationChannel string
+
+const (
+	NotificationChannelInApp   NotificationChannel = "in_app"  // 站内信（含实时推送）
+	NotificationChannelEmail   NotificationChannel = "email"   // 邮件
+	NotificationChannelWebhook NotificationChannel = "webhook" // 用户注册的 Webhook
+)
+
+// NotificationChannels 所有投递渠道
+var NotificationChannels = []NotificationChannel{
+	NotificationChannelInApp,
+	NotificationChannelEmail,
+	NotificationChannelWebhook,
+}
+
+// NotificationTypeInfo 通知类型的说明与各渠道的默认开关
+type NotificationTypeInfo struct {
+	Type         NotificationType
+	Description  string
+	Configurable bool // 为 false 时用户不能修改，始终按默认值投递
+	Defaults     map[NotificationChannel]bool
+}
+
+// NotificationCatalogue 所有通知类型，顺序即偏好设置页面的展示顺序
+var NotificationCatalogue = []NotificationTypeInfo{
+	{NotificationTypeWelcome, "注册欢迎", true, inApp},
+	{NotificationTypeNewLogin, "账号登录提醒", false, inApp},
+	{NotificationTypeAnnouncement, "平台公告", false, inApp},
+	{NotificationTypeBountyCreated, "发布悬赏令成功", true, inApp},
+	{NotificationTypeBountyApplied, "收到悬赏令申请", true, inAppAndEmail},
+	{NotificationTypeApplicationApproved, "申请被批准", true, inAppAndEmail},
+	{NotificationTypeApplicationRejected, "申请被拒绝", true, inApp},
+	{NotificationTypeMilestoneSubmitted, "里程碑已提交", true, inApp},
+	{NotificationTypeMilestoneConfirmed, "里程碑已确认", true, inApp},
+	{NotificationTypeSettlementApplied, "收到清算申请", true, inAppAndEmail},
+	{NotificationTypeSettlementCompleted, "清算完成", true, inAppAndEmail},
+	{NotificationTypeBountySettled, "赏金到账", true, inAppAndEmail},
+	{NotificationTypeBountyCancelled, "悬赏令被取消", true, inAppAndEmail},
+	{NotificationTypeBountyLiked, "悬赏令被点赞", true, inApp},
+	{NotificationTypeBountyCommented, "悬赏令收到评论", true, inApp},
+	{NotificationTypeUserRated, "收到评价", true, inApp},
+	{NotificationTypeInvitationReceived, "收到组队邀请", true, inAppAndEmail},
+	{NotificationTypeInvitationAccepted, "组队邀请被接受", true, inApp},
+	{NotificationTypeInvitationRejected, "组队邀请被拒绝", true, inApp},
+	{NotificationTypeInvitationCancelled, "组队邀请被撤回", true, inApp},
+	{NotificationTypeInvitationExpired, "组队邀请已过期", true, inApp},
+	{NotificationTypeSubmissionRejected, "里程碑成果被驳回", true, inAppAndEmail},
+	{NotificationTypeSplitPlanUpdated, "赏金分配方式变更", true, inAppAndEmail},
+}
+
+// Webhook 渠道默认开启，实际是否投递由用户注册的 Webhook 订阅的事件决定
+var (
+	inApp         = map[NotificationChannel]bool{NotificationChannelInApp: true, NotificationChannelWebhook: true}
+	inAppAndEmail = map[NotificationChannel]bool{NotificationChannelInApp: true, NotificationChannelEmail: true, NotificationChannelWebhook: true}
+)
+
+// LookupNotificationType 查找通知类型的说明
+func LookupNotificationType(t NotificationType) (NotificationTypeInfo, bool) {
+	for _, info := range NotificationCatalogue {
+		if info.Type == t {
+			return info, true
+		}
+	}
+	return NotificationTypeInfo{}, false
+}
+
+// ValidNotificationChannel 判断是否为已定义的投递渠道
+func ValidNotificationChannel(c NotificationChannel) bool {
+	for _, channel := range NotificationChannels {
+		if channel == c {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// 领域事件类型，也可以作为 Webhook 订阅的事件名
+const (
+	EventBountyCreated           = "bounty.created"
+	EventBountyStatusChanged     = "bounty.status_changed"
+	EventBountySettled           = "bounty.settled"
+	EventBountyForceCancelled    = "bounty.force_cancelled"
+	EventApplicationApproved     = "application.approved"
+	EventApplicationRejected     = "application.rejected"
+	EventInvitationSent          = "invitation.sent"
+	EventInvitationAccepted      = "invitation.accepted"
+	EventInvitationRejected      = "invitation.rejected"
+	EventInvitationCancelled     = "invitation.cancelled"
+	EventInvitationExpired       = "invitation.expired"
+	EventMilestoneSubmitted      = "milestone.submitted"
+	EventSubmissionAccepted      = "milestone.submission_accepted"
+	EventSubmissionRejected      = "milestone.submission_rejected"
+	EventMilestoneRewardReleased = "milestone.reward_released"
+	EventSplitPlanUpdated        = "bounty.split_updated"
+)
+
+// DomainEventTypes 所有领域事件类型
+var DomainEventTypes = []string{
+	EventBountyCreated,
+	EventBountyStatusChanged,
+	EventBountySettled,
+	EventBountyForceCancelled,
+	EventApplicationApproved,
+	EventApplicationRejected,
+	EventInvitationSent,
+	EventInvitationAccepted,
+	EventInvitationRejected,
+	EventInvitationCancelled,
+	EventInvitationExpired,
+	EventMilestoneSubmitted,
+	EventSubmissionAccepted,
+	EventSubmissionRejected,
+	EventMilestoneRewardReleased,
+	EventSplitPlanUpdated,
+}
+
+// OutboxEvent 事务性发件箱中的领域事件，与触发它的状态变更在同一事务中写入，
+// 由后台分发器投递给各个消费者，保证至少投递一次
+//
+// 状态：ProcessedAt 不为空表示所有消费者都已处理；ProcessedAt 与 NextAttemptAt 都为空表示重试次数用尽
+type OutboxEvent struct {
+	BaseModel
+	Type               string         `gorm:"size:100;not null;index" json:"type"`
+	AggregateType      string         `gorm:"size:50;not null" json:"aggregate_type"` // 事件所属的聚合，如 Bounty、Application
+	AggregateID        uuid.UUID      `gorm:"type:uuid;not null;index" json:"aggregate_id"`
+	Payload            string         `gorm:"type:text;not null" json:"payload"` // JSON
+	Attempts           int            `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt      *time.Time     `gorm:"index" json:"next_attempt_at"`
+	CompletedConsumers pq.StringArray `gorm:"type:text[]" json:"completed_consumers"` // 已成功处理的消费者，重试时跳过
+	ProcessedAt        *time.Time     `gorm:"index" json:"processed_at"`
+	LastError          string         `gorm:"type:text" json:"last_error"`
+}
+
+// Completed 判断某个消费者是否已经处理过该事件
+func (e *OutboxEvent) Completed(consumer string) bool {
+	for _, name := range e.CompletedConsumers {
+		if name == consumer {
+			return true
+		}
+	}
+	return false
+}
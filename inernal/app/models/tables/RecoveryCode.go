@@ -0,0 +1,14 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// RecoveryCode 两步验证的恢复码，丢失验证器时可代替 TOTP 验证码登录，每个只能使用一次
+type RecoveryCode struct {
+	BaseModel
+	UserID   uuid.UUID  `gorm:"type:uuid;not null;index"`
+	CodeHash string     `gorm:"type:varchar(64);not null;index"` // 只保存摘要
+	UsedAt   *time.Time // 使用时间，为空表示尚未使用
+}
@@ -0,0 +1,67 @@
+package tables
+
+// Role 用户角色
+type Role string
+
+const (
+	RoleUser      Role = "user"      // 普通用户
+	RoleModerator Role = "moderator" // 版主：处理违规内容与悬赏令
+	RoleAdmin     Role = "admin"     // 管理员：拥有全部权限
+)
+
+// Permission 可授予用户的操作权限
+type Permission string
+
+const (
+	PermissionUsersUnlock            Permission = "users.unlock"            // 解除账号登录锁定
+	PermissionUsersSuspend           Permission = "users.suspend"           // 封禁与解封用户
+	PermissionUsersManageRoles       Permission = "users.manage_roles"      // 修改用户角色
+	PermissionBountiesForceCancel    Permission = "bounties.force_cancel"   // 强制取消悬赏令
+	PermissionCommentsDelete         Permission = "comments.delete"         // 删除任意评论
+	PermissionNotificationsBroadcast Permission = "notifications.broadcast" // 向所有用户广播通知
+	PermissionWalletsCredit          Permission = "wallets.credit"          // 确认线下或第三方支付到账后为用户钱包入账
+)
+
+// rolePermissions 各角色默认拥有的权限，管理员拥有全部权限
+var rolePermissions = map[Role][]Permission{
+	RoleUser: {},
+	RoleModerator: {
+		PermissionBountiesForceCancel,
+		PermissionCommentsDelete,
+	},
+	RoleAdmin: {
+		PermissionUsersUnlock,
+		PermissionUsersSuspend,
+		PermissionUsersManageRoles,
+		PermissionBountiesForceCancel,
+		PermissionCommentsDelete,
+		PermissionNotificationsBroadcast,
+		PermissionWalletsCredit,
+	},
+}
+
+// Valid 判断是否为已定义的角色
+func (r Role) Valid() bool {
+	_, ok := rolePermissions[r]
+	return ok
+}
+
+// Permissions 返回角色默认拥有的权限
+func (r Role) Permissions() []Permission {
+	return rolePermissions[r]
+}
+
+// HasPermission 判断用户通过角色或单独授予是否拥有指定权限
+func (u *User) HasPermission(permission Permission) bool {
+	for _, p := range u.Role.Permissions() {
+		if p == permission {
+			return true
+		}
+	}
+	for _, p := range u.Permissions {
+		if Permission(p) == permission {
+			return true
+		}
+	}
+	return false
+}
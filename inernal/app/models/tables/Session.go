@@ -0,0 +1,25 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// Session 登录会话，每次登录创建一条，保存刷新令牌的摘要
+// 刷新令牌每使用一次就轮换，旧令牌摘要保存在 PreviousTokenHash 中用于发现令牌被盗用
+type Session struct {
+	BaseModel
+	UserID            uuid.UUID  `gorm:"type:uuid;not null;index"`
+	RefreshTokenHash  string     `gorm:"type:varchar(64);not null;uniqueIndex"`
+	PreviousTokenHash string     `gorm:"type:varchar(64);index"`
+	ExpiresAt         time.Time  `gorm:"not null"`
+	RevokedAt         *time.Time // 注销或被吊销的时间，为空表示会话有效
+	LastUsedAt        time.Time
+	UserAgent         string `gorm:"type:varchar(255)"`
+	IPAddress         string `gorm:"type:varchar(64)"`
+}
+
+// IsActive 判断会话在指定时间是否仍然有效
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,48 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+)
+
+// SplitRule 结算时托管赏金在接收者之间的分配方式
+type SplitRule string
+
+const (
+	SplitRuleEqual      SplitRule = "equal"      // 所有接收者平分
+	SplitRulePercentage SplitRule = "percentage" // 按开工前约定的固定比例分配，见 BountySplitShare
+	SplitRuleMilestone  SplitRule = "milestone"  // 按里程碑归属分配，每个里程碑占相同份额，见 Milestone.OwnerID
+)
+
+// Valid 判断是否为已知的分配方式
+func (r SplitRule) Valid() bool {
+	switch r {
+	case SplitRuleEqual, SplitRulePercentage, SplitRuleMilestone:
+		return true
+	}
+	return false
+}
+
+// BountySplitShare 按比例分配时每个接收者约定的比例，同一悬赏令的比例之和为 100
+type BountySplitShare struct {
+	BaseModel
+	BountyID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bounty_split_share" json:"bounty_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_bounty_split_share" json:"user_id"`
+	Percentage float64   `gorm:"not null" json:"percentage"`
+}
+
+// SettlementLineItem 结算明细，记录每个接收者按何种规则分得多少赏金
+type SettlementLineItem struct {
+	BaseModel
+	BountyID   uuid.UUID `gorm:"type:uuid;not null;index" json:"bounty_id"`
+	UserID     uuid.UUID `gorm:"type:uuid;not null;index" json:"user_id"`
+	Rule       SplitRule `gorm:"type:varchar(20);not null" json:"rule"`
+	Percentage float64   `gorm:"not null" json:"percentage"`
+	Amount     float64   `gorm:"not null" json:"amount"`
+	Memo       string    `json:"memo"` // 按里程碑分配时列出归属的里程碑
+
+	// 里程碑验收时发放阶段赏金的明细记录对应的里程碑，最终结算的明细为空
+	MilestoneID *uuid.UUID `gorm:"type:uuid;index" json:"milestone_id"`
+
+	// 关联
+	User User `gorm:"foreignKey:UserID;references:ID" json:"user,omitempty"`
+}
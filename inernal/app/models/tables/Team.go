@@ -0,0 +1,37 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// TeamRole 团队成员的角色
+type TeamRole string
+
+const (
+	TeamRoleLeader TeamRole = "leader" // 队长，每个团队只有一个，可以邀请、移除成员并以团队身份申请悬赏令
+	TeamRoleMember TeamRole = "member"
+)
+
+// Team 由接受组队邀请的极客组成的团队，可以作为一个整体申请悬赏令
+type Team struct {
+	BaseModel
+	Name        string    `gorm:"size:100;not null" json:"name"`
+	Description string    `gorm:"type:text" json:"description"`
+	LeaderID    uuid.UUID `gorm:"type:uuid;not null;index" json:"leader_id"`
+
+	// 关联
+	Members []TeamMember `gorm:"foreignKey:TeamID;references:ID" json:"members,omitempty"`
+}
+
+// TeamMember 团队成员，队长也是成员之一
+type TeamMember struct {
+	BaseModel
+	TeamID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_team_member" json:"team_id"`
+	UserID   uuid.UUID `gorm:"type:uuid;not null;uniqueIndex:idx_team_member;index" json:"user_id"`
+	Role     TeamRole  `gorm:"type:varchar(20);not null;default:'member'" json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+
+	// 关联
+	User User `gorm:"foreignKey:UserID;references:ID" json:"user"`
+}
@@ -5,23 +5,36 @@ import (
 	"time"
 )
 
+// 账号状态
+const (
+	AccountStatusActive    = "active"
+	AccountStatusSuspended = "suspended"
+	AccountStatusDeleted   = "deleted"
+)
+
 type User struct {
 	BaseModel
-	Username             string    `gorm:"uniqueIndex;not null"`
-	Email                string    `gorm:"uniqueIndex;not null"`
-	Password             string    `gorm:"not null"`
-	LastLogin            time.Time `gorm:"index"`
-	AccountStatus        string    `gorm:"default:'active'"` // "active", "suspended", "deleted"
-	Verified             bool      `gorm:"default:false"`
-	NotificationsEnabled bool      `gorm:"default:true"`
-	ProfilePicture       string    `gorm:"type:text"`
+	Username             string          `gorm:"uniqueIndex;not null"`
+	Email                string          `gorm:"uniqueIndex;not null"`
+	Password             string          `gorm:"not null"`
+	LastLogin            time.Time       `gorm:"index"`
+	AccountStatus        string          `gorm:"default:'active'"` // "active", "suspended", "deleted"
+	Role                 Role            `gorm:"type:varchar(20);default:'user';not null;index"`
+	Permissions          pq.StringArray  `gorm:"type:text[]"` // 在角色之外单独授予的权限
+	Verified             bool            `gorm:"default:false"`
+	NotificationsEnabled bool            `gorm:"default:true"`
+	DigestFrequency      DigestFrequency `gorm:"type:varchar(10);default:'daily'"` // 未读通知摘要邮件的发送频率
+	ProfilePicture       string          `gorm:"type:text"`
 
 	Preferences        map[string]string `gorm:"type:jsonb"`
 	EmailPreferences   map[string]bool   `gorm:"type:jsonb"`
 	ContactPreferences map[string]bool   `gorm:"type:jsonb"`
 	SecurityQuestions  map[string]string `gorm:"type:jsonb"`
 	TwoFactorEnabled   bool              `gorm:"default:false"`
+	TwoFactorSecret    string            `gorm:"type:varchar(64)" json:"-"` // TOTP 密钥（base32），开始绑定时生成，确认后才启用
+	TwoFactorCounter   int64             `gorm:"default:0" json:"-"`        // 最近一次通过校验的 TOTP 时间步，防止验证码重放
 	LoginAttempts      int               `gorm:"default:0"`
+	LockedUntil        *time.Time        `json:"-"` // 登录失败次数过多时的临时锁定截止时间
 	LastPasswordChange time.Time
 	Timezone           string `gorm:"default:'UTC'"`
 	PreferredLanguage  string `gorm:"default:'en'"`
@@ -0,0 +1,24 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"time"
+)
+
+// UserTokenPurpose 一次性令牌的用途
+type UserTokenPurpose string
+
+const (
+	UserTokenEmailVerification UserTokenPurpose = "email_verification"
+	UserTokenPasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken 通过邮件发送的一次性令牌（邮箱验证、重置密码），只保存令牌摘要
+type UserToken struct {
+	BaseModel
+	UserID    uuid.UUID        `gorm:"type:uuid;not null;index"`
+	Purpose   UserTokenPurpose `gorm:"type:varchar(32);not null"`
+	TokenHash string           `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ExpiresAt time.Time        `gorm:"not null"`
+	UsedAt    *time.Time       // 使用时间，为空表示尚未使用
+}
@@ -0,0 +1,58 @@
+package tables
+
+import (
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"time"
+)
+
+// WebhookEventPing POST /webhooks/:id/test 发送的测试事件，不需要订阅
+const WebhookEventPing = "ping"
+
+// Webhook 用户注册的外部回调地址，事件名与通知类型一致（如 BountyCreated、ApplicationApproved）
+type Webhook struct {
+	BaseModel
+	UserID      uuid.UUID      `gorm:"type:uuid;not null;index" json:"user_id"`
+	URL         string         `gorm:"type:text;not null" json:"url"`
+	Secret      string         `gorm:"type:varchar(64);not null" json:"-"` // 用于 HMAC-SHA256 签名，仅在创建时返回一次
+	Events      pq.StringArray `gorm:"type:text[]" json:"events"`          // 订阅的事件，为空时订阅全部事件
+	Description string         `gorm:"size:255" json:"description"`
+	Active      bool           `gorm:"not null" json:"active"` // 停用后不再投递新事件，未完成的重试也会放弃
+}
+
+// Subscribed 判断 Webhook 是否订阅了某个事件
+func (w *Webhook) Subscribed(event string) bool {
+	if len(w.Events) == 0 {
+		return true
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDeliveryStatus 投递状态
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // 等待投递或等待重试
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // 对方返回 2xx
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"    // 重试次数用尽或 Webhook 已停用
+)
+
+// WebhookDelivery 一次事件投递及其最近一次尝试的结果，作为投递日志展示给用户
+type WebhookDelivery struct {
+	BaseModel
+	WebhookID      uuid.UUID             `gorm:"type:uuid;not null;index" json:"webhook_id"`
+	Event          string                `gorm:"size:100;not null" json:"event"`
+	Payload        string                `gorm:"type:text;not null" json:"payload"`
+	Status         WebhookDeliveryStatus `gorm:"type:varchar(20);not null;index:idx_webhook_delivery_due" json:"status"`
+	Attempts       int                   `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time            `gorm:"index:idx_webhook_delivery_due" json:"next_attempt_at"` // 下一次尝试的时间，不再重试时为空
+	ResponseStatus int                   `json:"response_status"`
+	Error          string                `gorm:"type:text" json:"error"`
+	DurationMs     int64                 `json:"duration_ms"`
+	DeliveredAt    *time.Time            `json:"delivered_at"`
+}
@@ -1,18 +1,28 @@
 package repositories
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
+	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// ErrApplicationNotPending 申请已被处理
+var ErrApplicationNotPending = errors.New("只能批准待处理的申请")
+
 type ApplicationRepository interface {
+	WithTx(tx *gorm.DB) ApplicationRepository
 	Create(application *tables.Application) error
 	FindAllByBountyID(bountyID uuid.UUID) ([]tables.Application, error)
+	FindPageByBountyID(bountyID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Application], error)
 	UpdateApplicationStatus(applicationID uuid.UUID, status string) error
 	GetApprovedApplicationsByBountyID(bountyID uuid.UUID) ([]*tables.Application, error)
 	HasUserApplied(bountyID uuid.UUID, UserID uuid.UUID) (bool, error)
-	ApproveApplication(applicationID uuid.UUID, receiverID uuid.UUID) error
+	// HasTeamApplied 团队是否已有待处理或已批准的申请
+	HasTeamApplied(bountyID, teamID uuid.UUID) (bool, error)
+	// ApproveApplication 批准仍处于待处理状态的申请并拒绝同一悬赏令的其他待处理申请，悬赏令的状态由调用方经状态机保存
+	ApproveApplication(app *tables.Application) error
 	FindByID(applicationID uuid.UUID) (*tables.Application, error)
 }
 
@@ -24,6 +34,11 @@ func NewApplicationRepository(db *gorm.DB) ApplicationRepository {
 	return &applicationRepository{db: db}
 }
 
+// WithTx 返回绑定到指定事务的仓库实例
+func (r *applicationRepository) WithTx(tx *gorm.DB) ApplicationRepository {
+	return &applicationRepository{db: tx}
+}
+
 func (r *applicationRepository) FindByID(applicationID uuid.UUID) (*tables.Application, error) {
 	var app tables.Application
 	err := r.db.Where("id = ?", applicationID).Preload("User").First(&app).Error
@@ -33,39 +48,23 @@ func (r *applicationRepository) FindByID(applicationID uuid.UUID) (*tables.Appli
 	return &app, nil
 }
 
-func (r *applicationRepository) ApproveApplication(applicationID uuid.UUID, receiverID uuid.UUID) error {
+func (r *applicationRepository) ApproveApplication(app *tables.Application) error {
 	return r.db.Transaction(func(tx *gorm.DB) error {
-		// 1. 更新申请状态为 "approved"
-		if err := tx.Model(&tables.Application{}).
-			Where("id = ?", applicationID).
-			Update("status", "approved").Error; err != nil {
-			return err
-		}
-
-		// 2. 更新对应的悬赏令 receiver_id
-		// 获取申请信息，确保申请与悬赏令关联
-		var app tables.Application
-		if err := tx.Where("id = ?", applicationID).First(&app).Error; err != nil {
-			return err
+		// 仅当申请仍为 pending 时批准，避免并发批准
+		result := tx.Model(&tables.Application{}).
+			Where("id = ? AND status = ?", app.ID, "pending").
+			Update("status", "approved")
+		if result.Error != nil {
+			return result.Error
 		}
-
-		if err := tx.Model(&tables.Bounty{}).
-			Where("id = ?", app.BountyID).
-			Updates(map[string]interface{}{
-				"receiver_id": app.UserID,
-				"status":      "assigned", // 可选：更新悬赏令状态
-			}).Error; err != nil {
-			return err
+		if result.RowsAffected == 0 {
+			return ErrApplicationNotPending
 		}
 
-		// 可选：将其他 pending 的申请状态更新为 "rejected"
-		if err := tx.Model(&tables.Application{}).
+		// 将同一悬赏令其他 pending 的申请更新为 "rejected"
+		return tx.Model(&tables.Application{}).
 			Where("bounty_id = ? AND status = ?", app.BountyID, "pending").
-			Update("status", "rejected").Error; err != nil {
-			return err
-		}
-
-		return nil
+			Update("status", "rejected").Error
 	})
 }
 
@@ -81,6 +80,17 @@ func (r *applicationRepository) HasUserApplied(bountyID uuid.UUID, UserID uuid.U
 	return count > 0, nil
 }
 
+func (r *applicationRepository) HasTeamApplied(bountyID, teamID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&tables.Application{}).
+		Where("bounty_id = ? AND team_id = ? AND status IN ?", bountyID, teamID, []string{"pending", "approved"}).
+		Count(&count).Error
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (r *applicationRepository) Create(application *tables.Application) error {
 	return r.db.Create(application).Error
 }
@@ -95,6 +105,12 @@ func (r *applicationRepository) FindAllByBountyID(bountyID uuid.UUID) ([]tables.
 	return apps, err
 }
 
+// FindPageByBountyID 分页获取悬赏令的申请，默认最新的在前
+func (r *applicationRepository) FindPageByBountyID(bountyID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Application], error) {
+	query := r.db.Model(&tables.Application{}).Where("bounty_id = ?", bountyID)
+	return paginate[tables.Application](query, page, createdAtSorts, SortNewest, "User", "Team")
+}
+
 func (r *applicationRepository) UpdateApplicationStatus(applicationID uuid.UUID, status string) error {
 	return r.db.Model(&tables.Application{}).
 		Where("id = ?", applicationID).
@@ -105,6 +121,7 @@ func (r *applicationRepository) GetApprovedApplicationsByBountyID(bountyID uuid.
 	var apps []*tables.Application
 	err := r.db.Where("bounty_id = ? AND status = ?", bountyID, "approved").
 		Preload("User").
+		Preload("Team").
 		Order("created_at desc").
 		Find(&apps).Error
 	return apps, err
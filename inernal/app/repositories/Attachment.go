@@ -0,0 +1,139 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+type AttachmentRepository interface {
+	WithTx(tx *gorm.DB) AttachmentRepository
+	Create(attachment *tables.Attachment) error
+	Update(attachment *tables.Attachment) error
+	// FindByID 查找附件，不存在时返回 gorm.ErrRecordNotFound
+	FindByID(id uuid.UUID) (*tables.Attachment, error)
+	// FindPendingByKey 按对象键查找尚未完成直传的附件，不存在时返回 gorm.ErrRecordNotFound
+	FindPendingByKey(key string) (*tables.Attachment, error)
+	// Delete 物理删除附件记录，文件由调用方从对象存储中删除
+	Delete(attachment *tables.Attachment) error
+	// SumSizeByOwner 统计用户上传的附件总字节数
+	SumSizeByOwner(ownerID uuid.UUID) (int64, error)
+	// LinkToBounty 将用户上传的、尚未关联的附件关联到悬赏令，返回关联的数量
+	LinkToBounty(ownerID, bountyID uuid.UUID, ids []uuid.UUID) (int64, error)
+	// LinkToMilestone 将用户上传的附件关联到里程碑，仅处理尚未关联或只关联了同一悬赏令的附件，返回关联的数量
+	LinkToMilestone(ownerID, bountyID, milestoneID uuid.UUID, ids []uuid.UUID) (int64, error)
+	// FindOrphans 查找 before 之前上传、从未关联、未完成直传或关联的悬赏令、里程碑已被删除的附件
+	FindOrphans(before time.Time, limit int) ([]tables.Attachment, error)
+
+	// AcceptNDA 记录用户接受悬赏令的保密协议，重复接受时不报错
+	AcceptNDA(acceptance *tables.BountyNDAAcceptance) error
+	HasAcceptedNDA(bountyID, userID uuid.UUID) (bool, error)
+}
+
+type attachmentRepository struct {
+	db *gorm.DB
+}
+
+func NewAttachmentRepository(db *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: db}
+}
+
+// WithTx 返回绑定到指定事务的仓库实例
+func (r *attachmentRepository) WithTx(tx *gorm.DB) AttachmentRepository {
+	return &attachmentRepository{db: tx}
+}
+
+func (r *attachmentRepository) Create(attachment *tables.Attachment) error {
+	return r.db.Create(attachment).Error
+}
+
+func (r *attachmentRepository) Update(attachment *tables.Attachment) error {
+	return r.db.Save(attachment).Error
+}
+
+func (r *attachmentRepository) FindByID(id uuid.UUID) (*tables.Attachment, error) {
+	var attachment tables.Attachment
+	if err := r.db.First(&attachment, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *attachmentRepository) FindPendingByKey(key string) (*tables.Attachment, error) {
+	var attachment tables.Attachment
+	if err := r.db.First(&attachment, "key = ? AND sha256 = ''", key).Error; err != nil {
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+func (r *attachmentRepository) Delete(attachment *tables.Attachment) error {
+	return r.db.Unscoped().Delete(attachment).Error
+}
+
+func (r *attachmentRepository) SumSizeByOwner(ownerID uuid.UUID) (int64, error) {
+	var total int64
+	err := r.db.Model(&tables.Attachment{}).
+		Where("owner_id = ?", ownerID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+func (r *attachmentRepository) LinkToBounty(ownerID, bountyID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(&tables.Attachment{}).
+		Where("id IN ? AND owner_id = ? AND bounty_id IS NULL AND milestone_id IS NULL", ids, ownerID).
+		Update("bounty_id", bountyID)
+	return result.RowsAffected, result.Error
+}
+
+func (r *attachmentRepository) LinkToMilestone(ownerID, bountyID, milestoneID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Model(&tables.Attachment{}).
+		Where("id IN ? AND owner_id = ? AND milestone_id IS NULL AND (bounty_id IS NULL OR bounty_id = ?)", ids, ownerID, bountyID).
+		Updates(map[string]interface{}{
+			"bounty_id":    bountyID,
+			"milestone_id": milestoneID,
+		})
+	return result.RowsAffected, result.Error
+}
+
+func (r *attachmentRepository) FindOrphans(before time.Time, limit int) ([]tables.Attachment, error) {
+	var attachments []tables.Attachment
+	err := r.db.
+		Where("created_at < ?", before).
+		Where(r.db.
+			Where("bounty_id IS NULL AND milestone_id IS NULL").
+			Or("sha256 = ''").
+			Or("bounty_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM bounties WHERE bounties.id = attachments.bounty_id AND bounties.deleted_at IS NULL)").
+			Or("milestone_id IS NOT NULL AND NOT EXISTS (SELECT 1 FROM milestones WHERE milestones.id = attachments.milestone_id AND milestones.deleted_at IS NULL)")).
+		Order("created_at").
+		Limit(limit).
+		Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *attachmentRepository) AcceptNDA(acceptance *tables.BountyNDAAcceptance) error {
+	var existing int64
+	err := r.db.Model(&tables.BountyNDAAcceptance{}).
+		Where("bounty_id = ? AND user_id = ?", acceptance.BountyID, acceptance.UserID).
+		Count(&existing).Error
+	if err != nil || existing > 0 {
+		return err
+	}
+	return r.db.Create(acceptance).Error
+}
+
+func (r *attachmentRepository) HasAcceptedNDA(bountyID, userID uuid.UUID) (bool, error) {
+	var count int64
+	err := r.db.Model(&tables.BountyNDAAcceptance{}).
+		Where("bounty_id = ? AND user_id = ?", bountyID, userID).
+		Count(&count).Error
+	return count > 0, err
+}
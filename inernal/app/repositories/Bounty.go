@@ -5,22 +5,36 @@ import (
 	"GeekReward/inernal/app/models/tables"
 	"errors"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"gorm.io/gorm"
 )
 
 // BountyRepository 定义关于 Bounty 的数据访问接口
 type BountyRepository interface {
+	WithTx(tx *gorm.DB) BountyRepository
 	CreateBounty(bounty *tables.Bounty) error
-	FindBounties(filters dtos.BountyFilter) ([]tables.Bounty, error)
+	FindBounties(filters dtos.BountyFilter, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error)
+	SearchBounties(query dtos.BountySearchQuery) ([]tables.Bounty, int64, error)
+	CountBountyFacet(query dtos.BountySearchQuery, column string) ([]dtos.FacetCount, error)
 	FindBountyByID(id uuid.UUID) (*tables.Bounty, error)
 	UpdateBounty(bounty *tables.Bounty) error
-	DeleteBounty(bounty *tables.Bounty) error
+	// UpdateBountyStatus 仅当悬赏令仍处于 fromStatus 时写入状态转换涉及的字段，状态已被修改时返回 false
+	UpdateBountyStatus(bounty *tables.Bounty, fromStatus tables.BountyStatus) (bool, error)
+	// DeleteUnassignedBounty 删除尚未指派接收者的悬赏令，悬赏令已被指派或已离开创建状态时不删除并返回 false
+	DeleteUnassignedBounty(bounty *tables.Bounty) (bool, error)
+	// UpdatePaymentStatus 只更新悬赏令的付款状态，包括已删除的悬赏令
+	UpdatePaymentStatus(bountyID uuid.UUID, status string) error
 	IncrementField(bountyID uuid.UUID, fieldName string) error
-	FindByUserID(userID uuid.UUID) ([]tables.Bounty, error)
-	FindReceivedByUserID(userID uuid.UUID) ([]tables.Bounty, error)
+	FindByUserID(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error)
+	FindReceivedByUserID(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error)
+	// CountActiveByReceiverTeam 统计团队接下的、尚未结算或取消的悬赏令数量
+	CountActiveByReceiverTeam(teamID uuid.UUID) (int64, error)
 	GetCommentsByBountyID(bountyID uuid.UUID) ([]tables.Comment, error)
+	FindCommentsPage(bountyID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Comment], error)
 	AddLike(like *tables.Like) error
 	AddComment(comment *tables.Comment) error
+	FindCommentByID(id uuid.UUID) (*tables.Comment, error)
+	DeleteComment(comment *tables.Comment) error
 	AddRating(rating *tables.Rating) error
 	FindByIDWithUsers(id uuid.UUID) (*tables.Bounty, error)
 	IsBountyLikedByUser(userID, bountyID uuid.UUID) (bool, error)
@@ -33,6 +47,8 @@ type BountyRepository interface {
 	UpdateRating(rating *tables.Rating) error
 	GetRatingsByBountyID(bountyID uuid.UUID, ratings *[]tables.Rating) error
 	UpdateBountyRating(bountyID uuid.UUID, avgScore float64, reviewCount int) error
+	CreateStatusChange(change *tables.BountyStatusChange) error
+	FindStatusChangesByBountyID(bountyID uuid.UUID) ([]tables.BountyStatusChange, error)
 }
 
 // 定义 bountyRepository 对象并介入全局变量 db，在接下来的数据操作方法中实现对数据库操作主体的引用
@@ -45,9 +61,12 @@ func NewBountyRepository(db *gorm.DB) BountyRepository {
 	return &bountyRepository{db: db}
 }
 
-func (r *bountyRepository) FindBounties(filters dtos.BountyFilter) ([]tables.Bounty, error) {
-	var bounties []tables.Bounty
+// WithTx 返回绑定到指定事务的仓库实例
+func (r *bountyRepository) WithTx(tx *gorm.DB) BountyRepository {
+	return &bountyRepository{db: tx}
+}
 
+func (r *bountyRepository) FindBounties(filters dtos.BountyFilter, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error) {
 	query := r.db.Model(&tables.Bounty{})
 
 	// 如果有 status
@@ -63,20 +82,103 @@ func (r *bountyRepository) FindBounties(filters dtos.BountyFilter) ([]tables.Bou
 		query = query.Where("receiver_id = ?", *filters.ReceiverID)
 	}
 
-	// 设置分页
-	if filters.Limit > 0 {
-		query = query.Limit(filters.Limit)
+	return paginate[tables.Bounty](query, page, bountySorts, SortNewest)
+}
+
+// 可以作为分面统计的列
+const (
+	BountyFacetCategory        = "category"
+	BountyFacetDifficultyLevel = "difficulty_level"
+)
+
+// SearchBounties 全文检索并按条件筛选公开的悬赏令，返回当前页及命中总数
+// 有关键词时按相关度排序，否则按创建时间倒序
+func (r *bountyRepository) SearchBounties(query dtos.BountySearchQuery) ([]tables.Bounty, int64, error) {
+	var bounties []tables.Bounty
+	var total int64
+
+	db := applyBountySearchFilters(r.db.Model(&tables.Bounty{}), query, "")
+	if err := db.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if query.Q != "" {
+		db = db.Order(gorm.Expr("ts_rank("+tables.BountySearchVector+", plainto_tsquery('simple', ?)) DESC", query.Q))
+	}
+	db = db.Order("created_at DESC")
+
+	if query.Limit > 0 {
+		db = db.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		db = db.Offset(query.Offset)
+	}
+
+	if err := db.Find(&bounties).Error; err != nil {
+		return nil, 0, err
 	}
-	if filters.Offset > 0 {
-		query = query.Offset(filters.Offset)
+	return bounties, total, nil
+}
+
+// CountBountyFacet 统计某一列每个取值命中的悬赏令数量
+// 统计时忽略该列自身的筛选条件，便于前端展示切换到其他取值后的结果数
+func (r *bountyRepository) CountBountyFacet(query dtos.BountySearchQuery, column string) ([]dtos.FacetCount, error) {
+	if column != BountyFacetCategory && column != BountyFacetDifficultyLevel {
+		return nil, errors.New("不支持的分面字段")
 	}
 
-	// 执行查询
-	err := query.Find(&bounties).Error
+	var facets []dtos.FacetCount
+	err := applyBountySearchFilters(r.db.Model(&tables.Bounty{}), query, column).
+		Select(column + " AS value, COUNT(*) AS count").
+		Where(column + " <> ''").
+		Group(column).
+		Order("count DESC, value ASC").
+		Scan(&facets).Error
 	if err != nil {
 		return nil, err
 	}
-	return bounties, nil
+	return facets, nil
+}
+
+// applyBountySearchFilters 将搜索条件拼接到查询上，skipFacet 指定的分面列不参与筛选
+func applyBountySearchFilters(db *gorm.DB, query dtos.BountySearchQuery, skipFacet string) *gorm.DB {
+	// 私有悬赏令不出现在搜索结果中
+	db = db.Where("visibility <> ?", "private")
+
+	if query.Q != "" {
+		db = db.Where(tables.BountySearchVector+" @@ plainto_tsquery('simple', ?)", query.Q)
+	}
+	if len(query.Tags) > 0 {
+		db = db.Where("tags && ?", pq.StringArray(query.Tags))
+	}
+	if len(query.Skills) > 0 {
+		db = db.Where("required_skills && ?", pq.StringArray(query.Skills))
+	}
+	if len(query.Categories) > 0 && skipFacet != BountyFacetCategory {
+		db = db.Where("category IN ?", query.Categories)
+	}
+	if len(query.DifficultyLevel) > 0 && skipFacet != BountyFacetDifficultyLevel {
+		db = db.Where("difficulty_level IN ?", query.DifficultyLevel)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if query.Location != "" {
+		db = db.Where("location = ?", query.Location)
+	}
+	if query.MinReward != nil {
+		db = db.Where("reward >= ?", *query.MinReward)
+	}
+	if query.MaxReward != nil {
+		db = db.Where("reward <= ?", *query.MaxReward)
+	}
+	if query.DeadlineFrom != nil {
+		db = db.Where("deadline >= ?", *query.DeadlineFrom)
+	}
+	if query.DeadlineTo != nil {
+		db = db.Where("deadline < ?", *query.DeadlineTo)
+	}
+	return db
 }
 
 func (r *bountyRepository) CreateBounty(bounty *tables.Bounty) error {
@@ -93,24 +195,48 @@ func (r *bountyRepository) UpdateBounty(bounty *tables.Bounty) error {
 	return r.db.Save(bounty).Error
 }
 
-func (r *bountyRepository) DeleteBounty(bounty *tables.Bounty) error {
-	return r.db.Delete(bounty).Error
+func (r *bountyRepository) UpdateBountyStatus(bounty *tables.Bounty, fromStatus tables.BountyStatus) (bool, error) {
+	result := r.db.Model(&tables.Bounty{}).
+		Where("id = ? AND status = ?", bounty.ID, fromStatus).
+		Updates(map[string]interface{}{
+			"status":           bounty.Status,
+			"payment_status":   bounty.PaymentStatus,
+			"receiver_id":      bounty.ReceiverID,
+			"receiver_team_id": bounty.ReceiverTeamID,
+			"updated_at":       bounty.UpdatedAt,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *bountyRepository) DeleteUnassignedBounty(bounty *tables.Bounty) (bool, error) {
+	result := r.db.Where("status = ? AND receiver_id IS NULL", tables.BountyStatusCreated).Delete(bounty)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *bountyRepository) UpdatePaymentStatus(bountyID uuid.UUID, status string) error {
+	return r.db.Unscoped().Model(&tables.Bounty{}).Where("id = ?", bountyID).Update("payment_status", status).Error
 }
 
 func (r *bountyRepository) IncrementField(bountyID uuid.UUID, fieldName string) error {
 	return r.db.Model(&tables.Bounty{}).Where("id = ?", bountyID).Update(fieldName, gorm.Expr(fieldName+" + ?", 1)).Error
 }
 
-func (r *bountyRepository) FindByUserID(userID uuid.UUID) ([]tables.Bounty, error) {
-	var bounties []tables.Bounty
-	err := r.db.Where("user_id = ?", userID).Find(&bounties).Error
-	return bounties, err
+func (r *bountyRepository) FindByUserID(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error) {
+	query := r.db.Model(&tables.Bounty{}).Where("user_id = ?", userID)
+	return paginate[tables.Bounty](query, page, bountySorts, SortNewest)
 }
 
-func (r *bountyRepository) FindReceivedByUserID(userID uuid.UUID) ([]tables.Bounty, error) {
-	var bounties []tables.Bounty
-	err := r.db.Where("receiver_id = ?", userID).Find(&bounties).Error
-	return bounties, err
+func (r *bountyRepository) FindReceivedByUserID(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error) {
+	query := r.db.Model(&tables.Bounty{}).Where("receiver_id = ?", userID)
+	return paginate[tables.Bounty](query, page, bountySorts, SortNewest)
+}
+
+func (r *bountyRepository) CountActiveByReceiverTeam(teamID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&tables.Bounty{}).
+		Where("receiver_team_id = ? AND status NOT IN ?", teamID, []tables.BountyStatus{tables.BountyStatusSettled, tables.BountyStatusCancelled}).
+		Count(&count).Error
+	return count, err
 }
 
 func (r *bountyRepository) GetCommentsByBountyID(bountyID uuid.UUID) ([]tables.Comment, error) {
@@ -124,6 +250,12 @@ func (r *bountyRepository) GetCommentsByBountyID(bountyID uuid.UUID) ([]tables.C
 	return comments, err
 }
 
+// FindCommentsPage 分页获取悬赏令的评论，默认最新的在前
+func (r *bountyRepository) FindCommentsPage(bountyID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Comment], error) {
+	query := r.db.Model(&tables.Comment{}).Where("bounty_id = ?", bountyID)
+	return paginate[tables.Comment](query, page, createdAtSorts, SortNewest, "User")
+}
+
 func (r *bountyRepository) AddLike(like *tables.Like) error {
 	return r.db.Create(like).Error
 }
@@ -132,6 +264,16 @@ func (r *bountyRepository) AddComment(comment *tables.Comment) error {
 	return r.db.Create(comment).Error
 }
 
+func (r *bountyRepository) FindCommentByID(id uuid.UUID) (*tables.Comment, error) {
+	var comment tables.Comment
+	err := r.db.First(&comment, "id = ?", id).Error
+	return &comment, err
+}
+
+func (r *bountyRepository) DeleteComment(comment *tables.Comment) error {
+	return r.db.Delete(comment).Error
+}
+
 func (r *bountyRepository) AddRating(rating *tables.Rating) error {
 	return r.db.Create(rating).Error
 }
@@ -200,3 +342,18 @@ func (r *bountyRepository) UpdateBountyRating(bountyID uuid.UUID, avgScore float
 		"review_count":   reviewCount,
 	}).Error
 }
+
+// CreateStatusChange 写入一条悬赏令状态变更记录
+func (r *bountyRepository) CreateStatusChange(change *tables.BountyStatusChange) error {
+	return r.db.Create(change).Error
+}
+
+// FindStatusChangesByBountyID 按时间顺序获取悬赏令的所有状态变更记录
+func (r *bountyRepository) FindStatusChangesByBountyID(bountyID uuid.UUID) ([]tables.BountyStatusChange, error) {
+	var changes []tables.BountyStatusChange
+	err := r.db.Where("bounty_id = ?", bountyID).
+		Preload("Actor").
+		Order("created_at asc").
+		Find(&changes).Error
+	return changes, err
+}
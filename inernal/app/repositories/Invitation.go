@@ -1,17 +1,29 @@
 package repositories
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
 type InvitationRepository interface {
-	GetInvitation(inviterID, inviteeID uuid.UUID) (*tables.Invitation, error)
+	WithTx(tx *gorm.DB) InvitationRepository
+	// FindPendingInvitation 查找邀请者发给受邀者的、仍在等待处理的邀请，没有时返回 nil
+	FindPendingInvitation(inviterID, inviteeID uuid.UUID) (*tables.Invitation, error)
 	CreateInvitation(invitation *tables.Invitation) error
 	GetInvitationByID(id uuid.UUID) (*tables.Invitation, error)
-	UpdateInvitation(invitation *tables.Invitation) error
+	// TransitionStatus 仅当邀请仍处于 Pending 状态时更新为 status，返回是否更新成功，
+	// 避免接受、拒绝、取消与过期清理之间的竞争
+	TransitionStatus(invitation *tables.Invitation, status string, respondedAt time.Time) (bool, error)
+	// FindSentPage 分页获取用户发出的邀请，status 为空时返回全部
+	FindSentPage(inviterID uuid.UUID, status string, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error)
+	// FindReceivedPage 分页获取用户收到的邀请，status 为空时返回全部
+	FindReceivedPage(inviteeID uuid.UUID, status string, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error)
+	// FindExpiredPending 获取截至 now 已过期但仍为 Pending 的邀请
+	FindExpiredPending(now time.Time, limit int) ([]tables.Invitation, error)
 }
 
 type invitationRepository struct {
@@ -22,9 +34,16 @@ func NewInvitationRepository(db *gorm.DB) InvitationRepository {
 	return &invitationRepository{db: db}
 }
 
-func (r *invitationRepository) GetInvitation(inviterID, inviteeID uuid.UUID) (*tables.Invitation, error) {
+// WithTx 返回绑定到指定事务的仓库实例
+func (r *invitationRepository) WithTx(tx *gorm.DB) InvitationRepository {
+	return &invitationRepository{db: tx}
+}
+
+func (r *invitationRepository) FindPendingInvitation(inviterID, inviteeID uuid.UUID) (*tables.Invitation, error) {
 	var invitation tables.Invitation
-	if err := r.db.First(&invitation, "inviter_id = ? AND invitee_id = ?", inviterID, inviteeID).Error; err != nil {
+	err := r.db.First(&invitation, "inviter_id = ? AND invitee_id = ? AND status = ?",
+		inviterID, inviteeID, tables.InvitationStatusPending).Error
+	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, nil
 		}
@@ -45,6 +64,48 @@ func (r *invitationRepository) GetInvitationByID(id uuid.UUID) (*tables.Invitati
 	return &invitation, nil
 }
 
-func (r *invitationRepository) UpdateInvitation(invitation *tables.Invitation) error {
-	return r.db.Save(invitation).Error
+func (r *invitationRepository) TransitionStatus(invitation *tables.Invitation, status string, respondedAt time.Time) (bool, error) {
+	result := r.db.Model(&tables.Invitation{}).
+		Where("id = ? AND status = ?", invitation.ID, tables.InvitationStatusPending).
+		Updates(map[string]interface{}{
+			"status":       status,
+			"team_id":      invitation.TeamID,
+			"responded_at": respondedAt,
+			"updated_at":   respondedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	invitation.Status = status
+	invitation.RespondedAt = &respondedAt
+	invitation.UpdatedAt = respondedAt
+	return true, nil
+}
+
+func (r *invitationRepository) FindSentPage(inviterID uuid.UUID, status string, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error) {
+	query := r.db.Model(&tables.Invitation{}).Where("inviter_id = ?", inviterID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return paginate[tables.Invitation](query, page, createdAtSorts, SortNewest, "Invitee", "Team", "Bounty")
+}
+
+func (r *invitationRepository) FindReceivedPage(inviteeID uuid.UUID, status string, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error) {
+	query := r.db.Model(&tables.Invitation{}).Where("invitee_id = ?", inviteeID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	return paginate[tables.Invitation](query, page, createdAtSorts, SortNewest, "Inviter", "Team", "Bounty")
+}
+
+func (r *invitationRepository) FindExpiredPending(now time.Time, limit int) ([]tables.Invitation, error) {
+	var invitations []tables.Invitation
+	err := r.db.Where("status = ? AND expires_at < ?", tables.InvitationStatusPending, now).
+		Order("expires_at").
+		Limit(limit).
+		Find(&invitations).Error
+	return invitations, err
 }
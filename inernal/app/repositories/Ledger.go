@@ -0,0 +1,128 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/pkg/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"math"
+)
+
+var (
+	// ErrInsufficientBalance 账户余额不足以完成本次记账
+	ErrInsufficientBalance = errors.New("账户余额不足")
+	// ErrUnbalancedEntry 分录借贷不平衡
+	ErrUnbalancedEntry = errors.New("记账分录借贷不平衡")
+)
+
+type LedgerRepository interface {
+	// WithTx 返回绑定到指定事务的仓库实例
+	WithTx(tx *gorm.DB) LedgerRepository
+	// GetOrCreateAccount 获取指定类型与归属的账户，不存在时自动创建
+	GetOrCreateAccount(accountType tables.LedgerAccountType, ownerID uuid.UUID) (*tables.LedgerAccount, error)
+	// FindAccount 获取指定类型与归属的账户，不存在时返回 nil
+	FindAccount(accountType tables.LedgerAccountType, ownerID uuid.UUID) (*tables.LedgerAccount, error)
+	// PostEntry 写入一笔分录并同步更新所涉及账户的余额
+	PostEntry(entry *tables.JournalEntry) error
+	// FindLinesByAccountID 分页获取某个账户的分录行（含所属分录），默认按时间倒序
+	FindLinesByAccountID(accountID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.JournalLine], error)
+	// SumEscrowByPublisher 统计某个发布者所有悬赏令托管账户的余额之和
+	SumEscrowByPublisher(userID uuid.UUID) (float64, error)
+}
+
+type ledgerRepository struct {
+	db *gorm.DB
+}
+
+func NewLedgerRepository(db *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: db}
+}
+
+func (r *ledgerRepository) WithTx(tx *gorm.DB) LedgerRepository {
+	return &ledgerRepository{db: tx}
+}
+
+func (r *ledgerRepository) FindAccount(accountType tables.LedgerAccountType, ownerID uuid.UUID) (*tables.LedgerAccount, error) {
+	var account tables.LedgerAccount
+	err := r.db.Where("type = ? AND owner_id = ?", accountType, ownerID).First(&account).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+func (r *ledgerRepository) GetOrCreateAccount(accountType tables.LedgerAccountType, ownerID uuid.UUID) (*tables.LedgerAccount, error) {
+	account := tables.LedgerAccount{Type: accountType, OwnerID: ownerID}
+	// 并发创建时依赖唯一索引，冲突则忽略后再查询
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&account).Error; err != nil {
+		return nil, err
+	}
+	var existing tables.LedgerAccount
+	if err := r.db.Where("type = ? AND owner_id = ?", accountType, ownerID).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+func (r *ledgerRepository) PostEntry(entry *tables.JournalEntry) error {
+	if len(entry.Lines) < 2 {
+		return ErrUnbalancedEntry
+	}
+	var sum float64
+	for i := range entry.Lines {
+		entry.Lines[i].Amount = utils.RoundCents(entry.Lines[i].Amount)
+		sum += entry.Lines[i].Amount
+	}
+	if math.Abs(sum) >= 0.005 {
+		return ErrUnbalancedEntry
+	}
+
+	// 若调用方已处于事务中，Transaction 会以保存点的方式嵌套执行
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, line := range entry.Lines {
+			// 加行锁，避免并发记账导致余额计算错误
+			var account tables.LedgerAccount
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				First(&account, "id = ?", line.AccountID).Error; err != nil {
+				return err
+			}
+
+			balance := utils.RoundCents(account.Balance + line.Amount)
+			// 除外部资金账户外，任何账户都不允许透支
+			if balance < 0 && account.Type != tables.LedgerAccountExternal {
+				return fmt.Errorf("%w: 账户 %s 需要 %.2f，当前余额 %.2f", ErrInsufficientBalance, account.Type, -line.Amount, account.Balance)
+			}
+
+			if err := tx.Model(&tables.LedgerAccount{}).
+				Where("id = ?", account.ID).
+				Update("balance", balance).Error; err != nil {
+				return err
+			}
+		}
+
+		// 同时写入分录与分录行
+		return tx.Create(entry).Error
+	})
+}
+
+func (r *ledgerRepository) FindLinesByAccountID(accountID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.JournalLine], error) {
+	query := r.db.Model(&tables.JournalLine{}).Where("account_id = ?", accountID)
+	return paginate[tables.JournalLine](query, page, createdAtSorts, SortNewest, "Entry")
+}
+
+func (r *ledgerRepository) SumEscrowByPublisher(userID uuid.UUID) (float64, error) {
+	var total float64
+	err := r.db.Model(&tables.LedgerAccount{}).
+		Joins("JOIN bounties ON bounties.id = ledger_accounts.owner_id AND bounties.deleted_at IS NULL").
+		Where("ledger_accounts.type = ? AND bounties.user_id = ?", tables.LedgerAccountEscrow, userID).
+		Select("COALESCE(SUM(ledger_accounts.balance), 0)").
+		Scan(&total).Error
+	return total, err
+}
@@ -5,19 +5,35 @@ import (
 	"errors"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"time"
 )
 
 type MilestoneRepository interface {
+	// WithTx 返回绑定到指定事务的仓库实例
+	WithTx(tx *gorm.DB) MilestoneRepository
 	// FindByBountyID 根据悬赏令 ID 获取所有相关的里程碑
 	FindByBountyID(bountyID uuid.UUID) ([]tables.Milestone, error)
 	// FindByID 根据里程碑 ID 获取单个里程碑
 	FindByID(id uuid.UUID) (*tables.Milestone, error)
 	// CreateMilestone 悬赏令发布者创建里程碑
 	CreateMilestone(milestone *tables.Milestone) error
-	// DeleteMilestone 悬赏令发布者删除某个里程碑
-	DeleteMilestone(milestone *tables.Milestone) error
+	// DeleteMilestone 悬赏令发布者删除某个里程碑，仅当里程碑尚未通过验收且未发放阶段赏金时删除，返回是否删除成功
+	DeleteMilestone(milestone *tables.Milestone) (bool, error)
 	// UpdateMilestone 悬赏令发布者更新某个里程碑
 	UpdateMilestone(milestone *tables.Milestone) error
+	// MarkRewardReleased 仅当阶段赏金尚未发放时记录发放时间，返回是否更新成功，避免重复发放
+	MarkRewardReleased(milestoneID uuid.UUID, at time.Time) (bool, error)
+
+	// CreateSubmission 保存接收者提交的里程碑成果
+	CreateSubmission(submission *tables.MilestoneSubmission) error
+	// FindSubmissionByID 根据 ID 获取成果提交，不存在时返回 nil
+	FindSubmissionByID(id uuid.UUID) (*tables.MilestoneSubmission, error)
+	// FindLatestSubmission 获取里程碑最近一轮的成果提交，没有提交时返回 nil
+	FindLatestSubmission(milestoneID uuid.UUID) (*tables.MilestoneSubmission, error)
+	// FindSubmissions 按轮次获取里程碑的全部成果提交，预加载提交者与审核者
+	FindSubmissions(milestoneID uuid.UUID) ([]tables.MilestoneSubmission, error)
+	// ReviewSubmission 仅当提交仍处于 Pending 状态时写入审核结果，返回是否更新成功，避免重复审核
+	ReviewSubmission(submission *tables.MilestoneSubmission) (bool, error)
 }
 
 type milestoneRepository struct {
@@ -28,6 +44,10 @@ func NewMilestoneRepository(db *gorm.DB) MilestoneRepository {
 	return &milestoneRepository{db: db}
 }
 
+func (r *milestoneRepository) WithTx(tx *gorm.DB) MilestoneRepository {
+	return &milestoneRepository{db: tx}
+}
+
 func (r *milestoneRepository) FindByBountyID(bountyID uuid.UUID) ([]tables.Milestone, error) {
 	var milestones []tables.Milestone
 	err := r.db.Where("bounty_id = ?", bountyID).Find(&milestones).Error
@@ -63,6 +83,66 @@ func (r *milestoneRepository) UpdateMilestone(milestone *tables.Milestone) error
 	return r.db.Save(milestone).Error
 }
 
-func (r *milestoneRepository) DeleteMilestone(milestone *tables.Milestone) error {
-	return r.db.Delete(milestone).Error
+func (r *milestoneRepository) MarkRewardReleased(milestoneID uuid.UUID, at time.Time) (bool, error) {
+	result := r.db.Model(&tables.Milestone{}).
+		Where("id = ? AND reward_released_at IS NULL", milestoneID).
+		Update("reward_released_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *milestoneRepository) DeleteMilestone(milestone *tables.Milestone) (bool, error) {
+	result := r.db.
+		Where("is_completed = ? AND reward_released_at IS NULL", false).
+		Where("NOT EXISTS (SELECT 1 FROM milestone_submissions WHERE milestone_submissions.milestone_id = milestones.id AND milestone_submissions.status = ?)", tables.SubmissionStatusAccepted).
+		Delete(milestone)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *milestoneRepository) CreateSubmission(submission *tables.MilestoneSubmission) error {
+	return r.db.Create(submission).Error
+}
+
+func (r *milestoneRepository) FindSubmissionByID(id uuid.UUID) (*tables.MilestoneSubmission, error) {
+	var submission tables.MilestoneSubmission
+	err := r.db.First(&submission, "id = ?", id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+func (r *milestoneRepository) FindLatestSubmission(milestoneID uuid.UUID) (*tables.MilestoneSubmission, error) {
+	var submission tables.MilestoneSubmission
+	err := r.db.Where("milestone_id = ?", milestoneID).Order("round DESC").First(&submission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &submission, nil
+}
+
+func (r *milestoneRepository) FindSubmissions(milestoneID uuid.UUID) ([]tables.MilestoneSubmission, error) {
+	var submissions []tables.MilestoneSubmission
+	err := r.db.Preload("Submitter").Preload("Reviewer").
+		Where("milestone_id = ?", milestoneID).
+		Order("round").
+		Find(&submissions).Error
+	return submissions, err
+}
+
+func (r *milestoneRepository) ReviewSubmission(submission *tables.MilestoneSubmission) (bool, error) {
+	result := r.db.Model(&tables.MilestoneSubmission{}).
+		Where("id = ? AND status = ?", submission.ID, tables.SubmissionStatusPending).
+		Updates(map[string]interface{}{
+			"status":      submission.Status,
+			"feedback":    submission.Feedback,
+			"reviewer_id": submission.ReviewerID,
+			"reviewed_at": submission.ReviewedAt,
+		})
+	return result.RowsAffected > 0, result.Error
 }
@@ -1,16 +1,41 @@
 package repositories
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type NotificationRepository interface {
 	CreateNotification(notification *tables.Notification) error
-	FindNotificationsByUserID(userID uuid.UUID) ([]tables.Notification, error)
-	MarkAsRead(notificationID uuid.UUID) error
-	DeleteNotification(notificationID uuid.UUID) error
+	// CreateEventNotification 创建由发件箱事件生成的通知，同一事件已通知过该接收者时跳过并返回 false
+	CreateEventNotification(notification *tables.Notification) (bool, error)
+	// CreateNotifications 批量创建通知
+	CreateNotifications(notifications []tables.Notification) error
+	FindNotificationsByUserID(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[tables.Notification], error)
+	// FindGroupsByUserID 按类型与关联资源折叠通知后分页
+	FindGroupsByUserID(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[dtos.NotificationGroup], error)
+	// FindUserNotification 查找属于该用户的通知，不存在或属于其他用户时返回 gorm.ErrRecordNotFound
+	FindUserNotification(userID, notificationID uuid.UUID) (*tables.Notification, error)
+	// FindUnreadCreatedBetween 返回用户在 [from, to) 内收到且仍未读的通知，按时间倒序
+	FindUnreadCreatedBetween(userID uuid.UUID, from, to time.Time) ([]tables.Notification, error)
+	// CountUnread 统计用户的未读通知数
+	CountUnread(userID uuid.UUID) (int64, error)
+	// CountUnreadByType 按类型统计用户的未读通知数
+	CountUnreadByType(userID uuid.UUID) (map[tables.NotificationType]int64, error)
+	// MarkAsRead 将用户的一条通知标记为已读，不属于该用户时不做修改
+	MarkAsRead(userID, notificationID uuid.UUID) error
+	// MarkAllAsRead 将用户符合筛选条件的未读通知全部标记为已读，返回受影响的条数
+	MarkAllAsRead(userID uuid.UUID, filter dtos.NotificationFilter) (int64, error)
+	// MarkManyAsRead 将用户的多条通知标记为已读，不属于该用户的ID会被忽略
+	MarkManyAsRead(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error)
+	// DeleteNotification 删除用户的一条通知，不属于该用户时不做修改
+	DeleteNotification(userID, notificationID uuid.UUID) error
+	// DeleteNotifications 删除用户的多条通知，不属于该用户的ID会被忽略
+	DeleteNotifications(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error)
 }
 
 type notificationRepository struct {
@@ -25,16 +50,134 @@ func (r *notificationRepository) CreateNotification(notification *tables.Notific
 	return r.db.Create(notification).Error
 }
 
-func (r *notificationRepository) FindNotificationsByUserID(userID uuid.UUID) ([]tables.Notification, error) {
+func (r *notificationRepository) CreateEventNotification(notification *tables.Notification) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "event_id"}},
+		DoNothing: true,
+	}).Create(notification)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *notificationRepository) CreateNotifications(notifications []tables.Notification) error {
+	if len(notifications) == 0 {
+		return nil
+	}
+	return r.db.CreateInBatches(notifications, 500).Error
+}
+
+// applyNotificationFilter 为通知查询追加筛选条件
+func applyNotificationFilter(query *gorm.DB, filter dtos.NotificationFilter) *gorm.DB {
+	if len(filter.Types) > 0 {
+		query = query.Where("type IN ?", filter.Types)
+	}
+	if filter.RelatedType != "" {
+		query = query.Where("related_type = ?", filter.RelatedType)
+	}
+	if filter.IsRead != nil {
+		query = query.Where("is_read = ?", *filter.IsRead)
+	}
+	if filter.CreatedFrom != nil {
+		query = query.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		query = query.Where("created_at < ?", *filter.CreatedTo)
+	}
+	return query
+}
+
+func (r *notificationRepository) FindNotificationsByUserID(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[tables.Notification], error) {
+	query := r.db.Model(&tables.Notification{}).Where("user_id = ?", userID)
+	query = applyNotificationFilter(query, filter)
+	return paginate[tables.Notification](query, page, createdAtSorts, SortNewest)
+}
+
+// FindGroupsByUserID 先在子查询中按 (type, related_type, related_id) 聚合，再对聚合结果做键集分页
+// 没有关联资源的通知以自身ID作为分组键，不会被折叠
+func (r *notificationRepository) FindGroupsByUserID(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[dtos.NotificationGroup], error) {
+	groups := r.db.Model(&tables.Notification{}).
+		Select(`(array_agg(id ORDER BY created_at DESC, id DESC))[1] AS id,
+			type,
+			related_type,
+			(array_agg(related_id))[1] AS related_id,
+			(array_agg(title ORDER BY created_at DESC, id DESC))[1] AS title,
+			(array_agg(description ORDER BY created_at DESC, id DESC))[1] AS description,
+			COUNT(*) AS count,
+			COUNT(*) FILTER (WHERE NOT is_read) AS unread_count,
+			COUNT(DISTINCT actor_id) AS actor_count,
+			(array_agg(actor_id ORDER BY created_at DESC, id DESC) FILTER (WHERE actor_id IS NOT NULL))[1] AS latest_actor_id,
+			array_agg(id::text ORDER BY created_at DESC, id DESC) AS ids,
+			MAX(created_at) AS created_at`).
+		Where("user_id = ?", userID)
+	groups = applyNotificationFilter(groups, filter).
+		Group("type, related_type, COALESCE(related_id, id)")
+
+	query := r.db.Table("(?) AS notification_groups", groups)
+	return paginate[dtos.NotificationGroup](query, page, createdAtSorts, SortNewest)
+}
+
+func (r *notificationRepository) FindUserNotification(userID, notificationID uuid.UUID) (*tables.Notification, error) {
+	var notification tables.Notification
+	err := r.db.First(&notification, "id = ? AND user_id = ?", notificationID, userID).Error
+	return &notification, err
+}
+
+func (r *notificationRepository) FindUnreadCreatedBetween(userID uuid.UUID, from, to time.Time) ([]tables.Notification, error) {
 	var notifications []tables.Notification
-	err := r.db.Where("user_id = ?", userID).Order("created_at desc").Find(&notifications).Error
+	err := r.db.Where("user_id = ? AND is_read = ? AND created_at >= ? AND created_at < ?", userID, false, from, to).
+		Order("created_at DESC").
+		Find(&notifications).Error
 	return notifications, err
 }
 
-func (r *notificationRepository) MarkAsRead(notificationID uuid.UUID) error {
-	return r.db.Model(&tables.Notification{}).Where("id = ?", notificationID).Update("is_read", true).Error
+func (r *notificationRepository) CountUnread(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&tables.Notification{}).Where("user_id = ? AND is_read = ?", userID, false).Count(&count).Error
+	return count, err
+}
+
+func (r *notificationRepository) CountUnreadByType(userID uuid.UUID) (map[tables.NotificationType]int64, error) {
+	var rows []struct {
+		Type  tables.NotificationType
+		Count int64
+	}
+	err := r.db.Model(&tables.Notification{}).
+		Select("type, COUNT(*) AS count").
+		Where("user_id = ? AND is_read = ?", userID, false).
+		Group("type").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[tables.NotificationType]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Type] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *notificationRepository) MarkAsRead(userID, notificationID uuid.UUID) error {
+	return r.db.Model(&tables.Notification{}).Where("id = ? AND user_id = ?", notificationID, userID).Update("is_read", true).Error
+}
+
+func (r *notificationRepository) MarkAllAsRead(userID uuid.UUID, filter dtos.NotificationFilter) (int64, error) {
+	query := r.db.Model(&tables.Notification{}).Where("user_id = ? AND is_read = ?", userID, false)
+	result := applyNotificationFilter(query, filter).Update("is_read", true)
+	return result.RowsAffected, result.Error
+}
+
+func (r *notificationRepository) MarkManyAsRead(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error) {
+	result := r.db.Model(&tables.Notification{}).
+		Where("user_id = ? AND id IN ? AND is_read = ?", userID, notificationIDs, false).
+		Update("is_read", true)
+	return result.RowsAffected, result.Error
+}
+
+func (r *notificationRepository) DeleteNotification(userID, notificationID uuid.UUID) error {
+	return r.db.Delete(&tables.Notification{}, "id = ? AND user_id = ?", notificationID, userID).Error
 }
 
-func (r *notificationRepository) DeleteNotification(notificationID uuid.UUID) error {
-	return r.db.Delete(&tables.Notification{}, "id = ?", notificationID).Error
+func (r *notificationRepository) DeleteNotifications(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error) {
+	result := r.db.Where("user_id = ? AND id IN ?", userID, notificationIDs).Delete(&tables.Notification{})
+	return result.RowsAffected, result.Error
 }
@@ -0,0 +1,54 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type NotificationDigestRepository interface {
+	// FindRecipients 返回开启了摘要邮件、邮箱已验证且自 since 起有未读通知的正常用户
+	FindRecipients(since time.Time) ([]tables.User, error)
+	// Claim 写入摘要记录占位，同一用户同一周期已有记录时返回 false
+	Claim(digest *tables.NotificationDigest) (bool, error)
+	// MarkSent 记录摘要的发送时间
+	MarkSent(digestID uuid.UUID, sentAt time.Time) error
+	// Release 删除未发送成功的摘要记录，使该周期可以重试
+	Release(digestID uuid.UUID) error
+}
+
+type notificationDigestRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationDigestRepository(db *gorm.DB) NotificationDigestRepository {
+	return &notificationDigestRepository{db: db}
+}
+
+func (r *notificationDigestRepository) FindRecipients(since time.Time) ([]tables.User, error) {
+	var users []tables.User
+	err := r.db.
+		Where("digest_frequency IN ?", []tables.DigestFrequency{tables.DigestFrequencyDaily, tables.DigestFrequencyWeekly}).
+		Where("notifications_enabled = ? AND verified = ? AND account_status = ?", true, true, tables.AccountStatusActive).
+		Where(`EXISTS (SELECT 1 FROM notifications
+			WHERE notifications.user_id = users.id AND NOT notifications.is_read
+			AND notifications.deleted_at IS NULL AND notifications.created_at >= ?)`, since).
+		Find(&users).Error
+	return users, err
+}
+
+func (r *notificationDigestRepository) Claim(digest *tables.NotificationDigest) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(digest)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *notificationDigestRepository) MarkSent(digestID uuid.UUID, sentAt time.Time) error {
+	return r.db.Model(&tables.NotificationDigest{}).Where("id = ?", digestID).Update("sent_at", sentAt).Error
+}
+
+func (r *notificationDigestRepository) Release(digestID uuid.UUID) error {
+	// 物理删除，否则软删除的记录仍会占用唯一索引
+	return r.db.Unscoped().Delete(&tables.NotificationDigest{}, "id = ?", digestID).Error
+}
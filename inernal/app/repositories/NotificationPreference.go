@@ -0,0 +1,47 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationPreferenceRepository interface {
+	// FindByUserID 返回用户设置过的全部偏好
+	FindByUserID(userID uuid.UUID) ([]tables.NotificationPreference, error)
+	// FindByUserAndType 返回用户对某类通知设置过的偏好
+	FindByUserAndType(userID uuid.UUID, notificationType tables.NotificationType) ([]tables.NotificationPreference, error)
+	// Upsert 按 (用户, 类型, 渠道) 新增或更新偏好
+	Upsert(preferences []tables.NotificationPreference) error
+}
+
+type notificationPreferenceRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationPreferenceRepository(db *gorm.DB) NotificationPreferenceRepository {
+	return &notificationPreferenceRepository{db: db}
+}
+
+func (r *notificationPreferenceRepository) FindByUserID(userID uuid.UUID) ([]tables.NotificationPreference, error) {
+	var preferences []tables.NotificationPreference
+	err := r.db.Where("user_id = ?", userID).Find(&preferences).Error
+	return preferences, err
+}
+
+func (r *notificationPreferenceRepository) FindByUserAndType(userID uuid.UUID, notificationType tables.NotificationType) ([]tables.NotificationPreference, error) {
+	var preferences []tables.NotificationPreference
+	err := r.db.Where("user_id = ? AND type = ?", userID, notificationType).Find(&preferences).Error
+	return preferences, err
+}
+
+func (r *notificationPreferenceRepository) Upsert(preferences []tables.NotificationPreference) error {
+	if len(preferences) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}, {Name: "channel"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&preferences).Error
+}
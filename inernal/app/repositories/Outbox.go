@@ -0,0 +1,71 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type OutboxRepository interface {
+	// WithTx 返回绑定到指定事务的仓库实例，事件必须与状态变更在同一事务中写入
+	WithTx(tx *gorm.DB) OutboxRepository
+	Create(events ...*tables.OutboxEvent) error
+	// ClaimDue 按创建顺序领取最多 limit 条到期的事件，并将其下一次尝试时间推迟 lease，避免被重复领取
+	ClaimDue(now time.Time, limit int, lease time.Duration) ([]tables.OutboxEvent, error)
+	Update(event *tables.OutboxEvent) error
+	// DeleteProcessedBefore 清理 before 之前已处理完的事件，返回删除的条数
+	DeleteProcessedBefore(before time.Time) (int64, error)
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: db}
+}
+
+func (r *outboxRepository) WithTx(tx *gorm.DB) OutboxRepository {
+	return &outboxRepository{db: tx}
+}
+
+func (r *outboxRepository) Create(events ...*tables.OutboxEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.Create(events).Error
+}
+
+func (r *outboxRepository) ClaimDue(now time.Time, limit int, lease time.Duration) ([]tables.OutboxEvent, error) {
+	var events []tables.OutboxEvent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("processed_at IS NULL AND next_attempt_at <= ?", now).
+			Order("created_at").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		return tx.Model(&tables.OutboxEvent{}).
+			Where("id IN ?", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).Error
+	})
+	return events, err
+}
+
+func (r *outboxRepository) Update(event *tables.OutboxEvent) error {
+	return r.db.Save(event).Error
+}
+
+func (r *outboxRepository) DeleteProcessedBefore(before time.Time) (int64, error) {
+	result := r.db.Unscoped().Where("processed_at < ?", before).Delete(&tables.OutboxEvent{})
+	return result.RowsAffected, result.Error
+}
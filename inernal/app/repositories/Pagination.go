@@ -0,0 +1,170 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"reflect"
+)
+
+var (
+	ErrInvalidCursor = errors.New("无效的分页游标")
+	ErrInvalidSort   = errors.New("不支持的排序方式")
+)
+
+// 列表接口支持的排序方式
+const (
+	SortNewest   = "newest"
+	SortOldest   = "oldest"
+	SortReward   = "reward"
+	SortDeadline = "deadline"
+	SortLikes    = "likes"
+	SortViews    = "views"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// sortKey 描述一种排序方式：数据库列、对应的结构体字段以及方向
+// 相同取值的记录再按 id 同向排序，保证游标位置唯一
+type sortKey struct {
+	column string
+	field  string
+	desc   bool
+}
+
+// createdAtSorts 只按创建时间排序的列表（评论、通知、申请、钱包流水）
+var createdAtSorts = map[string]sortKey{
+	SortNewest: {column: "created_at", field: "CreatedAt", desc: true},
+	SortOldest: {column: "created_at", field: "CreatedAt", desc: false},
+}
+
+// bountySorts 悬赏令列表支持的排序方式
+var bountySorts = map[string]sortKey{
+	SortNewest:   {column: "created_at", field: "CreatedAt", desc: true},
+	SortOldest:   {column: "created_at", field: "CreatedAt", desc: false},
+	SortReward:   {column: "reward", field: "Reward", desc: true},
+	SortDeadline: {column: "deadline", field: "Deadline", desc: false}, // 最先截止的排在前面
+	SortLikes:    {column: "likes_count", field: "LikesCount", desc: true},
+	SortViews:    {column: "view_count", field: "ViewCount", desc: true},
+}
+
+// pageCursor 游标内容，编码为 base64 后对客户端不透明
+type pageCursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uuid.UUID       `json:"id"`
+}
+
+// paginate 对查询做基于 (排序列, id) 的键集分页
+// db 只应包含筛选条件，Count 时不能带 Preload，预加载的关联通过 preloads 传入
+func paginate[T any](db *gorm.DB, query dtos.PageQuery, sorts map[string]sortKey, defaultSort string, preloads ...string) (*dtos.Page[T], error) {
+	sortName := query.Sort
+	if sortName == "" {
+		sortName = defaultSort
+	}
+	key, ok := sorts[sortName]
+	if !ok {
+		return nil, ErrInvalidSort
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	if limit > maxPageLimit {
+		limit = maxPageLimit
+	}
+
+	base := db.Session(&gorm.Session{})
+
+	var total int64
+	if err := base.Count(&total).Error; err != nil {
+		return nil, err
+	}
+
+	direction, compare := "ASC", ">"
+	if key.desc {
+		direction, compare = "DESC", "<"
+	}
+
+	list := base
+	if query.Cursor != "" {
+		value, id, err := decodeCursor[T](query.Cursor, sortName, key)
+		if err != nil {
+			return nil, err
+		}
+		list = list.Where("("+key.column+", id) "+compare+" (?, ?)", value, id)
+	}
+	for _, preload := range preloads {
+		list = list.Preload(preload)
+	}
+
+	// 多取一条用于判断是否还有下一页
+	var items []T
+	err := list.Order(key.column + " " + direction).
+		Order("id " + direction).
+		Limit(limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &dtos.Page[T]{Items: items, Total: total}
+	if len(items) > limit {
+		page.Items = items[:limit]
+		page.NextCursor, err = encodeCursor(&page.Items[limit-1], sortName, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	return page, nil
+}
+
+// encodeCursor 用一页中最后一条记录生成下一页的游标
+func encodeCursor[T any](item *T, sortName string, key sortKey) (string, error) {
+	record := reflect.ValueOf(item).Elem()
+	value, err := json.Marshal(record.FieldByName(key.field).Interface())
+	if err != nil {
+		return "", err
+	}
+	raw, err := json.Marshal(pageCursor{
+		Sort:  sortName,
+		Value: value,
+		ID:    record.FieldByName("ID").Interface().(uuid.UUID),
+	})
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// decodeCursor 解析游标，游标必须由同一种排序方式生成
+func decodeCursor[T any](encoded, sortName string, key sortKey) (any, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, uuid.Nil, ErrInvalidCursor
+	}
+	var cursor pageCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.Sort != sortName {
+		return nil, uuid.Nil, ErrInvalidCursor
+	}
+
+	field, ok := reflect.TypeOf((*T)(nil)).Elem().FieldByName(key.field)
+	if !ok {
+		return nil, uuid.Nil, ErrInvalidCursor
+	}
+	value := reflect.New(field.Type)
+	if err := json.Unmarshal(cursor.Value, value.Interface()); err != nil {
+		return nil, uuid.Nil, ErrInvalidCursor
+	}
+	return value.Elem().Interface(), cursor.ID, nil
+}
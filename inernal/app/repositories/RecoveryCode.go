@@ -0,0 +1,65 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// RecoveryCodeRepository 定义两步验证恢复码的数据访问接口
+type RecoveryCodeRepository interface {
+	// ReplaceForUser 删除用户原有的恢复码并写入新的一组
+	ReplaceForUser(userID uuid.UUID, codeHashes []string) error
+	// Consume 将匹配的未使用恢复码标记为已使用，没有匹配时返回 false
+	Consume(userID uuid.UUID, codeHash string) (bool, error)
+	// CountUnused 统计用户剩余可用的恢复码数量
+	CountUnused(userID uuid.UUID) (int64, error)
+	DeleteByUserID(userID uuid.UUID) error
+}
+
+type recoveryCodeRepository struct {
+	db *gorm.DB
+}
+
+func NewRecoveryCodeRepository(db *gorm.DB) RecoveryCodeRepository {
+	return &recoveryCodeRepository{db: db}
+}
+
+func (r *recoveryCodeRepository) ReplaceForUser(userID uuid.UUID, codeHashes []string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&tables.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		codes := make([]tables.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, tables.RecoveryCode{UserID: userID, CodeHash: hash})
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+func (r *recoveryCodeRepository) Consume(userID uuid.UUID, codeHash string) (bool, error) {
+	result := r.db.Model(&tables.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, codeHash).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *recoveryCodeRepository) CountUnused(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&tables.RecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *recoveryCodeRepository) DeleteByUserID(userID uuid.UUID) error {
+	return r.db.Unscoped().Where("user_id = ?", userID).Delete(&tables.RecoveryCode{}).Error
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// SessionRepository 定义登录会话的数据访问接口
+type SessionRepository interface {
+	Create(session *tables.Session) error
+	// FindByID 获取会话，不存在时返回 nil
+	FindByID(id uuid.UUID) (*tables.Session, error)
+	// FindByRefreshTokenHash 根据当前刷新令牌的摘要获取会话，不存在时返回 nil
+	FindByRefreshTokenHash(hash string) (*tables.Session, error)
+	// FindByPreviousTokenHash 根据已被轮换掉的刷新令牌摘要获取会话，不存在时返回 nil
+	FindByPreviousTokenHash(hash string) (*tables.Session, error)
+	// Rotate 用新的刷新令牌摘要替换当前摘要，旧摘要不匹配时返回 false
+	Rotate(session *tables.Session, newHash string, expiresAt time.Time) (bool, error)
+	Revoke(id uuid.UUID) error
+	RevokeAllByUserID(userID uuid.UUID) error
+}
+
+type sessionRepository struct {
+	db *gorm.DB
+}
+
+func NewSessionRepository(db *gorm.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+func (r *sessionRepository) Create(session *tables.Session) error {
+	return r.db.Create(session).Error
+}
+
+func (r *sessionRepository) FindByID(id uuid.UUID) (*tables.Session, error) {
+	return r.findOne("id = ?", id)
+}
+
+func (r *sessionRepository) FindByRefreshTokenHash(hash string) (*tables.Session, error) {
+	return r.findOne("refresh_token_hash = ?", hash)
+}
+
+func (r *sessionRepository) FindByPreviousTokenHash(hash string) (*tables.Session, error) {
+	return r.findOne("previous_token_hash = ?", hash)
+}
+
+func (r *sessionRepository) findOne(query string, args ...interface{}) (*tables.Session, error) {
+	var session tables.Session
+	err := r.db.Where(query, args...).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// Rotate 以当前摘要为条件更新，并发使用同一个刷新令牌时只有一个请求能成功
+func (r *sessionRepository) Rotate(session *tables.Session, newHash string, expiresAt time.Time) (bool, error) {
+	now := time.Now()
+	result := r.db.Model(&tables.Session{}).
+		Where("id = ? AND refresh_token_hash = ? AND revoked_at IS NULL", session.ID, session.RefreshTokenHash).
+		Updates(map[string]interface{}{
+			"previous_token_hash": session.RefreshTokenHash,
+			"refresh_token_hash":  newHash,
+			"expires_at":          expiresAt,
+			"last_used_at":        now,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	session.PreviousTokenHash = session.RefreshTokenHash
+	session.RefreshTokenHash = newHash
+	session.ExpiresAt = expiresAt
+	session.LastUsedAt = now
+	return true, nil
+}
+
+func (r *sessionRepository) Revoke(id uuid.UUID) error {
+	return r.db.Model(&tables.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+func (r *sessionRepository) RevokeAllByUserID(userID uuid.UUID) error {
+	return r.db.Model(&tables.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
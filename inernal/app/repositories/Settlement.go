@@ -0,0 +1,60 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type SettlementRepository interface {
+	WithTx(tx *gorm.DB) SettlementRepository
+	FindShares(bountyID uuid.UUID) ([]tables.BountySplitShare, error)
+	// ReplaceShares 用新的比例替换悬赏令原有的全部比例
+	ReplaceShares(bountyID uuid.UUID, shares []tables.BountySplitShare) error
+	CreateLineItems(items []tables.SettlementLineItem) error
+	FindLineItems(bountyID uuid.UUID) ([]tables.SettlementLineItem, error)
+}
+
+type settlementRepository struct {
+	db *gorm.DB
+}
+
+func NewSettlementRepository(db *gorm.DB) SettlementRepository {
+	return &settlementRepository{db: db}
+}
+
+// WithTx 返回绑定到指定事务的仓库实例
+func (r *settlementRepository) WithTx(tx *gorm.DB) SettlementRepository {
+	return &settlementRepository{db: tx}
+}
+
+func (r *settlementRepository) FindShares(bountyID uuid.UUID) ([]tables.BountySplitShare, error) {
+	var shares []tables.BountySplitShare
+	err := r.db.Where("bounty_id = ?", bountyID).Order("created_at").Find(&shares).Error
+	return shares, err
+}
+
+func (r *settlementRepository) ReplaceShares(bountyID uuid.UUID, shares []tables.BountySplitShare) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("bounty_id = ?", bountyID).Delete(&tables.BountySplitShare{}).Error; err != nil {
+			return err
+		}
+		if len(shares) == 0 {
+			return nil
+		}
+		return tx.Create(&shares).Error
+	})
+}
+
+func (r *settlementRepository) CreateLineItems(items []tables.SettlementLineItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	return r.db.Create(&items).Error
+}
+
+func (r *settlementRepository) FindLineItems(bountyID uuid.UUID) ([]tables.SettlementLineItem, error) {
+	var items []tables.SettlementLineItem
+	err := r.db.Where("bounty_id = ?", bountyID).Preload("User").Order("created_at").Find(&items).Error
+	return items, err
+}
@@ -0,0 +1,116 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type TeamRepository interface {
+	WithTx(tx *gorm.DB) TeamRepository
+	Create(team *tables.Team) error
+	// FindByID 查找团队并预加载成员，不存在时返回 gorm.ErrRecordNotFound
+	FindByID(teamID uuid.UUID) (*tables.Team, error)
+	// FindByUserID 返回用户所在的所有团队
+	FindByUserID(userID uuid.UUID) ([]tables.Team, error)
+	// FindLedByUserID 返回用户最早担任队长的团队，没有时返回 nil
+	FindLedByUserID(userID uuid.UUID) (*tables.Team, error)
+	Update(team *tables.Team) error
+	// Delete 删除团队及其全部成员
+	Delete(teamID uuid.UUID) error
+
+	AddMember(member *tables.TeamMember) error
+	// FindMember 查找团队中的某个成员，不是成员时返回 nil
+	FindMember(teamID, userID uuid.UUID) (*tables.TeamMember, error)
+	FindMembers(teamID uuid.UUID) ([]tables.TeamMember, error)
+	RemoveMember(teamID, userID uuid.UUID) error
+}
+
+type teamRepository struct {
+	db *gorm.DB
+}
+
+func NewTeamRepository(db *gorm.DB) TeamRepository {
+	return &teamRepository{db: db}
+}
+
+// WithTx 返回绑定到指定事务的仓库实例
+func (r *teamRepository) WithTx(tx *gorm.DB) TeamRepository {
+	return &teamRepository{db: tx}
+}
+
+func (r *teamRepository) Create(team *tables.Team) error {
+	return r.db.Create(team).Error
+}
+
+func (r *teamRepository) FindByID(teamID uuid.UUID) (*tables.Team, error) {
+	var team tables.Team
+	err := r.db.
+		Preload("Members", func(db *gorm.DB) *gorm.DB { return db.Order("joined_at") }).
+		Preload("Members.User").
+		First(&team, "id = ?", teamID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (r *teamRepository) FindByUserID(userID uuid.UUID) ([]tables.Team, error) {
+	var teams []tables.Team
+	err := r.db.
+		Where("id IN (?)", r.db.Model(&tables.TeamMember{}).Select("team_id").Where("user_id = ?", userID)).
+		Order("created_at").
+		Find(&teams).Error
+	return teams, err
+}
+
+func (r *teamRepository) FindLedByUserID(userID uuid.UUID) (*tables.Team, error) {
+	var team tables.Team
+	if err := r.db.Where("leader_id = ?", userID).Order("created_at").First(&team).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &team, nil
+}
+
+func (r *teamRepository) Update(team *tables.Team) error {
+	return r.db.Omit("Members").Save(team).Error
+}
+
+func (r *teamRepository) Delete(teamID uuid.UUID) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("team_id = ?", teamID).Delete(&tables.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tables.Team{}, "id = ?", teamID).Error
+	})
+}
+
+func (r *teamRepository) AddMember(member *tables.TeamMember) error {
+	return r.db.Create(member).Error
+}
+
+func (r *teamRepository) FindMember(teamID, userID uuid.UUID) (*tables.TeamMember, error) {
+	var member tables.TeamMember
+	if err := r.db.First(&member, "team_id = ? AND user_id = ?", teamID, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &member, nil
+}
+
+func (r *teamRepository) FindMembers(teamID uuid.UUID) ([]tables.TeamMember, error) {
+	var members []tables.TeamMember
+	err := r.db.Where("team_id = ?", teamID).Preload("User").Order("joined_at").Find(&members).Error
+	return members, err
+}
+
+// RemoveMember 物理删除成员记录，以便之后可以再次加入
+func (r *teamRepository) RemoveMember(teamID, userID uuid.UUID) error {
+	return r.db.Unscoped().Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&tables.TeamMember{}).Error
+}
@@ -0,0 +1,24 @@
+package repositories
+
+import (
+	"gorm.io/gorm"
+)
+
+// TransactionManager 用于在同一个数据库事务中组合多个仓库的操作
+// 在回调中通过 xxxRepo.WithTx(tx) 获取绑定到事务的仓库实例
+type TransactionManager interface {
+	Transaction(fn func(tx *gorm.DB) error) error
+}
+
+type transactionManager struct {
+	db *gorm.DB
+}
+
+func NewTransactionManager(db *gorm.DB) TransactionManager {
+	return &transactionManager{db: db}
+}
+
+// Transaction 回调返回错误时整个事务回滚
+func (m *transactionManager) Transaction(fn func(tx *gorm.DB) error) error {
+	return m.db.Transaction(fn)
+}
@@ -4,6 +4,8 @@ import (
 	"GeekReward/inernal/app/models/tables"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
 )
 
 type UserRepository interface {
@@ -12,6 +14,36 @@ type UserRepository interface {
 	FindByUsername(username string) (*tables.User, error)
 	FindByUserID(id uuid.UUID) (*tables.User, error)
 	UpdateUserProfile(user *tables.User) error
+	// IncrementLoginAttempts 登录失败次数加一，返回累计的失败次数
+	IncrementLoginAttempts(userID uuid.UUID) (int, error)
+	// LockUntil 将账号锁定到指定时间
+	LockUntil(userID uuid.UUID, until time.Time) error
+	// RecordLoginSuccess 登录成功后清零失败次数、解除锁定并更新最近登录时间
+	RecordLoginSuccess(userID uuid.UUID, at time.Time) error
+	// ResetLoginAttempts 清零失败次数并解除锁定
+	ResetLoginAttempts(userID uuid.UUID) error
+	// MarkVerified 将用户邮箱标记为已验证
+	MarkVerified(userID uuid.UUID) error
+	// UpdatePassword 更新密码哈希并记录修改时间
+	UpdatePassword(userID uuid.UUID, passwordHash string, at time.Time) error
+	// UpdateRole 修改用户角色
+	UpdateRole(userID uuid.UUID, role tables.Role) error
+	// UpdateAccountStatus 修改账号状态
+	UpdateAccountStatus(userID uuid.UUID, status string) error
+	// UpdateNotificationsEnabled 修改通知总开关
+	UpdateNotificationsEnabled(userID uuid.UUID, enabled bool) error
+	// UpdateDigestFrequency 修改未读通知摘要邮件的发送频率
+	UpdateDigestFrequency(userID uuid.UUID, frequency tables.DigestFrequency) error
+	// FindActiveUserIDs 返回所有状态正常的用户ID
+	FindActiveUserIDs() ([]uuid.UUID, error)
+	// AdvanceTOTPCounter 仅当 counter 大于已记录的时间步时更新，返回是否更新成功
+	AdvanceTOTPCounter(userID uuid.UUID, counter int64) (bool, error)
+	// UpdateProfilePicture 只更新头像地址
+	UpdateProfilePicture(userID uuid.UUID, profilePicture string) error
+	// UpdateTwoFactor 只更新两步验证的密钥、启用状态与时间步，用于生成密钥与关闭两步验证
+	UpdateTwoFactor(userID uuid.UUID, secret string, enabled bool, counter int64) error
+	// EnableTwoFactor 仅当两步验证未启用、密钥仍为 secret 且 counter 大于已记录的时间步时启用，返回是否启用成功
+	EnableTwoFactor(userID uuid.UUID, secret string, counter int64) (bool, error)
 }
 
 type userRepository struct {
@@ -47,3 +79,121 @@ func (r *userRepository) FindByUserID(id uuid.UUID) (*tables.User, error) {
 func (r *userRepository) UpdateUserProfile(user *tables.User) error {
 	return r.db.Save(user).Error
 }
+
+func (r *userRepository) AdvanceTOTPCounter(userID uuid.UUID, counter int64) (bool, error) {
+	result := r.db.Model(&tables.User{}).
+		Where("id = ? AND two_factor_counter < ?", userID, counter).
+		Update("two_factor_counter", counter)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *userRepository) IncrementLoginAttempts(userID uuid.UUID) (int, error) {
+	var user tables.User
+	result := r.db.Model(&user).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "login_attempts"}}}).
+		Where("id = ?", userID).
+		UpdateColumn("login_attempts", gorm.Expr("login_attempts + 1"))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return 0, gorm.ErrRecordNotFound
+	}
+	return user.LoginAttempts, nil
+}
+
+func (r *userRepository) LockUntil(userID uuid.UUID, until time.Time) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumn("locked_until", until).Error
+}
+
+func (r *userRepository) RecordLoginSuccess(userID uuid.UUID, at time.Time) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"login_attempts": 0,
+		"locked_until":   nil,
+		"last_login":     at,
+	}).Error
+}
+
+func (r *userRepository) ResetLoginAttempts(userID uuid.UUID) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"login_attempts": 0,
+		"locked_until":   nil,
+	}).Error
+}
+
+func (r *userRepository) MarkVerified(userID uuid.UUID) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumn("verified", true).Error
+}
+
+func (r *userRepository) UpdateProfilePicture(userID uuid.UUID, profilePicture string) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).Update("profile_picture", profilePicture).Error
+}
+
+func (r *userRepository) UpdateTwoFactor(userID uuid.UUID, secret string, enabled bool, counter int64) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"two_factor_secret":  secret,
+		"two_factor_enabled": enabled,
+		"two_factor_counter": counter,
+	}).Error
+}
+
+func (r *userRepository) EnableTwoFactor(userID uuid.UUID, secret string, counter int64) (bool, error) {
+	result := r.db.Model(&tables.User{}).
+		Where("id = ? AND two_factor_enabled = ? AND two_factor_secret = ? AND two_factor_counter < ?", userID, false, secret, counter).
+		UpdateColumns(map[string]interface{}{
+			"two_factor_enabled": true,
+			"two_factor_counter": counter,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *userRepository) UpdatePassword(userID uuid.UUID, passwordHash string, at time.Time) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumns(map[string]interface{}{
+		"password":             passwordHash,
+		"last_password_change": at,
+	}).Error
+}
+
+func (r *userRepository) UpdateRole(userID uuid.UUID, role tables.Role) error {
+	result := r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumn("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) UpdateAccountStatus(userID uuid.UUID, status string) error {
+	result := r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumn("account_status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (r *userRepository) FindActiveUserIDs() ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	err := r.db.Model(&tables.User{}).
+		Where("account_status = ?", tables.AccountStatusActive).
+		Pluck("id", &ids).Error
+	return ids, err
+}
+
+func (r *userRepository) UpdateNotificationsEnabled(userID uuid.UUID, enabled bool) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumn("notifications_enabled", enabled).Error
+}
+
+func (r *userRepository) UpdateDigestFrequency(userID uuid.UUID, frequency tables.DigestFrequency) error {
+	return r.db.Model(&tables.User{}).Where("id = ?", userID).UpdateColumn("digest_frequency", frequency).Error
+}
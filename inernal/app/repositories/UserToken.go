@@ -0,0 +1,52 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// UserTokenRepository 定义一次性令牌的数据访问接口
+type UserTokenRepository interface {
+	// Issue 作废用户同一用途下尚未使用的令牌，并保存新的令牌
+	Issue(token *tables.UserToken) error
+	// Consume 将未过期、未使用的令牌标记为已使用并返回，令牌无效时返回 nil
+	Consume(tokenHash string, purpose tables.UserTokenPurpose) (*tables.UserToken, error)
+}
+
+type userTokenRepository struct {
+	db *gorm.DB
+}
+
+func NewUserTokenRepository(db *gorm.DB) UserTokenRepository {
+	return &userTokenRepository{db: db}
+}
+
+func (r *userTokenRepository) Issue(token *tables.UserToken) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&tables.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", time.Now()).Error
+		if err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+}
+
+// Consume 以单条带条件的 UPDATE 完成校验与标记，同一令牌并发使用时只有一个请求成功
+func (r *userTokenRepository) Consume(tokenHash string, purpose tables.UserTokenPurpose) (*tables.UserToken, error) {
+	var tokens []tables.UserToken
+	result := r.db.Model(&tokens).
+		Clauses(clause.Returning{}).
+		Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return &tokens[0], nil
+}
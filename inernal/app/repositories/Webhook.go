@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+type WebhookRepository interface {
+	Create(webhook *tables.Webhook) error
+	// FindByID 查找 Webhook，不存在时返回 gorm.ErrRecordNotFound
+	FindByID(webhookID uuid.UUID) (*tables.Webhook, error)
+	FindByUserID(userID uuid.UUID) ([]tables.Webhook, error)
+	CountByUserID(userID uuid.UUID) (int64, error)
+	// FindSubscribers 返回用户所有订阅了该事件的启用中的 Webhook
+	FindSubscribers(userID uuid.UUID, event string) ([]tables.Webhook, error)
+	Update(webhook *tables.Webhook) error
+	Delete(webhookID uuid.UUID) error
+
+	CreateDelivery(delivery *tables.WebhookDelivery) error
+	UpdateDelivery(delivery *tables.WebhookDelivery) error
+	FindDeliveriesPage(webhookID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.WebhookDelivery], error)
+	// ClaimDueDeliveries 领取最多 limit 条已到期的待投递记录，并将其下一次尝试时间推迟 lease，
+	// 避免多个实例或下一轮检查重复投递；投递完成后由 UpdateDelivery 写回真实结果
+	ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]tables.WebhookDelivery, error)
+}
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &webhookRepository{db: db}
+}
+
+func (r *webhookRepository) Create(webhook *tables.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *webhookRepository) FindByID(webhookID uuid.UUID) (*tables.Webhook, error) {
+	var webhook tables.Webhook
+	if err := r.db.First(&webhook, "id = ?", webhookID).Error; err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+func (r *webhookRepository) FindByUserID(userID uuid.UUID) ([]tables.Webhook, error) {
+	var webhooks []tables.Webhook
+	err := r.db.Where("user_id = ?", userID).Order("created_at").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) CountByUserID(userID uuid.UUID) (int64, error) {
+	var count int64
+	err := r.db.Model(&tables.Webhook{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
+}
+
+func (r *webhookRepository) FindSubscribers(userID uuid.UUID, event string) ([]tables.Webhook, error) {
+	var webhooks []tables.Webhook
+	err := r.db.
+		Where("user_id = ? AND active = ?", userID, true).
+		Where("(events IS NULL OR cardinality(events) = 0 OR ? = ANY(events))", event).
+		Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *webhookRepository) Update(webhook *tables.Webhook) error {
+	return r.db.Save(webhook).Error
+}
+
+func (r *webhookRepository) Delete(webhookID uuid.UUID) error {
+	return r.db.Delete(&tables.Webhook{}, "id = ?", webhookID).Error
+}
+
+func (r *webhookRepository) CreateDelivery(delivery *tables.WebhookDelivery) error {
+	return r.db.Create(delivery).Error
+}
+
+func (r *webhookRepository) UpdateDelivery(delivery *tables.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+func (r *webhookRepository) FindDeliveriesPage(webhookID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.WebhookDelivery], error) {
+	query := r.db.Model(&tables.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	return paginate[tables.WebhookDelivery](query, page, createdAtSorts, SortNewest)
+}
+
+func (r *webhookRepository) ClaimDueDeliveries(now time.Time, limit int, lease time.Duration) ([]tables.WebhookDelivery, error) {
+	var deliveries []tables.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", tables.WebhookDeliveryPending, now).
+			Order("next_attempt_at").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uuid.UUID, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Model(&tables.WebhookDelivery{}).
+			Where("id IN ?", ids).
+			UpdateColumn("next_attempt_at", now.Add(lease)).Error
+	})
+	return deliveries, err
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"GeekReward/inernal/app/controllers"
 	"GeekReward/inernal/app/middlewares"
+	"GeekReward/inernal/app/models/tables"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
@@ -18,9 +19,16 @@ func SetupRouter(
 	milestoneController *controllers.MilestoneController,
 	invitationController *controllers.InvitationController,
 	attachmentController *controllers.AttachmentController,
+	walletController *controllers.WalletController,
+	twoFactorController *controllers.TwoFactorController,
+	adminController *controllers.AdminController,
+	realtimeController *controllers.RealtimeController,
+	webhookController *controllers.WebhookController,
+	teamController *controllers.TeamController,
 ) *gin.Engine {
-	// 创建Gin路由引擎实例
-	r := gin.Default()
+	// 创建Gin路由引擎实例，请求日志隐去查询参数中的访问令牌
+	r := gin.New()
+	r.Use(middlewares.Logger(), gin.Recovery())
 
 	// 配置CORS中间件，允许来自前端的跨域请求
 	r.Use(cors.New(cors.Config{
@@ -30,25 +38,42 @@ func SetupRouter(
 		AllowCredentials: true,
 	}))
 
-	r.Static("/uploads", "./uploads")
+	// 公开文件（头像）：/uploads 重定向到存储后端的预签名地址，/storage 为本地存储的预签名地址
+	r.GET("/uploads/*key", attachmentController.DownloadFile)
+	r.GET("/storage/*key", attachmentController.ServeLocalFile)
+	r.PUT("/storage/*key", attachmentController.ServeLocalFile)
 
 	// 注册路由和控制器
 	api := r.Group("")
 	// api.Use(middlewares.JWTAuthMiddleware())
 	{
 		// 处理文件上传
-		api.POST("/attachment", attachmentController.UploadAttachment)
+		api.POST("/attachment", middlewares.JWTAuthMiddleware(), attachmentController.UploadAttachment)                           // 上传附件（需JWT认证）
+		api.POST("/attachment/presign", middlewares.JWTAuthMiddleware(), attachmentController.PresignUpload)                      // 获取直传存储的上传地址（需JWT认证）
+		api.POST("/attachments/:attachment_id/complete", middlewares.JWTAuthMiddleware(), attachmentController.CompleteUpload)    // 确认直传完成（需JWT认证）
+		api.GET("/attachments/:attachment_id/download", middlewares.JWTAuthMiddleware(), attachmentController.DownloadAttachment) // 校验权限后下载附件（需JWT认证）
+		api.DELETE("/attachments/:attachment_id", middlewares.JWTAuthMiddleware(), attachmentController.DeleteAttachment)         // 上传者删除附件（需JWT认证）
 
 		// 用户认证相关路由
-		api.POST("/register", authController.Register) // 用户注册
-		api.POST("/login", authController.Login)       // 用户登录
+		api.POST("/register", authController.Register)                                                            // 用户注册
+		api.POST("/login", authController.Login)                                                                  // 用户登录
+		api.POST("/login/2fa", authController.LoginTwoFactor)                                                     // 两步登录：提交挑战令牌与验证码
+		api.POST("/token/refresh", authController.RefreshToken)                                                   // 使用刷新令牌换取新的访问令牌
+		api.POST("/logout", middlewares.JWTAuthMiddleware(), authController.Logout)                               // 注销当前会话（需JWT认证）
+		api.POST("/logout-all", middlewares.JWTAuthMiddleware(), authController.LogoutAll)                        // 注销所有会话（需JWT认证）
+		api.POST("/verify-email", authController.VerifyEmail)                                                     // 使用邮件中的令牌验证邮箱
+		api.POST("/verify-email/resend", middlewares.JWTAuthMiddleware(), authController.ResendVerificationEmail) // 重新发送验证邮件（需JWT认证）
+		api.POST("/password/forgot", authController.ForgotPassword)                                               // 发送重置密码邮件
+		api.POST("/password/reset", authController.ResetPassword)                                                 // 使用邮件中的令牌重置密码
 
 		// 悬赏令相关路由
-		// GET /bounties?status=Settled&publisher_id=...&receiver_id=...&limit=10&offset=0
+		// GET /bounties?status=Settled&publisher_id=...&receiver_id=...&sort=newest&limit=10&cursor=...
 		api.GET("/bounties", bountyController.GetBounties)                                                                       // 获取所有悬赏令
 		api.POST("/bounties", middlewares.JWTAuthMiddleware(), bountyController.CreateBounty)                                    // 创建悬赏令（需JWT认证）
+		api.GET("/bounties/search", bountyController.SearchBounties)                                                             // 全文检索与分面筛选悬赏令
 		api.GET("/bounties/:bounty_id", bountyController.GetBounty)                                                              // 获取指定悬赏令
 		api.GET("/bounties/:bounty_id/comments", bountyController.GetBountyComments)                                             // 获取指定悬赏令的评论
+		api.GET("/bounties/:bounty_id/timeline", bountyController.GetBountyTimeline)                                             // 获取指定悬赏令的时间线
 		api.PUT("/bounties/:bounty_id", middlewares.JWTAuthMiddleware(), bountyController.UpdateBounty)                          // 更新悬赏令（需JWT认证）
 		api.DELETE("/bounties/:bounty_id", middlewares.JWTAuthMiddleware(), bountyController.DeleteBounty)                       // 删除悬赏令（需JWT认证）
 		api.POST("/bounties/:bounty_id/like", middlewares.JWTAuthMiddleware(), bountyController.LikeBounty)                      // 点赞悬赏令（需JWT认证）
@@ -57,6 +82,9 @@ func SetupRouter(
 		api.POST("/bounties/:bounty_id/rate", middlewares.JWTAuthMiddleware(), bountyController.RateBounty)                      // 评分悬赏令（需JWT认证）
 		api.GET("/bounties/:bounty_id/interaction", middlewares.JWTAuthMiddleware(), bountyController.GetUserBountyInteraction)  // 获取用户的悬赏令互动信息（需JWT认证）
 		api.POST("/bounties/:bounty_id/settle-accounts", middlewares.JWTAuthMiddleware(), bountyController.SettleBountyAccounts) // 结算悬赏令（需JWT认证）
+		api.GET("/bounties/:bounty_id/settlement", middlewares.JWTAuthMiddleware(), bountyController.GetSettlement)              // 查看结算明细或结算预览（需JWT认证）
+		api.PUT("/bounties/:bounty_id/split", middlewares.JWTAuthMiddleware(), bountyController.UpdateSplitPlan)                 // 接收者（团队为队长）设置赏金分配方式（需JWT认证）
+		api.POST("/bounties/:bounty_id/nda", middlewares.JWTAuthMiddleware(), attachmentController.AcceptNDA)                    // 接受悬赏令的保密协议（需JWT认证）
 		// 发布方取消
 		api.POST("/bounties/:bounty_id/cancel-settlement/publisher", middlewares.JWTAuthMiddleware(), bountyController.CancelSettlementByPublisher)
 		// 接收方取消
@@ -69,20 +97,65 @@ func SetupRouter(
 		api.POST("/geeks/:id", middlewares.JWTAuthMiddleware(), geekController.ExpressAffection)                             // 向特定极客或团队表达好感（需JWT认证）
 		api.PUT("/invitation/:invitation_id/accept", middlewares.JWTAuthMiddleware(), invitationController.AcceptInvitation) // 接受组队邀请（需JWT认证）
 		api.PUT("/invitation/:invitation_id/reject", middlewares.JWTAuthMiddleware(), invitationController.RejectInvitation) // 拒绝组队邀请（需JWT认证）
+		api.PUT("/invitation/:invitation_id/cancel", middlewares.JWTAuthMiddleware(), invitationController.CancelInvitation) // 撤回组队邀请（需JWT认证）
+		api.GET("/invitations/sent", middlewares.JWTAuthMiddleware(), invitationController.GetSentInvitations)               // 获取发出的组队邀请（需JWT认证）
+		api.GET("/invitations/received", middlewares.JWTAuthMiddleware(), invitationController.GetReceivedInvitations)       // 获取收到的组队邀请（需JWT认证）
 		api.POST("/geeks/:id/express-affection", middlewares.JWTAuthMiddleware(), geekController.ExpressAffection)
 
+		// 团队相关路由：成员通过接受组队邀请加入，队长可以团队身份申请悬赏令
+		api.POST("/teams", middlewares.JWTAuthMiddleware(), teamController.CreateTeam)                             // 创建团队，创建者为队长（需JWT认证）
+		api.GET("/teams", middlewares.JWTAuthMiddleware(), teamController.GetTeams)                                // 获取自己所在的团队（需JWT认证）
+		api.GET("/teams/:team_id", teamController.GetTeam)                                                         // 获取团队信息及成员
+		api.PUT("/teams/:team_id", middlewares.JWTAuthMiddleware(), teamController.UpdateTeam)                     // 队长修改团队信息（需JWT认证）
+		api.DELETE("/teams/:team_id", middlewares.JWTAuthMiddleware(), teamController.DeleteTeam)                  // 队长解散团队（需JWT认证）
+		api.GET("/teams/:team_id/members", teamController.GetMembers)                                              // 获取团队成员
+		api.POST("/teams/:team_id/leave", middlewares.JWTAuthMiddleware(), teamController.LeaveTeam)               // 退出团队（需JWT认证）
+		api.DELETE("/teams/:team_id/members/:user_id", middlewares.JWTAuthMiddleware(), teamController.KickMember) // 队长移除成员（需JWT认证）
+
 		// 用户信息相关路由
-		api.GET("/user/profile", middlewares.JWTAuthMiddleware(), userController.GetUserInfo)                     // 获取用户信息（需JWT认证）
-		api.PUT("/user/profile", middlewares.JWTAuthMiddleware(), userController.UpdateUserInfo)                  // 更新用户信息（需JWT认证）
-		api.GET("/user/bounties", middlewares.JWTAuthMiddleware(), bountyController.GetBountiesByUser)            // 获取用户发布的悬赏令（需JWT认证）
-		api.GET("/user/received-bounties", middlewares.JWTAuthMiddleware(), bountyController.GetReceivedBounties) // 获取用户接收的悬赏令（需JWT认证）
+		api.GET("/user/profile", middlewares.JWTAuthMiddleware(), userController.GetUserInfo)                                    // 获取用户信息（需JWT认证）
+		api.PUT("/user/profile", middlewares.JWTAuthMiddleware(), userController.UpdateUserInfo)                                 // 更新用户信息（需JWT认证）
+		api.PUT("/user/avatar", middlewares.JWTAuthMiddleware(), userController.UpdateAvatar)                                    // 上传头像并生成缩略图（需JWT认证）
+		api.GET("/user/bounties", middlewares.JWTAuthMiddleware(), bountyController.GetBountiesByUser)                           // 获取用户发布的悬赏令（需JWT认证）
+		api.GET("/user/received-bounties", middlewares.JWTAuthMiddleware(), bountyController.GetReceivedBounties)                // 获取用户接收的悬赏令（需JWT认证）
+		api.GET("/user/notification-preferences", middlewares.JWTAuthMiddleware(), userController.GetNotificationPreferences)    // 获取各类通知在各渠道的开关（需JWT认证）
+		api.PUT("/user/notification-preferences", middlewares.JWTAuthMiddleware(), userController.UpdateNotificationPreferences) // 修改通知偏好（需JWT认证）
+
+		// 钱包相关路由
+		api.GET("/user/wallet", middlewares.JWTAuthMiddleware(), walletController.GetWallet)                    // 获取钱包余额（需JWT认证）
+		api.GET("/user/wallet/transactions", middlewares.JWTAuthMiddleware(), walletController.GetTransactions) // 获取钱包流水（需JWT认证）
+
+		// 两步验证相关路由
+		api.GET("/user/2fa", middlewares.JWTAuthMiddleware(), twoFactorController.GetStatus)                               // 获取两步验证状态（需JWT认证）
+		api.POST("/user/2fa/setup", middlewares.JWTAuthMiddleware(), twoFactorController.Setup)                            // 生成TOTP密钥（需JWT认证）
+		api.POST("/user/2fa/enable", middlewares.JWTAuthMiddleware(), twoFactorController.Enable)                          // 确认验证码并启用两步验证（需JWT认证）
+		api.POST("/user/2fa/disable", middlewares.JWTAuthMiddleware(), twoFactorController.Disable)                        // 关闭两步验证（需JWT认证）
+		api.POST("/user/2fa/recovery-codes", middlewares.JWTAuthMiddleware(), twoFactorController.RegenerateRecoveryCodes) // 重新生成恢复码（需JWT认证）
 
 		// 通知相关路由
-		api.GET("/notifications", middlewares.JWTAuthMiddleware(), notificationController.GetUserNotifications)            // 获取用户的所有通知（需JWT认证）
+		api.GET("/notifications", middlewares.JWTAuthMiddleware(), notificationController.GetUserNotifications)            // 获取用户的所有通知，支持按类型、已读状态、日期筛选（需JWT认证）
+		api.GET("/notifications/grouped", middlewares.JWTAuthMiddleware(), notificationController.GetGroupedNotifications) // 获取折叠重复事件后的通知（需JWT认证）
+		api.GET("/notifications/unread-count", middlewares.JWTAuthMiddleware(), notificationController.GetUnreadCount)     // 获取未读通知数（需JWT认证）
+		api.PUT("/notifications/read-all", middlewares.JWTAuthMiddleware(), notificationController.MarkAllAsRead)          // 全部标记为已读（需JWT认证）
+		api.PUT("/notifications/read", middlewares.JWTAuthMiddleware(), notificationController.MarkNotificationsAsRead)    // 批量标记为已读（需JWT认证）
+		api.DELETE("/notifications", middlewares.JWTAuthMiddleware(), notificationController.DeleteNotifications)          // 批量删除通知（需JWT认证）
 		api.PUT("/notifications/:id/read", middlewares.JWTAuthMiddleware(), notificationController.MarkNotificationAsRead) // 标记通知为已读（需JWT认证）
 		api.DELETE("/notifications/:id", middlewares.JWTAuthMiddleware(), notificationController.DeleteNotification)       // 删除通知（需JWT认证）
-		// 下面这个多为测试/管理员使用
-		api.POST("/notifications", middlewares.JWTAuthMiddleware(), notificationController.CreateNotification)
+
+		// 实时推送：新通知、未读数变化与悬赏令状态变化
+		api.GET("/notifications/stream", middlewares.JWTStreamAuthMiddleware(), realtimeController.Stream) // Server-Sent Events（需JWT认证，可用 access_token 查询参数）
+		api.GET("/ws", middlewares.JWTStreamAuthMiddleware(), realtimeController.WebSocket)                // WebSocket（需JWT认证，可用 access_token 查询参数）
+		// 手动创建通知，仅管理员可用
+		api.POST("/notifications", middlewares.JWTAuthMiddleware(), middlewares.RequireRole(tables.RoleAdmin), notificationController.CreateNotification)
+
+		// Webhook 相关路由：事件名与通知类型一致，请求带 HMAC-SHA256 签名
+		api.POST("/webhooks", middlewares.JWTAuthMiddleware(), webhookController.CreateWebhook)               // 注册 Webhook，返回签名密钥（需JWT认证）
+		api.GET("/webhooks", middlewares.JWTAuthMiddleware(), webhookController.GetWebhooks)                  // 获取自己的 Webhook（需JWT认证）
+		api.GET("/webhooks/:id", middlewares.JWTAuthMiddleware(), webhookController.GetWebhook)               // 获取单个 Webhook（需JWT认证）
+		api.PUT("/webhooks/:id", middlewares.JWTAuthMiddleware(), webhookController.UpdateWebhook)            // 修改 Webhook（需JWT认证）
+		api.DELETE("/webhooks/:id", middlewares.JWTAuthMiddleware(), webhookController.DeleteWebhook)         // 删除 Webhook（需JWT认证）
+		api.GET("/webhooks/:id/deliveries", middlewares.JWTAuthMiddleware(), webhookController.GetDeliveries) // 获取投递日志（需JWT认证）
+		api.POST("/webhooks/:id/test", middlewares.JWTAuthMiddleware(), webhookController.TestWebhook)        // 发送测试事件（需JWT认证）
 
 		// 悬赏令申请相关路由
 		api.POST("/applications/:bounty_id", middlewares.JWTAuthMiddleware(), applicationController.CreateApplication)              // 向特定悬赏任务发出悬赏令申请（需JWT认证）
@@ -92,11 +165,15 @@ func SetupRouter(
 		api.PUT("/applications/:application_id/reject", middlewares.JWTAuthMiddleware(), applicationController.RejectApplication)   // 拒绝悬赏令申请（需JWT认证）
 
 		// 里程碑相关路由
-		api.GET("/bounties/:bounty_id/milestones", milestoneController.GetMilestonesByBountyID)                                                           // 获取指定悬赏令的里程碑
-		api.POST("/bounties/:bounty_id/milestones", middlewares.JWTAuthMiddleware(), milestoneController.CreateMilestone)                                 // 悬赏令发布者公布里程碑
-		api.PUT("/bounties/:bounty_id/milestones/:milestone_id/promulgator", middlewares.JWTAuthMiddleware(), milestoneController.UpdateMilestone)        // 悬赏令发布者更新里程碑（需JWT认证）
-		api.PUT("/bounties/:bounty_id/milestones/:milestone_id/receiver", middlewares.JWTAuthMiddleware(), milestoneController.UpdateMilestoneByReceiver) // 悬赏零接收者更新里程碑（需JWT认证）
-		api.DELETE("/bounties/:bounty_id/milestones/:milestone_id", middlewares.JWTAuthMiddleware(), milestoneController.DeleteMilestone)                 // 删除里程碑（需JWT认证）
+		api.GET("/bounties/:bounty_id/milestones", milestoneController.GetMilestonesByBountyID)                                                                            // 获取指定悬赏令的里程碑
+		api.POST("/bounties/:bounty_id/milestones", middlewares.JWTAuthMiddleware(), milestoneController.CreateMilestone)                                                  // 悬赏令发布者公布里程碑
+		api.PUT("/bounties/:bounty_id/milestones/rewards", middlewares.JWTAuthMiddleware(), milestoneController.UpdateMilestoneRewards)                                    // 悬赏令发布者为各里程碑分配阶段赏金（需JWT认证）
+		api.PUT("/bounties/:bounty_id/milestones/:milestone_id/promulgator", middlewares.JWTAuthMiddleware(), milestoneController.UpdateMilestone)                         // 悬赏令发布者更新里程碑（需JWT认证）
+		api.DELETE("/bounties/:bounty_id/milestones/:milestone_id", middlewares.JWTAuthMiddleware(), milestoneController.DeleteMilestone)                                  // 删除里程碑（需JWT认证）
+		api.POST("/bounties/:bounty_id/milestones/:milestone_id/submissions", middlewares.JWTAuthMiddleware(), milestoneController.SubmitMilestone)                        // 接收者提交里程碑成果（需JWT认证）
+		api.GET("/bounties/:bounty_id/milestones/:milestone_id/submissions", middlewares.JWTAuthMiddleware(), milestoneController.GetSubmissions)                          // 发布者与接收者查看各轮成果（需JWT认证）
+		api.POST("/bounties/:bounty_id/milestones/:milestone_id/submissions/:submission_id/accept", middlewares.JWTAuthMiddleware(), milestoneController.AcceptSubmission) // 发布者验收通过成果（需JWT认证）
+		api.POST("/bounties/:bounty_id/milestones/:milestone_id/submissions/:submission_id/reject", middlewares.JWTAuthMiddleware(), milestoneController.RejectSubmission) // 发布者驳回成果并给出修改意见（需JWT认证）
 
 		// 新增的悬赏令状态相关路由
 		api.POST("/bounties/:bounty_id/confirm-milestones", middlewares.JWTAuthMiddleware(), bountyController.ConfirmMilestones) // 接收者确认提交所有里程碑
@@ -104,5 +181,18 @@ func SetupRouter(
 		api.POST("/bounties/:bounty_id/settle", middlewares.JWTAuthMiddleware(), bountyController.ApplySettlement)               // 接收者申请悬赏令清算
 	}
 
+	// 管理后台路由：仅版主与管理员可访问，具体操作再按权限校验
+	admin := r.Group("/admin", middlewares.JWTAuthMiddleware(), middlewares.RequireRole(tables.RoleModerator, tables.RoleAdmin))
+	{
+		admin.POST("/users/:user_id/unlock", middlewares.RequirePermission(tables.PermissionUsersUnlock), adminController.UnlockAccount)                      // 解除账号登录锁定
+		admin.POST("/users/:user_id/suspend", middlewares.RequirePermission(tables.PermissionUsersSuspend), adminController.SuspendUser)                      // 封禁用户并吊销其会话
+		admin.POST("/users/:user_id/reactivate", middlewares.RequirePermission(tables.PermissionUsersSuspend), adminController.ReactivateUser)                // 解除用户封禁
+		admin.PUT("/users/:user_id/role", middlewares.RequirePermission(tables.PermissionUsersManageRoles), adminController.UpdateUserRole)                   // 修改用户角色
+		admin.POST("/bounties/:bounty_id/cancel", middlewares.RequirePermission(tables.PermissionBountiesForceCancel), adminController.ForceCancelBounty)     // 强制取消悬赏令
+		admin.DELETE("/comments/:comment_id", middlewares.RequirePermission(tables.PermissionCommentsDelete), adminController.DeleteComment)                  // 删除评论
+		admin.POST("/notifications/broadcast", middlewares.RequirePermission(tables.PermissionNotificationsBroadcast), adminController.BroadcastNotification) // 向所有用户广播通知
+		admin.POST("/users/:user_id/wallet/deposit", middlewares.RequirePermission(tables.PermissionWalletsCredit), walletController.Deposit)                 // 确认支付到账后为用户钱包入账
+	}
+
 	return r
 }
@@ -0,0 +1,216 @@
+package routes
+
+import (
+	"GeekReward/inernal/app/controllers"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/inernal/app/services"
+	"GeekReward/pkg/utils"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 以下仓储桩只实现授权检查之前会用到的查询，其余方法未实现，被调用时会 panic 并由 gin 转为 500
+type stubBountyRepository struct {
+	repositories.BountyRepository
+	bounty *tables.Bounty
+}
+
+func (r *stubBountyRepository) FindBountyByID(id uuid.UUID) (*tables.Bounty, error) {
+	if id != r.bounty.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	bounty := *r.bounty
+	return &bounty, nil
+}
+
+type stubMilestoneRepository struct {
+	repositories.MilestoneRepository
+	milestone *tables.Milestone
+}
+
+func (r *stubMilestoneRepository) FindByID(id uuid.UUID) (*tables.Milestone, error) {
+	if id != r.milestone.ID {
+		return nil, nil
+	}
+	milestone := *r.milestone
+	return &milestone, nil
+}
+
+func (r *stubMilestoneRepository) FindByBountyID(bountyID uuid.UUID) ([]tables.Milestone, error) {
+	if bountyID != r.milestone.BountyID {
+		return nil, nil
+	}
+	return []tables.Milestone{*r.milestone}, nil
+}
+
+type stubApplicationRepository struct {
+	repositories.ApplicationRepository
+	application *tables.Application
+}
+
+func (r *stubApplicationRepository) FindByID(id uuid.UUID) (*tables.Application, error) {
+	if id != r.application.ID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	application := *r.application
+	return &application, nil
+}
+
+type protectedFixture struct {
+	router      *gin.Engine
+	bounty      *tables.Bounty
+	milestone   *tables.Milestone
+	application *tables.Application
+}
+
+func newProtectedFixture(t *testing.T) *protectedFixture {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if err := utils.InitJWT("routes-test-secret-at-least-32-bytes-long", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+
+	publisher, receiver := uuid.New(), uuid.New()
+	bounty := &tables.Bounty{UserID: publisher, ReceiverID: &receiver, Status: tables.BountyStatusAssigned, Reward: 100}
+	bounty.ID = uuid.New()
+	milestone := &tables.Milestone{BountyID: bounty.ID, Title: "m1"}
+	milestone.ID = uuid.New()
+	application := &tables.Application{BountyID: bounty.ID, UserID: receiver, Status: "pending"}
+	application.ID = uuid.New()
+
+	bountyRepo := &stubBountyRepository{bounty: bounty}
+	milestoneRepo := &stubMilestoneRepository{milestone: milestone}
+	applicationRepo := &stubApplicationRepository{application: application}
+
+	bountyService := services.NewBountyService(nil, bountyRepo, applicationRepo, milestoneRepo, nil, nil, nil, nil, nil, nil, services.PenaltyRates{}, nil)
+	milestoneService := services.NewMilestoneService(milestoneRepo, bountyRepo, applicationRepo, nil, nil, nil, nil, nil, nil)
+	applicationService := services.NewApplicationService(applicationRepo, bountyRepo, nil, nil, nil, nil)
+
+	router := SetupRouter(
+		nil,
+		controllers.NewBountyController(bountyService, milestoneService, nil),
+		nil,
+		nil,
+		nil,
+		controllers.NewApplicationController(applicationService, bountyService, nil),
+		controllers.NewMilestoneController(milestoneService, nil),
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+		nil,
+	)
+	return &protectedFixture{router: router, bounty: bounty, milestone: milestone, application: application}
+}
+
+// TestProtectedRoutesForbidStrangers 已登录但既非发布者也非接收者的用户访问受保护的路由时应得到 403
+func TestProtectedRoutesForbidStrangers(t *testing.T) {
+	f := newProtectedFixture(t)
+	token, err := utils.GenerateJWT(uuid.New(), uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bounty := "/bounties/" + f.bounty.ID.String()
+	milestone := bounty + "/milestones/" + f.milestone.ID.String()
+	submission := milestone + "/submissions/" + uuid.NewString()
+	application := "/applications/" + f.application.ID.String()
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{http.MethodPut, bounty, `{"title":"changed"}`},
+		{http.MethodDelete, bounty, ""},
+		{http.MethodPut, bounty + "/split", `{"rule":"equal"}`},
+		{http.MethodPost, bounty + "/settle-accounts", ""},
+		{http.MethodPost, bounty + "/cancel-settlement/publisher", ""},
+		{http.MethodPost, bounty + "/cancel-settlement/receiver", ""},
+		{http.MethodPost, bounty + "/confirm-milestones", ""},
+		{http.MethodPost, bounty + "/verify-milestones", ""},
+		{http.MethodPost, bounty + "/settle", ""},
+
+		{http.MethodPost, bounty + "/milestones", `{"title":"m2","description":"d","due_date":"2030-01-01T00:00:00Z"}`},
+		{http.MethodPut, bounty + "/milestones/rewards", `{"unit":"amount","items":[]}`},
+		{http.MethodPut, milestone + "/promulgator", `{"title":"changed"}`},
+		{http.MethodDelete, milestone, ""},
+		{http.MethodPost, milestone + "/submissions", `{"content":"done"}`},
+		{http.MethodGet, milestone + "/submissions", ""},
+		{http.MethodPost, submission + "/accept", ""},
+		{http.MethodPost, submission + "/reject", `{"feedback":"redo"}`},
+
+		{http.MethodGet, "/applications/" + f.bounty.ID.String() + "/private", ""},
+		{http.MethodPut, application + "/approve", ""},
+		{http.MethodPut, application + "/reject", ""},
+	}
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+			req.Header.Set("Authorization", "Bearer "+token)
+			if c.body != "" {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			w := httptest.NewRecorder()
+			f.router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("status = %d, want 403; body %s", w.Code, w.Body.String())
+			}
+			var resp map[string]any
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp["error"] == nil {
+				t.Fatalf("response without error message: %s", w.Body.String())
+			}
+		})
+	}
+}
+
+// TestProtectedRoutesRequireToken 未携带令牌时受保护的路由应返回 401，不会进入授权检查
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	f := newProtectedFixture(t)
+	req := httptest.NewRequest(http.MethodDelete, "/bounties/"+f.bounty.ID.String(), nil)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+}
+
+// TestDeleteAssignedBountyRejected 发布者也不能删除已指派接收者的悬赏令，需通过取消流程处理
+func TestDeleteAssignedBountyRejected(t *testing.T) {
+	f := newProtectedFixture(t)
+	token, err := utils.GenerateJWT(f.bounty.UserID, uuid.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/bounties/"+f.bounty.ID.String(), nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("status = %d, want 409; body %s", w.Code, w.Body.String())
+	}
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp["error"] != services.ErrBountyDeleteLocked.Error() {
+		t.Fatalf("error = %v, want %q", resp["error"], services.ErrBountyDeleteLocked)
+	}
+}
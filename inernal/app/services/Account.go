@@ -0,0 +1,180 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/mailer"
+	"GeekReward/pkg/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidUserToken     = errors.New("链接无效或已过期")
+	ErrEmailNotVerified     = errors.New("请先验证邮箱")
+	ErrEmailAlreadyVerified = errors.New("邮箱已验证")
+)
+
+// AccountSettings 邮箱验证与重置密码的配置
+type AccountSettings struct {
+	FrontendURL      string        // 邮件中链接指向的前端地址
+	VerificationTTL  time.Duration // 邮箱验证链接有效期
+	PasswordResetTTL time.Duration // 重置密码链接有效期
+}
+
+// AccountService 定义邮箱验证与找回密码相关的服务接口
+type AccountService interface {
+	// SendEmailVerification 向用户邮箱发送验证链接，之前发送的链接随即失效
+	SendEmailVerification(userID uuid.UUID) error
+	// VerifyEmail 使用邮件中的令牌完成邮箱验证
+	VerifyEmail(token string) error
+	// RequestPasswordReset 向邮箱发送重置密码链接，邮箱未注册时同样返回成功
+	RequestPasswordReset(email string) error
+	// ResetPassword 使用邮件中的令牌设置新密码，并注销所有已登录的会话
+	ResetPassword(token, newPassword string) error
+}
+
+type accountService struct {
+	userRepo      repositories.UserRepository
+	userTokenRepo repositories.UserTokenRepository
+	sessionRepo   repositories.SessionRepository
+	mailer        mailer.Mailer
+	settings      AccountSettings
+}
+
+func NewAccountService(
+	userRepo repositories.UserRepository,
+	userTokenRepo repositories.UserTokenRepository,
+	sessionRepo repositories.SessionRepository,
+	mailer mailer.Mailer,
+	settings AccountSettings,
+) AccountService {
+	return &accountService{
+		userRepo:      userRepo,
+		userTokenRepo: userTokenRepo,
+		sessionRepo:   sessionRepo,
+		mailer:        mailer,
+		settings:      settings,
+	}
+}
+
+func (s *accountService) SendEmailVerification(userID uuid.UUID) error {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if user.Verified {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := s.issueToken(user.ID, tables.UserTokenEmailVerification, s.settings.VerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.link("/verify-email", token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "验证你的 GeekReward 邮箱",
+		Body: fmt.Sprintf("%s，你好：\n\n请在 %s 内打开以下链接完成邮箱验证：\n\n%s\n\n如果这不是你本人的操作，请忽略此邮件。\n",
+			user.Username, formatTTL(s.settings.VerificationTTL), link),
+	})
+}
+
+func (s *accountService) VerifyEmail(token string) error {
+	userToken, err := s.userTokenRepo.Consume(utils.HashToken(token), tables.UserTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+	if userToken == nil {
+		return ErrInvalidUserToken
+	}
+	return s.userRepo.MarkVerified(userToken.UserID)
+}
+
+func (s *accountService) RequestPasswordReset(email string) error {
+	user, err := s.userRepo.FindByEmail(strings.TrimSpace(email))
+	if err != nil {
+		// 不暴露邮箱是否已注册
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if user.AccountStatus == tables.AccountStatusDeleted {
+		return nil
+	}
+
+	token, err := s.issueToken(user.ID, tables.UserTokenPasswordReset, s.settings.PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.link("/reset-password", token)
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "重置你的 GeekReward 密码",
+		Body: fmt.Sprintf("%s，你好：\n\n我们收到了重置密码的请求，请在 %s 内打开以下链接设置新密码：\n\n%s\n\n如果这不是你本人的操作，请忽略此邮件，你的密码不会被修改。\n",
+			user.Username, formatTTL(s.settings.PasswordResetTTL), link),
+	})
+}
+
+func (s *accountService) ResetPassword(token, newPassword string) error {
+	userToken, err := s.userTokenRepo.Consume(utils.HashToken(token), tables.UserTokenPasswordReset)
+	if err != nil {
+		return err
+	}
+	if userToken == nil {
+		return ErrInvalidUserToken
+	}
+
+	hashedPassword, err := utils.HashPassword(newPassword)
+	if err != nil {
+		return err
+	}
+	if err := s.userRepo.UpdatePassword(userToken.UserID, hashedPassword, time.Now()); err != nil {
+		return err
+	}
+
+	// 密码已修改，原有会话全部失效，同时解除登录锁定
+	if err := s.sessionRepo.RevokeAllByUserID(userToken.UserID); err != nil {
+		return err
+	}
+	return s.userRepo.ResetLoginAttempts(userToken.UserID)
+}
+
+// issueToken 生成一次性令牌并保存摘要，返回令牌原文
+func (s *accountService) issueToken(userID uuid.UUID, purpose tables.UserTokenPurpose, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+	err = s.userTokenRepo.Issue(&tables.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// link 拼接前端页面链接
+func (s *accountService) link(path, token string) string {
+	return strings.TrimRight(s.settings.FrontendURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// formatTTL 将有效期格式化为便于阅读的中文
+func formatTTL(ttl time.Duration) string {
+	if ttl >= time.Hour && ttl%time.Hour == 0 {
+		return fmt.Sprintf("%d 小时", int(ttl/time.Hour))
+	}
+	return fmt.Sprintf("%d 分钟", int(ttl/time.Minute))
+}
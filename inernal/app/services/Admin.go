@@ -0,0 +1,116 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"errors"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCannotModerateSelf = errors.New("不能对自己执行该操作")
+	ErrInvalidRole        = errors.New("无效的角色")
+)
+
+// AdminService 管理员与版主的后台操作
+type AdminService interface {
+	// SuspendUser 封禁用户并吊销其所有会话
+	SuspendUser(actorID, userID uuid.UUID) error
+	// ReactivateUser 解除封禁
+	ReactivateUser(userID uuid.UUID) error
+	// UpdateUserRole 修改用户角色
+	UpdateUserRole(actorID, userID uuid.UUID, role tables.Role) error
+	// ForceCancelBounty 以系统身份强制取消悬赏令
+	ForceCancelBounty(actorID, bountyID uuid.UUID, reason string) error
+	// DeleteComment 删除任意评论
+	DeleteComment(commentID uuid.UUID) error
+	// BroadcastNotification 向所有状态正常的用户发送站内公告，返回发送数量
+	BroadcastNotification(actorID uuid.UUID, input dtos.BroadcastNotificationInput) (int, error)
+}
+
+type adminService struct {
+	userRepo         repositories.UserRepository
+	sessionRepo      repositories.SessionRepository
+	notificationRepo repositories.NotificationRepository
+	bountyService    BountyService
+	hub              NotificationHub
+}
+
+func NewAdminService(
+	userRepo repositories.UserRepository,
+	sessionRepo repositories.SessionRepository,
+	notificationRepo repositories.NotificationRepository,
+	bountyService BountyService,
+	hub NotificationHub,
+) AdminService {
+	return &adminService{
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		notificationRepo: notificationRepo,
+		bountyService:    bountyService,
+		hub:              hub,
+	}
+}
+
+func (s *adminService) SuspendUser(actorID, userID uuid.UUID) error {
+	if actorID == userID {
+		return ErrCannotModerateSelf
+	}
+	if err := s.userRepo.UpdateAccountStatus(userID, tables.AccountStatusSuspended); err != nil {
+		return err
+	}
+	// 吊销会话后，已签发的访问令牌会被认证中间件拒绝
+	return s.sessionRepo.RevokeAllByUserID(userID)
+}
+
+func (s *adminService) ReactivateUser(userID uuid.UUID) error {
+	return s.userRepo.UpdateAccountStatus(userID, tables.AccountStatusActive)
+}
+
+func (s *adminService) UpdateUserRole(actorID, userID uuid.UUID, role tables.Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	// 防止管理员误操作撤销自己的管理权限
+	if actorID == userID {
+		return ErrCannotModerateSelf
+	}
+	return s.userRepo.UpdateRole(userID, role)
+}
+
+func (s *adminService) ForceCancelBounty(actorID, bountyID uuid.UUID, reason string) error {
+	return s.bountyService.ForceCancelBounty(bountyID, actorID, reason)
+}
+
+func (s *adminService) DeleteComment(commentID uuid.UUID) error {
+	return s.bountyService.DeleteComment(commentID)
+}
+
+func (s *adminService) BroadcastNotification(actorID uuid.UUID, input dtos.BroadcastNotificationInput) (int, error) {
+	userIDs, err := s.userRepo.FindActiveUserIDs()
+	if err != nil {
+		return 0, err
+	}
+
+	notifications := make([]tables.Notification, 0, len(userIDs))
+	for _, userID := range userIDs {
+		notifications = append(notifications, tables.Notification{
+			UserID:      userID,
+			ActorID:     &actorID,
+			Type:        tables.NotificationTypeAnnouncement,
+			Title:       input.Title,
+			Description: input.Description,
+			RelatedID:   input.RelatedID,
+			RelatedType: input.RelatedType,
+			Metadata:    input.Metadata,
+		})
+	}
+	if err := s.notificationRepo.CreateNotifications(notifications); err != nil {
+		return 0, err
+	}
+	for i := range notifications {
+		publishNotification(s.hub, s.notificationRepo, &notifications[i])
+	}
+	return len(notifications), nil
+}
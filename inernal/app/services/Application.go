@@ -1,17 +1,21 @@
 package services
 
 import (
+	"GeekReward/inernal/app/authz"
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
 	"errors"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type ApplicationService interface {
-	CreateApplication(bountyID uuid.UUID, userID uuid.UUID, note string) error
-	GetApplications(bountyID uuid.UUID) ([]tables.Application, error)
-	ApproveApplication(applicationID uuid.UUID) error
-	RejectApplication(applicationID uuid.UUID) error
+	// CreateApplication 创建申请，teamID 不为空时以团队身份申请，申请者必须是队长
+	CreateApplication(bountyID uuid.UUID, userID uuid.UUID, note string, teamID *uuid.UUID) error
+	GetApplications(bountyID, userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Application], error)
+	ApproveApplication(applicationID, userID uuid.UUID) error
+	RejectApplication(applicationID, userID uuid.UUID) error
 	GetPublicApplications(bountyID uuid.UUID) ([]*tables.Application, error)
 	HasUserApplied(bountyID uuid.UUID, uid uuid.UUID) (bool, error)
 }
@@ -19,6 +23,10 @@ type ApplicationService interface {
 type applicationService struct {
 	applicationRepo repositories.ApplicationRepository
 	bountyRepo      repositories.BountyRepository
+	teamRepo        repositories.TeamRepository
+	outboxRepo      repositories.OutboxRepository
+	txManager       repositories.TransactionManager
+	hub             NotificationHub
 }
 
 func (s *applicationService) HasUserApplied(bountyID uuid.UUID, UserID uuid.UUID) (bool, error) {
@@ -28,15 +36,23 @@ func (s *applicationService) HasUserApplied(bountyID uuid.UUID, UserID uuid.UUID
 func NewApplicationService(
 	applicationRepo repositories.ApplicationRepository,
 	bountyRepo repositories.BountyRepository,
+	teamRepo repositories.TeamRepository,
+	outboxRepo repositories.OutboxRepository,
+	txManager repositories.TransactionManager,
+	hub NotificationHub,
 ) ApplicationService {
 	return &applicationService{
 		applicationRepo: applicationRepo,
 		bountyRepo:      bountyRepo,
+		teamRepo:        teamRepo,
+		outboxRepo:      outboxRepo,
+		txManager:       txManager,
+		hub:             hub,
 	}
 }
 
 // CreateApplication 创建新的悬赏令申请
-func (s *applicationService) CreateApplication(bountyID uuid.UUID, userID uuid.UUID, note string) error {
+func (s *applicationService) CreateApplication(bountyID uuid.UUID, userID uuid.UUID, note string, teamID *uuid.UUID) error {
 	// 1. 判断是否已有"pending"/"approved"申请
 	hasApplied, err := s.applicationRepo.HasUserApplied(bountyID, userID)
 	if err != nil {
@@ -46,9 +62,31 @@ func (s *applicationService) CreateApplication(bountyID uuid.UUID, userID uuid.U
 		return errors.New("你已对该悬赏令提交过申请或已被批准，无法再次申请")
 	}
 
+	// 2. 以团队身份申请时，只有队长可以代表团队，且同一团队不能重复申请
+	if teamID != nil {
+		team, err := s.teamRepo.FindByID(*teamID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrTeamNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if team.LeaderID != userID {
+			return ErrNotTeamLeader
+		}
+		teamApplied, err := s.applicationRepo.HasTeamApplied(bountyID, team.ID)
+		if err != nil {
+			return err
+		}
+		if teamApplied {
+			return errors.New("该团队已对该悬赏令提交过申请或已被批准，无法再次申请")
+		}
+	}
+
 	application := &tables.Application{
 		BountyID: bountyID,
 		UserID:   userID,
+		TeamID:   teamID,
 		Status:   "pending",
 		Note:     note, // 可选
 	}
@@ -61,13 +99,20 @@ func (s *applicationService) GetPublicApplications(bountyID uuid.UUID) ([]*table
 	return s.applicationRepo.GetApprovedApplicationsByBountyID(bountyID)
 }
 
-// GetApplications 获取指定悬赏令的所有申请
-func (s *applicationService) GetApplications(bountyID uuid.UUID) ([]tables.Application, error) {
-	return s.applicationRepo.FindAllByBountyID(bountyID)
+// GetApplications 发布者分页获取指定悬赏令的申请
+func (s *applicationService) GetApplications(bountyID, userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Application], error) {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Can(userID, authz.ActionApplicationList, bounty); err != nil {
+		return nil, err
+	}
+	return s.applicationRepo.FindPageByBountyID(bountyID, page)
 }
 
-// ApproveApplication 批准申请
-func (s *applicationService) ApproveApplication(applicationID uuid.UUID) error {
+// ApproveApplication 发布者批准申请，悬赏令进入 Assigned 状态
+func (s *applicationService) ApproveApplication(applicationID, userID uuid.UUID) error {
 	// 获取申请信息
 	app, err := s.applicationRepo.FindByID(applicationID)
 	if err != nil {
@@ -78,22 +123,78 @@ func (s *applicationService) ApproveApplication(applicationID uuid.UUID) error {
 		return errors.New("只能批准待处理的申请")
 	}
 
-	// 调用仓库层的方法，批准申请并更新悬赏令 receiver_id
-	return s.applicationRepo.ApproveApplication(applicationID, app.UserID)
+	// 只有发布者可以批准，再由状态机校验悬赏令尚未指定接收者
+	bounty, err := s.bountyRepo.FindBountyByID(app.BountyID)
+	if err != nil {
+		return err
+	}
+	if err := authz.Can(userID, authz.ActionApplicationApprove, bounty); err != nil {
+		return err
+	}
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusAssigned, bountyActorOf(bounty, userID), &userID, "发布者批准申请并指定接收者")
+	if err != nil {
+		return err
+	}
+	bounty.ReceiverID = &app.UserID
+	bounty.ReceiverTeamID = app.TeamID
+
+	// 批准申请并在同一事务中保存悬赏令的接收者与新状态、状态变更记录及 bounty.status_changed 事件，同时写入 application.approved 事件
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if err := s.applicationRepo.WithTx(tx).ApproveApplication(app); err != nil {
+			return err
+		}
+		if err := saveBountyTransition(tx, s.bountyRepo, s.outboxRepo, bounty, change); err != nil {
+			return err
+		}
+		return s.createReviewedEvent(tx, tables.EventApplicationApproved, app, bounty)
+	})
+	if err != nil {
+		return err
+	}
+	publishBountyStatusChange(s.hub, bounty, change)
+	return nil
 }
 
-// RejectApplication 拒绝申请
-func (s *applicationService) RejectApplication(applicationID uuid.UUID) error {
+// RejectApplication 发布者拒绝申请
+func (s *applicationService) RejectApplication(applicationID, userID uuid.UUID) error {
 	// 获取申请信息
 	app, err := s.applicationRepo.FindByID(applicationID)
 	if err != nil {
 		return errors.New("申请不存在")
 	}
 
+	bounty, err := s.bountyRepo.FindBountyByID(app.BountyID)
+	if err != nil {
+		return err
+	}
+	if err := authz.Can(userID, authz.ActionApplicationReject, bounty); err != nil {
+		return err
+	}
+
 	if app.Status != "pending" {
 		return errors.New("只能拒绝待处理的申请")
 	}
 
-	// 更新申请状态为 "rejected"
-	return s.applicationRepo.UpdateApplicationStatus(applicationID, "rejected")
+	// 更新申请状态为 "rejected"，同时写入 application.rejected 事件
+	return s.txManager.Transaction(func(tx *gorm.DB) error {
+		if err := s.applicationRepo.WithTx(tx).UpdateApplicationStatus(applicationID, "rejected"); err != nil {
+			return err
+		}
+		return s.createReviewedEvent(tx, tables.EventApplicationRejected, app, bounty)
+	})
+}
+
+// createReviewedEvent 在事务中写入申请被批准或拒绝的事件，由通知消费者告知申请者
+func (s *applicationService) createReviewedEvent(tx *gorm.DB, eventType string, app *tables.Application, bounty *tables.Bounty) error {
+	event, err := newOutboxEvent(eventType, "Application", app.ID, &dtos.ApplicationReviewedEvent{
+		ApplicationID: app.ID,
+		BountyID:      bounty.ID,
+		PublisherID:   bounty.UserID,
+		ApplicantID:   app.UserID,
+		Title:         bounty.Title,
+	})
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.WithTx(tx).Create(event)
 }
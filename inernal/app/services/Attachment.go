@@ -0,0 +1,547 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/storage"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAttachmentNotFound     = errors.New("附件不存在")
+	ErrAttachmentForbidden    = errors.New("无权访问该附件")
+	ErrNotAttachmentOwner     = errors.New("只有上传者可以删除附件")
+	ErrAttachmentLinkDenied   = errors.New("只有悬赏令的发布者和接收者可以上传附件")
+	ErrAttachmentLinkNotFound = errors.New("附件关联的悬赏令或里程碑不存在")
+	ErrAttachmentNotUploaded  = errors.New("附件尚未上传到存储")
+	ErrNDANotAccepted         = errors.New("请先接受该悬赏令的保密协议")
+	ErrNDANotRequired         = errors.New("该悬赏令不需要签署保密协议")
+	ErrUploadClosed           = errors.New("上传地址已失效")
+)
+
+const (
+	// attachmentPrefix 附件在对象存储中的键前缀
+	attachmentPrefix = "attachments"
+	// attachmentIncomingPrefix 直传附件在完成前的暂存键前缀，完成时移动到 attachmentPrefix 下，
+	// 之后原上传地址即使尚未过期也无法再覆盖已校验的文件
+	attachmentIncomingPrefix = "incoming"
+	// attachmentPath 附件对外的访问地址前缀，完整地址形如 /attachments/<id>/download
+	attachmentPath = "/attachments/"
+	// attachmentSweepBatch 每轮垃圾回收处理的附件数
+	attachmentSweepBatch = 100
+)
+
+// AttachmentURL 返回附件的下载地址，访问时需要登录并通过权限校验
+func AttachmentURL(id uuid.UUID) string {
+	return attachmentPath + id.String() + "/download"
+}
+
+// attachmentIDFromURL 从 AttachmentURL 生成的地址中解析出附件 ID
+func attachmentIDFromURL(url string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(url, attachmentPath)
+	if !ok {
+		return uuid.Nil, false
+	}
+	id, err := uuid.Parse(strings.TrimSuffix(rest, "/download"))
+	return id, err == nil
+}
+
+// AttachmentSettings 附件下载地址的有效期与孤儿文件的回收策略
+type AttachmentSettings struct {
+	PresignTTL    time.Duration // 预签名下载地址的有效期
+	OrphanTTL     time.Duration // 上传后多久仍未关联到悬赏令或未完成直传即视为孤儿文件
+	SweepInterval time.Duration // 回收孤儿文件的间隔
+	Upload        UploadSettings
+}
+
+// AttachmentService 管理附件的上传、下载与回收，记录每个文件的上传者，
+// 并按关联悬赏令的可见性与保密协议控制下载权限
+type AttachmentService interface {
+	// Upload 校验文件类型、大小与用户配额，删除图片元数据后保存文件并记录附件，
+	// 关联悬赏令或里程碑时上传者必须是该悬赏令的发布者或接收者
+	Upload(ctx context.Context, ownerID uuid.UUID, input dtos.AttachmentUpload) (*tables.Attachment, error)
+	// PresignUpload 记录附件并返回直传存储的上传地址，客户端上传完成后需调用 CompleteUpload
+	PresignUpload(ownerID uuid.UUID, input dtos.AttachmentUpload) (*tables.Attachment, string, error)
+	// CompleteUpload 上传者确认直传完成，读取存储中的文件补全大小与 SHA-256，并将文件移动到正式的对象键
+	CompleteUpload(ctx context.Context, attachmentID, userID uuid.UUID) (*tables.Attachment, error)
+	// UploadLimit 返回直传地址允许写入的最大字节数，对象键不属于尚未完成直传的附件时返回 ErrUploadClosed
+	UploadLimit(key string) (int64, error)
+	// DownloadURL 校验用户的访问权限后返回附件的预签名下载地址
+	DownloadURL(attachmentID, userID uuid.UUID) (string, error)
+	// Delete 上传者删除附件
+	Delete(ctx context.Context, attachmentID, userID uuid.UUID) error
+	// AcceptNDA 用户接受悬赏令的保密协议，之后可以下载该悬赏令的附件
+	AcceptNDA(bountyID, userID uuid.UUID) error
+	// CollectGarbage 删除截至 now 的孤儿文件，返回删除的数量
+	CollectGarbage(ctx context.Context, now time.Time) (int, error)
+	// Run 按 SweepInterval 回收孤儿文件，直到 ctx 结束
+	Run(ctx context.Context) error
+}
+
+type attachmentService struct {
+	attachmentRepo  repositories.AttachmentRepository
+	bountyRepo      repositories.BountyRepository
+	milestoneRepo   repositories.MilestoneRepository
+	teamRepo        repositories.TeamRepository
+	applicationRepo repositories.ApplicationRepository
+	blob            storage.Blob
+	settings        AttachmentSettings
+}
+
+func NewAttachmentService(
+	attachmentRepo repositories.AttachmentRepository,
+	bountyRepo repositories.BountyRepository,
+	milestoneRepo repositories.MilestoneRepository,
+	teamRepo repositories.TeamRepository,
+	applicationRepo repositories.ApplicationRepository,
+	blob storage.Blob,
+	settings AttachmentSettings,
+) AttachmentService {
+	if settings.PresignTTL <= 0 {
+		settings.PresignTTL = 15 * time.Minute
+	}
+	if settings.OrphanTTL <= 0 {
+		settings.OrphanTTL = 24 * time.Hour
+	}
+	if settings.SweepInterval <= 0 {
+		settings.SweepInterval = time.Hour
+	}
+	settings.Upload = settings.Upload.withDefaults()
+	return &attachmentService{
+		attachmentRepo:  attachmentRepo,
+		bountyRepo:      bountyRepo,
+		milestoneRepo:   milestoneRepo,
+		teamRepo:        teamRepo,
+		applicationRepo: applicationRepo,
+		blob:            blob,
+		settings:        settings,
+	}
+}
+
+func (s *attachmentService) Upload(ctx context.Context, ownerID uuid.UUID, input dtos.AttachmentUpload) (*tables.Attachment, error) {
+	limits := s.settings.Upload
+	if input.Size > limits.MaxSize {
+		return nil, s.tooLarge()
+	}
+	mediaType, content, err := sniffContentType(input.Content)
+	if err != nil {
+		return nil, err
+	}
+	if !limits.allows(mediaType) {
+		return nil, fmt.Errorf("%w：%s", ErrFileTypeNotAllowed, mediaType)
+	}
+
+	attachment := &tables.Attachment{
+		OwnerID:     ownerID,
+		Key:         storage.NewKey(attachmentPrefix, keyFilename(input.Filename, mediaType)),
+		Filename:    input.Filename,
+		MimeType:    mediaType,
+		BountyID:    input.BountyID,
+		MilestoneID: input.MilestoneID,
+	}
+	if err := s.resolveLink(attachment); err != nil {
+		return nil, err
+	}
+	if err := s.checkQuota(ownerID, max(input.Size, 0)); err != nil {
+		return nil, err
+	}
+
+	// 图片需要整体读入以删除元数据，其余文件流式写入存储，超过大小限制时中止
+	size := input.Size
+	if strippableImage(mediaType) {
+		data, err := readLimited(content, limits.MaxSize)
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, s.tooLarge()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if data, err = stripImageMetadata(mediaType, data); err != nil {
+			return nil, err
+		}
+		content, size = bytes.NewReader(data), int64(len(data))
+	} else {
+		content = &limitedReader{r: content, limit: limits.MaxSize}
+	}
+
+	// 写入存储的同时计算大小与 SHA-256
+	hash := sha256.New()
+	counter := &countingReader{r: io.TeeReader(content, hash)}
+	if err := s.blob.Put(ctx, attachment.Key, counter, size, mediaType); err != nil {
+		if errors.Is(err, ErrFileTooLarge) {
+			return nil, s.tooLarge()
+		}
+		return nil, err
+	}
+	attachment.Size = counter.n
+	attachment.SHA256 = hex.EncodeToString(hash.Sum(nil))
+
+	// 客户端声明的大小可能不准确，按实际大小再检查一次配额
+	err = s.checkQuota(ownerID, attachment.Size)
+	if err == nil {
+		err = s.attachmentRepo.Create(attachment)
+	}
+	if err != nil {
+		if delErr := s.blob.Delete(ctx, attachment.Key); delErr != nil {
+			log.Println("删除未记录的附件失败:", delErr)
+		}
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// PresignUpload 直传时无法预先读取内容，按文件名推断的类型与声明的大小校验，
+// 确认上传时再按实际内容校验
+func (s *attachmentService) PresignUpload(ownerID uuid.UUID, input dtos.AttachmentUpload) (*tables.Attachment, string, error) {
+	limits := s.settings.Upload
+	if input.Size > limits.MaxSize {
+		return nil, "", s.tooLarge()
+	}
+	mediaType, _, _ := mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(filepath.Ext(input.Filename))))
+	if mediaType == "" || !limits.allows(mediaType) {
+		return nil, "", fmt.Errorf("%w：%s", ErrFileTypeNotAllowed, filepath.Ext(input.Filename))
+	}
+
+	attachment := &tables.Attachment{
+		OwnerID:     ownerID,
+		Key:         storage.NewKey(attachmentIncomingPrefix, keyFilename(input.Filename, mediaType)),
+		Filename:    input.Filename,
+		MimeType:    mediaType,
+		BountyID:    input.BountyID,
+		MilestoneID: input.MilestoneID,
+	}
+	if err := s.resolveLink(attachment); err != nil {
+		return nil, "", err
+	}
+	if err := s.checkQuota(ownerID, max(input.Size, 0)); err != nil {
+		return nil, "", err
+	}
+	uploadURL, err := s.blob.PresignPut(attachment.Key, s.settings.PresignTTL)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := s.attachmentRepo.Create(attachment); err != nil {
+		return nil, "", err
+	}
+	return attachment, uploadURL, nil
+}
+
+// CompleteUpload 读取直传的文件并按与 Upload 相同的规则校验，不通过时删除文件与记录；
+// 通过后写入正式的对象键并删除暂存文件，重复确认时直接返回
+func (s *attachmentService) CompleteUpload(ctx context.Context, attachmentID, userID uuid.UUID) (*tables.Attachment, error) {
+	attachment, err := s.findAttachment(attachmentID)
+	if err != nil {
+		return nil, err
+	}
+	if attachment.OwnerID != userID {
+		return nil, ErrNotAttachmentOwner
+	}
+	if attachment.SHA256 != "" {
+		return attachment, nil
+	}
+
+	reader, err := s.blob.Open(ctx, attachment.Key)
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrAttachmentNotUploaded
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := readLimited(reader, s.settings.Upload.MaxSize)
+	reader.Close()
+	if errors.Is(err, ErrFileTooLarge) {
+		return nil, s.reject(ctx, attachment, s.tooLarge())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 实际内容必须与申请上传地址时的文件类型一致
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if mediaType != attachment.MimeType {
+		return nil, s.reject(ctx, attachment, fmt.Errorf("%w：%s", ErrFileTypeNotAllowed, mediaType))
+	}
+	if strippableImage(mediaType) {
+		data, err = stripImageMetadata(mediaType, data)
+		if err != nil {
+			return nil, s.reject(ctx, attachment, err)
+		}
+	}
+	if err := s.checkQuota(userID, int64(len(data))); err != nil {
+		return nil, s.reject(ctx, attachment, err)
+	}
+
+	incomingKey := attachment.Key
+	finalKey := storage.NewKey(attachmentPrefix, keyFilename(attachment.Filename, mediaType))
+	if err := s.blob.Put(ctx, finalKey, bytes.NewReader(data), int64(len(data)), mediaType); err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(data)
+	attachment.Key = finalKey
+	attachment.Size = int64(len(data))
+	attachment.SHA256 = hex.EncodeToString(hash[:])
+	if err := s.attachmentRepo.Update(attachment); err != nil {
+		if err := s.blob.Delete(ctx, finalKey); err != nil {
+			log.Println("删除未保存的附件失败:", err)
+		}
+		return nil, err
+	}
+	if err := s.blob.Delete(ctx, incomingKey); err != nil {
+		log.Println("删除直传暂存文件失败:", err)
+	}
+	return attachment, nil
+}
+
+func (s *attachmentService) UploadLimit(key string) (int64, error) {
+	if !strings.HasPrefix(key, attachmentIncomingPrefix+"/") {
+		return 0, ErrUploadClosed
+	}
+	_, err := s.attachmentRepo.FindPendingByKey(key)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrUploadClosed
+	}
+	if err != nil {
+		return 0, err
+	}
+	return s.settings.Upload.MaxSize, nil
+}
+
+// checkQuota 检查用户已上传的附件加上 size 字节后是否超过配额
+func (s *attachmentService) checkQuota(ownerID uuid.UUID, size int64) error {
+	quota := s.settings.Upload.UserQuota
+	if quota <= 0 {
+		return nil
+	}
+	used, err := s.attachmentRepo.SumSizeByOwner(ownerID)
+	if err != nil {
+		return err
+	}
+	if used+size > quota {
+		return fmt.Errorf("%w（已使用 %s，上限 %s）", ErrStorageQuotaExceeded, formatBytes(used), formatBytes(quota))
+	}
+	return nil
+}
+
+func (s *attachmentService) tooLarge() error {
+	return fmt.Errorf("%w：附件最大 %s", ErrFileTooLarge, formatBytes(s.settings.Upload.MaxSize))
+}
+
+// reject 删除未通过校验的直传文件及其记录，返回校验错误
+func (s *attachmentService) reject(ctx context.Context, attachment *tables.Attachment, cause error) error {
+	if err := s.remove(ctx, attachment); err != nil {
+		log.Println("删除未通过校验的附件失败:", err)
+	}
+	return cause
+}
+
+func (s *attachmentService) DownloadURL(attachmentID, userID uuid.UUID) (string, error) {
+	attachment, err := s.findAttachment(attachmentID)
+	if err != nil {
+		return "", err
+	}
+	if err := s.authorize(attachment, userID); err != nil {
+		return "", err
+	}
+	return s.blob.PresignGet(attachment.Key, s.settings.PresignTTL)
+}
+
+// authorize 上传者总能访问；未关联悬赏令的附件只有上传者能访问；
+// 悬赏令的发布者总能访问，私有悬赏令仅对接收者开放，需要保密协议的悬赏令要求先接受协议
+func (s *attachmentService) authorize(attachment *tables.Attachment, userID uuid.UUID) error {
+	if attachment.OwnerID == userID {
+		return nil
+	}
+	if attachment.BountyID == nil {
+		return ErrAttachmentForbidden
+	}
+	bounty, err := s.findBounty(*attachment.BountyID)
+	if errors.Is(err, ErrAttachmentNotFound) {
+		return ErrAttachmentForbidden
+	}
+	if err != nil {
+		return err
+	}
+
+	publisher, participant, err := s.bountyRole(bounty, userID)
+	if err != nil {
+		return err
+	}
+	if publisher {
+		return nil
+	}
+	if bounty.Visibility == "private" && !participant {
+		return ErrAttachmentForbidden
+	}
+	if bounty.NDARequired {
+		accepted, err := s.attachmentRepo.HasAcceptedNDA(bounty.ID, userID)
+		if err != nil {
+			return err
+		}
+		if !accepted {
+			return ErrNDANotAccepted
+		}
+	}
+	return nil
+}
+
+// resolveLink 校验附件关联的悬赏令与里程碑，关联里程碑时补全所属的悬赏令，
+// 上传者必须是该悬赏令的发布者或接收者
+func (s *attachmentService) resolveLink(attachment *tables.Attachment) error {
+	if attachment.MilestoneID != nil {
+		milestone, err := s.milestoneRepo.FindByID(*attachment.MilestoneID)
+		if err != nil {
+			return err
+		}
+		if milestone == nil || (attachment.BountyID != nil && *attachment.BountyID != milestone.BountyID) {
+			return ErrAttachmentLinkNotFound
+		}
+		attachment.BountyID = &milestone.BountyID
+	}
+	if attachment.BountyID == nil {
+		return nil
+	}
+
+	bounty, err := s.findBounty(*attachment.BountyID)
+	if errors.Is(err, ErrAttachmentNotFound) {
+		return ErrAttachmentLinkNotFound
+	}
+	if err != nil {
+		return err
+	}
+	publisher, participant, err := s.bountyRole(bounty, attachment.OwnerID)
+	if err != nil {
+		return err
+	}
+	if !publisher && !participant {
+		return ErrAttachmentLinkDenied
+	}
+	return nil
+}
+
+// bountyRole 判断用户是否为悬赏令的发布者或接收者
+func (s *attachmentService) bountyRole(bounty *tables.Bounty, userID uuid.UUID) (publisher, participant bool, err error) {
+	if bounty.UserID == userID {
+		return true, false, nil
+	}
+	if bounty.ReceiverID != nil && *bounty.ReceiverID == userID {
+		return false, true, nil
+	}
+	participants, err := bountyParticipants(s.teamRepo, s.applicationRepo, bounty)
+	if err != nil {
+		return false, false, err
+	}
+	return false, slices.Contains(participants, userID), nil
+}
+
+func (s *attachmentService) Delete(ctx context.Context, attachmentID, userID uuid.UUID) error {
+	attachment, err := s.findAttachment(attachmentID)
+	if err != nil {
+		return err
+	}
+	if attachment.OwnerID != userID {
+		return ErrNotAttachmentOwner
+	}
+	return s.remove(ctx, attachment)
+}
+
+func (s *attachmentService) AcceptNDA(bountyID, userID uuid.UUID) error {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if err != nil {
+		return err
+	}
+	if !bounty.NDARequired {
+		return ErrNDANotRequired
+	}
+	return s.attachmentRepo.AcceptNDA(&tables.BountyNDAAcceptance{
+		BountyID:   bountyID,
+		UserID:     userID,
+		AcceptedAt: time.Now(),
+	})
+}
+
+func (s *attachmentService) CollectGarbage(ctx context.Context, now time.Time) (int, error) {
+	removed := 0
+	for {
+		orphans, err := s.attachmentRepo.FindOrphans(now.Add(-s.settings.OrphanTTL), attachmentSweepBatch)
+		if err != nil {
+			return removed, err
+		}
+		for i := range orphans {
+			// 删除失败的附件下一轮会再次被找到，本轮直接结束避免重复处理
+			if err := s.remove(ctx, &orphans[i]); err != nil {
+				return removed, err
+			}
+			removed++
+		}
+		if len(orphans) < attachmentSweepBatch {
+			return removed, nil
+		}
+	}
+}
+
+func (s *attachmentService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.settings.SweepInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.CollectGarbage(ctx, time.Now()); err != nil {
+			log.Println("回收孤儿附件失败:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// remove 先删除文件再删除记录，删除记录失败时文件已不存在，下载会得到 404
+func (s *attachmentService) remove(ctx context.Context, attachment *tables.Attachment) error {
+	if err := s.blob.Delete(ctx, attachment.Key); err != nil {
+		return err
+	}
+	return s.attachmentRepo.Delete(attachment)
+}
+
+func (s *attachmentService) findAttachment(id uuid.UUID) (*tables.Attachment, error) {
+	attachment, err := s.attachmentRepo.FindByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, err
+}
+
+func (s *attachmentService) findBounty(id uuid.UUID) (*tables.Bounty, error) {
+	bounty, err := s.bountyRepo.FindBountyByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAttachmentNotFound
+	}
+	return bounty, err
+}
+
+// countingReader 统计读取的字节数
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
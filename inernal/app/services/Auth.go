@@ -5,22 +5,91 @@ import (
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
 	"GeekReward/pkg/utils"
+	"context"
 	"errors"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
-	"os"
+	"log"
+	"time"
 )
 
+var (
+	ErrInvalidCredentials    = errors.New("邮箱或密码不正确")
+	ErrAccountSuspended      = errors.New("账号已被停用，请联系管理员")
+	ErrAccountDeleted        = errors.New("账号已注销")
+	ErrInvalidChallengeToken = errors.New("登录验证已过期，请重新输入密码")
+	ErrInvalidRefreshToken   = errors.New("刷新令牌无效或已过期")
+	ErrRefreshTokenReused    = errors.New("刷新令牌已被使用，会话已被吊销，请重新登录")
+)
+
+// 登录锁定的范围
+const (
+	LockScopeAccount = "account" // 该账号失败次数过多
+	LockScopeIP      = "ip"      // 该 IP 失败次数过多
+)
+
+// LoginLockedError 登录失败次数过多，在 Until 之前拒绝登录
+type LoginLockedError struct {
+	Scope string
+	Until time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	if e.Scope == LockScopeIP {
+		return "登录尝试过于频繁，请稍后再试"
+	}
+	return "登录失败次数过多，账号已被临时锁定"
+}
+
+// AuthSettings 认证相关的配置
+type AuthSettings struct {
+	RefreshTokenTTL time.Duration
+	AccountLockout  LockoutPolicy // 按账号统计的登录失败锁定策略
+}
+
 type AuthService interface {
 	Register(input dtos.RegisterInput) (*tables.User, error)
-	Login(input dtos.LoginInput) (string, *tables.User, error)
+	// Login 校验密码，启用了两步验证的用户只返回挑战令牌
+	Login(input dtos.LoginInput, client dtos.ClientInfo) (*dtos.LoginResult, error)
+	// VerifyTwoFactorLogin 两步登录的第二步，校验挑战令牌与验证码后签发令牌
+	VerifyTwoFactorLogin(input dtos.TwoFactorLoginInput, client dtos.ClientInfo) (*dtos.LoginResult, error)
+	// RefreshToken 使用刷新令牌换取新的访问令牌，同时轮换刷新令牌
+	RefreshToken(refreshToken string) (*dtos.TokenPair, error)
+	// Logout 注销指定会话
+	Logout(sessionID uuid.UUID) error
+	// LogoutAll 注销用户的所有会话
+	LogoutAll(userID uuid.UUID) error
+	// IsSessionActive 判断访问令牌所属的会话是否仍然有效，供认证中间件使用
+	IsSessionActive(sessionID, userID uuid.UUID) bool
+	// UnlockAccount 管理员解除账号的登录锁定
+	UnlockAccount(userID uuid.UUID) error
 }
 
 type authService struct {
-	userRepo repositories.UserRepository
+	userRepo         repositories.UserRepository
+	sessionRepo      repositories.SessionRepository
+	twoFactorService TwoFactorService
+	ipLimiter        LoginLimiter
+	avatarService    AvatarService
+	settings         AuthSettings
 }
 
-func NewAuthService(userRepo repositories.UserRepository) AuthService {
-	return &authService{userRepo: userRepo}
+func NewAuthService(
+	userRepo repositories.UserRepository,
+	sessionRepo repositories.SessionRepository,
+	twoFactorService TwoFactorService,
+	ipLimiter LoginLimiter,
+	avatarService AvatarService,
+	settings AuthSettings,
+) AuthService {
+	return &authService{
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		twoFactorService: twoFactorService,
+		ipLimiter:        ipLimiter,
+		avatarService:    avatarService,
+		settings:         settings,
+	}
 }
 
 func (s *authService) Register(input dtos.RegisterInput) (*tables.User, error) {
@@ -61,12 +130,10 @@ func (s *authService) Register(input dtos.RegisterInput) (*tables.User, error) {
 
 	// 5. 创建数据库记录
 	if err := s.userRepo.CreateUser(user); err != nil {
-		// 如果数据库写入失败 & 用户上传了头像, 可能考虑删除文件
-		if input.ProfilePicture != "" {
-			// 这里的 input.ProfilePicture 是 "/uploads/avatars/xxx.jpg"
-			// 如果想删除物理文件，需要组合 ./uploads/avatars/xxx.jpg
-			filePath := "." + input.ProfilePicture // 变成 ./uploads/avatars/xxx.jpg
-			_ = os.Remove(filePath)
+		// 如果数据库写入失败 & 用户上传了头像, 删除已保存的文件及其缩略图
+		// 这里的 input.ProfilePicture 是 "/uploads/avatars/xxx.jpg"，对应对象键 avatars/xxx.jpg
+		if err := s.avatarService.Delete(context.Background(), input.ProfilePicture); err != nil {
+			log.Println("删除头像文件失败:", err)
 		}
 		return nil, err
 	}
@@ -74,23 +141,239 @@ func (s *authService) Register(input dtos.RegisterInput) (*tables.User, error) {
 	return user, nil
 }
 
-func (s *authService) Login(input dtos.LoginInput) (string, *tables.User, error) {
+func (s *authService) Login(input dtos.LoginInput, client dtos.ClientInfo) (*dtos.LoginResult, error) {
+	// 该 IP 失败次数过多时，不再校验任何账号
+	if until, locked := s.ipLimiter.LockedUntil(client.IPAddress); locked {
+		return nil, &LoginLockedError{Scope: LockScopeIP, Until: until}
+	}
+
 	user, err := s.userRepo.FindByEmail(input.Email)
 	if err != nil {
-		return "", nil, errors.New("用户不存在或数据库错误")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 与密码错误返回相同的错误，避免暴露邮箱是否已注册
+			if until, locked := s.ipLimiter.Fail(client.IPAddress); locked {
+				return nil, &LoginLockedError{Scope: LockScopeIP, Until: until}
+			}
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	// 账号处于锁定期内时不校验密码
+	if err := checkAccountLock(user); err != nil {
+		return nil, err
 	}
 
 	// 校验密码
 	if !utils.CheckPasswordHash(input.Password, user.Password) {
-		return "", nil, errors.New("密码不正确")
+		return nil, s.recordLoginFailure(user, client, ErrInvalidCredentials)
+	}
+
+	// 密码正确后才返回账号状态，避免通过状态探测账号
+	if err := checkAccountStatus(user); err != nil {
+		return nil, err
+	}
+
+	// 启用了两步验证时，只签发挑战令牌，等待用户提交验证码
+	if user.TwoFactorEnabled {
+		challenge, err := utils.GenerateChallengeToken(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		return &dtos.LoginResult{User: user, TwoFactorRequired: true, ChallengeToken: challenge}, nil
+	}
+
+	// 创建会话并签发令牌
+	return s.completeLogin(user, client)
+}
+
+func (s *authService) VerifyTwoFactorLogin(input dtos.TwoFactorLoginInput, client dtos.ClientInfo) (*dtos.LoginResult, error) {
+	userID, err := utils.ValidateChallengeToken(input.ChallengeToken)
+	if err != nil {
+		return nil, ErrInvalidChallengeToken
 	}
 
-	// 生成JWT
-	token, err := utils.GenerateJWT(user.ID) // 传入 user.ID
+	if until, locked := s.ipLimiter.LockedUntil(client.IPAddress); locked {
+		return nil, &LoginLockedError{Scope: LockScopeIP, Until: until}
+	}
+
+	user, err := s.userRepo.FindByUserID(userID)
 	if err != nil {
-		return "", nil, err
+		return nil, ErrInvalidChallengeToken
+	}
+	if err := checkAccountLock(user); err != nil {
+		return nil, err
+	}
+	if err := checkAccountStatus(user); err != nil {
+		return nil, err
 	}
 
-	// 4. 返回 token, user
-	return token, user, nil
+	// 校验 TOTP 验证码或恢复码，验证码错误同样计入失败次数
+	if err := s.twoFactorService.Verify(user, input.Code); err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			return nil, s.recordLoginFailure(user, client, err)
+		}
+		return nil, err
+	}
+
+	return s.completeLogin(user, client)
+}
+
+// completeLogin 清零失败次数、记录登录时间，并创建会话签发令牌
+func (s *authService) completeLogin(user *tables.User, client dtos.ClientInfo) (*dtos.LoginResult, error) {
+	now := time.Now()
+	if err := s.userRepo.RecordLoginSuccess(user.ID, now); err != nil {
+		return nil, err
+	}
+	user.LoginAttempts = 0
+	user.LockedUntil = nil
+	user.LastLogin = now
+
+	tokens, err := s.createSession(user.ID, client)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.LoginResult{User: user, Tokens: tokens}, nil
+}
+
+// recordLoginFailure 同时按账号与 IP 记录一次失败，达到阈值时返回锁定错误，否则返回 cause
+func (s *authService) recordLoginFailure(user *tables.User, client dtos.ClientInfo, cause error) error {
+	ipUntil, ipLocked := s.ipLimiter.Fail(client.IPAddress)
+
+	attempts, err := s.userRepo.IncrementLoginAttempts(user.ID)
+	if err != nil {
+		return err
+	}
+	if lock := s.settings.AccountLockout.LockDuration(attempts); lock > 0 {
+		until := time.Now().Add(lock)
+		if err := s.userRepo.LockUntil(user.ID, until); err != nil {
+			return err
+		}
+		return &LoginLockedError{Scope: LockScopeAccount, Until: until}
+	}
+
+	if ipLocked {
+		return &LoginLockedError{Scope: LockScopeIP, Until: ipUntil}
+	}
+	return cause
+}
+
+// checkAccountLock 账号处于锁定期内时返回锁定错误
+func checkAccountLock(user *tables.User) error {
+	if user.LockedUntil != nil && time.Now().Before(*user.LockedUntil) {
+		return &LoginLockedError{Scope: LockScopeAccount, Until: *user.LockedUntil}
+	}
+	return nil
+}
+
+// checkAccountStatus 停用或注销的账号不允许登录
+func checkAccountStatus(user *tables.User) error {
+	switch user.AccountStatus {
+	case tables.AccountStatusSuspended:
+		return ErrAccountSuspended
+	case tables.AccountStatusDeleted:
+		return ErrAccountDeleted
+	}
+	return nil
+}
+
+// createSession 创建新的登录会话，签发访问令牌与刷新令牌
+func (s *authService) createSession(userID uuid.UUID, client dtos.ClientInfo) (*dtos.TokenPair, error) {
+	refreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &tables.Session{
+		UserID:           userID,
+		RefreshTokenHash: utils.HashToken(refreshToken),
+		ExpiresAt:        now.Add(s.settings.RefreshTokenTTL),
+		LastUsedAt:       now,
+		UserAgent:        client.UserAgent,
+		IPAddress:        client.IPAddress,
+	}
+	if err := s.sessionRepo.Create(session); err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(session, refreshToken)
+}
+
+// tokenPair 为会话签发访问令牌，并与刷新令牌原文组合返回
+func (s *authService) tokenPair(session *tables.Session, refreshToken string) (*dtos.TokenPair, error) {
+	accessToken, err := utils.GenerateJWT(session.UserID, session.ID)
+	if err != nil {
+		return nil, err
+	}
+	return &dtos.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(utils.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+func (s *authService) RefreshToken(refreshToken string) (*dtos.TokenPair, error) {
+	hash := utils.HashToken(refreshToken)
+
+	session, err := s.sessionRepo.FindByRefreshTokenHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		// 已经轮换掉的刷新令牌再次出现，说明令牌可能被盗用，直接吊销整个会话
+		reused, err := s.sessionRepo.FindByPreviousTokenHash(hash)
+		if err != nil {
+			return nil, err
+		}
+		if reused != nil {
+			if err := s.sessionRepo.Revoke(reused.ID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if !session.IsActive(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// 轮换刷新令牌
+	newRefreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := s.sessionRepo.Rotate(session, utils.HashToken(newRefreshToken), time.Now().Add(s.settings.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// 并发请求已经先一步轮换或注销了该会话
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.tokenPair(session, newRefreshToken)
+}
+
+func (s *authService) Logout(sessionID uuid.UUID) error {
+	return s.sessionRepo.Revoke(sessionID)
+}
+
+func (s *authService) LogoutAll(userID uuid.UUID) error {
+	return s.sessionRepo.RevokeAllByUserID(userID)
+}
+
+func (s *authService) IsSessionActive(sessionID, userID uuid.UUID) bool {
+	session, err := s.sessionRepo.FindByID(sessionID)
+	if err != nil || session == nil {
+		return false
+	}
+	return session.UserID == userID && session.IsActive(time.Now())
+}
+
+func (s *authService) UnlockAccount(userID uuid.UUID) error {
+	if _, err := s.userRepo.FindByUserID(userID); err != nil {
+		return err
+	}
+	return s.userRepo.ResetLoginAttempts(userID)
 }
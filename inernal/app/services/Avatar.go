@@ -0,0 +1,185 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/imaging"
+	"GeekReward/pkg/storage"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// AvatarPrefix 头像在对象存储中的键前缀，头像是公开的，可以通过 /uploads 直接访问
+const AvatarPrefix = "avatars"
+
+// avatarTypes 可以生成缩略图的头像类型
+var avatarTypes = []string{"image/jpeg", "image/png", "image/gif"}
+
+// AvatarService 保存用户头像：校验图片、删除元数据并生成各尺寸的正方形缩略图。
+// 缩略图与原图保存在一起，对象键为原图键去掉扩展名后加 _<边长>.png，如 avatars/<id>_64.png
+type AvatarService interface {
+	// Upload 校验并保存头像，返回原图与缩略图的地址
+	Upload(ctx context.Context, r io.Reader, size int64) (*dtos.Avatar, error)
+	// SetUserAvatar 保存新头像并替换用户当前的头像，旧头像及其缩略图随之删除
+	SetUserAvatar(ctx context.Context, userID uuid.UUID, r io.Reader, size int64) (*dtos.Avatar, error)
+	// Variants 返回头像地址对应的缩略图地址，不是本站保存的头像时返回 nil
+	Variants(profilePicture string) map[string]string
+	// Delete 删除头像及其缩略图，不是本站保存的头像时不做任何操作
+	Delete(ctx context.Context, profilePicture string) error
+}
+
+type avatarService struct {
+	userRepo repositories.UserRepository
+	blob     storage.Blob
+	settings UploadSettings
+}
+
+func NewAvatarService(userRepo repositories.UserRepository, blob storage.Blob, settings UploadSettings) AvatarService {
+	return &avatarService{
+		userRepo: userRepo,
+		blob:     blob,
+		settings: settings.withDefaults(),
+	}
+}
+
+func (s *avatarService) Upload(ctx context.Context, r io.Reader, size int64) (*dtos.Avatar, error) {
+	if size > s.settings.AvatarMaxSize {
+		return nil, s.tooLarge()
+	}
+	mediaType, content, err := sniffContentType(r)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(avatarTypes, mediaType) {
+		return nil, fmt.Errorf("%w：头像仅支持 JPEG、PNG、GIF", ErrFileTypeNotAllowed)
+	}
+	data, err := readLimited(content, s.settings.AvatarMaxSize)
+	if errors.Is(err, ErrFileTooLarge) {
+		return nil, s.tooLarge()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	img, orientation, err := imaging.Decode(data, s.settings.AvatarMaxPixels)
+	if errors.Is(err, imaging.ErrImageTooLarge) {
+		return nil, fmt.Errorf("%w：头像最多 %d 像素", ErrFileTooLarge, s.settings.AvatarMaxPixels)
+	}
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if data, err = stripImageMetadata(mediaType, data); err != nil {
+		return nil, err
+	}
+
+	key := storage.NewKey(AvatarPrefix, "avatar"+extensionFor(mediaType))
+	written := []string{key}
+	cleanup := func() {
+		for _, k := range written {
+			if err := s.blob.Delete(ctx, k); err != nil {
+				log.Println("删除头像文件失败:", err)
+			}
+		}
+	}
+
+	if err := s.blob.Put(ctx, key, bytes.NewReader(data), int64(len(data)), mediaType); err != nil {
+		return nil, err
+	}
+	for _, side := range s.settings.AvatarSizes {
+		thumbnail, err := imaging.EncodePNG(imaging.Thumbnail(img, orientation, side))
+		if err == nil {
+			variantKey := avatarVariantKey(key, side)
+			written = append(written, variantKey)
+			err = s.blob.Put(ctx, variantKey, bytes.NewReader(thumbnail), int64(len(thumbnail)), "image/png")
+		}
+		if err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+
+	url := storage.PublicURL(key)
+	return &dtos.Avatar{URL: url, Variants: s.Variants(url)}, nil
+}
+
+func (s *avatarService) SetUserAvatar(ctx context.Context, userID uuid.UUID, r io.Reader, size int64) (*dtos.Avatar, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	avatar, err := s.Upload(ctx, r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	// 解码与上传耗时较长，只更新头像字段，避免覆盖期间对用户的其他修改
+	previous := user.ProfilePicture
+	if err := s.userRepo.UpdateProfilePicture(userID, avatar.URL); err != nil {
+		if delErr := s.Delete(ctx, avatar.URL); delErr != nil {
+			log.Println("删除头像文件失败:", delErr)
+		}
+		return nil, err
+	}
+	if err := s.Delete(ctx, previous); err != nil {
+		log.Println("删除旧头像失败:", err)
+	}
+	return avatar, nil
+}
+
+func (s *avatarService) Variants(profilePicture string) map[string]string {
+	key, ok := avatarKey(profilePicture)
+	if !ok {
+		return nil
+	}
+	variants := make(map[string]string, len(s.settings.AvatarSizes))
+	for _, side := range s.settings.AvatarSizes {
+		variants[strconv.Itoa(side)] = storage.PublicURL(avatarVariantKey(key, side))
+	}
+	return variants
+}
+
+func (s *avatarService) Delete(ctx context.Context, profilePicture string) error {
+	key, ok := avatarKey(profilePicture)
+	if !ok {
+		return nil
+	}
+	keys := []string{key}
+	for _, side := range s.settings.AvatarSizes {
+		keys = append(keys, avatarVariantKey(key, side))
+	}
+	for _, k := range keys {
+		if err := s.blob.Delete(ctx, k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *avatarService) tooLarge() error {
+	return fmt.Errorf("%w：头像最大 %s", ErrFileTooLarge, formatBytes(s.settings.AvatarMaxSize))
+}
+
+// avatarKey 从头像地址中解析出原图的对象键
+func avatarKey(profilePicture string) (string, bool) {
+	key, ok := storage.KeyFromURL(profilePicture)
+	if !ok || !strings.HasPrefix(key, AvatarPrefix+"/") {
+		return "", false
+	}
+	return key, true
+}
+
+// avatarVariantKey 返回指定边长缩略图的对象键
+func avatarVariantKey(key string, side int) string {
+	if dot := strings.LastIndex(key, "."); dot > strings.LastIndex(key, "/") {
+		key = key[:dot]
+	}
+	return key + "_" + strconv.Itoa(side) + ".png"
+}
@@ -1,52 +1,83 @@
 package services
 
 import (
+	"GeekReward/inernal/app/authz"
 	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/utils"
 	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"log"
+	"math"
+	"slices"
+	"sort"
 	"time"
 )
 
+var (
+	ErrBountyDeleteLocked   = errors.New("只能删除尚未指派接收者的悬赏令，已开工的悬赏令请通过取消流程处理")
+	ErrBountyStatusConflict = errors.New("悬赏令状态已被其他操作修改，请刷新后重试")
+)
+
 // BountyService 定义悬赏令相关的服务接口
 type BountyService interface {
 	CreateBounty(input dtos.BountyDTO, userID uuid.UUID) (*tables.Bounty, error)
 	GetBounty(id uuid.UUID) (*tables.Bounty, error)
-	UpdateBounty(id uuid.UUID, input dtos.BountyDTO) (*tables.Bounty, error)
-	DeleteBounty(id uuid.UUID) error
+	UpdateBounty(id, userID uuid.UUID, input dtos.BountyDTO) (*tables.Bounty, error)
+	DeleteBounty(id, userID uuid.UUID) error
 	LikeBounty(userID, bountyID uuid.UUID) error
 	UnlikeBounty(userID, bountyID uuid.UUID) error
 	RateBounty(userID, bountyID uuid.UUID, score float64) error
 	IncrementViewCount(bountyID uuid.UUID) error
-	GetBountiesByUserID(userID uuid.UUID) ([]tables.Bounty, error)
-	GetReceivedBounties(userID uuid.UUID) ([]tables.Bounty, error)
+	GetBountiesByUserID(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error)
+	GetReceivedBounties(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error)
 	GetUserBountyInteraction(userID, bountyID uuid.UUID) (*dtos.BountyInteraction, error)
-	SettleBountyAccounts(bountyID uuid.UUID) error
+	SettleBountyAccounts(bountyID, userID uuid.UUID) error
 	ConfirmMilestones(bountyID uuid.UUID, userID uuid.UUID) error
 	VerifyMilestones(bountyID, userID uuid.UUID) error
 	ApplySettlement(bountyID, userID uuid.UUID) error
-	FindBounties(filters dtos.BountyFilter) ([]tables.Bounty, error)
+	FindBounties(filters dtos.BountyFilter, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error)
+	SearchBounties(query dtos.BountySearchQuery) (*dtos.BountySearchResult, error)
 	PostComment(userID, bountyID uuid.UUID, content string) (*tables.Comment, error)
-	GetCommentsByBountyID(bountyID uuid.UUID) ([]tables.Comment, error)
+	GetCommentsByBountyID(bountyID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Comment], error)
+	GetBountyTimeline(bountyID uuid.UUID) ([]dtos.BountyTimelineEvent, error)
 
 	// CancelSettlementByPublisher 发布方取消处于Settling状态的悬赏令
 	CancelSettlementByPublisher(bountyID, userID uuid.UUID) error
 
 	// CancelSettlementByReceiver 接收方取消处于Settling状态的悬赏令
 	CancelSettlementByReceiver(bountyID, userID uuid.UUID) error
+
+	// ForceCancelBounty 管理员以系统身份强制取消悬赏令，托管赏金全额退还发布者
+	ForceCancelBounty(bountyID, actorID uuid.UUID, reason string) error
+
+	// GetSettlement 获取结算明细，结算前为预览
+	GetSettlement(bountyID, userID uuid.UUID) (*dtos.SettlementView, error)
+
+	// UpdateSplitPlan 接收者设置赏金的分配方式，团队接下的悬赏令只有队长可以设置
+	UpdateSplitPlan(bountyID, userID uuid.UUID, input dtos.UpdateSplitPlanInput) (*dtos.SettlementView, error)
+
+	// DeleteComment 删除评论并更新悬赏令的评论数
+	DeleteComment(commentID uuid.UUID) error
 }
 
 // bountyService 是 BountyService 接口的具体实现
 type bountyService struct {
-	userRepo         repositories.UserRepository
-	bountyRepo       repositories.BountyRepository
-	applicationRepo  repositories.ApplicationRepository
-	notificationRepo repositories.NotificationRepository
-	milestoneRepo    repositories.MilestoneRepository
+	userRepo        repositories.UserRepository
+	bountyRepo      repositories.BountyRepository
+	applicationRepo repositories.ApplicationRepository
+	milestoneRepo   repositories.MilestoneRepository
+	ledgerRepo      repositories.LedgerRepository
+	teamRepo        repositories.TeamRepository
+	settlementRepo  repositories.SettlementRepository
+	attachmentRepo  repositories.AttachmentRepository
+	outboxRepo      repositories.OutboxRepository
+	txManager       repositories.TransactionManager
+	penaltyRates    PenaltyRates
+	hub             NotificationHub
 }
 
 // PostComment 用户对某个bounty发表评论
@@ -71,10 +102,9 @@ func (s *bountyService) PostComment(userID, bountyID uuid.UUID, content string)
 	return comment, nil
 }
 
-// GetCommentsByBountyID 获取某个bounty下的所有评论(含user信息)
-func (s *bountyService) GetCommentsByBountyID(bountyID uuid.UUID) ([]tables.Comment, error) {
-	// 直接调用commentRepo.GetCommentsByBountyID
-	return s.bountyRepo.GetCommentsByBountyID(bountyID)
+// GetCommentsByBountyID 分页获取某个bounty下的评论(含user信息)
+func (s *bountyService) GetCommentsByBountyID(bountyID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Comment], error) {
+	return s.bountyRepo.FindCommentsPage(bountyID, page)
 }
 
 // NewBountyService 创建一个新的 BountyService 实例
@@ -82,15 +112,29 @@ func NewBountyService(
 	userRepo repositories.UserRepository,
 	bountyRepo repositories.BountyRepository,
 	applicationRepo repositories.ApplicationRepository,
-	notificationRepo repositories.NotificationRepository,
 	milestoneRepo repositories.MilestoneRepository,
+	ledgerRepo repositories.LedgerRepository,
+	teamRepo repositories.TeamRepository,
+	settlementRepo repositories.SettlementRepository,
+	attachmentRepo repositories.AttachmentRepository,
+	outboxRepo repositories.OutboxRepository,
+	txManager repositories.TransactionManager,
+	penaltyRates PenaltyRates,
+	hub NotificationHub,
 ) BountyService {
 	return &bountyService{
-		userRepo:         userRepo,
-		bountyRepo:       bountyRepo,
-		applicationRepo:  applicationRepo,
-		notificationRepo: notificationRepo,
-		milestoneRepo:    milestoneRepo,
+		userRepo:        userRepo,
+		bountyRepo:      bountyRepo,
+		applicationRepo: applicationRepo,
+		milestoneRepo:   milestoneRepo,
+		ledgerRepo:      ledgerRepo,
+		teamRepo:        teamRepo,
+		settlementRepo:  settlementRepo,
+		attachmentRepo:  attachmentRepo,
+		outboxRepo:      outboxRepo,
+		txManager:       txManager,
+		penaltyRates:    penaltyRates,
+		hub:             hub,
 	}
 }
 
@@ -105,25 +149,52 @@ func (s *bountyService) CancelSettlementByPublisher(bountyID, userID uuid.UUID)
 	}
 
 	// 发布方必须是 bounty.UserID
-	if bounty.UserID != userID {
-		return errors.New("you are not the publisher of this bounty")
+	if err := authz.Can(userID, authz.ActionSettlementCancelPublisher, bounty); err != nil {
+		return err
 	}
+	actor := BountyActorPublisher
 
-	// 仅当 bounty.Status == Settling 时，才能取消
-	if bounty.Status != tables.BountyStatusSettling {
-		return fmt.Errorf("bounty is not in settling state, current status: %s", bounty.Status)
+	// 由状态机校验：仅当 bounty.Status == Settling 时，才能取消
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusCancelled, actor, &userID, "发布方取消清算")
+	if err != nil {
+		return err
 	}
 
-	// TODO: 扣除违约金 -> 资金相关处理
-	// 例如: payPenalty(bounty.UserID, "publisher", bounty.Reward * 0.1)
+	// 在同一事务中扣除违约金、退还剩余赏金并更新状态
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if bounty.PaymentStatus == tables.PaymentStatusEscrowed {
+			ledgerRepo := s.ledgerRepo.WithTx(tx)
+			escrowed, err := escrowBalance(ledgerRepo, bounty.ID)
+			if err != nil {
+				return err
+			}
 
-	// 状态改为Cancelled
-	bounty.Status = tables.BountyStatusCancelled
-	bounty.UpdatedAt = time.Now()
-	if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
+			// 发布方违约金从托管赏金中赔付给接收者，其余退还发布方
+			penalty := 0.0
+			transfers := []ledgerTransfer{{tables.LedgerAccountEscrow, bounty.ID, -escrowed}}
+			if bounty.ReceiverID != nil {
+				penalty = math.Min(utils.RoundCents(bounty.Reward*s.penaltyRates.Publisher), escrowed)
+				transfers = append(transfers, ledgerTransfer{tables.LedgerAccountUser, *bounty.ReceiverID, penalty})
+			}
+			transfers = append(transfers, ledgerTransfer{tables.LedgerAccountUser, bounty.UserID, escrowed - penalty})
+
+			err = postLedgerEntry(ledgerRepo, tables.JournalEntryCancelByPublisher, &bounty.ID,
+				"发布方取消悬赏令【"+bounty.Title+"】，违约金 "+fmt.Sprintf("%.2f", penalty),
+				transfers...,
+			)
+			if err != nil {
+				return err
+			}
+			bounty.PaymentStatus = tables.PaymentStatusRefunded
+		}
+
+		// 持久化 Cancelled 状态
+		return s.saveTransition(tx, bounty, change)
+	})
+	if err != nil {
 		return err
 	}
-
+	publishBountyStatusChange(s.hub, bounty, change)
 	return nil
 }
 
@@ -138,31 +209,174 @@ func (s *bountyService) CancelSettlementByReceiver(bountyID, userID uuid.UUID) e
 	}
 
 	// 接收方必须是 bounty.ReceiverID
-	if bounty.ReceiverID == nil || *bounty.ReceiverID != userID {
-		return errors.New("you are not the receiver of this bounty")
+	if err := authz.Can(userID, authz.ActionSettlementCancelReceiver, bounty); err != nil {
+		return err
 	}
+	actor := BountyActorReceiver
 
-	// 必须处于 Settling 状态
-	if bounty.Status != tables.BountyStatusSettling {
-		return fmt.Errorf("bounty is not in settling state, current status: %s", bounty.Status)
+	// 由状态机校验：必须处于 Settling 状态
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusCancelled, actor, &userID, "接收方取消清算")
+	if err != nil {
+		return err
 	}
 
-	// TODO: 扣除违约金 -> 资金相关处理
-	// 例如: payPenalty(*bounty.ReceiverID, "receiver", bounty.Reward * 0.05)
+	// 在同一事务中退还赏金、扣除违约金并更新状态
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if bounty.PaymentStatus == tables.PaymentStatusEscrowed {
+			ledgerRepo := s.ledgerRepo.WithTx(tx)
+			escrowed, err := escrowBalance(ledgerRepo, bounty.ID)
+			if err != nil {
+				return err
+			}
+
+			// 托管赏金全额退还发布方，接收方违约金从其钱包中赔付给发布方
+			penalty := utils.RoundCents(bounty.Reward * s.penaltyRates.Receiver)
+			err = postLedgerEntry(ledgerRepo, tables.JournalEntryCancelByReceiver, &bounty.ID,
+				"接收方取消悬赏令【"+bounty.Title+"】，违约金 "+fmt.Sprintf("%.2f", penalty),
+				ledgerTransfer{tables.LedgerAccountEscrow, bounty.ID, -escrowed},
+				ledgerTransfer{tables.LedgerAccountUser, *bounty.ReceiverID, -penalty},
+				ledgerTransfer{tables.LedgerAccountUser, bounty.UserID, escrowed + penalty},
+			)
+			if err != nil {
+				return err
+			}
+			bounty.PaymentStatus = tables.PaymentStatusRefunded
+		}
 
-	// 状态改为Cancelled
-	bounty.Status = tables.BountyStatusCancelled
-	bounty.UpdatedAt = time.Now()
-	if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
+		// 持久化 Cancelled 状态
+		return s.saveTransition(tx, bounty, change)
+	})
+	if err != nil {
+		return err
+	}
+	publishBountyStatusChange(s.hub, bounty, change)
+	return nil
+}
+
+// ForceCancelBounty 管理员以系统身份强制取消悬赏令，不收取违约金
+func (s *bountyService) ForceCancelBounty(bountyID, actorID uuid.UUID, reason string) error {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if err != nil {
+		return err
+	}
+
+	// 由状态机校验：已结算或已取消的悬赏令不能再取消
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusCancelled, BountyActorSystem, &actorID, reason)
+	if err != nil {
 		return err
 	}
 
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if bounty.PaymentStatus == tables.PaymentStatusEscrowed {
+			ledgerRepo := s.ledgerRepo.WithTx(tx)
+			escrowed, err := escrowBalance(ledgerRepo, bounty.ID)
+			if err != nil {
+				return err
+			}
+			err = postLedgerEntry(ledgerRepo, tables.JournalEntryForceCancel, &bounty.ID,
+				"管理员取消悬赏令【"+bounty.Title+"】，退还赏金",
+				ledgerTransfer{tables.LedgerAccountEscrow, bounty.ID, -escrowed},
+				ledgerTransfer{tables.LedgerAccountUser, bounty.UserID, escrowed},
+			)
+			if err != nil {
+				return err
+			}
+			bounty.PaymentStatus = tables.PaymentStatusRefunded
+		}
+		if err := s.saveTransition(tx, bounty, change); err != nil {
+			return err
+		}
+		// 由通知消费者告知发布者与接收者
+		event, err := newOutboxEvent(tables.EventBountyForceCancelled, "Bounty", bounty.ID, &dtos.BountyForceCancelledEvent{
+			BountyID:    bounty.ID,
+			PublisherID: bounty.UserID,
+			ReceiverID:  bounty.ReceiverID,
+			Title:       bounty.Title,
+			ActorID:     actorID,
+			Reason:      reason,
+		})
+		if err != nil {
+			return err
+		}
+		return s.outboxRepo.WithTx(tx).Create(event)
+	})
+	if err != nil {
+		return err
+	}
+	publishBountyStatusChange(s.hub, bounty, change)
 	return nil
 }
 
-func (s *bountyService) FindBounties(filters dtos.BountyFilter) ([]tables.Bounty, error) {
-	// 可在此进行更多业务检查，如：limit过大，status是否有效枚举等
-	return s.bountyRepo.FindBounties(filters)
+// DeleteComment 删除评论并更新悬赏令的评论数
+func (s *bountyService) DeleteComment(commentID uuid.UUID) error {
+	comment, err := s.bountyRepo.FindCommentByID(commentID)
+	if err != nil {
+		return err
+	}
+	return s.txManager.Transaction(func(tx *gorm.DB) error {
+		bountyRepo := s.bountyRepo.WithTx(tx)
+		if err := bountyRepo.DeleteComment(comment); err != nil {
+			return err
+		}
+		return bountyRepo.DecrementField(comment.BountyID, "comments_count")
+	})
+}
+
+// saveTransition 在事务中持久化悬赏令的新状态、对应的状态变更记录及 bounty.status_changed 事件
+func (s *bountyService) saveTransition(tx *gorm.DB, bounty *tables.Bounty, change *tables.BountyStatusChange) error {
+	return saveBountyTransition(tx, s.bountyRepo, s.outboxRepo, bounty, change)
+}
+
+// saveBountyTransition 供其他服务在自己的事务中持久化状态机产生的转换，所有状态变更都经由此处写入事件
+func saveBountyTransition(
+	tx *gorm.DB,
+	bountyRepo repositories.BountyRepository,
+	outboxRepo repositories.OutboxRepository,
+	bounty *tables.Bounty,
+	change *tables.BountyStatusChange,
+) error {
+	bountyRepo = bountyRepo.WithTx(tx)
+	// 悬赏令在事务外读取，只有数据库中仍处于转换前的状态时才写入，并发的另一转换已提交时整个事务回滚
+	updated, err := bountyRepo.UpdateBountyStatus(bounty, change.FromStatus)
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return ErrBountyStatusConflict
+	}
+	if err := bountyRepo.CreateStatusChange(change); err != nil {
+		return err
+	}
+	event, err := newOutboxEvent(tables.EventBountyStatusChanged, "Bounty", bounty.ID, &dtos.BountyStatusChangedEvent{
+		BountyID:    bounty.ID,
+		PublisherID: bounty.UserID,
+		ReceiverID:  bounty.ReceiverID,
+		Title:       bounty.Title,
+		FromStatus:  change.FromStatus,
+		ToStatus:    change.ToStatus,
+		ActorID:     change.ActorID,
+		ActorRole:   change.ActorRole,
+		Reason:      change.Reason,
+	})
+	if err != nil {
+		return err
+	}
+	return outboxRepo.WithTx(tx).Create(event)
+}
+
+// requireMilestonesAccepted 里程碑只能通过发布者验收成果完成，存在未验收的里程碑时返回错误
+func requireMilestonesAccepted(milestones []tables.Milestone) error {
+	for _, m := range milestones {
+		if !m.IsCompleted {
+			return fmt.Errorf("%w：【%s】", ErrMilestonesNotAccepted, m.Title)
+		}
+	}
+	return nil
+}
+
+func (s *bountyService) FindBounties(filters dtos.BountyFilter, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error) {
+	// 可在此进行更多业务检查，如：status是否有效枚举等
+	return s.bountyRepo.FindBounties(filters, page)
 }
 
 // ConfirmMilestones 接受者确认提交所有里程碑
@@ -176,12 +390,13 @@ func (s *bountyService) ConfirmMilestones(bountyID uuid.UUID, userID uuid.UUID)
 		return errors.New("悬赏令未找到")
 	}
 
-	// 2. 检查当前用户是否为接收者
-	if bounty.ReceiverID == nil {
-		return errors.New("悬赏令并未被接收")
+	// 2. 当前用户必须为接收者，再由状态机校验悬赏令处于 Assigned 状态
+	if err := authz.Can(userID, authz.ActionMilestoneConfirm, bounty); err != nil {
+		return err
 	}
-	if *bounty.ReceiverID != userID {
-		return errors.New("你并不是该悬赏令的接收者")
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusMilestonesConfirmed, bountyActorOf(bounty, userID), &userID, "接收者确认提交所有里程碑")
+	if err != nil {
+		return err
 	}
 
 	// 3. 获取该悬赏令下的所有里程碑
@@ -192,27 +407,18 @@ func (s *bountyService) ConfirmMilestones(bountyID uuid.UUID, userID uuid.UUID)
 	if len(milestones) == 0 {
 		return errors.New("该悬赏令下没有对应的里程碑")
 	}
-
-	// 标记里程碑为完成
-	for _, m := range milestones {
-		if !m.IsCompleted {
-			m.IsCompleted = true
-			if err := s.milestoneRepo.UpdateMilestone(&m); err != nil {
-				return err
-			}
-		}
-	}
-	// 更新 BountyStatus -> MilestonesConfirmed
-	if bounty.Status == tables.BountyStatusCreated {
-		bounty.Status = tables.BountyStatusMilestonesConfirmed
-		bounty.UpdatedAt = time.Now()
-		if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("当前 bounty status 字段并不是一个可以有效转换悬赏令转台的状态字段, 当前状态: %v", bounty.Status)
+	if err := requireMilestonesAccepted(milestones); err != nil {
+		return err
 	}
 
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		// 更新 BountyStatus -> MilestonesConfirmed
+		return s.saveTransition(tx, bounty, change)
+	})
+	if err != nil {
+		return err
+	}
+	publishBountyStatusChange(s.hub, bounty, change)
 	return nil
 }
 
@@ -227,9 +433,13 @@ func (s *bountyService) VerifyMilestones(bountyID, userID uuid.UUID) error {
 		return errors.New("悬赏令未找到")
 	}
 
-	// 2. 检查当前用户是否为发布者
-	if bounty.UserID != userID {
-		return errors.New("你不是该悬赏令的发布者")
+	// 2. 当前用户必须为发布者，再由状态机校验里程碑已被接收者确认
+	if err := authz.Can(userID, authz.ActionMilestoneVerify, bounty); err != nil {
+		return err
+	}
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusMilestonesVerified, bountyActorOf(bounty, userID), &userID, "发布者审核并确认所有里程碑")
+	if err != nil {
+		return err
 	}
 
 	// 3. 获取该悬赏令下的所有里程碑
@@ -240,28 +450,19 @@ func (s *bountyService) VerifyMilestones(bountyID, userID uuid.UUID) error {
 	if len(milestones) == 0 {
 		return errors.New("该悬赏令下没有找到对应的里程碑")
 	}
-
-	// 标记里程碑为完成
-	for _, m := range milestones {
-		if !m.IsCompleted {
-			m.IsCompleted = true
-			if err := s.milestoneRepo.UpdateMilestone(&m); err != nil {
-				return err
-			}
-		}
+	if err := requireMilestonesAccepted(milestones); err != nil {
+		return err
 	}
 
-	// 5. 所有里程碑完成 -> 发布者确认
-	// 更新 BountyStatus -> MilestonesConfirmed
-	if bounty.Status == tables.BountyStatusCreated {
-		bounty.Status = tables.BountyStatusMilestonesConfirmed
-		bounty.UpdatedAt = time.Now()
-		if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("当前 bounty status 字段并不是一个可以有效转换悬赏令转台的状态字段, 当前状态: %v", bounty.Status)
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		// 5. 所有里程碑完成 -> 发布者确认
+		// 更新 BountyStatus -> MilestonesVerified
+		return s.saveTransition(tx, bounty, change)
+	})
+	if err != nil {
+		return err
 	}
+	publishBountyStatusChange(s.hub, bounty, change)
 	return nil
 }
 
@@ -276,32 +477,46 @@ func (s *bountyService) ApplySettlement(bountyID, userID uuid.UUID) error {
 		return errors.New("悬赏令未找到")
 	}
 
-	// 2. 检查当前用户是否为接收者
-	if bounty.ReceiverID == nil {
-		return errors.New("悬赏令并未被接收")
+	// 2. 当前用户必须为接收者，再由状态机校验里程碑已被发布者审核
+	if err := authz.Can(userID, authz.ActionSettlementApply, bounty); err != nil {
+		return err
 	}
-	if *bounty.ReceiverID != userID {
-		return errors.New("你不是该悬赏令的接收者")
+	change, err := bountyStateMachine.Transition(bounty, tables.BountyStatusSettling, bountyActorOf(bounty, userID), &userID, "接收者申请清算")
+	if err != nil {
+		return err
 	}
 
-	// 检查 BountyStatus 是否已 Verify
-	if bounty.Status == tables.BountyStatusMilestonesVerified {
-		// 进入清算状态
-		bounty.Status = tables.BountyStatusSettling
-		bounty.UpdatedAt = time.Now()
-		if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
-			return err
-		}
-		// 若需要实际资金结算逻辑，可在此调用
-		// e.g. err = doFinancialSettlement(bounty)
-	} else {
-		return fmt.Errorf("当前 bounty status 字段并不是一个可以有效转换悬赏令转台的状态字段, 当前状态: %v", bounty.Status)
+	// 3. 申请清算前确认分配方式仍然有效，避免发布者确认结算时才失败
+	total, err := escrowBalance(s.ledgerRepo, bounty.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := s.splitLineItems(bounty, total); err != nil {
+		return err
+	}
+
+	// 进入清算状态，实际放款由发布者调用 SettleBountyAccounts 完成
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		return s.saveTransition(tx, bounty, change)
+	})
+	if err != nil {
+		return err
 	}
+	publishBountyStatusChange(s.hub, bounty, change)
 	return nil
 }
 
 // CreateBounty 创建一个新的悬赏令
 func (s *bountyService) CreateBounty(input dtos.BountyDTO, userID uuid.UUID) (*tables.Bounty, error) {
+	// 未验证邮箱的用户不能发布悬赏令
+	publisher, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !publisher.Verified {
+		return nil, ErrEmailNotVerified
+	}
+
 	deadline, err := time.Parse("2006-01-02", input.Deadline)
 	if err != nil {
 		log.Printf("Error parsing deadline: %v", err)
@@ -319,7 +534,7 @@ func (s *bountyService) CreateBounty(input dtos.BountyDTO, userID uuid.UUID) (*t
 		AttachmentURLs:          input.AttachmentUrls,
 		Anonymous:               input.Anonymous,
 		Priority:                input.Priority,
-		PaymentStatus:           input.PaymentStatus,
+		PaymentStatus:           tables.PaymentStatusUnpaid, // 资金状态由账本维护，忽略客户端传入的值
 		PreferredSolutionType:   input.PreferredSolutionType,
 		RequiredSkills:          input.RequiredSkills,
 		RequiredExperience:      input.RequiredExperience,
@@ -341,21 +556,77 @@ func (s *bountyService) CreateBounty(input dtos.BountyDTO, userID uuid.UUID) (*t
 		AcceptanceCriteria:      input.AcceptanceCriteria,
 		PaymentMethod:           input.PaymentMethod,
 		UserID:                  userID,
+		SplitRule:               tables.SplitRuleEqual,
+	}
+	if bounty.Reward < 0 {
+		return nil, errors.New("赏金不能为负数")
 	}
 
-	if err := s.bountyRepo.CreateBounty(bounty); err != nil {
+	// 创建悬赏令与锁定赏金在同一事务中完成，余额不足时整体回滚
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if bounty.Reward > 0 {
+			bounty.PaymentStatus = tables.PaymentStatusEscrowed
+		}
+		if err := s.bountyRepo.WithTx(tx).CreateBounty(bounty); err != nil {
+			return err
+		}
+		if err := s.linkAttachments(tx, bounty); err != nil {
+			return err
+		}
+		// 记录初始状态，作为时间线的起点
+		if err := s.bountyRepo.WithTx(tx).CreateStatusChange(&tables.BountyStatusChange{
+			BountyID:  bounty.ID,
+			ToStatus:  bounty.Status,
+			ActorID:   &userID,
+			ActorRole: string(BountyActorPublisher),
+			Reason:    "创建悬赏令",
+		}); err != nil {
+			return err
+		}
+		if err := postLedgerEntry(s.ledgerRepo.WithTx(tx), tables.JournalEntryEscrowLock, &bounty.ID,
+			"锁定悬赏令【"+bounty.Title+"】的赏金",
+			ledgerTransfer{tables.LedgerAccountUser, userID, -bounty.Reward},
+			ledgerTransfer{tables.LedgerAccountEscrow, bounty.ID, bounty.Reward},
+		); err != nil {
+			return err
+		}
+		event, err := newOutboxEvent(tables.EventBountyCreated, "Bounty", bounty.ID, &dtos.BountyCreatedEvent{
+			BountyID:    bounty.ID,
+			PublisherID: userID,
+			Title:       bounty.Title,
+			Reward:      bounty.Reward,
+		})
+		if err != nil {
+			return err
+		}
+		return s.outboxRepo.WithTx(tx).Create(event)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return bounty, nil
 }
 
+// linkAttachments 将悬赏令附件地址中发布者本人上传、尚未关联的附件关联到悬赏令，
+// 之后这些附件按悬赏令的可见性与保密协议控制访问
+func (s *bountyService) linkAttachments(tx *gorm.DB, bounty *tables.Bounty) error {
+	var ids []uuid.UUID
+	for _, url := range bounty.AttachmentURLs {
+		if id, ok := attachmentIDFromURL(url); ok {
+			ids = append(ids, id)
+		}
+	}
+	_, err := s.attachmentRepo.WithTx(tx).LinkToBounty(bounty.UserID, bounty.ID, ids)
+	return err
+}
+
 // GetBounty 根据 ID 获取悬赏令
 func (s *bountyService) GetBounty(id uuid.UUID) (*tables.Bounty, error) {
 	return s.bountyRepo.FindBountyByID(id)
 }
 
 // UpdateBounty 更新指定 ID 的悬赏令
-func (s *bountyService) UpdateBounty(id uuid.UUID, input dtos.BountyDTO) (*tables.Bounty, error) {
+func (s *bountyService) UpdateBounty(id, userID uuid.UUID, input dtos.BountyDTO) (*tables.Bounty, error) {
 	bounty, err := s.bountyRepo.FindBountyByID(id)
 	if err != nil {
 		return nil, err
@@ -363,6 +634,9 @@ func (s *bountyService) UpdateBounty(id uuid.UUID, input dtos.BountyDTO) (*table
 	if bounty == nil {
 		return nil, errors.New("bounty not found")
 	}
+	if err := authz.Can(userID, authz.ActionBountyUpdate, bounty); err != nil {
+		return nil, err
+	}
 
 	// 更新字段
 	if input.Title != "" {
@@ -371,7 +645,21 @@ func (s *bountyService) UpdateBounty(id uuid.UUID, input dtos.BountyDTO) (*table
 	if input.Description != "" {
 		bounty.Description = input.Description
 	}
-	if input.Reward >= 0 {
+	// 赏金变动时需同步调整托管金额，仅允许在悬赏令刚创建时修改
+	rewardDelta := 0.0
+	if input.Reward > 0 && input.Reward != bounty.Reward {
+		if bounty.Status != tables.BountyStatusCreated {
+			return nil, fmt.Errorf("悬赏令当前状态为 %s，无法修改赏金", bounty.Status)
+		}
+		// 阶段赏金之和须等于赏金，修改赏金会使已有的分配失效
+		milestones, err := s.milestoneRepo.FindByBountyID(bounty.ID)
+		if err != nil {
+			return nil, err
+		}
+		if slices.ContainsFunc(milestones, func(m tables.Milestone) bool { return m.RewardAmount > 0 }) {
+			return nil, ErrMilestoneRewardsOutdated
+		}
+		rewardDelta = utils.RoundCents(input.Reward - bounty.Reward)
 		bounty.Reward = input.Reward
 	}
 	if input.Deadline != "" {
@@ -386,14 +674,29 @@ func (s *bountyService) UpdateBounty(id uuid.UUID, input dtos.BountyDTO) (*table
 
 	bounty.UpdatedAt = time.Now()
 
-	if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if rewardDelta != 0 {
+			// 加价从发布者钱包补缴，降价则将差额退回
+			err := postLedgerEntry(s.ledgerRepo.WithTx(tx), tables.JournalEntryEscrowAdjust, &bounty.ID,
+				"调整悬赏令【"+bounty.Title+"】的赏金",
+				ledgerTransfer{tables.LedgerAccountUser, bounty.UserID, -rewardDelta},
+				ledgerTransfer{tables.LedgerAccountEscrow, bounty.ID, rewardDelta},
+			)
+			if err != nil {
+				return err
+			}
+			bounty.PaymentStatus = tables.PaymentStatusEscrowed
+		}
+		return s.bountyRepo.WithTx(tx).UpdateBounty(bounty)
+	})
+	if err != nil {
 		return nil, err
 	}
 	return bounty, nil
 }
 
 // DeleteBounty 删除指定 ID 的悬赏令
-func (s *bountyService) DeleteBounty(id uuid.UUID) error {
+func (s *bountyService) DeleteBounty(id, userID uuid.UUID) error {
 	bounty, err := s.bountyRepo.FindBountyByID(id)
 	if err != nil {
 		return err
@@ -401,7 +704,44 @@ func (s *bountyService) DeleteBounty(id uuid.UUID) error {
 	if bounty == nil {
 		return errors.New("bounty not found")
 	}
-	return s.bountyRepo.DeleteBounty(bounty)
+	if err := authz.Can(userID, authz.ActionBountyDelete, bounty); err != nil {
+		return err
+	}
+	// 已指派接收者的悬赏令需通过取消流程结束，以便结算违约金并保留接收者的里程碑
+	if bounty.Status != tables.BountyStatusCreated || bounty.ReceiverID != nil {
+		return ErrBountyDeleteLocked
+	}
+
+	// 删除前将托管中的赏金退还发布者
+	return s.txManager.Transaction(func(tx *gorm.DB) error {
+		bountyRepo := s.bountyRepo.WithTx(tx)
+		// 带状态条件删除，防止与批准申请并发时删除刚被指派的悬赏令
+		deleted, err := bountyRepo.DeleteUnassignedBounty(bounty)
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return ErrBountyDeleteLocked
+		}
+		if bounty.PaymentStatus != tables.PaymentStatusEscrowed {
+			return nil
+		}
+
+		ledgerRepo := s.ledgerRepo.WithTx(tx)
+		escrowed, err := escrowBalance(ledgerRepo, bounty.ID)
+		if err != nil {
+			return err
+		}
+		err = postLedgerEntry(ledgerRepo, tables.JournalEntryEscrowRefund, &bounty.ID,
+			"删除悬赏令【"+bounty.Title+"】，退还赏金",
+			ledgerTransfer{tables.LedgerAccountEscrow, bounty.ID, -escrowed},
+			ledgerTransfer{tables.LedgerAccountUser, bounty.UserID, escrowed},
+		)
+		if err != nil {
+			return err
+		}
+		return bountyRepo.UpdatePaymentStatus(bounty.ID, tables.PaymentStatusRefunded)
+	})
 }
 
 // LikeBounty 用户点赞悬赏令
@@ -460,14 +800,14 @@ func (s *bountyService) IncrementViewCount(bountyID uuid.UUID) error {
 	return s.bountyRepo.IncrementField(bountyID, "view_count")
 }
 
-// GetBountiesByUserID 获取用户发布的所有悬赏令
-func (s *bountyService) GetBountiesByUserID(userID uuid.UUID) ([]tables.Bounty, error) {
-	return s.bountyRepo.FindByUserID(userID)
+// GetBountiesByUserID 分页获取用户发布的悬赏令
+func (s *bountyService) GetBountiesByUserID(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error) {
+	return s.bountyRepo.FindByUserID(userID, page)
 }
 
-// GetReceivedBounties 获取用户接收的所有悬赏令
-func (s *bountyService) GetReceivedBounties(userID uuid.UUID) ([]tables.Bounty, error) {
-	return s.bountyRepo.FindReceivedByUserID(userID)
+// GetReceivedBounties 分页获取用户接收的悬赏令
+func (s *bountyService) GetReceivedBounties(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.Bounty], error) {
+	return s.bountyRepo.FindReceivedByUserID(userID, page)
 }
 
 // GetUserBountyInteraction 获取用户在指定悬赏令上的互动信息
@@ -485,9 +825,9 @@ func (s *bountyService) GetUserBountyInteraction(userID, bountyID uuid.UUID) (*d
 	return &dtos.BountyInteraction{Liked: liked, Score: score}, nil
 }
 
-// internal/app/services/bounty_service.go
-
-func (s *bountyService) SettleBountyAccounts(bountyID uuid.UUID) error {
+// SettleBountyAccounts 发布者确认结算，按悬赏令的分配方式将托管中剩余的赏金释放给接收者并保存结算明细，
+// 已随里程碑验收发放的阶段赏金不再重复发放
+func (s *bountyService) SettleBountyAccounts(bountyID, userID uuid.UUID) error {
 	// 获取悬赏令
 	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
 	if err != nil {
@@ -497,9 +837,15 @@ func (s *bountyService) SettleBountyAccounts(bountyID uuid.UUID) error {
 		return errors.New("bounty not found")
 	}
 
-	// 检查悬赏令状态是否允许结算
-	if bounty.PaymentStatus != "Pending" {
-		return errors.New("bounty is not in a state that can be settled")
+	// 只有发布者可以确认放款，且由状态机校验悬赏令处于 Settling 状态
+	if err := authz.Can(userID, authz.ActionSettlementRelease, bounty); err != nil {
+		return err
+	}
+	if err := bountyStateMachine.Check(bounty, tables.BountyStatusSettled, bountyActorOf(bounty, userID)); err != nil {
+		return err
+	}
+	if bounty.PaymentStatus != tables.PaymentStatusEscrowed {
+		return errors.New("bounty reward is not held in escrow")
 	}
 
 	// 获取所有通过的申请
@@ -512,48 +858,225 @@ func (s *bountyService) SettleBountyAccounts(bountyID uuid.UUID) error {
 		return errors.New("no approved applications to settle")
 	}
 
-	// 计算每个申请者应得的奖励
-	totalReward := bounty.Reward
-	rewardPerApplication := totalReward / float64(len(approvedApplications))
+	var change *tables.BountyStatusChange
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		// 先更新悬赏令的支付状态与状态，锁定悬赏令后再读取托管余额，
+		// 悬赏令已被并发取消时在此返回冲突，不会按已退还的余额结算
+		bounty.PaymentStatus = tables.PaymentStatusPaid
+		change, err = bountyStateMachine.Transition(bounty, tables.BountyStatusSettled, BountyActorPublisher, &userID, "发布者确认结算")
+		if err != nil {
+			return err
+		}
+		if err := s.saveTransition(tx, bounty, change); err != nil {
+			return err
+		}
 
-	// 分发奖励
-	for _, app := range approvedApplications {
-		// 更新申请状态为已结算
-		app.Status = "Settled"
-		if err := s.applicationRepo.UpdateApplicationStatus(app.ID, app.Status); err != nil {
+		ledgerRepo := s.ledgerRepo.WithTx(tx)
+		totalReward, err := escrowBalance(ledgerRepo, bounty.ID)
+		if err != nil {
 			return err
 		}
 
-		// 发送通知给申请者
-		notification := &tables.Notification{
-			UserID:      app.UserID,
-			Title:       "Bounty Settle",
-			Description: "Your application for bounty '" + bounty.Title + "' has been settled. Reward: $" + fmt.Sprintf("%.2f", rewardPerApplication),
-			IsRead:      false,
+		items, err := s.splitLineItems(bounty, totalReward)
+		if err != nil {
+			return err
 		}
-		if err := s.notificationRepo.CreateNotification(notification); err != nil {
+		if err := s.settlementRepo.WithTx(tx).CreateLineItems(items); err != nil {
 			return err
 		}
 
-		// 记录奖励分发（此处假设有一个函数处理资金转移）
-		// err = TransferFunds(app.UserID, rewardPerApplication)
-		// if err != nil {
-		//     return err
-		// }
-	}
+		transfers := []ledgerTransfer{{tables.LedgerAccountEscrow, bounty.ID, -totalReward}}
+		payouts := make([]dtos.BountyPayout, len(items))
+		for i, item := range items {
+			payouts[i] = dtos.BountyPayout{UserID: item.UserID, Amount: item.Amount}
+			transfers = append(transfers, ledgerTransfer{tables.LedgerAccountUser, item.UserID, item.Amount})
+		}
 
-	// 更新悬赏令的支付状态
-	bounty.PaymentStatus = "Completed"
-	if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
+		if err := postLedgerEntry(ledgerRepo, tables.JournalEntryEscrowRelease, &bounty.ID,
+			"结算悬赏令【"+bounty.Title+"】", transfers...); err != nil {
+			return err
+		}
+
+		// 更新申请状态为已结算
+		applicationRepo := s.applicationRepo.WithTx(tx)
+		for _, app := range approvedApplications {
+			if err := applicationRepo.UpdateApplicationStatus(app.ID, "Settled"); err != nil {
+				return err
+			}
+		}
+
+		// 资金到账后由通知消费者告知申请者
+		event, err := newOutboxEvent(tables.EventBountySettled, "Bounty", bounty.ID, &dtos.BountySettledEvent{
+			BountyID:    bounty.ID,
+			PublisherID: bounty.UserID,
+			Title:       bounty.Title,
+			Payouts:     payouts,
+		})
+		if err != nil {
+			return err
+		}
+		return s.outboxRepo.WithTx(tx).Create(event)
+	})
+	if err != nil {
 		return err
 	}
+	publishBountyStatusChange(s.hub, bounty, change)
+	return nil
+}
 
-	// 更新状态为Settled
-	bounty.Status = tables.BountyStatusSettled
-	bounty.UpdatedAt = time.Now()
-	if err := s.bountyRepo.UpdateBounty(bounty); err != nil {
-		return err
+// SearchBounties 搜索悬赏令，同时返回类别与难度的分面计数
+func (s *bountyService) SearchBounties(query dtos.BountySearchQuery) (*dtos.BountySearchResult, error) {
+	bounties, total, err := s.bountyRepo.SearchBounties(query)
+	if err != nil {
+		return nil, err
+	}
+	categories, err := s.bountyRepo.CountBountyFacet(query, repositories.BountyFacetCategory)
+	if err != nil {
+		return nil, err
+	}
+	difficulties, err := s.bountyRepo.CountBountyFacet(query, repositories.BountyFacetDifficultyLevel)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	// 保证空结果序列化为 [] 而不是 null
+	if bounties == nil {
+		bounties = []tables.Bounty{}
+	}
+	if categories == nil {
+		categories = []dtos.FacetCount{}
+	}
+	if difficulties == nil {
+		difficulties = []dtos.FacetCount{}
+	}
+
+	return &dtos.BountySearchResult{
+		Items: bounties,
+		Total: total,
+		Facets: dtos.BountySearchFacets{
+			Category:        categories,
+			DifficultyLevel: difficulties,
+		},
+	}, nil
+}
+
+// GetBountyTimeline 将状态变更、里程碑、申请与评论合并为按时间排序的时间线
+func (s *bountyService) GetBountyTimeline(bountyID uuid.UUID) ([]dtos.BountyTimelineEvent, error) {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := s.bountyRepo.FindStatusChangesByBountyID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := s.milestoneRepo.FindByBountyID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	applications, err := s.applicationRepo.FindAllByBountyID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	comments, err := s.bountyRepo.GetCommentsByBountyID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+
+	var events []dtos.BountyTimelineEvent
+
+	// 1. 状态变更
+	for _, change := range changes {
+		event := dtos.BountyTimelineEvent{
+			Type:      dtos.TimelineStatusChanged,
+			Timestamp: change.CreatedAt,
+			ActorID:   change.ActorID,
+			Summary:   fmt.Sprintf("悬赏令状态变更为 %s", change.ToStatus),
+			Data: map[string]any{
+				"from_status": change.FromStatus,
+				"to_status":   change.ToStatus,
+				"actor_role":  change.ActorRole,
+				"reason":      change.Reason,
+			},
+		}
+		if change.Actor != nil {
+			event.ActorName = change.Actor.Username
+		}
+		events = append(events, event)
+	}
+
+	// 2. 里程碑：创建时间与最后一次更新时间各生成一条事件
+	for _, m := range milestones {
+		data := map[string]any{
+			"milestone_id": m.ID,
+			"title":        m.Title,
+			"due_date":     m.DueDate,
+			"is_completed": m.IsCompleted,
+		}
+		events = append(events, dtos.BountyTimelineEvent{
+			Type:      dtos.TimelineMilestoneCreated,
+			Timestamp: m.CreatedAt,
+			ActorID:   &bounty.UserID,
+			Summary:   "发布里程碑【" + m.Title + "】",
+			Data:      data,
+		})
+		if m.UpdatedAt.Sub(m.CreatedAt) > time.Second {
+			events = append(events, dtos.BountyTimelineEvent{
+				Type:      dtos.TimelineMilestoneUpdated,
+				Timestamp: m.UpdatedAt,
+				Summary:   "更新里程碑【" + m.Title + "】",
+				Data:      data,
+			})
+		}
+	}
+
+	// 3. 申请：提交时间与审核时间
+	for _, app := range applications {
+		actorID := app.UserID
+		data := map[string]any{
+			"application_id": app.ID,
+			"status":         app.Status,
+			"note":           app.Note,
+		}
+		events = append(events, dtos.BountyTimelineEvent{
+			Type:      dtos.TimelineApplicationSubmitted,
+			Timestamp: app.CreatedAt,
+			ActorID:   &actorID,
+			ActorName: app.User.Username,
+			Summary:   "【" + app.User.Username + "】申请了该悬赏令",
+			Data:      data,
+		})
+		if app.Status != "pending" && app.UpdatedAt.After(app.CreatedAt) {
+			events = append(events, dtos.BountyTimelineEvent{
+				Type:      dtos.TimelineApplicationReviewed,
+				Timestamp: app.UpdatedAt,
+				ActorID:   &bounty.UserID,
+				Summary:   "【" + app.User.Username + "】的申请状态变更为 " + app.Status,
+				Data:      data,
+			})
+		}
+	}
+
+	// 4. 评论
+	for _, comment := range comments {
+		actorID := comment.UserID
+		events = append(events, dtos.BountyTimelineEvent{
+			Type:      dtos.TimelineCommentPosted,
+			Timestamp: comment.CreatedAt,
+			ActorID:   &actorID,
+			ActorName: comment.User.Username,
+			Summary:   "【" + comment.User.Username + "】发表了评论",
+			Data: map[string]any{
+				"comment_id": comment.ID,
+				"content":    comment.Content,
+			},
+		})
+	}
+
+	// 按时间先后排序，时间相同时保持上面的合并顺序
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+	return events, nil
 }
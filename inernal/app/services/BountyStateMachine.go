@@ -0,0 +1,136 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"fmt"
+	"github.com/google/uuid"
+	"time"
+)
+
+// BountyActor 触发悬赏令状态转换的角色
+type BountyActor string
+
+const (
+	BountyActorPublisher BountyActor = "publisher" // 悬赏令发布者
+	BountyActorReceiver  BountyActor = "receiver"  // 悬赏令接收者
+	BountyActorSystem    BountyActor = "system"    // 系统或管理员
+	BountyActorNone      BountyActor = ""          // 与悬赏令无关的用户
+)
+
+// TransitionError 表示一次不被允许的悬赏令状态转换
+type TransitionError struct {
+	From  tables.BountyStatus
+	To    tables.BountyStatus
+	Actor BountyActor
+}
+
+func (e *TransitionError) Error() string {
+	if e.Actor == BountyActorNone {
+		return fmt.Sprintf("你不是该悬赏令的参与者，无法将状态从 %s 变更为 %s", e.From, e.To)
+	}
+	return fmt.Sprintf("%s 无法将悬赏令状态从 %s 变更为 %s", e.Actor, e.From, e.To)
+}
+
+// BountyStateMachine 声明悬赏令所有合法的状态转换及可触发的角色
+type BountyStateMachine struct {
+	transitions map[tables.BountyStatus]map[tables.BountyStatus][]BountyActor
+}
+
+// NewBountyStateMachine 创建包含默认转换表的状态机
+//
+//	Created -> Assigned -> MilestonesConfirmed -> MilestonesVerified -> Settling -> Settled
+//	                                                                       \-> Cancelled
+func NewBountyStateMachine() *BountyStateMachine {
+	return &BountyStateMachine{
+		transitions: map[tables.BountyStatus]map[tables.BountyStatus][]BountyActor{
+			tables.BountyStatusCreated: {
+				// 发布者批准申请后指定接收者
+				tables.BountyStatusAssigned:  {BountyActorPublisher},
+				tables.BountyStatusCancelled: {BountyActorSystem},
+			},
+			tables.BountyStatusAssigned: {
+				// 接收者确认提交所有里程碑
+				tables.BountyStatusMilestonesConfirmed: {BountyActorReceiver},
+				tables.BountyStatusCancelled:           {BountyActorSystem},
+			},
+			tables.BountyStatusMilestonesConfirmed: {
+				// 发布者审核并确认所有里程碑
+				tables.BountyStatusMilestonesVerified: {BountyActorPublisher},
+				tables.BountyStatusCancelled:          {BountyActorSystem},
+			},
+			tables.BountyStatusMilestonesVerified: {
+				// 接收者申请清算
+				tables.BountyStatusSettling:  {BountyActorReceiver},
+				tables.BountyStatusCancelled: {BountyActorSystem},
+			},
+			tables.BountyStatusSettling: {
+				// 发布者确认放款，或任一方取消清算
+				tables.BountyStatusSettled:   {BountyActorPublisher},
+				tables.BountyStatusCancelled: {BountyActorPublisher, BountyActorReceiver, BountyActorSystem},
+			},
+			// Settled 与 Cancelled 为终态
+		},
+	}
+}
+
+// bountyStateMachine 所有服务共用的悬赏令状态机
+var bountyStateMachine = NewBountyStateMachine()
+
+// Can 判断角色能否将悬赏令从 from 转换为 to
+func (m *BountyStateMachine) Can(from, to tables.BountyStatus, actor BountyActor) bool {
+	for _, allowed := range m.transitions[from][to] {
+		if allowed == actor {
+			return true
+		}
+	}
+	return false
+}
+
+// Check 校验转换是否合法，不合法时返回 *TransitionError
+func (m *BountyStateMachine) Check(bounty *tables.Bounty, to tables.BountyStatus, actor BountyActor) error {
+	if !m.Can(bounty.Status, to, actor) {
+		return &TransitionError{From: bounty.Status, To: to, Actor: actor}
+	}
+	return nil
+}
+
+// Transition 校验并修改悬赏令状态，返回对应的状态变更记录
+// 仅修改内存中的对象，由调用方在同一事务中持久化悬赏令与变更记录
+func (m *BountyStateMachine) Transition(bounty *tables.Bounty, to tables.BountyStatus, actor BountyActor, actorID *uuid.UUID, reason string) (*tables.BountyStatusChange, error) {
+	if err := m.Check(bounty, to, actor); err != nil {
+		return nil, err
+	}
+	change := &tables.BountyStatusChange{
+		BountyID:   bounty.ID,
+		FromStatus: bounty.Status,
+		ToStatus:   to,
+		ActorID:    actorID,
+		ActorRole:  string(actor),
+		Reason:     reason,
+	}
+	bounty.Status = to
+	bounty.UpdatedAt = time.Now()
+	return change, nil
+}
+
+// AllowedTransitions 返回角色在当前状态下可以转换到的目标状态
+func (m *BountyStateMachine) AllowedTransitions(from tables.BountyStatus, actor BountyActor) []tables.BountyStatus {
+	var targets []tables.BountyStatus
+	for to := range m.transitions[from] {
+		if m.Can(from, to, actor) {
+			targets = append(targets, to)
+		}
+	}
+	return targets
+}
+
+// bountyActorOf 根据用户ID判断其在悬赏令中的角色
+func bountyActorOf(bounty *tables.Bounty, userID uuid.UUID) BountyActor {
+	if bounty.UserID == userID {
+		return BountyActorPublisher
+	}
+	if bounty.ReceiverID != nil && *bounty.ReceiverID == userID {
+		return BountyActorReceiver
+	}
+	return BountyActorNone
+}
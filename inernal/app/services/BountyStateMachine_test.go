@@ -0,0 +1,146 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"errors"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+)
+
+var (
+	allBountyStatuses = []tables.BountyStatus{
+		tables.BountyStatusCreated,
+		tables.BountyStatusAssigned,
+		tables.BountyStatusMilestonesConfirmed,
+		tables.BountyStatusMilestonesVerified,
+		tables.BountyStatusSettling,
+		tables.BountyStatusSettled,
+		tables.BountyStatusCancelled,
+	}
+	allBountyActors = []BountyActor{
+		BountyActorPublisher,
+		BountyActorReceiver,
+		BountyActorSystem,
+		BountyActorNone,
+	}
+)
+
+type transitionCell struct {
+	from  tables.BountyStatus
+	actor BountyActor
+	to    tables.BountyStatus
+}
+
+// allowedTransitions 期望允许的全部 (from, actor, to)，其余组合均应被拒绝
+var allowedTransitions = map[transitionCell]bool{
+	{tables.BountyStatusCreated, BountyActorPublisher, tables.BountyStatusAssigned}:                       true,
+	{tables.BountyStatusCreated, BountyActorSystem, tables.BountyStatusCancelled}:                         true,
+	{tables.BountyStatusAssigned, BountyActorReceiver, tables.BountyStatusMilestonesConfirmed}:            true,
+	{tables.BountyStatusAssigned, BountyActorSystem, tables.BountyStatusCancelled}:                        true,
+	{tables.BountyStatusMilestonesConfirmed, BountyActorPublisher, tables.BountyStatusMilestonesVerified}: true,
+	{tables.BountyStatusMilestonesConfirmed, BountyActorSystem, tables.BountyStatusCancelled}:             true,
+	{tables.BountyStatusMilestonesVerified, BountyActorReceiver, tables.BountyStatusSettling}:             true,
+	{tables.BountyStatusMilestonesVerified, BountyActorSystem, tables.BountyStatusCancelled}:              true,
+	{tables.BountyStatusSettling, BountyActorPublisher, tables.BountyStatusSettled}:                       true,
+	{tables.BountyStatusSettling, BountyActorPublisher, tables.BountyStatusCancelled}:                     true,
+	{tables.BountyStatusSettling, BountyActorReceiver, tables.BountyStatusCancelled}:                      true,
+	{tables.BountyStatusSettling, BountyActorSystem, tables.BountyStatusCancelled}:                        true,
+}
+
+func TestBountyStateMachineEveryCell(t *testing.T) {
+	m := NewBountyStateMachine()
+	for _, from := range allBountyStatuses {
+		for _, actor := range allBountyActors {
+			for _, to := range allBountyStatuses {
+				cell := transitionCell{from, actor, to}
+				want := allowedTransitions[cell]
+				t.Run(string(from)+"/"+string(actor)+"/"+string(to), func(t *testing.T) {
+					if got := m.Can(from, to, actor); got != want {
+						t.Fatalf("Can(%s -> %s by %q) = %v, want %v", from, to, actor, got, want)
+					}
+
+					actorID := uuid.New()
+					bounty := &tables.Bounty{Status: from}
+					bounty.ID = uuid.New()
+					change, err := m.Transition(bounty, to, actor, &actorID, "test")
+					if !want {
+						var transitionErr *TransitionError
+						if !errors.As(err, &transitionErr) {
+							t.Fatalf("Transition error = %v, want *TransitionError", err)
+						}
+						if transitionErr.From != from || transitionErr.To != to || transitionErr.Actor != actor {
+							t.Fatalf("TransitionError = %+v", transitionErr)
+						}
+						if bounty.Status != from {
+							t.Fatalf("rejected transition changed status to %s", bounty.Status)
+						}
+						return
+					}
+					if err != nil {
+						t.Fatalf("Transition error = %v", err)
+					}
+					if bounty.Status != to {
+						t.Fatalf("status = %s, want %s", bounty.Status, to)
+					}
+					if change.BountyID != bounty.ID || change.FromStatus != from || change.ToStatus != to ||
+						change.ActorRole != string(actor) || change.ActorID == nil || *change.ActorID != actorID {
+						t.Fatalf("unexpected change record %+v", change)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestBountyStateMachineTerminalStates(t *testing.T) {
+	m := NewBountyStateMachine()
+	for _, from := range []tables.BountyStatus{tables.BountyStatusSettled, tables.BountyStatusCancelled} {
+		for _, actor := range allBountyActors {
+			if targets := m.AllowedTransitions(from, actor); len(targets) != 0 {
+				t.Errorf("AllowedTransitions(%s, %q) = %v, want none", from, actor, targets)
+			}
+		}
+	}
+}
+
+func TestBountyStateMachineAllowedTransitions(t *testing.T) {
+	m := NewBountyStateMachine()
+	for _, from := range allBountyStatuses {
+		for _, actor := range allBountyActors {
+			var want []tables.BountyStatus
+			for _, to := range allBountyStatuses {
+				if allowedTransitions[transitionCell{from, actor, to}] {
+					want = append(want, to)
+				}
+			}
+			got := m.AllowedTransitions(from, actor)
+			slices.Sort(got)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("AllowedTransitions(%s, %q) = %v, want %v", from, actor, got, want)
+			}
+		}
+	}
+}
+
+func TestBountyActorOf(t *testing.T) {
+	publisher, receiver, stranger := uuid.New(), uuid.New(), uuid.New()
+	bounty := &tables.Bounty{UserID: publisher, ReceiverID: &receiver}
+	cases := []struct {
+		userID uuid.UUID
+		want   BountyActor
+	}{
+		{publisher, BountyActorPublisher},
+		{receiver, BountyActorReceiver},
+		{stranger, BountyActorNone},
+	}
+	for _, c := range cases {
+		if got := bountyActorOf(bounty, c.userID); got != c.want {
+			t.Errorf("bountyActorOf(%s) = %q, want %q", c.userID, got, c.want)
+		}
+	}
+	if got := bountyActorOf(&tables.Bounty{UserID: publisher}, receiver); got != BountyActorNone {
+		t.Errorf("bountyActorOf without receiver = %q, want none", got)
+	}
+}
@@ -5,19 +5,16 @@ import (
 	"GeekReward/inernal/app/repositories"
 	"errors"
 	"github.com/google/uuid"
-	"time"
 )
 
 type GeekService interface {
 	GetTopGeeks(limit int) ([]tables.User, error)
 	GetGeekByID(id uuid.UUID) (*tables.User, error)
-	SendInvitation(geekID uuid.UUID, inviterID uuid.UUID) error
 	ExpressAffection(geekID uuid.UUID, userID uuid.UUID) error
 }
 
 type geekService struct {
-	geekRepo       repositories.GeekRepository
-	invitationRepo repositories.InvitationRepository
+	geekRepo repositories.GeekRepository
 }
 
 func (s *geekService) ExpressAffection(geekID uuid.UUID, userID uuid.UUID) error {
@@ -52,14 +49,8 @@ func (s *geekService) ExpressAffection(geekID uuid.UUID, userID uuid.UUID) error
 	return nil
 }
 
-func NewGeekService(
-	geekRepo repositories.GeekRepository,
-	invitationRepo repositories.InvitationRepository,
-) GeekService {
-	return &geekService{
-		geekRepo:       geekRepo,
-		invitationRepo: invitationRepo,
-	}
+func NewGeekService(geekRepo repositories.GeekRepository) GeekService {
+	return &geekService{geekRepo: geekRepo}
 }
 
 func (s *geekService) GetTopGeeks(limit int) ([]tables.User, error) {
@@ -69,40 +60,3 @@ func (s *geekService) GetTopGeeks(limit int) ([]tables.User, error) {
 func (s *geekService) GetGeekByID(id uuid.UUID) (*tables.User, error) {
 	return s.geekRepo.GetGeekByID(id)
 }
-
-// SendInvitation 向特定极客发出组队邀请
-func (s *geekService) SendInvitation(geekID uuid.UUID, inviterID uuid.UUID) error {
-	// 检查极客是否存在
-	geek, err := s.geekRepo.GetGeekByID(geekID)
-	if err != nil {
-		return err
-	}
-	if geek == nil {
-		return errors.New("geek not found")
-	}
-
-	// 检查是否已经存在邀请
-	existingInvitation, err := s.invitationRepo.GetInvitation(inviterID, geekID)
-	if err != nil {
-		return err
-	}
-	if existingInvitation != nil {
-		return errors.New("invitation already exists")
-	}
-
-	// 创建邀请
-	invitation := &tables.Invitation{
-		ID:        uuid.New(),
-		InviterID: inviterID,
-		InviteeID: geekID,
-		Status:    "Pending", // Pending, Accepted, Rejected
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	if err := s.invitationRepo.CreateInvitation(invitation); err != nil {
-		return err
-	}
-
-	return nil
-}
@@ -1,111 +1,380 @@
 package services
 
 import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
+	"context"
 	"errors"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log"
+	"slices"
+	"time"
 )
 
+var (
+	ErrInvitationNotFound      = errors.New("邀请不存在")
+	ErrInvitationNotPending    = errors.New("邀请已被处理、撤回或已过期")
+	ErrInvitationExists        = errors.New("已向该用户发出过邀请，请等待对方回应")
+	ErrNotInvitee              = errors.New("只有受邀者才能处理该邀请")
+	ErrNotInviter              = errors.New("只有邀请者才能撤回该邀请")
+	ErrCannotInviteSelf        = errors.New("不能邀请自己")
+	ErrInviteeNotFound         = errors.New("受邀用户不存在")
+	ErrInvitationBountyMissing = errors.New("邀请关联的悬赏令不存在")
+	ErrInvalidInvitationStatus = errors.New("无效的邀请状态，可选值为 Pending, Accepted, Rejected, Cancelled, Expired")
+)
+
+// invitationSweepBatch 每轮过期清理处理的邀请数
+const invitationSweepBatch = 100
+
+// InvitationSettings 组队邀请的有效期与过期清理间隔
+type InvitationSettings struct {
+	TTL           time.Duration // 邀请发出后多久未处理即过期
+	SweepInterval time.Duration // 检查过期邀请的间隔
+}
+
+// InvitationService 管理组队邀请的完整生命周期：发出、接受、拒绝、撤回与过期，
+// 每次状态变化都通过发件箱通知另一方
 type InvitationService interface {
+	// SendInvitation 向用户发出组队邀请，input.TeamID 不为空时邀请其加入该团队，邀请者必须是队长
+	SendInvitation(inviterID, inviteeID uuid.UUID, input dtos.SendInvitationInput) (*tables.Invitation, error)
 	AcceptInvitation(invitationID uuid.UUID, userID uuid.UUID) error
 	RejectInvitation(invitationID uuid.UUID, userID uuid.UUID) error
+	// CancelInvitation 邀请者撤回尚未处理的邀请
+	CancelInvitation(invitationID uuid.UUID, userID uuid.UUID) error
+	GetSentInvitations(userID uuid.UUID, filter dtos.InvitationFilter, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error)
+	GetReceivedInvitations(userID uuid.UUID, filter dtos.InvitationFilter, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error)
+	// ExpireDue 将截至 now 已过期的邀请标记为 Expired，返回处理的数量
+	ExpireDue(now time.Time) (int, error)
+	// Run 按 SweepInterval 清理过期邀请，直到 ctx 结束
+	Run(ctx context.Context) error
 }
 
 type invitationService struct {
 	invitationRepo repositories.InvitationRepository
 	userRepo       repositories.UserRepository
+	teamRepo       repositories.TeamRepository
+	bountyRepo     repositories.BountyRepository
+	outboxRepo     repositories.OutboxRepository
+	txManager      repositories.TransactionManager
+	settings       InvitationSettings
 }
 
 func NewInvitationService(
 	invitationRepo repositories.InvitationRepository,
 	userRepo repositories.UserRepository,
+	teamRepo repositories.TeamRepository,
+	bountyRepo repositories.BountyRepository,
+	outboxRepo repositories.OutboxRepository,
+	txManager repositories.TransactionManager,
+	settings InvitationSettings,
 ) InvitationService {
+	if settings.TTL <= 0 {
+		settings.TTL = 7 * 24 * time.Hour
+	}
+	if settings.SweepInterval <= 0 {
+		settings.SweepInterval = 10 * time.Minute
+	}
 	return &invitationService{
 		invitationRepo: invitationRepo,
 		userRepo:       userRepo,
+		teamRepo:       teamRepo,
+		bountyRepo:     bountyRepo,
+		outboxRepo:     outboxRepo,
+		txManager:      txManager,
+		settings:       settings,
 	}
 }
 
-// AcceptInvitation 接受组队邀请
-func (s *invitationService) AcceptInvitation(invitationID uuid.UUID, userID uuid.UUID) error {
-	// 获取邀请
+func (s *invitationService) SendInvitation(inviterID, inviteeID uuid.UUID, input dtos.SendInvitationInput) (*tables.Invitation, error) {
+	if inviterID == inviteeID {
+		return nil, ErrCannotInviteSelf
+	}
+
+	// 检查受邀者是否存在
+	if _, err := s.userRepo.FindByUserID(inviteeID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInviteeNotFound
+		}
+		return nil, err
+	}
+
+	// 邀请加入指定团队时，只有队长可以邀请，且受邀者不能已是成员
+	if input.TeamID != nil {
+		team, err := s.teamRepo.FindByID(*input.TeamID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if team.LeaderID != inviterID {
+			return nil, ErrNotTeamLeader
+		}
+		member, err := s.teamRepo.FindMember(team.ID, inviteeID)
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			return nil, ErrAlreadyTeamMember
+		}
+	}
+
+	if input.BountyID != nil {
+		if _, err := s.bountyRepo.FindBountyByID(*input.BountyID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, ErrInvitationBountyMissing
+			}
+			return nil, err
+		}
+	}
+
+	// 同一对用户之间只能有一个待处理的邀请，被拒绝、撤回或过期后可以再次邀请
+	existingInvitation, err := s.invitationRepo.FindPendingInvitation(inviterID, inviteeID)
+	if err != nil {
+		return nil, err
+	}
+	if existingInvitation != nil {
+		return nil, ErrInvitationExists
+	}
+
+	now := time.Now()
+	invitation := &tables.Invitation{
+		ID:        uuid.New(),
+		InviterID: inviterID,
+		InviteeID: inviteeID,
+		TeamID:    input.TeamID,
+		BountyID:  input.BountyID,
+		Message:   input.Message,
+		Status:    tables.InvitationStatusPending,
+		ExpiresAt: now.Add(s.settings.TTL),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		if err := s.invitationRepo.WithTx(tx).CreateInvitation(invitation); err != nil {
+			return err
+		}
+		return s.recordEvent(tx, tables.EventInvitationSent, invitation)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// findPending 获取邀请并校验其仍可处理，已过期但尚未被清理的邀请同样视为不可处理
+func (s *invitationService) findPending(invitationID uuid.UUID) (*tables.Invitation, error) {
 	invitation, err := s.invitationRepo.GetInvitationByID(invitationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if invitation.Status != tables.InvitationStatusPending ||
+		(!invitation.ExpiresAt.IsZero() && invitation.ExpiresAt.Before(time.Now())) {
+		return nil, ErrInvitationNotPending
+	}
+	return invitation, nil
+}
+
+// transition 在事务中将 Pending 状态的邀请更新为 status 并写入对应的领域事件
+func (s *invitationService) transition(tx *gorm.DB, invitation *tables.Invitation, status, eventType string) error {
+	ok, err := s.invitationRepo.WithTx(tx).TransitionStatus(invitation, status, time.Now())
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvitationNotPending
+	}
+	return s.recordEvent(tx, eventType, invitation)
+}
+
+// recordEvent 写入邀请的领域事件，事件中带上双方的用户名供通知使用
+func (s *invitationService) recordEvent(tx *gorm.DB, eventType string, invitation *tables.Invitation) error {
+	inviter, err := s.userRepo.FindByUserID(invitation.InviterID)
+	if err != nil {
+		return err
+	}
+	invitee, err := s.userRepo.FindByUserID(invitation.InviteeID)
 	if err != nil {
 		return err
 	}
-	if invitation == nil {
-		return errors.New("invitation not found")
+	event, err := newOutboxEvent(eventType, "Invitation", invitation.ID, &dtos.InvitationEvent{
+		InvitationID: invitation.ID,
+		InviterID:    invitation.InviterID,
+		InviterName:  inviter.Username,
+		InviteeID:    invitation.InviteeID,
+		InviteeName:  invitee.Username,
+		TeamID:       invitation.TeamID,
+		BountyID:     invitation.BountyID,
+		Message:      invitation.Message,
+	})
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.WithTx(tx).Create(event)
+}
+
+// AcceptInvitation 接受组队邀请，受邀者加入邀请指定的团队；
+// 未指定团队时加入邀请者担任队长的团队，邀请者还没有团队时为其新建一个
+func (s *invitationService) AcceptInvitation(invitationID uuid.UUID, userID uuid.UUID) error {
+	invitation, err := s.findPending(invitationID)
+	if err != nil {
+		return err
 	}
 
 	// 验证邀请接受者是否为邀请的受邀者
 	if invitation.InviteeID != userID {
-		return errors.New("you are not authorized to accept this invitation")
+		return ErrNotInvitee
 	}
 
-	// 更新邀请状态为已接受
-	invitation.Status = "Accepted"
-	if err := s.invitationRepo.UpdateInvitation(invitation); err != nil {
-		return err
+	// 更新邀请状态与加入团队在同一事务中完成
+	return s.txManager.Transaction(func(tx *gorm.DB) error {
+		teamRepo := s.teamRepo.WithTx(tx)
+		team, err := s.invitationTeam(teamRepo, invitation)
+		if err != nil {
+			return err
+		}
+
+		member, err := teamRepo.FindMember(team.ID, invitation.InviteeID)
+		if err != nil {
+			return err
+		}
+		if member != nil {
+			return ErrAlreadyTeamMember
+		}
+		if err := checkNoActiveBounty(s.bountyRepo.WithTx(tx), team.ID); err != nil {
+			return err
+		}
+		if err := teamRepo.AddMember(&tables.TeamMember{
+			TeamID:   team.ID,
+			UserID:   invitation.InviteeID,
+			Role:     tables.TeamRoleMember,
+			JoinedAt: time.Now(),
+		}); err != nil {
+			return err
+		}
+
+		invitation.TeamID = &team.ID
+		return s.transition(tx, invitation, tables.InvitationStatusAccepted, tables.EventInvitationAccepted)
+	})
+}
+
+// invitationTeam 返回受邀者要加入的团队，必要时以邀请者为队长新建
+func (s *invitationService) invitationTeam(teamRepo repositories.TeamRepository, invitation *tables.Invitation) (*tables.Team, error) {
+	if invitation.TeamID != nil {
+		team, err := teamRepo.FindByID(*invitation.TeamID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamNotFound
+		}
+		return team, err
+	}
+
+	team, err := teamRepo.FindLedByUserID(invitation.InviterID)
+	if err != nil || team != nil {
+		return team, err
+	}
+
+	inviter, err := s.userRepo.FindByUserID(invitation.InviterID)
+	if err != nil {
+		return nil, err
+	}
+	team = &tables.Team{
+		Name:     inviter.Username + " 的团队",
+		LeaderID: inviter.ID,
+	}
+	if err := createTeamWithLeader(teamRepo, team); err != nil {
+		return nil, err
 	}
+	return team, nil
+}
 
-	// 执行其他逻辑，如将邀请者和受邀者加入同一团队
-	// team, err := s.teamRepo.GetTeamByUserID(invitation.InviterID)
-	// if err != nil {
-	//     return err
-	// }
-	// if team == nil {
-	//     team = &tables.Team{
-	//         ID:      uuid.New(),
-	//         LeaderID: invitation.InviterID,
-	//         Members: []uuid.UUID{invitation.InviterID, invitation.InviteeID},
-	//     }
-	//     if err := s.teamRepo.CreateTeam(team); err != nil {
-	//         return err
-	//     }
-	// } else {
-	//     team.Members = append(team.Members, invitation.InviteeID)
-	//     if err := s.teamRepo.UpdateTeam(team); err != nil {
-	//         return err
-	//     }
-	// }
-
-	return nil
-}
-
-// RejectInvitation 拒绝组队邀请
+// RejectInvitation 拒绝组队邀请，并通知邀请者
 func (s *invitationService) RejectInvitation(invitationID uuid.UUID, userID uuid.UUID) error {
-	// 获取邀请
-	invitation, err := s.invitationRepo.GetInvitationByID(invitationID)
+	invitation, err := s.findPending(invitationID)
 	if err != nil {
 		return err
 	}
-	if invitation == nil {
-		return errors.New("invitation not found")
-	}
 
-	// 验证邀请接受者是否为邀请的受邀者
+	// 验证拒绝者是否为邀请的受邀者
 	if invitation.InviteeID != userID {
-		return errors.New("you are not authorized to reject this invitation")
+		return ErrNotInvitee
 	}
 
-	// 更新邀请状态为已拒绝
-	invitation.Status = "Rejected"
-	if err := s.invitationRepo.UpdateInvitation(invitation); err != nil {
+	return s.txManager.Transaction(func(tx *gorm.DB) error {
+		return s.transition(tx, invitation, tables.InvitationStatusRejected, tables.EventInvitationRejected)
+	})
+}
+
+// CancelInvitation 邀请者撤回邀请，并通知受邀者
+func (s *invitationService) CancelInvitation(invitationID uuid.UUID, userID uuid.UUID) error {
+	invitation, err := s.findPending(invitationID)
+	if err != nil {
 		return err
 	}
+	if invitation.InviterID != userID {
+		return ErrNotInviter
+	}
+
+	return s.txManager.Transaction(func(tx *gorm.DB) error {
+		return s.transition(tx, invitation, tables.InvitationStatusCancelled, tables.EventInvitationCancelled)
+	})
+}
+
+func (s *invitationService) GetSentInvitations(userID uuid.UUID, filter dtos.InvitationFilter, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error) {
+	if filter.Status != "" && !slices.Contains(tables.InvitationStatuses, filter.Status) {
+		return nil, ErrInvalidInvitationStatus
+	}
+	return s.invitationRepo.FindSentPage(userID, filter.Status, page)
+}
+
+func (s *invitationService) GetReceivedInvitations(userID uuid.UUID, filter dtos.InvitationFilter, page dtos.PageQuery) (*dtos.Page[tables.Invitation], error) {
+	if filter.Status != "" && !slices.Contains(tables.InvitationStatuses, filter.Status) {
+		return nil, ErrInvalidInvitationStatus
+	}
+	return s.invitationRepo.FindReceivedPage(userID, filter.Status, page)
+}
 
-	// 发送通知给邀请者（假设有通知服务）
-	// notification := &tables.Notification{
-	//     UserID:    invitation.InviterID,
-	//     Type:      "invitation_rejected",
-	//     Message:   "Your invitation has been rejected.",
-	//     IsRead:    false,
-	//     CreatedAt: time.Now(),
-	//     UpdatedAt: time.Now(),
-	// }
-	// if err := s.notificationRepo.CreateNotification(notification); err != nil {
-	//     return err
-	// }
-
-	return nil
+func (s *invitationService) ExpireDue(now time.Time) (int, error) {
+	expired := 0
+	for {
+		invitations, err := s.invitationRepo.FindExpiredPending(now, invitationSweepBatch)
+		if err != nil {
+			return expired, err
+		}
+		for i := range invitations {
+			err := s.txManager.Transaction(func(tx *gorm.DB) error {
+				return s.transition(tx, &invitations[i], tables.InvitationStatusExpired, tables.EventInvitationExpired)
+			})
+			// 清理期间被接受、拒绝或撤回的邀请直接跳过
+			if errors.Is(err, ErrInvitationNotPending) {
+				continue
+			}
+			if err != nil {
+				return expired, err
+			}
+			expired++
+		}
+		if len(invitations) < invitationSweepBatch {
+			return expired, nil
+		}
+	}
+}
+
+func (s *invitationService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.settings.SweepInterval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.ExpireDue(time.Now()); err != nil {
+			log.Println("清理过期邀请失败:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
 }
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"math"
+)
+
+// PenaltyRates 取消清算时的违约金比例（相对于悬赏令赏金）
+type PenaltyRates struct {
+	Publisher float64 // 发布方取消时，从托管赏金中赔付给接收者的比例
+	Receiver  float64 // 接收方取消时，从接收者钱包中赔付给发布者的比例
+}
+
+// LedgerService 定义钱包与账本相关的服务接口
+type LedgerService interface {
+	GetWallet(userID uuid.UUID) (*dtos.WalletDTO, error)
+	GetTransactions(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[dtos.WalletTransactionDTO], error)
+	// Deposit 管理员确认支付到账后为用户钱包入账，资金来自外部资金账户
+	Deposit(actorID, userID uuid.UUID, amount float64, reference string) (*dtos.WalletDTO, error)
+}
+
+type ledgerService struct {
+	ledgerRepo repositories.LedgerRepository
+}
+
+func NewLedgerService(ledgerRepo repositories.LedgerRepository) LedgerService {
+	return &ledgerService{ledgerRepo: ledgerRepo}
+}
+
+// GetWallet 获取用户钱包余额及其托管中的赏金
+func (s *ledgerService) GetWallet(userID uuid.UUID) (*dtos.WalletDTO, error) {
+	account, err := s.ledgerRepo.GetOrCreateAccount(tables.LedgerAccountUser, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	escrowed, err := s.ledgerRepo.SumEscrowByPublisher(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.WalletDTO{
+		AccountID: account.ID,
+		Balance:   account.Balance,
+		Escrowed:  escrowed,
+	}, nil
+}
+
+// GetTransactions 分页获取用户钱包流水
+func (s *ledgerService) GetTransactions(userID uuid.UUID, page dtos.PageQuery) (*dtos.Page[dtos.WalletTransactionDTO], error) {
+	account, err := s.ledgerRepo.FindAccount(tables.LedgerAccountUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	if account == nil {
+		return &dtos.Page[dtos.WalletTransactionDTO]{Items: []dtos.WalletTransactionDTO{}}, nil
+	}
+
+	lines, err := s.ledgerRepo.FindLinesByAccountID(account.ID, page)
+	if err != nil {
+		return nil, err
+	}
+
+	transactions := make([]dtos.WalletTransactionDTO, 0, len(lines.Items))
+	for _, line := range lines.Items {
+		tx := dtos.WalletTransactionDTO{
+			EntryID:   line.EntryID,
+			Amount:    line.Amount,
+			CreatedAt: line.CreatedAt,
+		}
+		if line.Entry != nil {
+			tx.Type = string(line.Entry.Type)
+			tx.BountyID = line.Entry.BountyID
+			tx.Memo = line.Entry.Memo
+		}
+		transactions = append(transactions, tx)
+	}
+	return &dtos.Page[dtos.WalletTransactionDTO]{
+		Items:      transactions,
+		NextCursor: lines.NextCursor,
+		Total:      lines.Total,
+	}, nil
+}
+
+// Deposit 分录备注中记录操作的管理员与支付凭证号
+func (s *ledgerService) Deposit(actorID, userID uuid.UUID, amount float64, reference string) (*dtos.WalletDTO, error) {
+	if amount <= 0 {
+		return nil, errors.New("充值金额必须大于0")
+	}
+
+	memo := fmt.Sprintf("钱包充值（凭证号 %s，由管理员 %s 确认）", reference, actorID)
+	err := postLedgerEntry(s.ledgerRepo, tables.JournalEntryDeposit, nil, memo,
+		ledgerTransfer{tables.LedgerAccountExternal, uuid.Nil, -amount},
+		ledgerTransfer{tables.LedgerAccountUser, userID, amount},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetWallet(userID)
+}
+
+// ledgerTransfer 描述一笔分录中某个账户的余额变动
+type ledgerTransfer struct {
+	accountType tables.LedgerAccountType
+	ownerID     uuid.UUID
+	amount      float64
+}
+
+// postLedgerEntry 解析（必要时创建）各个账户后写入一笔分录，金额为 0 的变动会被忽略
+func postLedgerEntry(ledgerRepo repositories.LedgerRepository, entryType tables.JournalEntryType, bountyID *uuid.UUID, memo string, transfers ...ledgerTransfer) error {
+	entry := &tables.JournalEntry{
+		Type:     entryType,
+		BountyID: bountyID,
+		Memo:     memo,
+	}
+
+	for _, t := range transfers {
+		if math.Abs(t.amount) < 0.005 {
+			continue
+		}
+		account, err := ledgerRepo.GetOrCreateAccount(t.accountType, t.ownerID)
+		if err != nil {
+			return err
+		}
+		entry.Lines = append(entry.Lines, tables.JournalLine{
+			AccountID: account.ID,
+			Amount:    t.amount,
+		})
+	}
+
+	// 所有变动均为 0 时无需记账
+	if len(entry.Lines) == 0 {
+		return nil
+	}
+
+	if err := ledgerRepo.PostEntry(entry); err != nil {
+		return fmt.Errorf("记账失败(%s): %w", entryType, err)
+	}
+	return nil
+}
+
+// escrowBalance 获取悬赏令托管账户的当前余额
+func escrowBalance(ledgerRepo repositories.LedgerRepository, bountyID uuid.UUID) (float64, error) {
+	account, err := ledgerRepo.FindAccount(tables.LedgerAccountEscrow, bountyID)
+	if err != nil {
+		return 0, err
+	}
+	if account == nil {
+		return 0, nil
+	}
+	return account.Balance, nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// LockoutPolicy 登录失败的渐进式锁定策略
+// 失败次数达到 MaxAttempts 后锁定 BaseLock，此后每多失败一次锁定时长翻倍，最长不超过 MaxLock
+type LockoutPolicy struct {
+	MaxAttempts int
+	BaseLock    time.Duration
+	MaxLock     time.Duration
+	// Window 距离最近一次失败超过该时长后失败计数清零，仅用于按 IP 统计
+	Window time.Duration
+}
+
+// LockDuration 返回累计失败 attempts 次后应锁定的时长，未达到阈值时返回 0
+func (p LockoutPolicy) LockDuration(attempts int) time.Duration {
+	if p.MaxAttempts <= 0 || attempts < p.MaxAttempts {
+		return 0
+	}
+	lock := p.BaseLock
+	for i := p.MaxAttempts; i < attempts && lock < p.MaxLock; i++ {
+		lock *= 2
+	}
+	if p.MaxLock > 0 && lock > p.MaxLock {
+		lock = p.MaxLock
+	}
+	return lock
+}
+
+// LoginLimiter 按来源（如客户端 IP）统计登录失败次数
+type LoginLimiter interface {
+	// LockedUntil 返回来源的锁定截止时间，未锁定时第二个返回值为 false
+	LockedUntil(key string) (time.Time, bool)
+	// Fail 记录一次失败，达到阈值时返回新的锁定截止时间
+	Fail(key string) (time.Time, bool)
+}
+
+type limiterEntry struct {
+	attempts    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// memoryLoginLimiter 进程内的登录失败计数，多实例部署时各实例分别计数
+type memoryLoginLimiter struct {
+	mu        sync.Mutex
+	policy    LockoutPolicy
+	entries   map[string]*limiterEntry
+	lastSweep time.Time
+}
+
+// NewMemoryLoginLimiter 创建进程内的登录失败计数器
+func NewMemoryLoginLimiter(policy LockoutPolicy) LoginLimiter {
+	return &memoryLoginLimiter{
+		policy:  policy,
+		entries: make(map[string]*limiterEntry),
+	}
+}
+
+func (l *memoryLoginLimiter) LockedUntil(key string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, ok := l.entries[key]
+	if !ok || !time.Now().Before(entry.lockedUntil) {
+		return time.Time{}, false
+	}
+	return entry.lockedUntil, true
+}
+
+func (l *memoryLoginLimiter) Fail(key string) (time.Time, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	entry, ok := l.entries[key]
+	if !ok || l.expired(entry, now) {
+		entry = &limiterEntry{}
+		l.entries[key] = entry
+	}
+	entry.attempts++
+	entry.lastFailure = now
+
+	lock := l.policy.LockDuration(entry.attempts)
+	if lock == 0 {
+		return time.Time{}, false
+	}
+	entry.lockedUntil = now.Add(lock)
+	return entry.lockedUntil, true
+}
+
+// expired 判断计数是否已经过了统计窗口且不在锁定中
+func (l *memoryLoginLimiter) expired(entry *limiterEntry, now time.Time) bool {
+	return now.After(entry.lockedUntil) && now.Sub(entry.lastFailure) > l.policy.Window
+}
+
+// sweep 每分钟最多清理一次过期的计数，避免占用的内存无限增长
+func (l *memoryLoginLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, entry := range l.entries {
+		if l.expired(entry, now) {
+			delete(l.entries, key)
+		}
+	}
+}
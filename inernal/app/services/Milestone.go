@@ -1,78 +1,274 @@
 package services
 
 import (
+	"GeekReward/inernal/app/authz"
 	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
 	"errors"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"strings"
 	"time"
 )
 
+var (
+	ErrMilestoneNotFound        = errors.New("里程碑未找到")
+	ErrMilestoneNotInProgress   = errors.New("只能在悬赏令进行中（已指派状态）提交或验收里程碑成果")
+	ErrMilestoneAlreadyAccepted = errors.New("里程碑已通过验收")
+	ErrMilestonesNotAccepted    = errors.New("还有里程碑未通过验收")
+	ErrSubmissionNotFound       = errors.New("里程碑成果提交不存在")
+	ErrSubmissionPending        = errors.New("上一轮成果尚未验收，请等待发布者处理")
+	ErrSubmissionNotPending     = errors.New("该成果已被验收或驳回")
+	ErrFeedbackRequired         = errors.New("驳回成果时必须填写修改意见")
+	ErrNotMilestoneParticipant  = errors.New("只有悬赏令的发布者和接收者可以查看里程碑成果")
+	ErrMilestoneDeleteLocked    = errors.New("里程碑已通过验收或已发放阶段赏金，不能删除")
+)
+
 type MilestoneService interface {
 	GetMilestonesByBountyID(bountyID uuid.UUID) ([]tables.Milestone, error)
 
 	// CreateMilestone 悬赏令发布者创建里程碑
-	CreateMilestone(bountyID uuid.UUID, input dtos.MilestoneDTO) (*tables.Milestone, error)
+	CreateMilestone(bountyID, userID uuid.UUID, input dtos.MilestoneDTO) (*tables.Milestone, error)
 
 	// UpdateMilestone 悬赏令（发布者）更新里程碑
-	UpdateMilestone(milestoneID uuid.UUID, input dtos.MilestoneUpdateDTO) error
+	UpdateMilestone(milestoneID, userID uuid.UUID, input dtos.MilestoneUpdateDTO) error
 
 	// DeleteMilestone 悬赏令（发布者）删除指定的悬赏令
-	DeleteMilestone(milestoneID uuid.UUID) error
+	DeleteMilestone(milestoneID, userID uuid.UUID) error
+
+	// SubmitMilestone 接收者提交里程碑成果，发布者驳回后可以修改后再次提交，每次提交为新的一轮
+	SubmitMilestone(bountyID, milestoneID, userID uuid.UUID, input dtos.SubmitMilestoneInput) (*tables.MilestoneSubmission, error)
+
+	// AcceptSubmission 发布者验收通过最近一轮成果，里程碑随之标记为完成
+	AcceptSubmission(bountyID, milestoneID, submissionID, userID uuid.UUID, input dtos.ReviewSubmissionInput) (*tables.MilestoneSubmission, error)
+
+	// RejectSubmission 发布者驳回最近一轮成果并给出修改意见
+	RejectSubmission(bountyID, milestoneID, submissionID, userID uuid.UUID, input dtos.ReviewSubmissionInput) (*tables.MilestoneSubmission, error)
 
-	// UpdateMilestoneByReceiver 悬赏令（接受者）更新里程碑（完成度）
-	UpdateMilestoneByReceiver(bountyID, milestoneID, userID uuid.UUID, input dtos.MilestoneUpdateDTO) error
+	// GetSubmissions 按轮次获取里程碑的全部成果提交，仅发布者与接收者可以查看
+	GetSubmissions(bountyID, milestoneID, userID uuid.UUID) ([]tables.MilestoneSubmission, error)
+
+	// UpdateMilestoneRewards 发布者为各里程碑分配阶段赏金，验收通过时自动发放
+	UpdateMilestoneRewards(bountyID, userID uuid.UUID, input dtos.UpdateMilestoneRewardsInput) ([]tables.Milestone, error)
 }
 
 type milestoneService struct {
-	milestoneRepo repositories.MilestoneRepository
-	bountyRepo    repositories.BountyRepository
-	userRepo      repositories.UserRepository
+	milestoneRepo   repositories.MilestoneRepository
+	bountyRepo      repositories.BountyRepository
+	applicationRepo repositories.ApplicationRepository
+	teamRepo        repositories.TeamRepository
+	ledgerRepo      repositories.LedgerRepository
+	settlementRepo  repositories.SettlementRepository
+	attachmentRepo  repositories.AttachmentRepository
+	outboxRepo      repositories.OutboxRepository
+	txManager       repositories.TransactionManager
 }
 
-func NewMilestoneService(milestoneRepo repositories.MilestoneRepository, bountyRepo repositories.BountyRepository) MilestoneService {
+func NewMilestoneService(
+	milestoneRepo repositories.MilestoneRepository,
+	bountyRepo repositories.BountyRepository,
+	applicationRepo repositories.ApplicationRepository,
+	teamRepo repositories.TeamRepository,
+	ledgerRepo repositories.LedgerRepository,
+	settlementRepo repositories.SettlementRepository,
+	attachmentRepo repositories.AttachmentRepository,
+	outboxRepo repositories.OutboxRepository,
+	txManager repositories.TransactionManager,
+) MilestoneService {
 	return &milestoneService{
-		milestoneRepo: milestoneRepo,
-		bountyRepo:    bountyRepo,
+		milestoneRepo:   milestoneRepo,
+		bountyRepo:      bountyRepo,
+		applicationRepo: applicationRepo,
+		teamRepo:        teamRepo,
+		ledgerRepo:      ledgerRepo,
+		settlementRepo:  settlementRepo,
+		attachmentRepo:  attachmentRepo,
+		outboxRepo:      outboxRepo,
+		txManager:       txManager,
 	}
 }
 
-// UpdateMilestoneByReceiver 悬赏零接收者更新里程碑
-func (s *milestoneService) UpdateMilestoneByReceiver(bountyID uuid.UUID, milestoneID uuid.UUID, userID uuid.UUID, input dtos.MilestoneUpdateDTO) error {
-	// 检查是否可以找到目标里程碑
-	milestone, err := s.milestoneRepo.FindByID(milestoneID)
+// SubmitMilestone 接收者提交里程碑成果，附件地址中接收者本人上传的附件随之关联到该里程碑
+func (s *milestoneService) SubmitMilestone(bountyID, milestoneID, userID uuid.UUID, input dtos.SubmitMilestoneInput) (*tables.MilestoneSubmission, error) {
+	milestone, bounty, err := s.findMilestone(bountyID, milestoneID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if milestone == nil {
-		return errors.New("里程碑未找到")
+	if err := authz.Can(userID, authz.ActionMilestoneSubmit, bounty); err != nil {
+		return nil, err
+	}
+	if bounty.Status != tables.BountyStatusAssigned {
+		return nil, ErrMilestoneNotInProgress
+	}
+	if milestone.IsCompleted {
+		return nil, ErrMilestoneAlreadyAccepted
 	}
 
-	// 获取并检查悬赏令
-	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	latest, err := s.milestoneRepo.FindLatestSubmission(milestoneID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	if bounty == nil {
-		return errors.New("悬赏令未找到")
+	round := 1
+	if latest != nil {
+		if latest.Status == tables.SubmissionStatusPending {
+			return nil, ErrSubmissionPending
+		}
+		round = latest.Round + 1
 	}
 
-	// 检查悬赏令是否有 ReceiverID
-	if bounty.ReceiverID == nil {
-		return errors.New("该悬赏令尚未被接受")
+	submission := &tables.MilestoneSubmission{
+		MilestoneID:    milestoneID,
+		BountyID:       bountyID,
+		SubmitterID:    userID,
+		Round:          round,
+		Content:        strings.TrimSpace(input.Content),
+		AttachmentURLs: input.AttachmentURLs,
+		Links:          input.Links,
+		CommitURLs:     input.CommitURLs,
+		Status:         tables.SubmissionStatusPending,
 	}
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		// (milestone_id, round) 唯一，并发提交时只有一个能成功
+		if err := s.milestoneRepo.WithTx(tx).CreateSubmission(submission); err != nil {
+			return err
+		}
+		var ids []uuid.UUID
+		for _, url := range submission.AttachmentURLs {
+			if id, ok := attachmentIDFromURL(url); ok {
+				ids = append(ids, id)
+			}
+		}
+		if _, err := s.attachmentRepo.WithTx(tx).LinkToMilestone(userID, bountyID, milestoneID, ids); err != nil {
+			return err
+		}
+		return s.recordSubmissionEvent(tx, tables.EventMilestoneSubmitted, submission, milestone, bounty)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
 
-	// 检查用户是否为悬赏令的接收者
-	if *bounty.ReceiverID != userID {
-		return errors.New("你没有权限更新此里程碑")
+func (s *milestoneService) AcceptSubmission(bountyID, milestoneID, submissionID, userID uuid.UUID, input dtos.ReviewSubmissionInput) (*tables.MilestoneSubmission, error) {
+	return s.reviewSubmission(bountyID, milestoneID, submissionID, userID, tables.SubmissionStatusAccepted, input.Feedback)
+}
+
+func (s *milestoneService) RejectSubmission(bountyID, milestoneID, submissionID, userID uuid.UUID, input dtos.ReviewSubmissionInput) (*tables.MilestoneSubmission, error) {
+	if strings.TrimSpace(input.Feedback) == "" {
+		return nil, ErrFeedbackRequired
 	}
+	return s.reviewSubmission(bountyID, milestoneID, submissionID, userID, tables.SubmissionStatusRejected, input.Feedback)
+}
 
-	// 悬赏令接受者仅仅允许更新里程碑字段
-	milestone.IsCompleted = input.IsCompleted
-	milestone.UpdatedAt = time.Now()
+// reviewSubmission 在同一事务中写入审核结果、验收通过时完成里程碑并发放阶段赏金，并通知提交者
+func (s *milestoneService) reviewSubmission(bountyID, milestoneID, submissionID, userID uuid.UUID, status, feedback string) (*tables.MilestoneSubmission, error) {
+	milestone, bounty, err := s.findMilestone(bountyID, milestoneID)
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Can(userID, authz.ActionMilestoneReview, bounty); err != nil {
+		return nil, err
+	}
+	if bounty.Status != tables.BountyStatusAssigned {
+		return nil, ErrMilestoneNotInProgress
+	}
+
+	submission, err := s.milestoneRepo.FindSubmissionByID(submissionID)
+	if err != nil {
+		return nil, err
+	}
+	if submission == nil || submission.MilestoneID != milestoneID {
+		return nil, ErrSubmissionNotFound
+	}
+	if submission.Status != tables.SubmissionStatusPending {
+		return nil, ErrSubmissionNotPending
+	}
+
+	now := time.Now()
+	submission.Status = status
+	submission.Feedback = strings.TrimSpace(feedback)
+	submission.ReviewerID = &userID
+	submission.ReviewedAt = &now
 
-	return s.milestoneRepo.UpdateMilestone(milestone)
+	eventType := tables.EventSubmissionRejected
+	if status == tables.SubmissionStatusAccepted {
+		eventType = tables.EventSubmissionAccepted
+	}
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		milestoneRepo := s.milestoneRepo.WithTx(tx)
+		ok, err := milestoneRepo.ReviewSubmission(submission)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrSubmissionNotPending
+		}
+		if status == tables.SubmissionStatusAccepted {
+			milestone.IsCompleted = true
+			milestone.UpdatedAt = now
+			if err := milestoneRepo.UpdateMilestone(milestone); err != nil {
+				return err
+			}
+			if err := s.releaseMilestoneReward(tx, bounty, milestone); err != nil {
+				return err
+			}
+		}
+		return s.recordSubmissionEvent(tx, eventType, submission, milestone, bounty)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return submission, nil
+}
+
+func (s *milestoneService) GetSubmissions(bountyID, milestoneID, userID uuid.UUID) ([]tables.MilestoneSubmission, error) {
+	_, bounty, err := s.findMilestone(bountyID, milestoneID)
+	if err != nil {
+		return nil, err
+	}
+	if authz.Can(userID, authz.ActionMilestoneReview, bounty) != nil && authz.Can(userID, authz.ActionMilestoneSubmit, bounty) != nil {
+		return nil, ErrNotMilestoneParticipant
+	}
+	return s.milestoneRepo.FindSubmissions(milestoneID)
+}
+
+// findMilestone 获取里程碑及其所属的悬赏令，里程碑必须属于路径中的悬赏令
+func (s *milestoneService) findMilestone(bountyID, milestoneID uuid.UUID) (*tables.Milestone, *tables.Bounty, error) {
+	milestone, err := s.milestoneRepo.FindByID(milestoneID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if milestone == nil || milestone.BountyID != bountyID {
+		return nil, nil, ErrMilestoneNotFound
+	}
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrMilestoneNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return milestone, bounty, nil
+}
+
+// recordSubmissionEvent 写入成果提交的领域事件，由通知消费者通知另一方
+func (s *milestoneService) recordSubmissionEvent(tx *gorm.DB, eventType string, submission *tables.MilestoneSubmission, milestone *tables.Milestone, bounty *tables.Bounty) error {
+	event, err := newOutboxEvent(eventType, "Milestone", milestone.ID, &dtos.MilestoneSubmissionEvent{
+		SubmissionID:   submission.ID,
+		MilestoneID:    milestone.ID,
+		MilestoneTitle: milestone.Title,
+		BountyID:       bounty.ID,
+		BountyTitle:    bounty.Title,
+		PublisherID:    bounty.UserID,
+		SubmitterID:    submission.SubmitterID,
+		Round:          submission.Round,
+		Feedback:       submission.Feedback,
+	})
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.WithTx(tx).Create(event)
 }
 
 // GetMilestonesByBountyID 获取指定悬赏令的所有里程碑
@@ -96,7 +292,7 @@ func (s *milestoneService) GetMilestonesByBountyID(bountyID uuid.UUID) ([]tables
 }
 
 // CreateMilestone 创建新的里程碑
-func (s *milestoneService) CreateMilestone(bountyID uuid.UUID, input dtos.MilestoneDTO) (*tables.Milestone, error) {
+func (s *milestoneService) CreateMilestone(bountyID, userID uuid.UUID, input dtos.MilestoneDTO) (*tables.Milestone, error) {
 	// 检查关联的悬赏令是否存在
 	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
 	if err != nil {
@@ -105,6 +301,9 @@ func (s *milestoneService) CreateMilestone(bountyID uuid.UUID, input dtos.Milest
 	if bounty == nil {
 		return nil, errors.New("bounty not found")
 	}
+	if err := authz.Can(userID, authz.ActionMilestoneCreate, bounty); err != nil {
+		return nil, err
+	}
 
 	// 创建里程碑
 	milestone := &tables.Milestone{
@@ -124,7 +323,7 @@ func (s *milestoneService) CreateMilestone(bountyID uuid.UUID, input dtos.Milest
 }
 
 // UpdateMilestone 发布者更新里程碑信息
-func (s *milestoneService) UpdateMilestone(milestoneID uuid.UUID, input dtos.MilestoneUpdateDTO) error {
+func (s *milestoneService) UpdateMilestone(milestoneID, userID uuid.UUID, input dtos.MilestoneUpdateDTO) error {
 	// 获取里程碑
 	milestone, err := s.milestoneRepo.FindByID(milestoneID)
 	if err != nil {
@@ -133,14 +332,16 @@ func (s *milestoneService) UpdateMilestone(milestoneID uuid.UUID, input dtos.Mil
 	if milestone == nil {
 		return errors.New("未找到悬赏令")
 	}
+	if err := s.authorize(userID, authz.ActionMilestoneUpdate, milestone); err != nil {
+		return err
+	}
 
 	// 更新里程碑字段
 	milestone.Title = input.Title
 	milestone.Description = input.Description
 	milestone.DueDate = input.DueDate
 
-	// 发布者也能修改完成状态 (保持可选)
-	// milestone.IsCompleted = input.IsCompleted
+	// 完成状态只能通过验收成果改变，见 AcceptSubmission
 
 	milestone.UpdatedAt = time.Now()
 
@@ -154,7 +355,7 @@ func (s *milestoneService) UpdateMilestone(milestoneID uuid.UUID, input dtos.Mil
 }
 
 // DeleteMilestone 删除里程碑
-func (s *milestoneService) DeleteMilestone(milestoneID uuid.UUID) error {
+func (s *milestoneService) DeleteMilestone(milestoneID, userID uuid.UUID) error {
 	// 获取里程碑
 	milestone, err := s.milestoneRepo.FindByID(milestoneID)
 	if err != nil {
@@ -163,12 +364,34 @@ func (s *milestoneService) DeleteMilestone(milestoneID uuid.UUID) error {
 	if milestone == nil {
 		return errors.New("悬赏令未找到")
 	}
+	if err := s.authorize(userID, authz.ActionMilestoneDelete, milestone); err != nil {
+		return err
+	}
+	// 已验收的成果与已发放的阶段赏金都是结算的依据，删除后无从追溯
+	if milestone.IsCompleted || milestone.RewardReleasedAt != nil {
+		return ErrMilestoneDeleteLocked
+	}
+	if milestone.RewardAmount > 0 {
+		return ErrMilestoneHasReward
+	}
 
-	// 删除里程碑
-	err = s.milestoneRepo.DeleteMilestone(milestone)
+	// 删除里程碑，与验收并发时以数据库中的状态为准
+	deleted, err := s.milestoneRepo.DeleteMilestone(milestone)
 	if err != nil {
 		return err
 	}
+	if !deleted {
+		return ErrMilestoneDeleteLocked
+	}
 
 	return nil
 }
+
+// authorize 以里程碑所属的悬赏令校验调用者的权限
+func (s *milestoneService) authorize(userID uuid.UUID, action authz.Action, milestone *tables.Milestone) error {
+	bounty, err := s.bountyRepo.FindBountyByID(milestone.BountyID)
+	if err != nil {
+		return err
+	}
+	return authz.Can(userID, action, bounty)
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"GeekReward/inernal/app/authz"
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/pkg/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"math"
+	"slices"
+	"time"
+)
+
+var (
+	ErrInvalidMilestoneRewards  = errors.New("无效的里程碑赏金分配")
+	ErrMilestoneRewardsLocked   = errors.New("只能在悬赏令开工前或进行中、且尚未发放任何阶段赏金时修改里程碑赏金")
+	ErrMilestoneRewardsOutdated = errors.New("已为里程碑分配阶段赏金，请先取消里程碑赏金分配再修改赏金")
+	ErrMilestoneHasReward       = errors.New("该里程碑已分配阶段赏金，请先调整里程碑赏金分配再删除")
+)
+
+// UpdateMilestoneRewards 发布者为里程碑分配阶段赏金，按金额分配时之和必须等于悬赏令的赏金，按比例分配时之和必须为 100%
+func (s *milestoneService) UpdateMilestoneRewards(bountyID, userID uuid.UUID, input dtos.UpdateMilestoneRewardsInput) ([]tables.Milestone, error) {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.New("悬赏令未找到")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := authz.Can(userID, authz.ActionMilestoneReward, bounty); err != nil {
+		return nil, err
+	}
+	if bounty.Status != tables.BountyStatusCreated && bounty.Status != tables.BountyStatusAssigned {
+		return nil, ErrMilestoneRewardsLocked
+	}
+
+	milestones, err := s.milestoneRepo.FindByBountyID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	if slices.ContainsFunc(milestones, func(m tables.Milestone) bool { return m.RewardReleasedAt != nil }) {
+		return nil, ErrMilestoneRewardsLocked
+	}
+
+	values := make(map[uuid.UUID]float64, len(input.Items))
+	var order []uuid.UUID
+	var sum float64
+	for _, item := range input.Items {
+		i := slices.IndexFunc(milestones, func(m tables.Milestone) bool { return m.ID == item.MilestoneID })
+		if i < 0 {
+			return nil, fmt.Errorf("%w: 里程碑 %s 不属于该悬赏令", ErrInvalidMilestoneRewards, item.MilestoneID)
+		}
+		if _, ok := values[item.MilestoneID]; ok {
+			return nil, fmt.Errorf("%w: 里程碑【%s】重复", ErrInvalidMilestoneRewards, milestones[i].Title)
+		}
+		// 已验收的里程碑不会再触发发放，其赏金只能留到最终结算
+		if milestones[i].IsCompleted && item.Value > 0 {
+			return nil, fmt.Errorf("%w: 里程碑【%s】已通过验收，不能再分配阶段赏金", ErrInvalidMilestoneRewards, milestones[i].Title)
+		}
+		values[item.MilestoneID] = item.Value
+		if item.Value > 0 {
+			order = append(order, item.MilestoneID)
+		}
+		sum += item.Value
+	}
+
+	// 按比例分配时金额向下取整到分，分不尽的零头计入最后一项
+	amounts := make(map[uuid.UUID]float64, len(order))
+	percentages := make(map[uuid.UUID]float64, len(order))
+	if len(order) > 0 {
+		if input.Unit == dtos.MilestoneRewardUnitPercentage {
+			if math.Abs(sum-100) > 0.01 {
+				return nil, fmt.Errorf("%w: 比例之和为 %.2f%%，必须为 100%%", ErrInvalidMilestoneRewards, sum)
+			}
+			var allocated float64
+			for i, id := range order {
+				percentages[id] = math.Round(values[id]*100) / 100
+				if i == len(order)-1 {
+					amounts[id] = utils.RoundCents(bounty.Reward - allocated)
+					break
+				}
+				amounts[id] = math.Floor(bounty.Reward*values[id]) / 100
+				allocated += amounts[id]
+			}
+		} else {
+			if math.Abs(sum-bounty.Reward) >= 0.005 {
+				return nil, fmt.Errorf("%w: 金额之和为 %.2f，必须等于悬赏令的赏金 %.2f", ErrInvalidMilestoneRewards, sum, bounty.Reward)
+			}
+			for _, id := range order {
+				amounts[id] = utils.RoundCents(values[id])
+				percentages[id] = math.Round(amounts[id]/bounty.Reward*10000) / 100
+			}
+		}
+	}
+
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		milestoneRepo := s.milestoneRepo.WithTx(tx)
+		for i := range milestones {
+			milestones[i].RewardAmount = amounts[milestones[i].ID]
+			milestones[i].RewardPercentage = percentages[milestones[i].ID]
+			if err := milestoneRepo.UpdateMilestone(&milestones[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return milestones, nil
+}
+
+// releaseMilestoneReward 里程碑验收通过时按悬赏令的分配方式将阶段赏金从托管账户发放给接收者，并保存结算明细，
+// 须在验收的事务中调用
+func (s *milestoneService) releaseMilestoneReward(tx *gorm.DB, bounty *tables.Bounty, milestone *tables.Milestone) error {
+	if milestone.RewardAmount < 0.005 || milestone.RewardReleasedAt != nil {
+		return nil
+	}
+	if bounty.PaymentStatus != tables.PaymentStatusEscrowed {
+		return errors.New("bounty reward is not held in escrow")
+	}
+
+	now := time.Now()
+	released, err := s.milestoneRepo.WithTx(tx).MarkRewardReleased(milestone.ID, now)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return nil
+	}
+	milestone.RewardReleasedAt = &now
+
+	participants, err := bountyParticipants(s.teamRepo, s.applicationRepo, bounty)
+	if err != nil {
+		return err
+	}
+	shares, err := s.settlementRepo.FindShares(bounty.ID)
+	if err != nil {
+		return err
+	}
+	// 按里程碑归属分配时阶段赏金全部归该里程碑的负责人
+	items, err := computeSplit(bounty.ID, bounty.SplitRule, milestone.RewardAmount, participants, shares, []tables.Milestone{*milestone})
+	if err != nil {
+		return err
+	}
+
+	transfers := []ledgerTransfer{{tables.LedgerAccountEscrow, bounty.ID, -milestone.RewardAmount}}
+	payouts := make([]dtos.BountyPayout, len(items))
+	for i := range items {
+		items[i].MilestoneID = &milestone.ID
+		items[i].Memo = "里程碑【" + milestone.Title + "】的阶段赏金"
+		payouts[i] = dtos.BountyPayout{UserID: items[i].UserID, Amount: items[i].Amount}
+		transfers = append(transfers, ledgerTransfer{tables.LedgerAccountUser, items[i].UserID, items[i].Amount})
+	}
+	if err := s.settlementRepo.WithTx(tx).CreateLineItems(items); err != nil {
+		return err
+	}
+	if err := postLedgerEntry(s.ledgerRepo.WithTx(tx), tables.JournalEntryMilestoneRelease, &bounty.ID,
+		"发放悬赏令【"+bounty.Title+"】里程碑【"+milestone.Title+"】的阶段赏金", transfers...); err != nil {
+		return err
+	}
+
+	event, err := newOutboxEvent(tables.EventMilestoneRewardReleased, "Milestone", milestone.ID, &dtos.MilestoneRewardReleasedEvent{
+		MilestoneID:    milestone.ID,
+		MilestoneTitle: milestone.Title,
+		BountyID:       bounty.ID,
+		BountyTitle:    bounty.Title,
+		PublisherID:    bounty.UserID,
+		Payouts:        payouts,
+	})
+	if err != nil {
+		return err
+	}
+	return s.outboxRepo.WithTx(tx).Create(event)
+}
@@ -1,17 +1,44 @@
 package services
 
 import (
+	"GeekReward/inernal/app/models/dtos"
 	"GeekReward/inernal/app/models/tables"
 	"GeekReward/inernal/app/repositories"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log"
+)
+
+var (
+	ErrNotificationNotFound = errors.New("通知不存在")
 )
 
 type NotificationService interface {
 	CreateNotification(notification *tables.Notification) error
-	GetUserNotifications(userID uuid.UUID) ([]tables.Notification, error)
-	MarkNotificationAsRead(notificationID uuid.UUID) error
-	DeleteNotification(notificationID uuid.UUID) error
+	// ForEvent 返回为指定发件箱事件创建通知的服务，同一事件对同一接收者只会通知一次
+	ForEvent(eventID uuid.UUID) NotificationService
+	GetUserNotifications(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[tables.Notification], error)
+	// GetGroupedNotifications 返回折叠重复事件后的通知列表，如“5 人点赞了你的悬赏令”
+	GetGroupedNotifications(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[dtos.NotificationGroup], error)
+	GetUnreadCount(userID uuid.UUID) (int64, error)
+	// GetUnreadCountByType 返回未读通知总数及各类型的未读数
+	GetUnreadCountByType(userID uuid.UUID) (*dtos.NotificationUnreadCount, error)
+	// GetPreferences 返回用户对每类通知在各渠道上的开关
+	GetPreferences(userID uuid.UUID) (*dtos.NotificationPreferences, error)
+	// UpdatePreferences 修改用户的通知偏好，返回修改后的完整设置
+	UpdatePreferences(userID uuid.UUID, input dtos.UpdateNotificationPreferencesInput) (*dtos.NotificationPreferences, error)
+	// MarkNotificationAsRead 将用户的一条通知标记为已读，通知不属于该用户时返回 ErrNotificationNotFound
+	MarkNotificationAsRead(userID, notificationID uuid.UUID) error
+	// MarkAllAsRead 将用户符合筛选条件的未读通知全部标记为已读
+	MarkAllAsRead(userID uuid.UUID, filter dtos.NotificationFilter) (int64, error)
+	// MarkNotificationsAsRead 批量标记已读，只作用于属于该用户的通知
+	MarkNotificationsAsRead(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error)
+	// DeleteNotification 删除用户的一条通知，通知不属于该用户时返回 ErrNotificationNotFound
+	DeleteNotification(userID, notificationID uuid.UUID) error
+	// DeleteNotifications 批量删除，只作用于属于该用户的通知
+	DeleteNotifications(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error)
 	CreateBountyApplicationNotification(applicantID, publisherID uuid.UUID, bountyID uuid.UUID, applicantName, bountyTitle string) error
 	CreateApplicationApprovedNotification(publisherID, applicantID uuid.UUID, bountyID uuid.UUID, bountyTitle string) error
 	CreateApplicationRejectedNotification(publisherID, applicantID uuid.UUID, bountyID uuid.UUID, bountyTitle string) error
@@ -27,6 +54,12 @@ type NotificationService interface {
 
 type notificationService struct {
 	notificationRepo repositories.NotificationRepository
+	preferenceRepo   repositories.NotificationPreferenceRepository
+	userRepo         repositories.UserRepository
+	hub              NotificationHub
+	senders          map[tables.NotificationChannel]NotificationSender
+	// eventID 非空时，创建的通知关联到该发件箱事件
+	eventID *uuid.UUID
 }
 
 // CreateUserRatedNotification 用于在“用户被评价”时自动构造通知
@@ -34,7 +67,7 @@ func (s *notificationService) CreateUserRatedNotification(actorID, targetUserID
 	notification := &tables.Notification{
 		UserID:      targetUserID,
 		ActorID:     &actorID,
-		Type:        "UserRated",
+		Type:        tables.NotificationTypeUserRated,
 		Title:       "你收到了一条评价",
 		Description: "评分：" + fmt.Sprintf("%.1f", rating) + "，评价内容：" + comment,
 		Metadata: map[string]any{
@@ -42,7 +75,7 @@ func (s *notificationService) CreateUserRatedNotification(actorID, targetUserID
 			"comment": comment,
 		},
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateBountyCancelledNotification 通知发布者 & 接收者
@@ -50,7 +83,7 @@ func (s *notificationService) CreateBountyCancelledNotification(publisherID, rec
 	notifications := []*tables.Notification{
 		{
 			UserID:      publisherID,
-			Type:        "BountyCancelled",
+			Type:        tables.NotificationTypeBountyCancelled,
 			Title:       "你的悬赏令已取消",
 			Description: "悬赏令【" + bountyTitle + "】已被取消。",
 			RelatedID:   &bountyID,
@@ -58,7 +91,7 @@ func (s *notificationService) CreateBountyCancelledNotification(publisherID, rec
 		},
 		{
 			UserID:      receiverID,
-			Type:        "BountyCancelled",
+			Type:        tables.NotificationTypeBountyCancelled,
 			Title:       "你参与的悬赏令已取消",
 			Description: "悬赏令【" + bountyTitle + "】已被取消。",
 			RelatedID:   &bountyID,
@@ -67,7 +100,7 @@ func (s *notificationService) CreateBountyCancelledNotification(publisherID, rec
 	}
 
 	for _, notification := range notifications {
-		if err := s.notificationRepo.CreateNotification(notification); err != nil {
+		if err := s.create(notification); err != nil {
 			return err
 		}
 	}
@@ -80,7 +113,7 @@ func (s *notificationService) CreateSettlementCompletedNotification(publisherID,
 	notifications := []*tables.Notification{
 		{
 			UserID:      publisherID,
-			Type:        "SettlementCompleted",
+			Type:        tables.NotificationTypeSettlementCompleted,
 			Title:       "悬赏清算成功",
 			Description: "悬赏令【" + bountyTitle + "】已清算完成。",
 			RelatedID:   &bountyID,
@@ -88,7 +121,7 @@ func (s *notificationService) CreateSettlementCompletedNotification(publisherID,
 		},
 		{
 			UserID:      receiverID,
-			Type:        "SettlementCompleted",
+			Type:        tables.NotificationTypeSettlementCompleted,
 			Title:       "悬赏清算成功",
 			Description: "悬赏令【" + bountyTitle + "】已清算完成。",
 			RelatedID:   &bountyID,
@@ -97,7 +130,7 @@ func (s *notificationService) CreateSettlementCompletedNotification(publisherID,
 	}
 
 	for _, notification := range notifications {
-		if err := s.notificationRepo.CreateNotification(notification); err != nil {
+		if err := s.create(notification); err != nil {
 			return err
 		}
 	}
@@ -110,13 +143,13 @@ func (s *notificationService) CreateSettlementAppliedNotification(receiverID, pu
 	notification := &tables.Notification{
 		UserID:      publisherID,
 		ActorID:     &receiverID,
-		Type:        "SettlementApplied",
+		Type:        tables.NotificationTypeSettlementApplied,
 		Title:       "悬赏清算申请",
 		Description: "接收者申请清算悬赏令【" + bountyTitle + "】，请及时审核。",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateMilestoneCompletedNotification 用于在“悬赏接收者提交了里程碑”时自动构造通知
@@ -124,13 +157,13 @@ func (s *notificationService) CreateMilestoneCompletedNotification(receiverID, p
 	notification := &tables.Notification{
 		UserID:      publisherID,
 		ActorID:     &receiverID,
-		Type:        "MilestoneSubmitted",
+		Type:        tables.NotificationTypeMilestoneSubmitted,
 		Title:       "里程碑已提交",
 		Description: "里程碑【" + milestoneTitle + "】已被接收者提交，请及时处理。",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateMilestoneConfirmedNotification 用于在“悬赏发布者确认了里程碑”时自动构造通知
@@ -138,13 +171,13 @@ func (s *notificationService) CreateMilestoneConfirmedNotification(publisherID,
 	notification := &tables.Notification{
 		UserID:      receiverID,
 		ActorID:     &publisherID,
-		Type:        "MilestoneConfirmed",
+		Type:        tables.NotificationTypeMilestoneConfirmed,
 		Title:       "里程碑已确认",
 		Description: "悬赏令的里程碑【" + milestoneTitle + "】已被发布者确认。",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateApplicationRejectedNotification 用于在“我的悬赏令申请被拒绝”时自动构造通知
@@ -152,13 +185,13 @@ func (s *notificationService) CreateApplicationRejectedNotification(publisherID,
 	notification := &tables.Notification{
 		UserID:      applicantID,
 		ActorID:     &publisherID,
-		Type:        "ApplicationRejected",
+		Type:        tables.NotificationTypeApplicationRejected,
 		Title:       "你的悬赏申请被拒绝",
 		Description: "很抱歉，你的悬赏申请【" + bountyTitle + "】被拒绝。",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateApplicationApprovedNotification 用于在“我的悬赏令申请被批准”时自动构造通知
@@ -166,13 +199,13 @@ func (s *notificationService) CreateApplicationApprovedNotification(publisherID,
 	notification := &tables.Notification{
 		UserID:      applicantID, // 通知接收者
 		ActorID:     &publisherID,
-		Type:        "ApplicationApproved",
+		Type:        tables.NotificationTypeApplicationApproved,
 		Title:       "你的悬赏申请已被批准",
 		Description: "你的悬赏申请【" + bountyTitle + "】已被批准，快去查看吧！",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateBountyApplicationNotification 用于在“某人申请了我的悬赏令”时自动构造通知
@@ -180,13 +213,13 @@ func (s *notificationService) CreateBountyApplicationNotification(applicantID, p
 	notification := &tables.Notification{
 		UserID:      publisherID, // 通知接收者
 		ActorID:     &applicantID,
-		Type:        "BountyApplied",
+		Type:        tables.NotificationTypeBountyApplied,
 		Title:       "有人申请了你的悬赏令",
 		Description: "申请者【" + applicantName + "】申请了悬赏令【" + bountyTitle + "】",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateBountyLikeNotification 用于在“某个用户点赞了我的悬赏令”时自动构造通知
@@ -194,13 +227,13 @@ func (s *notificationService) CreateBountyLikeNotification(actorID, publisherID
 	notification := &tables.Notification{
 		UserID:      publisherID, // 通知接收者
 		ActorID:     &actorID,    // 触发者
-		Type:        "BountyLiked",
+		Type:        tables.NotificationTypeBountyLiked,
 		Title:       "有人点赞了你的悬赏令",
 		Description: "你的悬赏令【" + bountyTitle + "】被点赞了",
 		RelatedID:   &bountyID,
 		RelatedType: "Bounty",
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
 // CreateCommentNotification 表示在悬赏令下评论时
@@ -208,7 +241,7 @@ func (s *notificationService) CreateCommentNotification(actorID, publisherID uui
 	notification := &tables.Notification{
 		UserID:      publisherID,
 		ActorID:     &actorID,
-		Type:        "BountyCommented",
+		Type:        tables.NotificationTypeBountyCommented,
 		Title:       "你的悬赏令有新评论",
 		Description: "评论内容：" + commentContent,
 		RelatedID:   &bountyID,
@@ -218,25 +251,193 @@ func (s *notificationService) CreateCommentNotification(actorID, publisherID uui
 			"comment": commentContent,
 		},
 	}
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
 }
 
-func NewNotificationService(notificationRepo repositories.NotificationRepository) NotificationService {
-	return &notificationService{notificationRepo: notificationRepo}
+// NewNotificationService senders 为站内信之外的渠道，未注册的渠道即使用户开启也不会投递
+func NewNotificationService(
+	notificationRepo repositories.NotificationRepository,
+	preferenceRepo repositories.NotificationPreferenceRepository,
+	userRepo repositories.UserRepository,
+	hub NotificationHub,
+	senders map[tables.NotificationChannel]NotificationSender,
+) NotificationService {
+	return &notificationService{
+		notificationRepo: notificationRepo,
+		preferenceRepo:   preferenceRepo,
+		userRepo:         userRepo,
+		hub:              hub,
+		senders:          senders,
+	}
+}
+
+// create 按接收者的偏好设置投递通知：站内信保存并实时推送，其余渠道异步发送
+func (s *notificationService) create(notification *tables.Notification) error {
+	user, channels, err := s.enabledChannels(notification.UserID, notification.Type)
+	if err != nil {
+		return err
+	}
+
+	if s.eventID != nil {
+		notification.EventID = s.eventID
+		// 事件重试时，已收到过该事件通知的接收者不再重复推送与发送其他渠道
+		created, err := s.notificationRepo.CreateEventNotification(notification)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return nil
+		}
+		// 站内渠道关闭时软删除该记录，不展示给用户，但仍占用唯一索引用于去重
+		if !channels[tables.NotificationChannelInApp] {
+			if err := s.notificationRepo.DeleteNotification(notification.UserID, notification.ID); err != nil {
+				return err
+			}
+		} else {
+			publishNotification(s.hub, s.notificationRepo, notification)
+		}
+	} else if channels[tables.NotificationChannelInApp] {
+		if err := s.notificationRepo.CreateNotification(notification); err != nil {
+			return err
+		}
+		publishNotification(s.hub, s.notificationRepo, notification)
+	}
+
+	for channel, sender := range s.senders {
+		if !channels[channel] {
+			continue
+		}
+		go func(channel tables.NotificationChannel, sender NotificationSender) {
+			if err := sender.Send(user, notification); err != nil {
+				log.Printf("通过 %s 发送通知失败: %v", channel, err)
+			}
+		}(channel, sender)
+	}
+	return nil
 }
 
 func (s *notificationService) CreateNotification(notification *tables.Notification) error {
-	return s.notificationRepo.CreateNotification(notification)
+	return s.create(notification)
+}
+
+func (s *notificationService) ForEvent(eventID uuid.UUID) NotificationService {
+	scoped := *s
+	scoped.eventID = &eventID
+	return &scoped
+}
+
+func (s *notificationService) GetUserNotifications(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[tables.Notification], error) {
+	return s.notificationRepo.FindNotificationsByUserID(userID, filter, page)
+}
+
+// notificationGroupSummaries 多人触发同一事件时的折叠摘要，%d 为不同触发者的数量
+var notificationGroupSummaries = map[tables.NotificationType]string{
+	tables.NotificationTypeBountyLiked:     "%d 人点赞了你的悬赏令",
+	tables.NotificationTypeBountyCommented: "%d 人评论了你的悬赏令",
+	tables.NotificationTypeBountyApplied:   "%d 人申请了你的悬赏令",
+}
+
+func (s *notificationService) GetGroupedNotifications(userID uuid.UUID, filter dtos.NotificationFilter, page dtos.PageQuery) (*dtos.Page[dtos.NotificationGroup], error) {
+	groups, err := s.notificationRepo.FindGroupsByUserID(userID, filter, page)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range groups.Items {
+		group := &groups.Items[i]
+		format, ok := notificationGroupSummaries[group.Type]
+		switch {
+		case group.Count == 1:
+			group.Summary = group.Title
+		case ok && group.ActorCount > 1:
+			group.Summary = fmt.Sprintf(format, group.ActorCount)
+		default:
+			group.Summary = fmt.Sprintf("%s（共 %d 条）", group.Title, group.Count)
+		}
+	}
+	return groups, nil
+}
+
+func (s *notificationService) GetUnreadCount(userID uuid.UUID) (int64, error) {
+	return s.notificationRepo.CountUnread(userID)
+}
+
+func (s *notificationService) GetUnreadCountByType(userID uuid.UUID) (*dtos.NotificationUnreadCount, error) {
+	byType, err := s.notificationRepo.CountUnreadByType(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dtos.NotificationUnreadCount{ByType: byType}
+	for _, count := range byType {
+		result.Total += count
+	}
+	return result, nil
+}
+
+func (s *notificationService) MarkNotificationAsRead(userID, notificationID uuid.UUID) error {
+	if _, err := s.findUserNotification(userID, notificationID); err != nil {
+		return err
+	}
+	if err := s.notificationRepo.MarkAsRead(userID, notificationID); err != nil {
+		return err
+	}
+	publishUnreadCount(s.hub, s.notificationRepo, userID)
+	return nil
 }
 
-func (s *notificationService) GetUserNotifications(userID uuid.UUID) ([]tables.Notification, error) {
-	return s.notificationRepo.FindNotificationsByUserID(userID)
+func (s *notificationService) DeleteNotification(userID, notificationID uuid.UUID) error {
+	notification, err := s.findUserNotification(userID, notificationID)
+	if err != nil {
+		return err
+	}
+	if err := s.notificationRepo.DeleteNotification(userID, notificationID); err != nil {
+		return err
+	}
+	if !notification.IsRead {
+		publishUnreadCount(s.hub, s.notificationRepo, userID)
+	}
+	return nil
 }
 
-func (s *notificationService) MarkNotificationAsRead(notificationID uuid.UUID) error {
-	return s.notificationRepo.MarkAsRead(notificationID)
+// findUserNotification 查找属于该用户的通知，其他用户的通知与不存在的通知一样返回 ErrNotificationNotFound
+func (s *notificationService) findUserNotification(userID, notificationID uuid.UUID) (*tables.Notification, error) {
+	notification, err := s.notificationRepo.FindUserNotification(userID, notificationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotificationNotFound
+	}
+	return notification, err
 }
 
-func (s *notificationService) DeleteNotification(notificationID uuid.UUID) error {
-	return s.notificationRepo.DeleteNotification(notificationID)
+func (s *notificationService) MarkAllAsRead(userID uuid.UUID, filter dtos.NotificationFilter) (int64, error) {
+	affected, err := s.notificationRepo.MarkAllAsRead(userID, filter)
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		publishUnreadCount(s.hub, s.notificationRepo, userID)
+	}
+	return affected, nil
+}
+
+func (s *notificationService) MarkNotificationsAsRead(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error) {
+	affected, err := s.notificationRepo.MarkManyAsRead(userID, notificationIDs)
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		publishUnreadCount(s.hub, s.notificationRepo, userID)
+	}
+	return affected, nil
+}
+
+func (s *notificationService) DeleteNotifications(userID uuid.UUID, notificationIDs []uuid.UUID) (int64, error) {
+	affected, err := s.notificationRepo.DeleteNotifications(userID, notificationIDs)
+	if err != nil {
+		return 0, err
+	}
+	if affected > 0 {
+		publishUnreadCount(s.hub, s.notificationRepo, userID)
+	}
+	return affected, nil
 }
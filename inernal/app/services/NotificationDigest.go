@@ -0,0 +1,260 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/mailer"
+	"bytes"
+	"context"
+	"embed"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates/digest
+var digestTemplateFS embed.FS
+
+// 摘要邮件的模板，按语言区分；纯文本模板定义 subject 与 body，HTML 模板定义 html
+var (
+	digestTextTemplates = map[string]*texttemplate.Template{
+		"en": texttemplate.Must(texttemplate.ParseFS(digestTemplateFS, "templates/digest/en.txt")),
+		"zh": texttemplate.Must(texttemplate.ParseFS(digestTemplateFS, "templates/digest/zh.txt")),
+	}
+	digestHTMLTemplates = map[string]*htmltemplate.Template{
+		"en": htmltemplate.Must(htmltemplate.ParseFS(digestTemplateFS, "templates/digest/en.html")),
+		"zh": htmltemplate.Must(htmltemplate.ParseFS(digestTemplateFS, "templates/digest/zh.html")),
+	}
+)
+
+// digestMaxItems 摘要中最多列出的通知条数，其余只显示数量
+const digestMaxItems = 20
+
+// DigestSettings 摘要邮件的发送设置
+type DigestSettings struct {
+	FrontendURL string
+	SendHour    int           // 用户当地时间几点之后发送上一周期的摘要
+	Interval    time.Duration // 检查到期摘要的间隔
+}
+
+// NotificationDigestService 定期将用户的未读通知汇总为摘要邮件
+type NotificationDigestService interface {
+	// Run 按 Interval 检查并发送到期的摘要，直到 ctx 结束
+	Run(ctx context.Context) error
+	// SendDue 发送截至 now 已到期且尚未发送的摘要，返回成功发送的封数
+	SendDue(now time.Time) (int, error)
+}
+
+type notificationDigestService struct {
+	digestRepo       repositories.NotificationDigestRepository
+	notificationRepo repositories.NotificationRepository
+	mailer           mailer.Mailer
+	settings         DigestSettings
+}
+
+func NewNotificationDigestService(
+	digestRepo repositories.NotificationDigestRepository,
+	notificationRepo repositories.NotificationRepository,
+	m mailer.Mailer,
+	settings DigestSettings,
+) NotificationDigestService {
+	if settings.Interval <= 0 {
+		settings.Interval = 15 * time.Minute
+	}
+	if settings.SendHour < 0 || settings.SendHour > 23 {
+		settings.SendHour = 8
+	}
+	return &notificationDigestService{
+		digestRepo:       digestRepo,
+		notificationRepo: notificationRepo,
+		mailer:           m,
+		settings:         settings,
+	}
+}
+
+func (s *notificationDigestService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.settings.Interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.SendDue(time.Now()); err != nil {
+			log.Println("发送通知摘要失败:", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *notificationDigestService) SendDue(now time.Time) (int, error) {
+	// 周摘要覆盖的时间最长，多取一天以容纳各时区的差异
+	users, err := s.digestRepo.FindRecipients(now.AddDate(0, 0, -8))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for i := range users {
+		ok, err := s.sendDigest(&users[i], now)
+		if err != nil {
+			// 单个用户失败不影响其他用户，记录已释放，下次检查时重试
+			log.Printf("向用户 %s 发送通知摘要失败: %v", users[i].ID, err)
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// sendDigest 发送用户上一个完整周期的摘要，周期未到期、没有未读通知或已经发送过时返回 false
+func (s *notificationDigestService) sendDigest(user *tables.User, now time.Time) (bool, error) {
+	loc := userLocation(user.Timezone)
+	start, end, due := digestPeriod(user.DigestFrequency, now.In(loc), s.settings.SendHour)
+	if !due {
+		return false, nil
+	}
+
+	notifications, err := s.notificationRepo.FindUnreadCreatedBetween(user.ID, start, end)
+	if err != nil || len(notifications) == 0 {
+		return false, err
+	}
+
+	digest := &tables.NotificationDigest{
+		UserID:      user.ID,
+		Frequency:   user.DigestFrequency,
+		PeriodStart: start,
+		PeriodEnd:   end,
+	}
+	for _, n := range notifications {
+		digest.NotificationIDs = append(digest.NotificationIDs, n.ID.String())
+	}
+	claimed, err := s.digestRepo.Claim(digest)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	msg, err := s.render(user, start, end, notifications)
+	if err == nil {
+		err = s.mailer.Send(msg)
+	}
+	if err != nil {
+		if releaseErr := s.digestRepo.Release(digest.ID); releaseErr != nil {
+			log.Printf("释放通知摘要记录 %s 失败: %v", digest.ID, releaseErr)
+		}
+		return false, err
+	}
+
+	if err := s.digestRepo.MarkSent(digest.ID, time.Now()); err != nil {
+		// 邮件已发出，占位记录仍能防止重复发送
+		log.Printf("记录通知摘要 %s 的发送时间失败: %v", digest.ID, err)
+	}
+	return true, nil
+}
+
+// digestPeriod 返回 local 所在时刻之前最近一个完整周期 [start, end)，以及是否已到发送时间
+// 日摘要为前一天，周摘要为上周一至本周一
+func digestPeriod(frequency tables.DigestFrequency, local time.Time, sendHour int) (start, end time.Time, due bool) {
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location())
+	switch frequency {
+	case tables.DigestFrequencyDaily:
+		end = today
+		start = end.AddDate(0, 0, -1)
+	case tables.DigestFrequencyWeekly:
+		sinceMonday := (int(today.Weekday()) + 6) % 7
+		end = today.AddDate(0, 0, -sinceMonday)
+		start = end.AddDate(0, 0, -7)
+	default:
+		return start, end, false
+	}
+	return start, end, !local.Before(end.Add(time.Duration(sendHour) * time.Hour))
+}
+
+// userLocation 解析用户的时区，无效时使用 UTC
+func userLocation(timezone string) *time.Location {
+	if timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// digestLanguage 将用户的首选语言映射为已有模板的语言，默认英文
+func digestLanguage(preferred string) string {
+	if strings.HasPrefix(strings.ToLower(preferred), "zh") {
+		return "zh"
+	}
+	return "en"
+}
+
+type digestItem struct {
+	Title       string
+	Description string
+	Time        string
+}
+
+type digestView struct {
+	Username         string
+	Frequency        string
+	Period           string
+	Total            int
+	Items            []digestItem
+	More             int
+	NotificationsURL string
+	SettingsURL      string
+}
+
+// render 按用户的语言与时区渲染摘要邮件的主题、纯文本与 HTML 正文
+func (s *notificationDigestService) render(user *tables.User, start, end time.Time, notifications []tables.Notification) (mailer.Message, error) {
+	lang := digestLanguage(user.PreferredLanguage)
+	loc := start.Location()
+
+	period := start.Format("2006-01-02")
+	if last := end.AddDate(0, 0, -1); !last.Equal(start) {
+		period += " ~ " + last.Format("2006-01-02")
+	}
+	view := digestView{
+		Username:         user.Username,
+		Frequency:        string(user.DigestFrequency),
+		Period:           period,
+		Total:            len(notifications),
+		NotificationsURL: s.settings.FrontendURL + "/notifications",
+		SettingsURL:      s.settings.FrontendURL + "/settings/notifications",
+	}
+	for i, n := range notifications {
+		if i == digestMaxItems {
+			view.More = len(notifications) - digestMaxItems
+			break
+		}
+		view.Items = append(view.Items, digestItem{
+			Title:       n.Title,
+			Description: n.Description,
+			Time:        n.CreatedAt.In(loc).Format("01-02 15:04"),
+		})
+	}
+
+	var subject, text, html bytes.Buffer
+	textTemplate := digestTextTemplates[lang]
+	if err := textTemplate.ExecuteTemplate(&subject, "subject", view); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := textTemplate.ExecuteTemplate(&text, "body", view); err != nil {
+		return mailer.Message{}, err
+	}
+	if err := digestHTMLTemplates[lang].ExecuteTemplate(&html, "html", view); err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      user.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Body:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/pubsub"
+	"context"
+	"encoding/json"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+	"time"
+)
+
+// realtimeChannel 所有服务实例共用的实时事件频道
+const realtimeChannel = "geekreward:realtime"
+
+// subscriberBuffer 每个连接的事件缓冲，客户端消费过慢时丢弃新事件
+const subscriberBuffer = 32
+
+// NotificationHub 将实时事件经由消息代理分发到所有服务实例上该用户的连接
+type NotificationHub interface {
+	// Publish 向指定用户推送事件，推送失败只记录日志，不影响业务操作
+	Publish(userIDs []uuid.UUID, eventType string, data any)
+	// Subscribe 在当前实例上为用户注册一个连接，返回事件通道与取消订阅函数
+	Subscribe(userID uuid.UUID) (<-chan dtos.RealtimeEvent, func())
+	// Run 订阅消息代理并分发事件，阻塞到 ctx 结束
+	Run(ctx context.Context) error
+}
+
+// realtimeEnvelope 在实例之间传递的消息
+type realtimeEnvelope struct {
+	UserIDs []uuid.UUID        `json:"user_ids"`
+	Event   dtos.RealtimeEvent `json:"event"`
+}
+
+type notificationHub struct {
+	broker      pubsub.Broker
+	mu          sync.RWMutex
+	subscribers map[uuid.UUID]map[chan dtos.RealtimeEvent]struct{}
+}
+
+func NewNotificationHub(broker pubsub.Broker) NotificationHub {
+	return &notificationHub{
+		broker:      broker,
+		subscribers: make(map[uuid.UUID]map[chan dtos.RealtimeEvent]struct{}),
+	}
+}
+
+func (h *notificationHub) Publish(userIDs []uuid.UUID, eventType string, data any) {
+	if len(userIDs) == 0 {
+		return
+	}
+	raw, err := json.Marshal(data)
+	if err != nil {
+		log.Println("序列化实时事件失败:", err)
+		return
+	}
+	payload, err := json.Marshal(realtimeEnvelope{
+		UserIDs: userIDs,
+		Event:   dtos.RealtimeEvent{Type: eventType, Data: raw, CreatedAt: time.Now()},
+	})
+	if err != nil {
+		log.Println("序列化实时事件失败:", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := h.broker.Publish(ctx, realtimeChannel, payload); err != nil {
+		log.Println("发布实时事件失败:", err)
+	}
+}
+
+func (h *notificationHub) Subscribe(userID uuid.UUID) (<-chan dtos.RealtimeEvent, func()) {
+	ch := make(chan dtos.RealtimeEvent, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = make(map[chan dtos.RealtimeEvent]struct{})
+	}
+	h.subscribers[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[userID], ch)
+			if len(h.subscribers[userID]) == 0 {
+				delete(h.subscribers, userID)
+			}
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+func (h *notificationHub) Run(ctx context.Context) error {
+	for {
+		err := h.broker.Subscribe(ctx, realtimeChannel, h.dispatch)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		// 消息代理暂时不可用时稍后重试
+		log.Println("订阅实时事件频道失败，稍后重试:", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+// dispatch 将消息投递给当前实例上对应用户的连接
+func (h *notificationHub) dispatch(payload []byte) {
+	var envelope realtimeEnvelope
+	if err := json.Unmarshal(payload, &envelope); err != nil {
+		log.Println("解析实时事件失败:", err)
+		return
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, userID := range envelope.UserIDs {
+		for ch := range h.subscribers[userID] {
+			select {
+			case ch <- envelope.Event:
+			default:
+				// 客户端消费过慢，丢弃事件，客户端可通过列表接口补齐
+			}
+		}
+	}
+}
+
+// publishNotification 推送新通知以及接收者最新的未读数
+func publishNotification(hub NotificationHub, notificationRepo repositories.NotificationRepository, notification *tables.Notification) {
+	hub.Publish([]uuid.UUID{notification.UserID}, dtos.RealtimeNotificationCreated, notification)
+	publishUnreadCount(hub, notificationRepo, notification.UserID)
+}
+
+// publishUnreadCount 推送用户最新的未读通知数
+func publishUnreadCount(hub NotificationHub, notificationRepo repositories.NotificationRepository, userID uuid.UUID) {
+	count, err := notificationRepo.CountUnread(userID)
+	if err != nil {
+		log.Println("统计未读通知失败:", err)
+		return
+	}
+	hub.Publish([]uuid.UUID{userID}, dtos.RealtimeUnreadCount, map[string]int64{"count": count})
+}
+
+// publishBountyStatusChange 在状态变更提交后通知悬赏令的发布者与接收者
+func publishBountyStatusChange(hub NotificationHub, bounty *tables.Bounty, change *tables.BountyStatusChange) {
+	recipients := []uuid.UUID{bounty.UserID}
+	if bounty.ReceiverID != nil {
+		recipients = append(recipients, *bounty.ReceiverID)
+	}
+	hub.Publish(recipients, dtos.RealtimeBountyStatusChanged, map[string]any{
+		"bounty_id":   bounty.ID,
+		"title":       bounty.Title,
+		"from_status": change.FromStatus,
+		"to_status":   change.ToStatus,
+		"actor_id":    change.ActorID,
+		"actor_role":  change.ActorRole,
+		"reason":      change.Reason,
+	})
+}
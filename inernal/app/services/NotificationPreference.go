@@ -0,0 +1,135 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+var (
+	ErrUnknownNotificationType         = errors.New("未知的通知类型")
+	ErrUnknownNotificationChannel      = errors.New("未知的通知渠道")
+	ErrNotificationTypeNotConfigurable = errors.New("该类通知不允许修改")
+	ErrInvalidDigestFrequency          = errors.New("无效的摘要邮件频率，可选值为 off, daily, weekly")
+)
+
+// enabledChannels 计算用户对某类通知开启的渠道
+// 优先级：不可配置的类型始终使用默认值；总开关关闭时其余类型全部关闭；否则用户设置覆盖默认值
+func (s *notificationService) enabledChannels(userID uuid.UUID, notificationType tables.NotificationType) (*tables.User, map[tables.NotificationChannel]bool, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info, ok := tables.LookupNotificationType(notificationType)
+	if !ok {
+		// 目录之外的类型（如管理员手动创建的通知）只投递站内信
+		return user, map[tables.NotificationChannel]bool{tables.NotificationChannelInApp: true}, nil
+	}
+
+	channels := make(map[tables.NotificationChannel]bool, len(tables.NotificationChannels))
+	for channel, enabled := range info.Defaults {
+		channels[channel] = enabled
+	}
+	if !info.Configurable {
+		return user, channels, nil
+	}
+	if !user.NotificationsEnabled {
+		return user, map[tables.NotificationChannel]bool{}, nil
+	}
+
+	preferences, err := s.preferenceRepo.FindByUserAndType(userID, notificationType)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, p := range preferences {
+		channels[p.Channel] = p.Enabled
+	}
+	return user, channels, nil
+}
+
+func (s *notificationService) GetPreferences(userID uuid.UUID) (*dtos.NotificationPreferences, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	preferences, err := s.preferenceRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	overrides := make(map[tables.NotificationType]map[tables.NotificationChannel]bool)
+	for _, p := range preferences {
+		if overrides[p.Type] == nil {
+			overrides[p.Type] = make(map[tables.NotificationChannel]bool)
+		}
+		overrides[p.Type][p.Channel] = p.Enabled
+	}
+
+	result := &dtos.NotificationPreferences{
+		Enabled:         user.NotificationsEnabled,
+		DigestFrequency: user.DigestFrequency,
+	}
+	for _, info := range tables.NotificationCatalogue {
+		item := dtos.NotificationPreferenceItem{
+			Type:         info.Type,
+			Description:  info.Description,
+			Configurable: info.Configurable,
+			Channels:     make(map[tables.NotificationChannel]bool, len(tables.NotificationChannels)),
+		}
+		for _, channel := range tables.NotificationChannels {
+			enabled := info.Defaults[channel]
+			if override, ok := overrides[info.Type][channel]; ok && info.Configurable {
+				enabled = override
+			}
+			item.Channels[channel] = enabled
+		}
+		result.Types = append(result.Types, item)
+	}
+	return result, nil
+}
+
+func (s *notificationService) UpdatePreferences(userID uuid.UUID, input dtos.UpdateNotificationPreferencesInput) (*dtos.NotificationPreferences, error) {
+	// 先校验全部输入，避免部分写入
+	if input.DigestFrequency != nil && !input.DigestFrequency.Valid() {
+		return nil, ErrInvalidDigestFrequency
+	}
+	var preferences []tables.NotificationPreference
+	for notificationType, channels := range input.Types {
+		info, ok := tables.LookupNotificationType(notificationType)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownNotificationType, notificationType)
+		}
+		if !info.Configurable {
+			return nil, fmt.Errorf("%w: %s", ErrNotificationTypeNotConfigurable, notificationType)
+		}
+		for channel, enabled := range channels {
+			if !tables.ValidNotificationChannel(channel) {
+				return nil, fmt.Errorf("%w: %s", ErrUnknownNotificationChannel, channel)
+			}
+			preferences = append(preferences, tables.NotificationPreference{
+				UserID:  userID,
+				Type:    notificationType,
+				Channel: channel,
+				Enabled: enabled,
+			})
+		}
+	}
+
+	if input.Enabled != nil {
+		if err := s.userRepo.UpdateNotificationsEnabled(userID, *input.Enabled); err != nil {
+			return nil, err
+		}
+	}
+	if input.DigestFrequency != nil {
+		if err := s.userRepo.UpdateDigestFrequency(userID, *input.DigestFrequency); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.preferenceRepo.Upsert(preferences); err != nil {
+		return nil, err
+	}
+	return s.GetPreferences(userID)
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/pkg/mailer"
+	"github.com/google/uuid"
+)
+
+// NotificationSender 站内信之外的通知渠道，按用户的偏好设置调用
+type NotificationSender interface {
+	Send(user *tables.User, notification *tables.Notification) error
+}
+
+// emailNotificationSender 通过邮件投递通知
+type emailNotificationSender struct {
+	mailer      mailer.Mailer
+	frontendURL string
+}
+
+func NewEmailNotificationSender(m mailer.Mailer, frontendURL string) NotificationSender {
+	return &emailNotificationSender{mailer: m, frontendURL: frontendURL}
+}
+
+func (s *emailNotificationSender) Send(user *tables.User, notification *tables.Notification) error {
+	body := "你好 " + user.Username + "，\n\n" +
+		notification.Description + "\n\n" +
+		"登录 GeekReward 查看详情：" + s.frontendURL + "/notifications\n\n" +
+		"如不想再收到此类邮件，可在通知设置中关闭。\n"
+	return s.mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "[GeekReward] " + notification.Title,
+		Body:    body,
+	})
+}
+
+// webhookNotificationSender 将通知作为事件投递给用户订阅了该类型的 Webhook
+type webhookNotificationSender struct {
+	webhookService WebhookService
+}
+
+func NewWebhookNotificationSender(webhookService WebhookService) NotificationSender {
+	return &webhookNotificationSender{webhookService: webhookService}
+}
+
+func (s *webhookNotificationSender) Send(user *tables.User, notification *tables.Notification) error {
+	data := dtos.WebhookNotificationData{
+		UserID:      user.ID,
+		ActorID:     notification.ActorID,
+		Title:       notification.Title,
+		Description: notification.Description,
+		RelatedID:   notification.RelatedID,
+		RelatedType: notification.RelatedType,
+		Metadata:    notification.Metadata,
+	}
+	eventID := uuid.New()
+	if notification.ID != uuid.Nil {
+		data.NotificationID = &notification.ID
+		eventID = notification.ID
+	}
+	return s.webhookService.Enqueue(user.ID, eventID, string(notification.Type), data)
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	outboxClaimBatch = 50
+	outboxClaimLease = time.Minute // 应大于处理一批事件的最长耗时
+)
+
+// EventConsumer 领域事件的消费者
+// 事件至少投递一次：消费者返回错误时该事件稍后重试，已成功的消费者不会再次收到，
+// 但同一消费者在部分成功后重试时可能重复处理，实现应尽量幂等
+type EventConsumer interface {
+	// Name 消费者的唯一名称，用于记录哪些消费者已处理过事件，上线后不应修改
+	Name() string
+	Handle(event *tables.OutboxEvent, payload dtos.DomainEvent) error
+}
+
+// OutboxSettings 发件箱分发设置
+type OutboxSettings struct {
+	PollInterval time.Duration // 检查新事件的间隔
+	MaxAttempts  int           // 超过后不再重试，事件保留在表中供排查
+	BaseBackoff  time.Duration // 第 n 次失败后等待 BaseBackoff * 2^(n-1) 再重试
+	MaxBackoff   time.Duration
+	Retention    time.Duration // 已处理事件的保留时长
+}
+
+// EventDispatcher 从发件箱中领取事件并依次交给各个消费者
+type EventDispatcher interface {
+	// Run 持续分发事件，直到 ctx 结束
+	Run(ctx context.Context) error
+}
+
+type eventDispatcher struct {
+	outboxRepo repositories.OutboxRepository
+	consumers  []EventConsumer
+	settings   OutboxSettings
+}
+
+func NewEventDispatcher(outboxRepo repositories.OutboxRepository, settings OutboxSettings, consumers ...EventConsumer) EventDispatcher {
+	if settings.PollInterval <= 0 {
+		settings.PollInterval = time.Second
+	}
+	if settings.MaxAttempts <= 0 {
+		settings.MaxAttempts = 10
+	}
+	if settings.BaseBackoff <= 0 {
+		settings.BaseBackoff = 5 * time.Second
+	}
+	if settings.MaxBackoff <= 0 {
+		settings.MaxBackoff = time.Hour
+	}
+	if settings.Retention <= 0 {
+		settings.Retention = 7 * 24 * time.Hour
+	}
+	return &eventDispatcher{
+		outboxRepo: outboxRepo,
+		consumers:  consumers,
+		settings:   settings,
+	}
+}
+
+// newOutboxEvent 序列化事件内容，生成待写入发件箱的记录
+func newOutboxEvent(eventType, aggregateType string, aggregateID uuid.UUID, payload dtos.DomainEvent) (*tables.OutboxEvent, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &tables.OutboxEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		Payload:       string(data),
+		NextAttemptAt: &now,
+	}, nil
+}
+
+// decodeDomainEvent 按事件类型反序列化事件内容
+func decodeDomainEvent(event *tables.OutboxEvent) (dtos.DomainEvent, error) {
+	var payload dtos.DomainEvent
+	switch event.Type {
+	case tables.EventBountyCreated:
+		payload = &dtos.BountyCreatedEvent{}
+	case tables.EventBountyStatusChanged:
+		payload = &dtos.BountyStatusChangedEvent{}
+	case tables.EventBountySettled:
+		payload = &dtos.BountySettledEvent{}
+	case tables.EventBountyForceCancelled:
+		payload = &dtos.BountyForceCancelledEvent{}
+	case tables.EventApplicationApproved, tables.EventApplicationRejected:
+		payload = &dtos.ApplicationReviewedEvent{}
+	case tables.EventInvitationSent, tables.EventInvitationAccepted, tables.EventInvitationRejected,
+		tables.EventInvitationCancelled, tables.EventInvitationExpired:
+		payload = &dtos.InvitationEvent{}
+	case tables.EventMilestoneSubmitted, tables.EventSubmissionAccepted, tables.EventSubmissionRejected:
+		payload = &dtos.MilestoneSubmissionEvent{}
+	case tables.EventMilestoneRewardReleased:
+		payload = &dtos.MilestoneRewardReleasedEvent{}
+	case tables.EventSplitPlanUpdated:
+		payload = &dtos.SplitPlanUpdatedEvent{}
+	default:
+		return nil, fmt.Errorf("未知的领域事件类型 %q", event.Type)
+	}
+	if err := json.Unmarshal([]byte(event.Payload), payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (d *eventDispatcher) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.settings.PollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+	for {
+		d.dispatchDue()
+
+		if time.Since(lastCleanup) > time.Hour {
+			if _, err := d.outboxRepo.DeleteProcessedBefore(time.Now().Add(-d.settings.Retention)); err != nil {
+				log.Println("清理已处理的领域事件失败:", err)
+			}
+			lastCleanup = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// dispatchDue 分批领取到期的事件并按创建顺序处理，直到没有到期事件
+func (d *eventDispatcher) dispatchDue() {
+	for {
+		events, err := d.outboxRepo.ClaimDue(time.Now(), outboxClaimBatch, outboxClaimLease)
+		if err != nil {
+			log.Println("领取领域事件失败:", err)
+			return
+		}
+		if len(events) == 0 {
+			return
+		}
+		for i := range events {
+			d.dispatch(&events[i])
+		}
+	}
+}
+
+// dispatch 将事件交给尚未处理过它的消费者，并写回处理结果
+func (d *eventDispatcher) dispatch(event *tables.OutboxEvent) {
+	event.Attempts++
+
+	var failures []string
+	payload, err := decodeDomainEvent(event)
+	if err != nil {
+		failures = append(failures, err.Error())
+	} else {
+		for _, consumer := range d.consumers {
+			if event.Completed(consumer.Name()) {
+				continue
+			}
+			if err := consumer.Handle(event, payload); err != nil {
+				failures = append(failures, consumer.Name()+": "+err.Error())
+				continue
+			}
+			event.CompletedConsumers = append(event.CompletedConsumers, consumer.Name())
+		}
+	}
+
+	now := time.Now()
+	switch {
+	case len(failures) == 0:
+		event.ProcessedAt = &now
+		event.NextAttemptAt = nil
+		event.LastError = ""
+	case event.Attempts >= d.settings.MaxAttempts:
+		event.NextAttemptAt = nil
+		event.LastError = strings.Join(failures, "; ")
+		log.Printf("领域事件 %s（%s）重试 %d 次后仍失败: %s", event.ID, event.Type, event.Attempts, event.LastError)
+	default:
+		next := now.Add(d.backoff(event.Attempts))
+		event.NextAttemptAt = &next
+		event.LastError = strings.Join(failures, "; ")
+	}
+
+	if err := d.outboxRepo.Update(event); err != nil {
+		// 租约到期后事件会被重新领取，已成功的消费者可能重复处理
+		log.Printf("记录领域事件 %s 的处理结果失败: %v", event.ID, err)
+	}
+}
+
+// backoff 第 n 次失败后的等待时间：BaseBackoff * 2^(n-1)，不超过 MaxBackoff
+func (d *eventDispatcher) backoff(attempts int) time.Duration {
+	wait := d.settings.BaseBackoff
+	for i := 1; i < attempts && wait < d.settings.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.settings.MaxBackoff {
+		wait = d.settings.MaxBackoff
+	}
+	return wait
+}
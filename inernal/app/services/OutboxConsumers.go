@@ -0,0 +1,243 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"fmt"
+)
+
+// notificationEventConsumer 将领域事件转换为站内通知，并由通知服务按用户偏好分发到其他渠道
+type notificationEventConsumer struct {
+	notificationService NotificationService
+}
+
+func NewNotificationEventConsumer(notificationService NotificationService) EventConsumer {
+	return &notificationEventConsumer{notificationService: notificationService}
+}
+
+func (c *notificationEventConsumer) Name() string {
+	return "notifications"
+}
+
+func (c *notificationEventConsumer) Handle(event *tables.OutboxEvent, payload dtos.DomainEvent) error {
+	// 通知与事件关联，事件重试时已通知过的接收者会被跳过
+	notifications := c.notificationService.ForEvent(event.ID)
+	switch e := payload.(type) {
+	case *dtos.BountyCreatedEvent:
+		return notifications.CreateNotification(&tables.Notification{
+			UserID:      e.PublisherID,
+			Type:        tables.NotificationTypeBountyCreated,
+			Title:       "悬赏令已创建",
+			Description: fmt.Sprintf("您已成功创建悬赏令 '%s'。", e.Title),
+			Metadata: map[string]interface{}{
+				"bounty_id":    e.BountyID.String(),
+				"bounty_title": e.Title,
+			},
+			RelatedID:   &e.BountyID,
+			RelatedType: "Bounty",
+		})
+
+	case *dtos.BountySettledEvent:
+		for _, payout := range e.Payouts {
+			err := notifications.CreateNotification(&tables.Notification{
+				UserID:      payout.UserID,
+				ActorID:     &e.PublisherID,
+				Type:        tables.NotificationTypeBountySettled,
+				Title:       "Bounty Settle",
+				Description: "Your application for bounty '" + e.Title + "' has been settled. Reward: $" + fmt.Sprintf("%.2f", payout.Amount),
+				RelatedID:   &e.BountyID,
+				RelatedType: "Bounty",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *dtos.BountyForceCancelledEvent:
+		for _, userID := range e.Participants() {
+			err := notifications.CreateNotification(&tables.Notification{
+				UserID:      userID,
+				ActorID:     &e.ActorID,
+				Type:        tables.NotificationTypeBountyCancelled,
+				Title:       "悬赏令已被管理员取消",
+				Description: "悬赏令【" + e.Title + "】已被管理员取消，原因：" + e.Reason,
+				RelatedID:   &e.BountyID,
+				RelatedType: "Bounty",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *dtos.ApplicationReviewedEvent:
+		if event.Type == tables.EventApplicationApproved {
+			return notifications.CreateApplicationApprovedNotification(e.PublisherID, e.ApplicantID, e.BountyID, e.Title)
+		}
+		return notifications.CreateApplicationRejectedNotification(e.PublisherID, e.ApplicantID, e.BountyID, e.Title)
+
+	case *dtos.InvitationEvent:
+		return notifications.CreateNotification(invitationNotification(event.Type, e))
+
+	case *dtos.MilestoneSubmissionEvent:
+		return notifications.CreateNotification(submissionNotification(event.Type, e))
+
+	case *dtos.MilestoneRewardReleasedEvent:
+		for _, payout := range e.Payouts {
+			err := notifications.CreateNotification(&tables.Notification{
+				UserID:      payout.UserID,
+				ActorID:     &e.PublisherID,
+				Type:        tables.NotificationTypeBountySettled,
+				Title:       "阶段赏金到账",
+				Description: fmt.Sprintf("悬赏令【%s】的里程碑【%s】已通过验收，阶段赏金 %.2f 已发放到您的钱包。", e.BountyTitle, e.MilestoneTitle, payout.Amount),
+				Metadata: map[string]interface{}{
+					"bounty_id":    e.BountyID.String(),
+					"milestone_id": e.MilestoneID.String(),
+				},
+				RelatedID:   &e.MilestoneID,
+				RelatedType: "Milestone",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case *dtos.SplitPlanUpdatedEvent:
+		for _, share := range e.Shares {
+			if share.UserID == e.LeaderID {
+				continue
+			}
+			err := notifications.CreateNotification(&tables.Notification{
+				UserID:      share.UserID,
+				ActorID:     &e.LeaderID,
+				Type:        tables.NotificationTypeSplitPlanUpdated,
+				Title:       "赏金分配方式已变更",
+				Description: fmt.Sprintf("队长修改了悬赏令【%s】的赏金分配方式，您的份额为 %.2f%%（按当前托管余额约 %.2f）。", e.Title, share.Percentage, share.Amount),
+				Metadata: map[string]interface{}{
+					"bounty_id": e.BountyID.String(),
+					"rule":      string(e.Rule),
+				},
+				RelatedID:   &e.BountyID,
+				RelatedType: "Bounty",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// 其余事件（如普通的状态变更）已通过实时推送告知，不生成通知
+	return nil
+}
+
+// webhookEventConsumer 将领域事件投递给相关用户订阅了该事件的 Webhook，
+// 投递记录的事件 ID 即发件箱事件的 ID，接收方可据此去重
+type webhookEventConsumer struct {
+	webhookService WebhookService
+}
+
+func NewWebhookEventConsumer(webhookService WebhookService) EventConsumer {
+	return &webhookEventConsumer{webhookService: webhookService}
+}
+
+func (c *webhookEventConsumer) Name() string {
+	return "webhooks"
+}
+
+func (c *webhookEventConsumer) Handle(event *tables.OutboxEvent, payload dtos.DomainEvent) error {
+	for _, userID := range payload.Participants() {
+		if err := c.webhookService.Enqueue(userID, event.ID, event.Type, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// invitationNotification 邀请发出时通知受邀者，之后的每次状态变化通知另一方：
+// 接受、拒绝通知邀请者，撤回通知受邀者，过期通知邀请者
+func invitationNotification(eventType string, e *dtos.InvitationEvent) *tables.Notification {
+	notification := &tables.Notification{
+		Metadata: map[string]interface{}{
+			"invitation_id": e.InvitationID.String(),
+		},
+		RelatedID:   &e.InvitationID,
+		RelatedType: "Invitation",
+	}
+	if e.TeamID != nil {
+		notification.Metadata["team_id"] = e.TeamID.String()
+	}
+	if e.BountyID != nil {
+		notification.Metadata["bounty_id"] = e.BountyID.String()
+	}
+
+	switch eventType {
+	case tables.EventInvitationSent:
+		notification.UserID, notification.ActorID = e.InviteeID, &e.InviterID
+		notification.Type = tables.NotificationTypeInvitationReceived
+		notification.Title = "收到组队邀请"
+		notification.Description = e.InviterName + " 邀请您加入团队"
+		if e.Message != "" {
+			notification.Description += "：" + e.Message
+		}
+	case tables.EventInvitationAccepted:
+		notification.UserID, notification.ActorID = e.InviterID, &e.InviteeID
+		notification.Type = tables.NotificationTypeInvitationAccepted
+		notification.Title = "组队邀请被接受"
+		notification.Description = e.InviteeName + " 接受了您的组队邀请"
+	case tables.EventInvitationRejected:
+		notification.UserID, notification.ActorID = e.InviterID, &e.InviteeID
+		notification.Type = tables.NotificationTypeInvitationRejected
+		notification.Title = "组队邀请被拒绝"
+		notification.Description = e.InviteeName + " 拒绝了您的组队邀请"
+	case tables.EventInvitationCancelled:
+		notification.UserID, notification.ActorID = e.InviteeID, &e.InviterID
+		notification.Type = tables.NotificationTypeInvitationCancelled
+		notification.Title = "组队邀请已撤回"
+		notification.Description = e.InviterName + " 撤回了发给您的组队邀请"
+	default:
+		notification.UserID = e.InviterID
+		notification.Type = tables.NotificationTypeInvitationExpired
+		notification.Title = "组队邀请已过期"
+		notification.Description = "您发给 " + e.InviteeName + " 的组队邀请已过期，对方未作回应"
+	}
+	return notification
+}
+
+// submissionNotification 接收者提交成果时通知发布者验收，发布者验收或驳回时通知接收者
+func submissionNotification(eventType string, e *dtos.MilestoneSubmissionEvent) *tables.Notification {
+	notification := &tables.Notification{
+		Metadata: map[string]interface{}{
+			"bounty_id":     e.BountyID.String(),
+			"milestone_id":  e.MilestoneID.String(),
+			"submission_id": e.SubmissionID.String(),
+			"round":         e.Round,
+		},
+		RelatedID:   &e.MilestoneID,
+		RelatedType: "Milestone",
+	}
+
+	switch eventType {
+	case tables.EventMilestoneSubmitted:
+		notification.UserID, notification.ActorID = e.PublisherID, &e.SubmitterID
+		notification.Type = tables.NotificationTypeMilestoneSubmitted
+		notification.Title = "里程碑成果待验收"
+		notification.Description = fmt.Sprintf("悬赏令【%s】的里程碑【%s】提交了第 %d 轮成果，请验收。", e.BountyTitle, e.MilestoneTitle, e.Round)
+	case tables.EventSubmissionAccepted:
+		notification.UserID, notification.ActorID = e.SubmitterID, &e.PublisherID
+		notification.Type = tables.NotificationTypeMilestoneConfirmed
+		notification.Title = "里程碑成果通过验收"
+		notification.Description = fmt.Sprintf("悬赏令【%s】的里程碑【%s】已通过验收。", e.BountyTitle, e.MilestoneTitle)
+	default:
+		notification.UserID, notification.ActorID = e.SubmitterID, &e.PublisherID
+		notification.Type = tables.NotificationTypeSubmissionRejected
+		notification.Title = "里程碑成果被驳回"
+		notification.Description = fmt.Sprintf("悬赏令【%s】的里程碑【%s】第 %d 轮成果被驳回：%s", e.BountyTitle, e.MilestoneTitle, e.Round, e.Feedback)
+	}
+	if e.Feedback != "" {
+		notification.Metadata["feedback"] = e.Feedback
+	}
+	return notification
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"testing"
+)
+
+// stubNotificationRepository 按 (接收者, 事件) 去重保存通知，模拟唯一索引
+type stubNotificationRepository struct {
+	repositories.NotificationRepository
+	created map[[2]uuid.UUID]tables.Notification
+	deleted []uuid.UUID
+}
+
+func (r *stubNotificationRepository) CreateEventNotification(notification *tables.Notification) (bool, error) {
+	key := [2]uuid.UUID{notification.UserID, *notification.EventID}
+	if _, ok := r.created[key]; ok {
+		return false, nil
+	}
+	notification.ID = uuid.New()
+	r.created[key] = *notification
+	return true, nil
+}
+
+func (r *stubNotificationRepository) FindUserNotification(userID, notificationID uuid.UUID) (*tables.Notification, error) {
+	for _, notification := range r.created {
+		if notification.ID == notificationID && notification.UserID == userID {
+			return &notification, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (r *stubNotificationRepository) DeleteNotification(userID, notificationID uuid.UUID) error {
+	if _, err := r.FindUserNotification(userID, notificationID); err != nil {
+		return nil
+	}
+	r.deleted = append(r.deleted, notificationID)
+	return nil
+}
+
+func (r *stubNotificationRepository) CountUnread(uuid.UUID) (int64, error) {
+	return int64(len(r.created)), nil
+}
+
+type stubUserRepository struct {
+	repositories.UserRepository
+}
+
+func (r *stubUserRepository) FindByUserID(id uuid.UUID) (*tables.User, error) {
+	user := &tables.User{NotificationsEnabled: true}
+	user.ID = id
+	return user, nil
+}
+
+type stubPreferenceRepository struct {
+	repositories.NotificationPreferenceRepository
+	disabled map[uuid.UUID]bool
+}
+
+func (r *stubPreferenceRepository) FindByUserAndType(userID uuid.UUID, _ tables.NotificationType) ([]tables.NotificationPreference, error) {
+	if !r.disabled[userID] {
+		return nil, nil
+	}
+	return []tables.NotificationPreference{{UserID: userID, Channel: tables.NotificationChannelInApp, Enabled: false}}, nil
+}
+
+// stubNotificationHub 记录推送给每个用户的新通知事件数
+type stubNotificationHub struct {
+	published map[uuid.UUID]int
+}
+
+func (h *stubNotificationHub) Publish(userIDs []uuid.UUID, eventType string, _ any) {
+	if eventType != dtos.RealtimeNotificationCreated {
+		return
+	}
+	for _, userID := range userIDs {
+		h.published[userID]++
+	}
+}
+
+func (h *stubNotificationHub) Subscribe(uuid.UUID) (<-chan dtos.RealtimeEvent, func()) {
+	return nil, func() {}
+}
+
+func (h *stubNotificationHub) Run(context.Context) error {
+	return nil
+}
+
+// TestNotificationConsumerRetryIsIdempotent 事件重试时已通知过的接收者不会收到重复通知
+func TestNotificationConsumerRetryIsIdempotent(t *testing.T) {
+	publisher, receiver, member := uuid.New(), uuid.New(), uuid.New()
+	notificationRepo := &stubNotificationRepository{created: map[[2]uuid.UUID]tables.Notification{}}
+	hub := &stubNotificationHub{published: map[uuid.UUID]int{}}
+	preferenceRepo := &stubPreferenceRepository{disabled: map[uuid.UUID]bool{member: true}}
+	consumer := NewNotificationEventConsumer(NewNotificationService(notificationRepo, preferenceRepo, &stubUserRepository{}, hub, nil))
+
+	event := &tables.OutboxEvent{Type: tables.EventBountySettled}
+	event.ID = uuid.New()
+	payload := &dtos.BountySettledEvent{
+		BountyID:    uuid.New(),
+		PublisherID: publisher,
+		Title:       "bounty",
+		Payouts: []dtos.BountyPayout{
+			{UserID: receiver, Amount: 60},
+			{UserID: member, Amount: 40},
+		},
+	}
+
+	for attempt := 0; attempt < 3; attempt++ {
+		if err := consumer.Handle(event, payload); err != nil {
+			t.Fatalf("attempt %d: Handle = %v", attempt, err)
+		}
+	}
+
+	if len(notificationRepo.created) != 2 {
+		t.Fatalf("created %d notifications, want 2", len(notificationRepo.created))
+	}
+	for key, notification := range notificationRepo.created {
+		if key[1] != event.ID || notification.EventID == nil || *notification.EventID != event.ID {
+			t.Fatalf("notification %+v not linked to event %s", notification, event.ID)
+		}
+	}
+	if hub.published[receiver] != 1 {
+		t.Errorf("receiver got %d pushes, want 1", hub.published[receiver])
+	}
+	// 关闭站内渠道的接收者只保留去重记录，不推送
+	if hub.published[member] != 0 {
+		t.Errorf("member got %d pushes, want 0", hub.published[member])
+	}
+	memberNotification := notificationRepo.created[[2]uuid.UUID{member, event.ID}]
+	if len(notificationRepo.deleted) != 1 || notificationRepo.deleted[0] != memberNotification.ID {
+		t.Errorf("deleted %v, want only the member's notification", notificationRepo.deleted)
+	}
+
+	// 其他事件仍会通知同一接收者
+	next := &tables.OutboxEvent{Type: tables.EventBountySettled}
+	next.ID = uuid.New()
+	if err := consumer.Handle(next, payload); err != nil {
+		t.Fatal(err)
+	}
+	if hub.published[receiver] != 2 {
+		t.Errorf("receiver got %d pushes after a new event, want 2", hub.published[receiver])
+	}
+}
+
+// TestDeleteNotificationChecksOwner 用户不能删除其他用户的通知
+func TestDeleteNotificationChecksOwner(t *testing.T) {
+	owner, stranger := uuid.New(), uuid.New()
+	eventID := uuid.New()
+	notification := tables.Notification{UserID: owner, EventID: &eventID}
+	notification.ID = uuid.New()
+	notificationRepo := &stubNotificationRepository{created: map[[2]uuid.UUID]tables.Notification{{owner, eventID}: notification}}
+	service := NewNotificationService(notificationRepo, nil, nil, &stubNotificationHub{published: map[uuid.UUID]int{}}, nil)
+
+	if err := service.DeleteNotification(stranger, notification.ID); !errors.Is(err, ErrNotificationNotFound) {
+		t.Fatalf("DeleteNotification(stranger) = %v, want ErrNotificationNotFound", err)
+	}
+	if len(notificationRepo.deleted) != 0 {
+		t.Fatalf("stranger deleted %v", notificationRepo.deleted)
+	}
+	if err := service.DeleteNotification(owner, notification.ID); err != nil {
+		t.Fatalf("DeleteNotification(owner) = %v", err)
+	}
+	if len(notificationRepo.deleted) != 1 || notificationRepo.deleted[0] != notification.ID {
+		t.Fatalf("deleted %v, want %s", notificationRepo.deleted, notification.ID)
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"GeekReward/inernal/app/authz"
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/utils"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"math"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidSplit             = errors.New("无效的赏金分配方式")
+	ErrSplitLocked              = errors.New("只能在悬赏令开工前（已指派状态）修改赏金分配方式")
+	ErrNotSettlementParticipant = errors.New("只有发布者和接收者可以查看结算明细")
+)
+
+// settlementParticipants 返回参与赏金分配的用户
+func (s *bountyService) settlementParticipants(bounty *tables.Bounty) ([]uuid.UUID, error) {
+	return bountyParticipants(s.teamRepo, s.applicationRepo, bounty)
+}
+
+// bountyParticipants 返回悬赏令的接收者：团队接下的悬赏令为团队全体成员，否则为所有通过申请的用户
+func bountyParticipants(
+	teamRepo repositories.TeamRepository,
+	applicationRepo repositories.ApplicationRepository,
+	bounty *tables.Bounty,
+) ([]uuid.UUID, error) {
+	var participants []uuid.UUID
+	if bounty.ReceiverTeamID != nil {
+		members, err := teamRepo.FindMembers(*bounty.ReceiverTeamID)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			participants = append(participants, member.UserID)
+		}
+		return participants, nil
+	}
+
+	apps, err := applicationRepo.GetApprovedApplicationsByBountyID(bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if !slices.Contains(participants, app.UserID) {
+			participants = append(participants, app.UserID)
+		}
+	}
+	return participants, nil
+}
+
+// computeSplit 按分配方式计算每个接收者应得的金额，金额向下取整到分，分不尽的零头计入最后一项
+func computeSplit(
+	bountyID uuid.UUID,
+	rule tables.SplitRule,
+	total float64,
+	participants []uuid.UUID,
+	shares []tables.BountySplitShare,
+	milestones []tables.Milestone,
+) ([]tables.SettlementLineItem, error) {
+	if len(participants) == 0 {
+		return nil, errors.New("no participants to settle")
+	}
+
+	var items []tables.SettlementLineItem
+	switch rule {
+	case tables.SplitRuleEqual, "":
+		percentage := 100 / float64(len(participants))
+		for _, userID := range participants {
+			items = append(items, tables.SettlementLineItem{UserID: userID, Percentage: percentage})
+		}
+		rule = tables.SplitRuleEqual
+
+	case tables.SplitRulePercentage:
+		var sum float64
+		for _, share := range shares {
+			if !slices.Contains(participants, share.UserID) {
+				return nil, fmt.Errorf("%w: 用户 %s 不是该悬赏令的接收者", ErrInvalidSplit, share.UserID)
+			}
+			sum += share.Percentage
+			items = append(items, tables.SettlementLineItem{UserID: share.UserID, Percentage: share.Percentage})
+		}
+		if math.Abs(sum-100) > 0.01 {
+			return nil, fmt.Errorf("%w: 分配比例之和为 %.2f%%，必须为 100%%", ErrInvalidSplit, sum)
+		}
+
+	case tables.SplitRuleMilestone:
+		if len(milestones) == 0 {
+			return nil, fmt.Errorf("%w: 悬赏令没有里程碑，无法按里程碑分配", ErrInvalidSplit)
+		}
+		// 每个里程碑占相同份额，同一负责人的里程碑合并为一项
+		titles := make(map[uuid.UUID][]string)
+		for _, milestone := range milestones {
+			if milestone.OwnerID == nil {
+				return nil, fmt.Errorf("%w: 里程碑【%s】没有负责人", ErrInvalidSplit, milestone.Title)
+			}
+			owner := *milestone.OwnerID
+			if !slices.Contains(participants, owner) {
+				return nil, fmt.Errorf("%w: 里程碑【%s】的负责人不是该悬赏令的接收者", ErrInvalidSplit, milestone.Title)
+			}
+			if _, ok := titles[owner]; !ok {
+				items = append(items, tables.SettlementLineItem{UserID: owner})
+			}
+			titles[owner] = append(titles[owner], milestone.Title)
+		}
+		for i := range items {
+			owned := titles[items[i].UserID]
+			items[i].Percentage = 100 * float64(len(owned)) / float64(len(milestones))
+			items[i].Memo = "负责里程碑：" + strings.Join(owned, "、")
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: 未知的分配方式 %s", ErrInvalidSplit, rule)
+	}
+
+	var allocated float64
+	for i := range items {
+		items[i].BountyID = bountyID
+		items[i].Rule = rule
+		items[i].Percentage = math.Round(items[i].Percentage*100) / 100
+		if i == len(items)-1 {
+			items[i].Amount = utils.RoundCents(total - allocated)
+			break
+		}
+		items[i].Amount = math.Floor(total*items[i].Percentage) / 100
+		allocated += items[i].Amount
+	}
+	return items, nil
+}
+
+// splitLineItems 按悬赏令当前的分配方式计算结算明细，阶段赏金已全部发放、没有剩余赏金时返回空明细
+func (s *bountyService) splitLineItems(bounty *tables.Bounty, total float64) ([]tables.SettlementLineItem, error) {
+	if total < 0.005 {
+		return nil, nil
+	}
+	participants, err := s.settlementParticipants(bounty)
+	if err != nil {
+		return nil, err
+	}
+	shares, err := s.settlementRepo.FindShares(bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	milestones, err := s.milestoneRepo.FindByBountyID(bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	// 已发放阶段赏金的里程碑不再参与剩余赏金的分配
+	unpaid := slices.DeleteFunc(slices.Clone(milestones), func(m tables.Milestone) bool { return m.RewardReleasedAt != nil })
+	if len(unpaid) > 0 {
+		milestones = unpaid
+	}
+	return computeSplit(bounty.ID, bounty.SplitRule, total, participants, shares, milestones)
+}
+
+// GetSettlement 获取结算明细，结算前返回按当前分配方式计算的预览，供所有接收者在确认结算前查看
+func (s *bountyService) GetSettlement(bountyID, userID uuid.UUID) (*dtos.SettlementView, error) {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	if bounty == nil {
+		return nil, errors.New("bounty not found")
+	}
+
+	participants, err := s.settlementParticipants(bounty)
+	if err != nil {
+		return nil, err
+	}
+	if bounty.UserID != userID && !slices.Contains(participants, userID) {
+		return nil, ErrNotSettlementParticipant
+	}
+
+	view := &dtos.SettlementView{BountyID: bounty.ID, Rule: bounty.SplitRule}
+	items, err := s.settlementRepo.FindLineItems(bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	if bounty.Status == tables.BountyStatusSettled {
+		view.Settled = true
+		view.Items = items
+		for _, item := range view.Items {
+			view.Total += item.Amount
+		}
+		view.Total = utils.RoundCents(view.Total)
+		return view, nil
+	}
+
+	// 结算前的明细只有随里程碑验收发放的阶段赏金
+	view.ReleasedItems = items
+	for _, item := range items {
+		view.Released += item.Amount
+	}
+	view.Released = utils.RoundCents(view.Released)
+
+	view.Total, err = escrowBalance(s.ledgerRepo, bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	view.Items, err = s.splitLineItems(bounty, view.Total)
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+// UpdateSplitPlan 接收者在开工前设置赏金的分配方式，保存前校验比例之和为 100% 或每个里程碑都有负责人；
+// 团队接下的悬赏令只有队长能修改，修改后通知其他成员各自的份额
+func (s *bountyService) UpdateSplitPlan(bountyID, userID uuid.UUID, input dtos.UpdateSplitPlanInput) (*dtos.SettlementView, error) {
+	bounty, err := s.bountyRepo.FindBountyByID(bountyID)
+	if err != nil {
+		return nil, err
+	}
+	if bounty == nil {
+		return nil, errors.New("bounty not found")
+	}
+	if err := authz.Can(userID, authz.ActionSettlementSplit, bounty); err != nil {
+		return nil, err
+	}
+	// 接收者是批准申请时的队长，队长变更后只能由现任队长修改
+	if bounty.ReceiverTeamID != nil {
+		team, err := s.teamRepo.FindByID(*bounty.ReceiverTeamID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTeamNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		if team.LeaderID != userID {
+			return nil, ErrNotTeamLeader
+		}
+	}
+	if bounty.Status != tables.BountyStatusAssigned {
+		return nil, ErrSplitLocked
+	}
+	if !input.Rule.Valid() {
+		return nil, fmt.Errorf("%w: 未知的分配方式 %s", ErrInvalidSplit, input.Rule)
+	}
+
+	participants, err := s.settlementParticipants(bounty)
+	if err != nil {
+		return nil, err
+	}
+
+	var shares []tables.BountySplitShare
+	if input.Rule == tables.SplitRulePercentage {
+		for _, share := range input.Shares {
+			if slices.ContainsFunc(shares, func(s tables.BountySplitShare) bool { return s.UserID == share.UserID }) {
+				return nil, fmt.Errorf("%w: 用户 %s 的比例重复", ErrInvalidSplit, share.UserID)
+			}
+			shares = append(shares, tables.BountySplitShare{
+				BountyID:   bounty.ID,
+				UserID:     share.UserID,
+				Percentage: share.Percentage,
+			})
+		}
+	}
+
+	milestones, err := s.milestoneRepo.FindByBountyID(bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	var changed []tables.Milestone
+	if input.Rule == tables.SplitRuleMilestone {
+		for _, owner := range input.MilestoneOwners {
+			i := slices.IndexFunc(milestones, func(m tables.Milestone) bool { return m.ID == owner.MilestoneID })
+			if i < 0 {
+				return nil, fmt.Errorf("%w: 里程碑 %s 不属于该悬赏令", ErrInvalidSplit, owner.MilestoneID)
+			}
+			ownerID := owner.UserID
+			milestones[i].OwnerID = &ownerID
+			changed = append(changed, milestones[i])
+		}
+	}
+
+	total, err := escrowBalance(s.ledgerRepo, bounty.ID)
+	if err != nil {
+		return nil, err
+	}
+	items, err := computeSplit(bounty.ID, input.Rule, total, participants, shares, milestones)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.txManager.Transaction(func(tx *gorm.DB) error {
+		bounty.SplitRule = input.Rule
+		if err := s.bountyRepo.WithTx(tx).UpdateBounty(bounty); err != nil {
+			return err
+		}
+		if err := s.settlementRepo.WithTx(tx).ReplaceShares(bounty.ID, shares); err != nil {
+			return err
+		}
+		milestoneRepo := s.milestoneRepo.WithTx(tx)
+		for i := range changed {
+			if err := milestoneRepo.UpdateMilestone(&changed[i]); err != nil {
+				return err
+			}
+		}
+
+		payload := &dtos.SplitPlanUpdatedEvent{BountyID: bounty.ID, Title: bounty.Title, LeaderID: userID, Rule: input.Rule}
+		for _, item := range items {
+			payload.Shares = append(payload.Shares, dtos.SplitPlanShare{UserID: item.UserID, Percentage: item.Percentage, Amount: item.Amount})
+		}
+		event, err := newOutboxEvent(tables.EventSplitPlanUpdated, "Bounty", bounty.ID, payload)
+		if err != nil {
+			return err
+		}
+		return s.outboxRepo.WithTx(tx).Create(event)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dtos.SettlementView{BountyID: bounty.ID, Rule: bounty.SplitRule, Total: total, Items: items}, nil
+}
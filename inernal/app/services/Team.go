@@ -0,0 +1,194 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"errors"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	ErrTeamNotFound          = errors.New("团队不存在")
+	ErrNotTeamLeader         = errors.New("只有队长才能执行该操作")
+	ErrNotTeamMember         = errors.New("该用户不是团队成员")
+	ErrAlreadyTeamMember     = errors.New("该用户已是团队成员")
+	ErrTeamLeaderCannotLeave = errors.New("队长不能退出团队，请先解散团队")
+	ErrCannotKickSelf        = errors.New("不能将自己移出团队")
+	ErrTeamHasActiveBounty   = errors.New("团队有进行中的悬赏令，结算或取消前成员不能变动")
+)
+
+// TeamService 管理团队及其成员，成员通过接受组队邀请加入
+type TeamService interface {
+	CreateTeam(userID uuid.UUID, input dtos.CreateTeamInput) (*tables.Team, error)
+	// GetTeams 返回用户所在的所有团队
+	GetTeams(userID uuid.UUID) ([]tables.Team, error)
+	GetTeam(teamID uuid.UUID) (*tables.Team, error)
+	UpdateTeam(teamID, userID uuid.UUID, input dtos.UpdateTeamInput) (*tables.Team, error)
+	// DeleteTeam 队长解散团队
+	DeleteTeam(teamID, userID uuid.UUID) error
+	GetMembers(teamID uuid.UUID) ([]tables.TeamMember, error)
+	// LeaveTeam 成员主动退出团队
+	LeaveTeam(teamID, userID uuid.UUID) error
+	// KickMember 队长将成员移出团队
+	KickMember(teamID, leaderID, memberID uuid.UUID) error
+}
+
+type teamService struct {
+	teamRepo   repositories.TeamRepository
+	bountyRepo repositories.BountyRepository
+	txManager  repositories.TransactionManager
+}
+
+func NewTeamService(
+	teamRepo repositories.TeamRepository,
+	bountyRepo repositories.BountyRepository,
+	txManager repositories.TransactionManager,
+) TeamService {
+	return &teamService{
+		teamRepo:   teamRepo,
+		bountyRepo: bountyRepo,
+		txManager:  txManager,
+	}
+}
+
+// createTeamWithLeader 在事务中创建团队并将队长加入成员列表
+func createTeamWithLeader(teamRepo repositories.TeamRepository, team *tables.Team) error {
+	if err := teamRepo.Create(team); err != nil {
+		return err
+	}
+	return teamRepo.AddMember(&tables.TeamMember{
+		TeamID:   team.ID,
+		UserID:   team.LeaderID,
+		Role:     tables.TeamRoleLeader,
+		JoinedAt: time.Now(),
+	})
+}
+
+func (s *teamService) CreateTeam(userID uuid.UUID, input dtos.CreateTeamInput) (*tables.Team, error) {
+	team := &tables.Team{
+		Name:        input.Name,
+		Description: input.Description,
+		LeaderID:    userID,
+	}
+	err := s.txManager.Transaction(func(tx *gorm.DB) error {
+		return createTeamWithLeader(s.teamRepo.WithTx(tx), team)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.teamRepo.FindByID(team.ID)
+}
+
+func (s *teamService) GetTeams(userID uuid.UUID) ([]tables.Team, error) {
+	return s.teamRepo.FindByUserID(userID)
+}
+
+func (s *teamService) GetTeam(teamID uuid.UUID) (*tables.Team, error) {
+	team, err := s.teamRepo.FindByID(teamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTeamNotFound
+	}
+	return team, err
+}
+
+// findLedTeam 获取团队并校验 userID 为队长
+func (s *teamService) findLedTeam(teamID, userID uuid.UUID) (*tables.Team, error) {
+	team, err := s.GetTeam(teamID)
+	if err != nil {
+		return nil, err
+	}
+	if team.LeaderID != userID {
+		return nil, ErrNotTeamLeader
+	}
+	return team, nil
+}
+
+func (s *teamService) UpdateTeam(teamID, userID uuid.UUID, input dtos.UpdateTeamInput) (*tables.Team, error) {
+	team, err := s.findLedTeam(teamID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name != nil {
+		team.Name = *input.Name
+	}
+	if input.Description != nil {
+		team.Description = *input.Description
+	}
+	if err := s.teamRepo.Update(team); err != nil {
+		return nil, err
+	}
+	return team, nil
+}
+
+func (s *teamService) DeleteTeam(teamID, userID uuid.UUID) error {
+	if _, err := s.findLedTeam(teamID, userID); err != nil {
+		return err
+	}
+	if err := checkNoActiveBounty(s.bountyRepo, teamID); err != nil {
+		return err
+	}
+	return s.teamRepo.Delete(teamID)
+}
+
+// checkNoActiveBounty 团队接下的悬赏令结算前，成员即是赏金的分配对象，不允许变动
+func checkNoActiveBounty(bountyRepo repositories.BountyRepository, teamID uuid.UUID) error {
+	count, err := bountyRepo.CountActiveByReceiverTeam(teamID)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTeamHasActiveBounty
+	}
+	return nil
+}
+
+func (s *teamService) GetMembers(teamID uuid.UUID) ([]tables.TeamMember, error) {
+	if _, err := s.GetTeam(teamID); err != nil {
+		return nil, err
+	}
+	return s.teamRepo.FindMembers(teamID)
+}
+
+func (s *teamService) LeaveTeam(teamID, userID uuid.UUID) error {
+	team, err := s.GetTeam(teamID)
+	if err != nil {
+		return err
+	}
+	if team.LeaderID == userID {
+		return ErrTeamLeaderCannotLeave
+	}
+	member, err := s.teamRepo.FindMember(teamID, userID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrNotTeamMember
+	}
+	if err := checkNoActiveBounty(s.bountyRepo, teamID); err != nil {
+		return err
+	}
+	return s.teamRepo.RemoveMember(teamID, userID)
+}
+
+func (s *teamService) KickMember(teamID, leaderID, memberID uuid.UUID) error {
+	if _, err := s.findLedTeam(teamID, leaderID); err != nil {
+		return err
+	}
+	if memberID == leaderID {
+		return ErrCannotKickSelf
+	}
+	member, err := s.teamRepo.FindMember(teamID, memberID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return ErrNotTeamMember
+	}
+	if err := checkNoActiveBounty(s.bountyRepo, teamID); err != nil {
+		return err
+	}
+	return s.teamRepo.RemoveMember(teamID, memberID)
+}
@@ -0,0 +1,252 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/utils"
+	"crypto/rand"
+	"errors"
+	"github.com/google/uuid"
+	"strings"
+	"time"
+)
+
+var (
+	ErrTwoFactorAlreadyEnabled = errors.New("两步验证已启用，如需更换验证器请先关闭")
+	ErrTwoFactorNotEnabled     = errors.New("两步验证未启用")
+	ErrTwoFactorNotSetup       = errors.New("请先生成两步验证密钥")
+	ErrInvalidTwoFactorCode    = errors.New("验证码无效或已使用")
+	ErrIncorrectPassword       = errors.New("密码不正确")
+)
+
+const (
+	recoveryCodeCount  = 10
+	recoveryCodeLength = 10 // 不含分隔符，展示为 XXXXX-XXXXX
+	recoveryCodeChars  = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// TwoFactorService 定义 TOTP 两步验证的绑定、校验与恢复码管理
+type TwoFactorService interface {
+	// GetStatus 获取两步验证的启用状态与剩余恢复码数量
+	GetStatus(userID uuid.UUID) (*dtos.TwoFactorStatus, error)
+	// Setup 生成新的 TOTP 密钥，此时尚未启用，需调用 Enable 确认
+	Setup(userID uuid.UUID) (*dtos.TwoFactorSetup, error)
+	// Enable 使用验证器生成的验证码确认绑定，启用两步验证并返回恢复码
+	Enable(userID uuid.UUID, code string) ([]string, error)
+	// Disable 校验密码与验证码（或恢复码）后关闭两步验证
+	Disable(userID uuid.UUID, password, code string) error
+	// RegenerateRecoveryCodes 校验验证码后重新生成恢复码，原有的恢复码全部作废
+	RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error)
+	// Verify 校验 TOTP 验证码或恢复码，供两步登录使用
+	Verify(user *tables.User, code string) error
+}
+
+type twoFactorService struct {
+	userRepo         repositories.UserRepository
+	recoveryCodeRepo repositories.RecoveryCodeRepository
+	issuer           string
+}
+
+func NewTwoFactorService(
+	userRepo repositories.UserRepository,
+	recoveryCodeRepo repositories.RecoveryCodeRepository,
+	issuer string,
+) TwoFactorService {
+	return &twoFactorService{
+		userRepo:         userRepo,
+		recoveryCodeRepo: recoveryCodeRepo,
+		issuer:           issuer,
+	}
+}
+
+func (s *twoFactorService) GetStatus(userID uuid.UUID) (*dtos.TwoFactorStatus, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &dtos.TwoFactorStatus{Enabled: user.TwoFactorEnabled}
+	if user.TwoFactorEnabled {
+		status.RecoveryCodesRemaining, err = s.recoveryCodeRepo.CountUnused(userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+func (s *twoFactorService) Setup(userID uuid.UUID) (*dtos.TwoFactorSetup, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.UpdateTwoFactor(userID, secret, false, 0); err != nil {
+		return nil, err
+	}
+
+	return &dtos.TwoFactorSetup{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPAuthURI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *twoFactorService) Enable(userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, ErrTwoFactorNotSetup
+	}
+
+	counter, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	// 并发的 Setup 更换了密钥或另一请求已启用时不会重复启用，同一验证码也不能再次使用
+	enabled, err := s.userRepo.EnableTwoFactor(userID, user.TwoFactorSecret, counter)
+	if err != nil {
+		return nil, err
+	}
+	if !enabled {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	return s.issueRecoveryCodes(userID)
+}
+
+func (s *twoFactorService) Disable(userID uuid.UUID, password, code string) error {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled {
+		return ErrTwoFactorNotEnabled
+	}
+	if !utils.CheckPasswordHash(password, user.Password) {
+		return ErrIncorrectPassword
+	}
+	if err := s.Verify(user, code); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.UpdateTwoFactor(userID, "", false, 0); err != nil {
+		return err
+	}
+	return s.recoveryCodeRepo.DeleteByUserID(userID)
+}
+
+func (s *twoFactorService) RegenerateRecoveryCodes(userID uuid.UUID, code string) ([]string, error) {
+	user, err := s.userRepo.FindByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.verifyTOTP(user, code); err != nil {
+		return nil, err
+	}
+	return s.issueRecoveryCodes(userID)
+}
+
+func (s *twoFactorService) Verify(user *tables.User, code string) error {
+	if !user.TwoFactorEnabled || user.TwoFactorSecret == "" {
+		return ErrTwoFactorNotEnabled
+	}
+
+	// 纯数字按 TOTP 验证码处理，其余按恢复码处理
+	code = strings.TrimSpace(code)
+	if isDigits(code) {
+		return s.verifyTOTP(user, code)
+	}
+
+	used, err := s.recoveryCodeRepo.Consume(user.ID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// verifyTOTP 校验 TOTP 验证码，同一时间步内的验证码只能使用一次
+func (s *twoFactorService) verifyTOTP(user *tables.User, code string) error {
+	counter, ok := utils.ValidateTOTP(user.TwoFactorSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	advanced, err := s.userRepo.AdvanceTOTPCounter(user.ID, counter)
+	if err != nil {
+		return err
+	}
+	if !advanced {
+		return ErrInvalidTwoFactorCode
+	}
+	user.TwoFactorCounter = counter
+	return nil
+}
+
+// issueRecoveryCodes 生成新的一组恢复码，只保存摘要，原文仅返回这一次
+func (s *twoFactorService) issueRecoveryCodes(userID uuid.UUID) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normalizeRecoveryCode(code)))
+	}
+	if err := s.recoveryCodeRepo.ReplaceForUser(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode 生成形如 XXXXX-XXXXX 的恢复码，去掉了易混淆的 0/O、1/I
+func generateRecoveryCode() (string, error) {
+	buf := make([]byte, recoveryCodeLength)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	code := make([]byte, recoveryCodeLength)
+	for i, b := range buf {
+		code[i] = recoveryCodeChars[int(b)%len(recoveryCodeChars)]
+	}
+	half := recoveryCodeLength / 2
+	return string(code[:half]) + "-" + string(code[half:]), nil
+}
+
+// normalizeRecoveryCode 忽略大小写、空格与分隔符
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToUpper(code)
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
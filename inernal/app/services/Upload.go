@@ -0,0 +1,164 @@
+package services
+
+import (
+	"GeekReward/pkg/imaging"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrFileTooLarge         = errors.New("文件超过大小限制")
+	ErrFileTypeNotAllowed   = errors.New("不支持的文件类型")
+	ErrStorageQuotaExceeded = errors.New("存储空间不足，请删除不需要的附件后重试")
+	ErrInvalidImage         = errors.New("无法识别的图片文件")
+)
+
+// sniffLength http.DetectContentType 最多读取的字节数
+const sniffLength = 512
+
+// UploadSettings 上传文件的校验规则，文件类型以内容嗅探结果为准，不信任文件名与客户端声明的类型
+type UploadSettings struct {
+	MaxSize         int64    // 单个附件的最大字节数
+	AvatarMaxSize   int64    // 头像的最大字节数
+	AvatarMaxPixels int64    // 头像的最大像素数（宽×高），超过时不解码
+	UserQuota       int64    // 每个用户附件的总字节数上限，0 表示不限制
+	AllowedTypes    []string // 允许上传的附件类型，如 image/png、application/pdf；以 / 结尾表示该大类下的全部类型，如 image/
+	AvatarSizes     []int    // 头像缩略图的边长
+}
+
+// withDefaults 补全未配置的项
+func (s UploadSettings) withDefaults() UploadSettings {
+	if s.MaxSize <= 0 {
+		s.MaxSize = 20 << 20
+	}
+	if s.AvatarMaxSize <= 0 {
+		s.AvatarMaxSize = 5 << 20
+	}
+	if s.AvatarMaxPixels <= 0 {
+		s.AvatarMaxPixels = 16_000_000
+	}
+	if len(s.AllowedTypes) == 0 {
+		s.AllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf", "application/zip", "text/plain"}
+	}
+	if len(s.AvatarSizes) == 0 {
+		s.AvatarSizes = []int{64, 128, 256}
+	}
+	return s
+}
+
+// allows 判断嗅探出的类型是否在允许列表中
+func (s UploadSettings) allows(mediaType string) bool {
+	return slices.ContainsFunc(s.AllowedTypes, func(allowed string) bool {
+		if strings.HasSuffix(allowed, "/") {
+			return strings.HasPrefix(mediaType, allowed)
+		}
+		return mediaType == allowed
+	})
+}
+
+// sniffContentType 读取内容开头的字节判断文件类型，返回不含参数的类型（如 text/plain）
+// 与可以从头读取完整内容的 Reader
+func sniffContentType(r io.Reader) (string, io.Reader, error) {
+	head := make([]byte, sniffLength)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return "", nil, err
+	}
+	head = head[:n]
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	return mediaType, io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// readLimited 读取全部内容，超过 limit 字节时返回 ErrFileTooLarge
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, ErrFileTooLarge
+	}
+	return data, nil
+}
+
+// limitedReader 读取超过 limit 字节时返回 ErrFileTooLarge，用于流式写入存储时限制大小
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	if l.n > l.limit {
+		return n, ErrFileTooLarge
+	}
+	return n, err
+}
+
+// strippableImage 判断是否为需要删除元数据的图片类型
+func strippableImage(mediaType string) bool {
+	switch mediaType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	}
+	return false
+}
+
+// stripImageMetadata 删除图片中的 EXIF 等元数据，内容无法解析时返回 ErrInvalidImage
+func stripImageMetadata(mediaType string, data []byte) ([]byte, error) {
+	stripped, err := imaging.StripMetadata(mediaType, data)
+	if errors.Is(err, imaging.ErrInvalidImage) {
+		return nil, ErrInvalidImage
+	}
+	return stripped, err
+}
+
+// preferredExtensions 常见类型使用的扩展名，mime.ExtensionsByType 对部分类型返回的顺序不固定
+var preferredExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"application/zip": ".zip",
+	"text/plain":      ".txt",
+}
+
+// extensionFor 返回对象键使用的扩展名，由嗅探出的类型决定，避免以 .html 等扩展名保存任意内容
+func extensionFor(mediaType string) string {
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mediaType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return ".bin"
+}
+
+// keyFilename 生成对象键时使用的文件名，扩展名替换为嗅探出的类型对应的扩展名
+func keyFilename(filename, mediaType string) string {
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename)) + extensionFor(mediaType)
+}
+
+// formatBytes 将字节数格式化为便于阅读的形式，用于错误提示
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
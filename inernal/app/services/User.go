@@ -73,7 +73,6 @@ func (s *userService) UpdateUser(id uuid.UUID, input dtos.UpdateUserProfile) (*t
 	user.EmailPreferences = input.EmailPreferences
 	user.ContactPreferences = input.ContactPreferences
 	user.SecurityQuestions = input.SecurityQuestions
-	user.TwoFactorEnabled = input.TwoFactorEnabled
 	user.Timezone = input.Timezone
 	user.PreferredLanguage = input.PreferredLanguage
 	user.SolvedCount = input.SolvedCount
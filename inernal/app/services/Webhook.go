@@ -0,0 +1,438 @@
+package services
+
+import (
+	"GeekReward/inernal/app/models/dtos"
+	"GeekReward/inernal/app/models/tables"
+	"GeekReward/inernal/app/repositories"
+	"GeekReward/pkg/netguard"
+	"GeekReward/pkg/utils"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound     = errors.New("Webhook 不存在")
+	ErrInvalidWebhookURL   = errors.New("Webhook 地址必须是 http 或 https 链接")
+	ErrWebhookURLForbidden = errors.New("Webhook 地址不能指向内网、回环或链路本地地址")
+	ErrTooManyWebhooks     = errors.New("Webhook 数量已达上限")
+	ErrUnknownWebhookEvent = errors.New("未知的 Webhook 事件")
+)
+
+const (
+	maxWebhooksPerUser = 10
+	webhookClaimBatch  = 20
+	webhookClaimLease  = time.Minute // 应大于一批投递的最长耗时（请求超时）
+)
+
+// WebhookSettings Webhook 投递设置
+type WebhookSettings struct {
+	Timeout      time.Duration // 单次请求超时
+	MaxAttempts  int           // 包括首次投递在内的最多尝试次数
+	BaseBackoff  time.Duration // 第 n 次失败后等待 BaseBackoff * 2^(n-1) 再重试
+	MaxBackoff   time.Duration
+	PollInterval time.Duration // 检查到期重试的间隔
+}
+
+// WebhookService 管理用户的 Webhook，并在后台投递事件、失败时按指数退避重试
+type WebhookService interface {
+	CreateWebhook(userID uuid.UUID, input dtos.CreateWebhookInput) (*dtos.WebhookCreated, error)
+	GetWebhooks(userID uuid.UUID) ([]tables.Webhook, error)
+	GetWebhook(userID, webhookID uuid.UUID) (*tables.Webhook, error)
+	UpdateWebhook(userID, webhookID uuid.UUID, input dtos.UpdateWebhookInput) (*tables.Webhook, error)
+	DeleteWebhook(userID, webhookID uuid.UUID) error
+	GetDeliveries(userID, webhookID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.WebhookDelivery], error)
+	// Ping 立即向 Webhook 发送一次测试事件并返回投递结果，失败时不重试
+	Ping(userID, webhookID uuid.UUID) (*tables.WebhookDelivery, error)
+	// Enqueue 为用户所有订阅了该事件的 Webhook 创建投递记录，由 Run 异步投递；
+	// eventID 作为请求体中的事件ID，同一事件重复入队时应保持不变，便于接收方去重
+	Enqueue(userID, eventID uuid.UUID, event string, data any) error
+	// Run 投递到期的记录，直到 ctx 结束
+	Run(ctx context.Context) error
+}
+
+type webhookService struct {
+	webhookRepo repositories.WebhookRepository
+	client      *http.Client
+	settings    WebhookSettings
+	wake        chan struct{}
+}
+
+func NewWebhookService(webhookRepo repositories.WebhookRepository, settings WebhookSettings) WebhookService {
+	if settings.Timeout <= 0 {
+		settings.Timeout = 10 * time.Second
+	}
+	if settings.MaxAttempts <= 0 {
+		settings.MaxAttempts = 8
+	}
+	if settings.BaseBackoff <= 0 {
+		settings.BaseBackoff = time.Minute
+	}
+	if settings.MaxBackoff <= 0 {
+		settings.MaxBackoff = 6 * time.Hour
+	}
+	if settings.PollInterval <= 0 {
+		settings.PollInterval = 5 * time.Second
+	}
+	return &webhookService{
+		webhookRepo: webhookRepo,
+		client: &http.Client{
+			Timeout: settings.Timeout,
+			// 连接时再次检查地址，防止保存后域名被重新解析到内网
+			Transport: netguard.Transport(),
+			// 不跟随重定向，3xx 视为投递失败
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		settings: settings,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// validateWebhookURL 只允许绝对的 http/https 地址，且主机解析到的地址都必须是公网地址
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return ErrInvalidWebhookURL
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := netguard.CheckHost(ctx, u.Hostname()); err != nil {
+		return fmt.Errorf("%w: %v", ErrWebhookURLForbidden, err)
+	}
+	return nil
+}
+
+// webhookEvents 校验订阅的事件，只能是通知目录中的类型或领域事件类型
+func webhookEvents(events []string) ([]string, error) {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		if _, ok := tables.LookupNotificationType(tables.NotificationType(event)); !ok && !slices.Contains(tables.DomainEventTypes, event) {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownWebhookEvent, event)
+		}
+		result = append(result, event)
+	}
+	return result, nil
+}
+
+func (s *webhookService) CreateWebhook(userID uuid.UUID, input dtos.CreateWebhookInput) (*dtos.WebhookCreated, error) {
+	if err := validateWebhookURL(input.URL); err != nil {
+		return nil, err
+	}
+	events, err := webhookEvents(input.Events)
+	if err != nil {
+		return nil, err
+	}
+	count, err := s.webhookRepo.CountByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= maxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	webhook := &tables.Webhook{
+		UserID:      userID,
+		URL:         input.URL,
+		Secret:      secret,
+		Events:      events,
+		Description: input.Description,
+		Active:      true,
+	}
+	if err := s.webhookRepo.Create(webhook); err != nil {
+		return nil, err
+	}
+	return &dtos.WebhookCreated{Webhook: *webhook, Secret: secret}, nil
+}
+
+func (s *webhookService) GetWebhooks(userID uuid.UUID) ([]tables.Webhook, error) {
+	return s.webhookRepo.FindByUserID(userID)
+}
+
+// GetWebhook 其他用户的 Webhook 同样返回 ErrWebhookNotFound，不暴露其是否存在
+func (s *webhookService) GetWebhook(userID, webhookID uuid.UUID) (*tables.Webhook, error) {
+	webhook, err := s.webhookRepo.FindByID(webhookID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if webhook.UserID != userID {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) UpdateWebhook(userID, webhookID uuid.UUID, input dtos.UpdateWebhookInput) (*tables.Webhook, error) {
+	webhook, err := s.GetWebhook(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.URL != nil {
+		if err := validateWebhookURL(*input.URL); err != nil {
+			return nil, err
+		}
+		webhook.URL = *input.URL
+	}
+	if input.Events != nil {
+		events, err := webhookEvents(*input.Events)
+		if err != nil {
+			return nil, err
+		}
+		webhook.Events = events
+	}
+	if input.Description != nil {
+		webhook.Description = *input.Description
+	}
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+
+	if err := s.webhookRepo.Update(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+func (s *webhookService) DeleteWebhook(userID, webhookID uuid.UUID) error {
+	if _, err := s.GetWebhook(userID, webhookID); err != nil {
+		return err
+	}
+	return s.webhookRepo.Delete(webhookID)
+}
+
+func (s *webhookService) GetDeliveries(userID, webhookID uuid.UUID, page dtos.PageQuery) (*dtos.Page[tables.WebhookDelivery], error) {
+	if _, err := s.GetWebhook(userID, webhookID); err != nil {
+		return nil, err
+	}
+	return s.webhookRepo.FindDeliveriesPage(webhookID, page)
+}
+
+func (s *webhookService) Ping(userID, webhookID uuid.UUID) (*tables.WebhookDelivery, error) {
+	webhook, err := s.GetWebhook(userID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(dtos.WebhookPayload{
+		ID:        uuid.New(),
+		Event:     tables.WebhookEventPing,
+		CreatedAt: time.Now(),
+		Data:      map[string]any{"webhook_id": webhook.ID},
+	})
+	if err != nil {
+		return nil, err
+	}
+	delivery := &tables.WebhookDelivery{
+		WebhookID: webhook.ID,
+		Event:     tables.WebhookEventPing,
+		Payload:   string(payload),
+		Status:    tables.WebhookDeliveryPending,
+	}
+	if err := s.webhookRepo.CreateDelivery(delivery); err != nil {
+		return nil, err
+	}
+
+	s.attempt(webhook, delivery, 1)
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+func (s *webhookService) Enqueue(userID, eventID uuid.UUID, event string, data any) error {
+	webhooks, err := s.webhookRepo.FindSubscribers(userID, event)
+	if err != nil || len(webhooks) == 0 {
+		return err
+	}
+
+	payload, err := json.Marshal(dtos.WebhookPayload{
+		ID:        eventID,
+		Event:     event,
+		CreatedAt: time.Now(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, webhook := range webhooks {
+		delivery := &tables.WebhookDelivery{
+			WebhookID:     webhook.ID,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        tables.WebhookDeliveryPending,
+			NextAttemptAt: &now,
+		}
+		if err := s.webhookRepo.CreateDelivery(delivery); err != nil {
+			return err
+		}
+	}
+
+	// 唤醒投递循环，不必等到下一次检查
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+func (s *webhookService) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.settings.PollInterval)
+	defer ticker.Stop()
+	for {
+		s.deliverDue()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// deliverDue 分批领取到期的投递记录并发投递，直到没有到期记录
+func (s *webhookService) deliverDue() {
+	for {
+		deliveries, err := s.webhookRepo.ClaimDueDeliveries(time.Now(), webhookClaimBatch, webhookClaimLease)
+		if err != nil {
+			log.Println("领取 Webhook 投递记录失败:", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(delivery *tables.WebhookDelivery) {
+				defer wg.Done()
+				s.deliver(delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+	}
+}
+
+// deliver 投递一条记录并写回结果，Webhook 已删除或停用时直接标记为失败
+func (s *webhookService) deliver(delivery *tables.WebhookDelivery) {
+	webhook, err := s.webhookRepo.FindByID(delivery.WebhookID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		s.abandon(delivery, "Webhook 已删除")
+	case err != nil:
+		// 租约到期后会被重新领取
+		log.Printf("读取 Webhook %s 失败: %v", delivery.WebhookID, err)
+		return
+	case !webhook.Active:
+		s.abandon(delivery, "Webhook 已停用")
+	default:
+		s.attempt(webhook, delivery, s.settings.MaxAttempts)
+	}
+
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		log.Printf("记录 Webhook 投递结果 %s 失败: %v", delivery.ID, err)
+	}
+}
+
+// abandon 放弃投递
+func (s *webhookService) abandon(delivery *tables.WebhookDelivery, reason string) {
+	delivery.Status = tables.WebhookDeliveryFailed
+	delivery.Error = reason
+	delivery.NextAttemptAt = nil
+}
+
+// attempt 发送一次请求并更新投递记录，失败且未达到 maxAttempts 时安排下一次重试
+func (s *webhookService) attempt(webhook *tables.Webhook, delivery *tables.WebhookDelivery, maxAttempts int) {
+	delivery.Attempts++
+	start := time.Now()
+	status, err := s.post(webhook, delivery)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	delivery.ResponseStatus = status
+
+	if err == nil && status >= 200 && status < 300 {
+		now := time.Now()
+		delivery.Status = tables.WebhookDeliverySucceeded
+		delivery.Error = ""
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+		return
+	}
+
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		delivery.Error = fmt.Sprintf("对方返回了 HTTP %d", status)
+	}
+	if delivery.Attempts >= maxAttempts {
+		delivery.Status = tables.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		return
+	}
+	next := time.Now().Add(s.backoff(delivery.Attempts))
+	delivery.Status = tables.WebhookDeliveryPending
+	delivery.NextAttemptAt = &next
+}
+
+// backoff 第 n 次失败后的等待时间：BaseBackoff * 2^(n-1)，不超过 MaxBackoff
+func (s *webhookService) backoff(attempts int) time.Duration {
+	wait := s.settings.BaseBackoff
+	for i := 1; i < attempts && wait < s.settings.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > s.settings.MaxBackoff {
+		wait = s.settings.MaxBackoff
+	}
+	return wait
+}
+
+// post 发送签名后的请求，只返回状态码，响应体不保存也不返回给用户
+func (s *webhookService) post(webhook *tables.Webhook, delivery *tables.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GeekReward-Webhook/1.0")
+	req.Header.Set("X-GeekReward-Event", delivery.Event)
+	req.Header.Set("X-GeekReward-Delivery", delivery.ID.String())
+	req.Header.Set("X-GeekReward-Timestamp", timestamp)
+	req.Header.Set("X-GeekReward-Signature", "sha256="+signWebhookPayload(webhook.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// signWebhookPayload 计算 hex(HMAC-SHA256(secret, timestamp + "." + payload))
+// 签名包含时间戳，接收方可以拒绝过旧的请求以防重放
+func signWebhookPayload(secret, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -10,13 +10,25 @@ func Migrate(db *gorm.DB) error {
 
 	db.Exec("CREATE EXTENSION IF NOT EXISTS \"uuid-ossp\";")
 
-	return db.AutoMigrate(
+	err := db.AutoMigrate(
 		// 基础用户数据库表
 		&tables.User{},
 
+		// 用户登录会话  保存刷新令牌摘要，用于令牌刷新与注销
+		&tables.Session{},
+
+		// 两步验证恢复码
+		&tables.RecoveryCode{},
+
+		// 邮箱验证与重置密码的一次性令牌
+		&tables.UserToken{},
+
 		// 基础悬赏令表  为用户对象所拥有或申请
 		&tables.Bounty{},
 
+		// 悬赏令状态变更记录  用于时间线展示
+		&tables.BountyStatusChange{},
+
 		// 关联于悬赏令  为悬赏令阶段性的分节
 		&tables.Milestone{},
 
@@ -24,6 +36,19 @@ func Migrate(db *gorm.DB) error {
 		&tables.Application{},
 		&tables.Notification{},
 
+		// 用户对各类通知在各渠道上的偏好设置
+		&tables.NotificationPreference{},
+
+		// 已发送的未读通知摘要邮件  防止同一周期重复发送
+		&tables.NotificationDigest{},
+
+		// 用户注册的 Webhook 及其投递日志
+		&tables.Webhook{},
+		&tables.WebhookDelivery{},
+
+		// 事务性发件箱  与状态变更在同一事务中写入的领域事件
+		&tables.OutboxEvent{},
+
 		// 用户与悬赏令的交互使用的模型
 		&tables.Comment{},
 		&tables.Like{},
@@ -32,5 +57,38 @@ func Migrate(db *gorm.DB) error {
 		// 极客与极客之间的社交活动模型
 		&tables.Affection{},
 		&tables.Invitation{},
+		&tables.Team{},
+		&tables.TeamMember{},
+
+		// 复式记账账本：账户、分录与分录行
+		&tables.LedgerAccount{},
+		&tables.JournalEntry{},
+		&tables.JournalLine{},
+
+		// 赏金分配：开工前约定的比例与结算明细
+		&tables.BountySplitShare{},
+		&tables.SettlementLineItem{},
+
+		// 附件记录与保密协议的接受记录
+		&tables.Attachment{},
+		&tables.BountyNDAAcceptance{},
+		&tables.MilestoneSubmission{},
 	)
+	if err != nil {
+		return err
+	}
+
+	// 悬赏令搜索使用的索引：标题与描述的全文检索，以及标签、技能的数组交集查询
+	indexes := []string{
+		"CREATE INDEX IF NOT EXISTS idx_bounties_search ON bounties USING GIN (" + tables.BountySearchVector + ")",
+		"CREATE INDEX IF NOT EXISTS idx_bounties_tags ON bounties USING GIN (tags)",
+		"CREATE INDEX IF NOT EXISTS idx_bounties_required_skills ON bounties USING GIN (required_skills)",
+	}
+	for _, index := range indexes {
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
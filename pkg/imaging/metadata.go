@@ -0,0 +1,240 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrInvalidImage 图片数据无法解析
+var ErrInvalidImage = errors.New("无效的图片数据")
+
+// StripMetadata 删除图片中的 EXIF、XMP、IPTC、注释等元数据，不重新编码像素数据，画质不受影响。
+// 支持 image/jpeg、image/png 与 image/webp，其余类型原样返回。
+// JPEG 的 EXIF 方向信息会以只包含 Orientation 的最小 EXIF 段保留，避免图片显示方向错误
+func StripMetadata(contentType string, data []byte) ([]byte, error) {
+	switch contentType {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+// JPEG 标记
+const (
+	jpegSOI  = 0xD8
+	jpegEOI  = 0xD9
+	jpegSOS  = 0xDA
+	jpegAPP0 = 0xE0
+	jpegAPP1 = 0xE1
+	jpegAPP2 = 0xE2 // ICC 色彩配置
+	jpegAPPE = 0xEE // Adobe 色彩变换
+	jpegCOM  = 0xFE
+)
+
+// stripJPEG 保留 APP0（JFIF）、APP2（ICC）、APP14（Adobe）与图像数据段，删除其余 APPn 段与注释
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return nil, ErrInvalidImage
+	}
+
+	var head, body bytes.Buffer
+	orientation := 1
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, ErrInvalidImage
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // 填充字节
+			i++
+			continue
+		}
+		if marker == jpegSOS || marker == jpegEOI {
+			break
+		}
+		// 段长度包含长度字段本身的 2 字节，小于 2 的段无效
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, ErrInvalidImage
+		}
+		segment := data[i:end]
+
+		switch {
+		case marker == jpegAPP0 && body.Len() == 0:
+			head.Write(segment)
+		case marker == jpegAPP1:
+			if o := exifOrientation(segment[4:]); o != 0 {
+				orientation = o
+			}
+		case marker == jpegAPP2, marker == jpegAPPE:
+			body.Write(segment)
+		case marker >= jpegAPP0 && marker <= 0xEF, marker == jpegCOM:
+			// 其余应用段与注释可能包含拍摄设备、位置、作者等信息
+		default:
+			body.Write(segment)
+		}
+		i = end
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:2])
+	out.Write(head.Bytes())
+	if orientation != 1 {
+		out.Write(orientationSegment(orientation))
+	}
+	out.Write(body.Bytes())
+	out.Write(data[i:])
+	return out.Bytes(), nil
+}
+
+// Orientation 返回 JPEG 中 EXIF 记录的方向（1-8），没有记录时返回 1
+func Orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != jpegSOI {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == jpegSOS || marker == jpegEOI {
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+		if marker == jpegAPP1 {
+			if o := exifOrientation(data[i+4 : end]); o != 0 {
+				return o
+			}
+		}
+		i = end
+	}
+	return 1
+}
+
+// exifOrientation 从 APP1 段的内容中读取 IFD0 的 Orientation 标签，不是 EXIF 或没有该标签时返回 0
+func exifOrientation(payload []byte) int {
+	tiff, ok := bytes.CutPrefix(payload, []byte("Exif\x00\x00"))
+	if !ok || len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	offset := int(order.Uint32(tiff[4:]))
+	if offset+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[offset:]))
+	for n := 0; n < count; n++ {
+		entry := offset + 2 + n*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			if o := int(order.Uint16(tiff[entry+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 0
+		}
+	}
+	return 0
+}
+
+// orientationSegment 生成只包含 Orientation 标签的 APP1 段
+func orientationSegment(orientation int) []byte {
+	segment := []byte{
+		0xFF, jpegAPP1, 0x00, 0x22, // 段长度 34
+		'E', 'x', 'i', 'f', 0x00, 0x00,
+		'M', 'M', 0x00, 0x2A, 0x00, 0x00, 0x00, 0x08, // 大端 TIFF 头，IFD0 偏移 8
+		0x00, 0x01, // 1 个标签
+		0x01, 0x12, 0x00, 0x03, 0x00, 0x00, 0x00, 0x01, 0x00, byte(orientation), 0x00, 0x00, // Orientation, SHORT, 1
+		0x00, 0x00, 0x00, 0x00, // 没有下一个 IFD
+	}
+	return segment
+}
+
+// pngMetadataChunks 可能包含拍摄信息或文字说明的 PNG 数据块
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		return nil, ErrInvalidImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.WriteString(signature)
+	for i := len(signature); ; {
+		if i+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		end := i + 12 + int(binary.BigEndian.Uint32(data[i:]))
+		if end > len(data) || end < i {
+			return nil, ErrInvalidImage
+		}
+		chunkType := string(data[i+4 : i+8])
+		if !pngMetadataChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+		i = end
+	}
+}
+
+// stripWebP 删除 EXIF 与 XMP 数据块，并清除 VP8X 中对应的标志位
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, ErrInvalidImage
+	}
+
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:12])
+	for i := 12; i < len(data); {
+		if i+8 > len(data) {
+			return nil, ErrInvalidImage
+		}
+		size := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + size + size%2 // 数据块按偶数字节对齐
+		if end > len(data) || end < i {
+			return nil, ErrInvalidImage
+		}
+		switch string(data[i : i+4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			chunk := bytes.Clone(data[i:end])
+			if len(chunk) > 8 {
+				chunk[8] &^= 0x08 | 0x04 // EXIF 与 XMP 标志
+			}
+			out.Write(chunk)
+		default:
+			out.Write(data[i:end])
+		}
+		i = end
+	}
+
+	stripped := out.Bytes()
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	return stripped, nil
+}
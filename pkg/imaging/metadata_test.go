@@ -0,0 +1,95 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// jpegSegment 拼出一个 JPEG 段，length 为写入长度字段的值
+func jpegSegment(marker byte, length uint16, payload ...byte) []byte {
+	return append([]byte{0xFF, marker, byte(length >> 8), byte(length)}, payload...)
+}
+
+func jpegOf(parts ...[]byte) []byte {
+	data := []byte{0xFF, jpegSOI}
+	for _, p := range parts {
+		data = append(data, p...)
+	}
+	return data
+}
+
+var jpegEnd = []byte{0xFF, jpegSOS, 0x00, 0x02, 0x01, 0x02, 0xFF, jpegEOI}
+
+func TestStripJPEGRejectsMalformedSegments(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"only SOI", []byte{0xFF, jpegSOI}},
+		{"truncated marker", jpegOf([]byte{0xFF, jpegAPP1, 0x00})},
+		{"APP1 length 0", jpegOf(jpegSegment(jpegAPP1, 0, 0x00, 0x00), jpegEnd)},
+		{"APP1 length 1", jpegOf(jpegSegment(jpegAPP1, 1, 0x00, 0x00), jpegEnd)},
+		{"APP0 length 0", jpegOf(jpegSegment(jpegAPP0, 0, 0x00, 0x00), jpegEnd)},
+		{"COM length 1", jpegOf(jpegSegment(jpegCOM, 1, 0x00, 0x00), jpegEnd)},
+		{"APP1 past end", jpegOf(jpegSegment(jpegAPP1, 64, 'E', 'x', 'i', 'f'))},
+		{"missing SOS", jpegOf(jpegSegment(jpegAPP0, 4, 'J', 'F'))},
+		{"garbage after segment", jpegOf(jpegSegment(jpegAPP0, 2), []byte{0x00, 0x00, 0x00, 0x00})},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := StripMetadata("image/jpeg", c.data); !errors.Is(err, ErrInvalidImage) {
+				t.Fatalf("StripMetadata = %v, want ErrInvalidImage", err)
+			}
+		})
+	}
+}
+
+func TestOrientationIgnoresMalformedSegments(t *testing.T) {
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"APP1 length 0", jpegOf(jpegSegment(jpegAPP1, 0, 0x00, 0x00), jpegEnd)},
+		{"APP1 length 1", jpegOf(jpegSegment(jpegAPP1, 1, 0x00, 0x00), jpegEnd)},
+		{"APP1 length 2", jpegOf(jpegSegment(jpegAPP1, 2), jpegEnd)},
+		{"APP1 past end", jpegOf(jpegSegment(jpegAPP1, 64, 'E', 'x', 'i', 'f'))},
+		{"truncated EXIF", jpegOf(jpegSegment(jpegAPP1, 10, 'E', 'x', 'i', 'f', 0, 0, 'M', 'M'), jpegEnd)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := Orientation(c.data); got != 1 {
+				t.Fatalf("Orientation = %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestStripJPEGKeepsOrientationOnly(t *testing.T) {
+	exif := orientationSegment(6)
+	data := jpegOf(
+		jpegSegment(jpegAPP0, 4, 'J', 'F'),
+		exif,
+		jpegSegment(jpegCOM, 6, 'g', 'p', 's', '!'),
+		jpegSegment(jpegAPP2, 4, 'I', 'C'),
+		jpegEnd,
+	)
+
+	stripped, err := StripMetadata("image/jpeg", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := jpegOf(
+		jpegSegment(jpegAPP0, 4, 'J', 'F'),
+		exif,
+		jpegSegment(jpegAPP2, 4, 'I', 'C'),
+		jpegEnd,
+	)
+	if !bytes.Equal(stripped, want) {
+		t.Fatalf("StripMetadata = % x\nwant % x", stripped, want)
+	}
+	if got := Orientation(stripped); got != 6 {
+		t.Fatalf("Orientation = %d, want 6", got)
+	}
+}
@@ -0,0 +1,115 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif" // 注册解码器
+	_ "image/jpeg"
+	"image/png"
+)
+
+// ErrImageTooLarge 图片的像素数超过上限
+var ErrImageTooLarge = errors.New("图片像素尺寸过大")
+
+// Decode 解码 JPEG、PNG 或 GIF 图片，返回图片与 JPEG 的 EXIF 方向。
+// 解码前先读取图片头中声明的尺寸，宽×高超过 maxPixels 时返回 ErrImageTooLarge，
+// 避免高压缩比的小文件声明巨大尺寸后耗尽内存
+func Decode(data []byte, maxPixels int64) (image.Image, int, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || config.Width <= 0 || config.Height <= 0 {
+		return nil, 0, ErrInvalidImage
+	}
+	if int64(config.Width)*int64(config.Height) > maxPixels {
+		return nil, 0, ErrImageTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, ErrInvalidImage
+	}
+	return img, Orientation(data), nil
+}
+
+// Thumbnail 从图片中心裁出正方形并缩放到 size×size，orientation 为 EXIF 方向（1-8），
+// 缩小时对源像素取区域平均，避免锯齿
+func Thumbnail(img image.Image, orientation, size int) *image.RGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	x0 := bounds.Min.X + (bounds.Dx()-side)/2
+	y0 := bounds.Min.Y + (bounds.Dy()-side)/2
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), img, image.Pt(x0, y0), draw.Src)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		sy0, sy1 := span(y, size, side)
+		for x := 0; x < size; x++ {
+			sx0, sx1 := span(x, size, side)
+			var r, g, b, a, n int
+			for sy := sy0; sy < sy1; sy++ {
+				row := square.Pix[sy*square.Stride:]
+				for sx := sx0; sx < sx1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+			d := scaled.Pix[y*scaled.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return orient(scaled, orientation)
+}
+
+// span 返回目标像素 i 在源图中对应的区间，放大时至少包含一个源像素
+func span(i, size, side int) (int, int) {
+	lo := i * side / size
+	hi := (i + 1) * side / size
+	if hi <= lo {
+		hi = lo + 1
+	}
+	return lo, hi
+}
+
+// orient 按 EXIF 方向翻转或旋转正方形图片
+func orient(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+	n := src.Bounds().Dx()
+	dst := image.NewRGBA(src.Bounds())
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // 水平翻转
+				sx, sy = n-1-x, y
+			case 3: // 旋转 180°
+				sx, sy = n-1-x, n-1-y
+			case 4: // 垂直翻转
+				sx, sy = x, n-1-y
+			case 5: // 沿主对角线翻转
+				sx, sy = y, x
+			case 6: // 顺时针旋转 90°
+				sx, sy = y, n-1-x
+			case 7: // 沿副对角线翻转
+				sx, sy = n-1-y, n-1-x
+			case 8: // 逆时针旋转 90°
+				sx, sy = n-1-y, x
+			}
+			copy(dst.Pix[y*dst.Stride+x*4:y*dst.Stride+x*4+4], src.Pix[sy*src.Stride+sx*4:])
+		}
+	}
+	return dst
+}
+
+// EncodePNG 将图片编码为 PNG，保留透明度
+func EncodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+package imaging
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"testing"
+)
+
+// pngWithSize 编码一张小图后改写 IHDR 中声明的宽高，模拟声明巨大尺寸的高压缩比图片
+func pngWithSize(t *testing.T, width, height uint32) []byte {
+	t.Helper()
+	data, err := EncodePNG(image.NewRGBA(image.Rect(0, 0, 4, 4)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 8 字节签名后依次为 IHDR 的长度、类型、13 字节内容与 CRC
+	ihdr := data[8+4 : 8+8+13]
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	binary.BigEndian.PutUint32(data[8+8+13:], crc32.ChecksumIEEE(ihdr))
+	return data
+}
+
+func TestDecodeRejectsOversizedImage(t *testing.T) {
+	data := pngWithSize(t, 50000, 50000)
+	if _, _, err := Decode(data, 16_000_000); !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("Decode = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestDecodeWithinLimit(t *testing.T) {
+	data := pngWithSize(t, 4, 4)
+	img, orientation, err := Decode(data, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds().Dx() != 4 || orientation != 1 {
+		t.Fatalf("Decode = %v, %d", img.Bounds(), orientation)
+	}
+	if _, _, err := Decode(data, 15); !errors.Is(err, ErrImageTooLarge) {
+		t.Fatalf("Decode over limit = %v, want ErrImageTooLarge", err)
+	}
+}
+
+func TestDecodeRejectsGarbage(t *testing.T) {
+	if _, _, err := Decode([]byte("not an image"), 16_000_000); !errors.Is(err, ErrInvalidImage) {
+		t.Fatalf("Decode = %v, want ErrInvalidImage", err)
+	}
+}
@@ -0,0 +1,31 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileMailer 将邮件写入目录中的 .eml 文件，用于本地开发
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer 创建文件邮件发送器，dir 为空时写入 ./mail
+func NewFileMailer(dir, from string) *FileMailer {
+	if dir == "" {
+		dir = "./mail"
+	}
+	return &FileMailer{dir: dir, from: from}
+}
+
+func (m *FileMailer) Send(msg Message) error {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%s.eml", time.Now().Format("20060102-150405"), uuid.New().String())
+	return os.WriteFile(filepath.Join(m.dir, name), buildRFC822(m.from, msg), 0644)
+}
@@ -0,0 +1,87 @@
+package mailer
+
+import (
+	"fmt"
+	"github.com/google/uuid"
+	"mime"
+	"strings"
+	"time"
+)
+
+// Message 一封待发送的邮件
+type Message struct {
+	To      string
+	Subject string
+	Body    string // 纯文本正文
+	HTML    string // 可选的 HTML 正文，设置后以 multipart/alternative 发送，Body 作为不支持 HTML 的客户端的后备
+}
+
+// Mailer 邮件发送接口，可按配置切换 SMTP、文件或内存实现
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// Config 邮件发送配置
+type Config struct {
+	Driver   string // smtp, file, memory
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+	Dir      string // file 驱动写入的目录
+}
+
+// New 根据配置创建邮件发送器
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.Host, cfg.Port, cfg.Username, cfg.Password, cfg.From), nil
+	case "file", "":
+		return NewFileMailer(cfg.Dir, cfg.From), nil
+	case "memory":
+		return NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", cfg.Driver)
+	}
+}
+
+// buildRFC822 生成包含基本头部的邮件，只有纯文本正文时为 text/plain，否则为 multipart/alternative
+func buildRFC822(from string, msg Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + from + "\r\n")
+	b.WriteString("To: " + msg.To + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	if msg.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(crlf(msg.Body))
+		return []byte(b.String())
+	}
+
+	boundary := "geekreward-" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	b.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n")
+	b.WriteString("\r\n")
+	// 按 RFC 2046，越靠后的部分越优先，HTML 放在最后
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Body},
+		{"text/html", msg.HTML},
+	} {
+		b.WriteString("--" + boundary + "\r\n")
+		b.WriteString("Content-Type: " + part.contentType + "; charset=UTF-8\r\n")
+		b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+		b.WriteString("\r\n")
+		b.WriteString(crlf(part.body))
+		b.WriteString("\r\n")
+	}
+	b.WriteString("--" + boundary + "--\r\n")
+	return []byte(b.String())
+}
+
+// crlf 将正文中的换行统一为 CRLF
+func crlf(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+}
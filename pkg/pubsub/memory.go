@@ -0,0 +1,48 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryBroker 进程内的消息代理，只适用于单实例部署
+type MemoryBroker struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers map[string]map[int]Handler
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{handlers: make(map[string]map[int]Handler)}
+}
+
+func (b *MemoryBroker) Publish(_ context.Context, channel string, payload []byte) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, handler := range b.handlers[channel] {
+		handler(payload)
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(ctx context.Context, channel string, handler Handler) error {
+	b.mu.Lock()
+	id := b.nextID
+	b.nextID++
+	if b.handlers[channel] == nil {
+		b.handlers[channel] = make(map[int]Handler)
+	}
+	b.handlers[channel][id] = handler
+	b.mu.Unlock()
+
+	<-ctx.Done()
+
+	b.mu.Lock()
+	delete(b.handlers[channel], id)
+	b.mu.Unlock()
+	return ctx.Err()
+}
+
+func (b *MemoryBroker) Close() error {
+	return nil
+}
@@ -0,0 +1,38 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+)
+
+// Handler 处理订阅到的消息，应尽快返回，避免阻塞其他订阅者
+type Handler func(payload []byte)
+
+// Broker 发布/订阅接口，用于在多个服务实例之间广播消息
+type Broker interface {
+	// Publish 向频道发布消息
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe 订阅频道并阻塞到 ctx 结束
+	Subscribe(ctx context.Context, channel string, handler Handler) error
+	Close() error
+}
+
+// Config 消息代理配置
+type Config struct {
+	Driver   string // memory, redis
+	Addr     string // redis 地址，host:port
+	Password string
+	DB       int
+}
+
+// New 根据配置创建消息代理
+func New(cfg Config) (Broker, error) {
+	switch cfg.Driver {
+	case "memory", "":
+		return NewMemoryBroker(), nil
+	case "redis":
+		return NewRedisBroker(cfg.Addr, cfg.Password, cfg.DB), nil
+	default:
+		return nil, fmt.Errorf("unknown pubsub driver %q", cfg.Driver)
+	}
+}
@@ -0,0 +1,51 @@
+package pubsub
+
+import (
+	"context"
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBroker 基于 Redis PUBLISH/SUBSCRIBE 的消息代理，用于多实例部署
+type RedisBroker struct {
+	client *redis.Client
+}
+
+func NewRedisBroker(addr, password string, db int) *RedisBroker {
+	return &RedisBroker{client: redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})}
+}
+
+func (b *RedisBroker) Publish(ctx context.Context, channel string, payload []byte) error {
+	return b.client.Publish(ctx, channel, payload).Err()
+}
+
+// Subscribe 连接断开时由 go-redis 自动重连并重新订阅
+func (b *RedisBroker) Subscribe(ctx context.Context, channel string, handler Handler) error {
+	sub := b.client.Subscribe(ctx, channel)
+	defer sub.Close()
+
+	// 等待订阅确认，尽早暴露连接错误
+	if _, err := sub.Receive(ctx); err != nil {
+		return err
+	}
+
+	messages := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-messages:
+			if !ok {
+				return nil
+			}
+			handler([]byte(msg.Payload))
+		}
+	}
+}
+
+func (b *RedisBroker) Close() error {
+	return b.client.Close()
+}
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Local 将对象保存在本地目录中，预签名地址由服务端的 /storage 路由校验后读写
+type Local struct {
+	dir     string
+	baseURL string
+	secret  []byte
+}
+
+// minSecretLength 预签名密钥的最小长度（字节）
+const minSecretLength = 32
+
+// NewLocal 创建本地存储，dir 为空时使用 ./uploads，baseURL 为空时使用 /storage
+func NewLocal(dir, baseURL, secret string) (*Local, error) {
+	if secret == "" {
+		return nil, errors.New("local storage requires a signing secret")
+	}
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("local storage signing secret must be at least %d bytes", minSecretLength)
+	}
+	if dir == "" {
+		dir = "./uploads"
+	}
+	if baseURL == "" {
+		baseURL = "/storage"
+	}
+	return &Local{dir: dir, baseURL: strings.TrimRight(baseURL, "/"), secret: []byte(secret)}, nil
+}
+
+func (l *Local) path(key string) (string, error) {
+	if err := CleanKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+// Put 先写入临时文件再重命名，避免读到写了一半的文件
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) PresignGet(key string, ttl time.Duration) (string, error) {
+	return l.presign("GET", key, ttl)
+}
+
+func (l *Local) PresignPut(key string, ttl time.Duration) (string, error) {
+	return l.presign("PUT", key, ttl)
+}
+
+func (l *Local) presign(method, key string, ttl time.Duration) (string, error) {
+	if err := CleanKey(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+	query := url.Values{
+		"expires":   {expires},
+		"signature": {l.sign(method, key, expires)},
+	}
+	return l.baseURL + "/" + key + "?" + query.Encode(), nil
+}
+
+// Verify 校验预签名地址中的 expires 与 signature
+func (l *Local) Verify(method, key, expires, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(signature), []byte(l.sign(method, key, expires))) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (l *Local) sign(method, key, expires string) string {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(method + "\n" + key + "\n" + expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLocalSecret = "local-storage-test-secret-32-bytes!"
+
+func newTestLocal(t *testing.T) *Local {
+	t.Helper()
+	l, err := NewLocal(t.TempDir(), "/storage", testLocalSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return l
+}
+
+// parsePresigned 拆出预签名地址中的对象键、expires 与 signature
+func parsePresigned(t *testing.T, raw string) (key, expires, signature string) {
+	t.Helper()
+	u, err := url.Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, ok := strings.CutPrefix(u.Path, "/storage/")
+	if !ok {
+		t.Fatalf("presigned url %q not under /storage/", raw)
+	}
+	return key, u.Query().Get("expires"), u.Query().Get("signature")
+}
+
+func TestNewLocalRequiresSecret(t *testing.T) {
+	for _, secret := range []string{"", "too-short"} {
+		if _, err := NewLocal(t.TempDir(), "", secret); err == nil {
+			t.Errorf("NewLocal(secret=%q) succeeded, want error", secret)
+		}
+	}
+}
+
+func TestLocalPresignVerifyRoundTrip(t *testing.T) {
+	l := newTestLocal(t)
+	for _, method := range []string{"GET", "PUT"} {
+		var raw string
+		var err error
+		if method == "GET" {
+			raw, err = l.PresignGet("attachments/report.pdf", time.Minute)
+		} else {
+			raw, err = l.PresignPut("attachments/report.pdf", time.Minute)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, expires, signature := parsePresigned(t, raw)
+		if key != "attachments/report.pdf" {
+			t.Fatalf("key = %q", key)
+		}
+		if err := l.Verify(method, key, expires, signature); err != nil {
+			t.Fatalf("Verify(%s) = %v", method, err)
+		}
+	}
+}
+
+func TestLocalVerifyRejectsTampering(t *testing.T) {
+	l := newTestLocal(t)
+	raw, err := l.PresignPut("incoming/a.png", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, expires, signature := parsePresigned(t, raw)
+	later := time.Now().Add(time.Hour).Unix()
+
+	other, err := NewLocal(t.TempDir(), "", strings.Repeat("x", 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name                            string
+		l                               *Local
+		method, key, expires, signature string
+	}{
+		{"other method", l, "GET", key, expires, signature},
+		{"other key", l, "PUT", "incoming/b.png", expires, signature},
+		{"extended expiry", l, "PUT", key, strconv.FormatInt(later, 10), signature},
+		{"bad expiry", l, "PUT", key, "soon", signature},
+		{"bad signature", l, "PUT", key, expires, strings.Repeat("0", len(signature))},
+		{"empty signature", l, "PUT", key, expires, ""},
+		{"other secret", other, "PUT", key, expires, signature},
+	}
+	for _, c := range cases {
+		if err := c.l.Verify(c.method, c.key, c.expires, c.signature); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: Verify = %v, want ErrInvalidSignature", c.name, err)
+		}
+	}
+}
+
+func TestLocalVerifyRejectsExpired(t *testing.T) {
+	l := newTestLocal(t)
+	raw, err := l.PresignGet("attachments/a.txt", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, expires, signature := parsePresigned(t, raw)
+	if err := l.Verify("GET", key, expires, signature); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify expired = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestLocalPresignRejectsInvalidKey(t *testing.T) {
+	l := newTestLocal(t)
+	for _, key := range []string{"", "/etc/passwd", "../secret", "a/../../b", `a\b`} {
+		if _, err := l.PresignGet(key, time.Minute); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("PresignGet(%q) = %v, want ErrInvalidKey", key, err)
+		}
+	}
+}
+
+func TestLocalPutOpenDelete(t *testing.T) {
+	l := newTestLocal(t)
+	ctx := context.Background()
+	key := "attachments/nested/file.txt"
+	content := []byte("hello")
+
+	if err := l.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	reader, err := l.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	got, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("Open = %q, %v", got, err)
+	}
+
+	if err := l.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := l.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open after delete = %v, want ErrNotFound", err)
+	}
+	if err := l.Delete(ctx, key); err != nil {
+		t.Fatalf("second Delete: %v", err)
+	}
+}
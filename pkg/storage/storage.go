@@ -0,0 +1,99 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound         = errors.New("对象不存在")
+	ErrInvalidKey       = errors.New("无效的对象键")
+	ErrInvalidSignature = errors.New("签名无效或已过期")
+)
+
+// PublicPath 对外暴露的文件路径前缀，数据库中保存的文件地址形如 /uploads/avatars/xxx.jpg，
+// 访问时由服务端重定向到存储后端的预签名下载地址
+const PublicPath = "/uploads/"
+
+// Blob 对象存储接口，可按配置切换本地文件系统或 S3 兼容的存储
+type Blob interface {
+	// Put 写入对象，size 为 -1 时表示长度未知
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 读取对象，不存在时返回 ErrNotFound
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete 删除对象，对象不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// PresignGet 生成 ttl 内有效的下载地址
+	PresignGet(key string, ttl time.Duration) (string, error)
+	// PresignPut 生成 ttl 内有效的上传地址，客户端使用 PUT 直接上传文件内容
+	PresignPut(key string, ttl time.Duration) (string, error)
+}
+
+// Config 对象存储配置
+type Config struct {
+	Driver string // local, s3
+
+	// local 驱动
+	Dir     string // 文件保存的目录
+	BaseURL string // 预签名地址的前缀，指向服务端的 /storage 路由
+	Secret  string // 预签名地址的 HMAC 密钥
+
+	// s3 驱动，兼容 MinIO 等实现了 S3 接口的服务
+	Endpoint  string // 如 https://s3.amazonaws.com 或 http://localhost:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 使用 endpoint/bucket/key 形式的地址，MinIO 需要开启
+}
+
+// New 根据配置创建对象存储
+func New(cfg Config) (Blob, error) {
+	switch cfg.Driver {
+	case "local", "":
+		return NewLocal(cfg.Dir, cfg.BaseURL, cfg.Secret)
+	case "s3":
+		return NewS3(cfg.Endpoint, cfg.Region, cfg.Bucket, cfg.AccessKey, cfg.SecretKey, cfg.PathStyle)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", cfg.Driver)
+	}
+}
+
+// NewKey 为上传的文件生成随机的对象键，保留原文件的扩展名，如 avatars/<uuid>.png
+func NewKey(prefix, filename string) string {
+	key := uuid.New().String() + strings.ToLower(filepath.Ext(filename))
+	if prefix == "" {
+		return key
+	}
+	return strings.Trim(prefix, "/") + "/" + key
+}
+
+// PublicURL 返回对象对外的访问地址
+func PublicURL(key string) string {
+	return PublicPath + key
+}
+
+// KeyFromURL 从 PublicURL 生成的地址中解析出对象键
+func KeyFromURL(url string) (string, bool) {
+	key, ok := strings.CutPrefix(url, PublicPath)
+	if !ok || CleanKey(key) != nil {
+		return "", false
+	}
+	return key, true
+}
+
+// CleanKey 校验对象键，拒绝空键、绝对路径与包含 .. 的键
+func CleanKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") || path.Clean(key) != key ||
+		key == ".." || strings.HasPrefix(key, "../") {
+		return ErrInvalidKey
+	}
+	return nil
+}
@@ -2,20 +2,71 @@ package utils
 
 import (
 	"errors"
+	"fmt"
 	"github.com/dgrijalva/jwt-go"
 	"github.com/google/uuid"
 	"time"
 )
 
-// jwtSecret 是用于签名JWT的密钥。为了安全起见，应该从配置或环境变量中获取。
-var jwtSecret = []byte("ToOrNotToGo,ItIsAQuestion.") // 替换为你自己的密钥
+// jwtSecret 是用于签名JWT的密钥，启动时通过 InitJWT 从配置中读取
+var jwtSecret []byte
+
+// accessTokenTTL 访问令牌的有效期，启动时通过 InitJWT 从配置中读取
+var accessTokenTTL = 15 * time.Minute
+
+// MinSecretLength 签名密钥的最小长度（字节），HS256 的密钥不应短于其输出长度
+const MinSecretLength = 32
+
+// ChallengeTokenTTL 两步登录中挑战令牌的有效期，用户需在此时间内提交验证码
+const ChallengeTokenTTL = 5 * time.Minute
+
+// 令牌用途，写入 typ 声明，防止挑战令牌被当作访问令牌使用
+const (
+	tokenTypeAccess    = "access"
+	tokenTypeChallenge = "2fa_challenge"
+)
+
+// AccessClaims 访问令牌中携带的身份信息
+type AccessClaims struct {
+	UserID    uuid.UUID
+	SessionID uuid.UUID // 签发该令牌的登录会话，用于注销后吊销令牌
+	ExpiresAt time.Time
+}
+
+// InitJWT 设置签名密钥与访问令牌有效期
+func InitJWT(secret string, ttl time.Duration) error {
+	if secret == "" {
+		return errors.New("jwt secret is not configured")
+	}
+	if len(secret) < MinSecretLength {
+		return fmt.Errorf("jwt secret must be at least %d bytes", MinSecretLength)
+	}
+	jwtSecret = []byte(secret)
+	if ttl > 0 {
+		accessTokenTTL = ttl
+	}
+	return nil
+}
+
+// AccessTokenTTL 返回访问令牌的有效期
+func AccessTokenTTL() time.Duration {
+	return accessTokenTTL
+}
+
+// GenerateJWT 为指定会话生成一个新的短期访问令牌
+func GenerateJWT(userID, sessionID uuid.UUID) (string, error) {
+	if len(jwtSecret) == 0 {
+		return "", errors.New("jwt secret is not configured")
+	}
 
-// GenerateJWT 生成一个新的JWT，用于用户认证
-func GenerateJWT(userID uuid.UUID) (string, error) {
 	// 创建一个新的JWT令牌，使用HS256签名方法
+	now := time.Now()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID.String(),                       // 在令牌中存储用户ID为字符串
-		"exp":     time.Now().Add(time.Hour * 72).Unix(), // 设置令牌的过期时间为72小时后
+		"typ":     tokenTypeAccess,
+		"user_id": userID.String(),                // 在令牌中存储用户ID为字符串
+		"sid":     sessionID.String(),             // 所属会话ID
+		"iat":     now.Unix(),                     // 签发时间
+		"exp":     now.Add(accessTokenTTL).Unix(), // 过期时间
 	})
 
 	// 使用密钥对令牌进行签名并生成字符串形式的令牌
@@ -27,8 +78,73 @@ func GenerateJWT(userID uuid.UUID) (string, error) {
 	return tokenString, nil
 }
 
-// ValidateJWT 验证JWT，并返回令牌中的用户ID
-func ValidateJWT(tokenString string) (uuid.UUID, error) {
+// GenerateChallengeToken 为已通过密码校验、尚需提交两步验证码的用户生成挑战令牌
+func GenerateChallengeToken(userID uuid.UUID) (string, error) {
+	if len(jwtSecret) == 0 {
+		return "", errors.New("jwt secret is not configured")
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"typ":     tokenTypeChallenge,
+		"user_id": userID.String(),
+		"iat":     now.Unix(),
+		"exp":     now.Add(ChallengeTokenTTL).Unix(),
+	})
+	return token.SignedString(jwtSecret)
+}
+
+// ValidateChallengeToken 验证挑战令牌，并返回令牌中的用户ID
+func ValidateChallengeToken(tokenString string) (uuid.UUID, error) {
+	claims, err := parseJWT(tokenString, tokenTypeChallenge)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	userIDStr, ok := claims["user_id"].(string)
+	if !ok {
+		return uuid.Nil, errors.New("invalid token claims")
+	}
+	return uuid.Parse(userIDStr)
+}
+
+// ValidateJWT 验证JWT，并返回令牌中的用户ID与会话ID
+func ValidateJWT(tokenString string) (*AccessClaims, error) {
+	claims, err := parseJWT(tokenString, tokenTypeAccess)
+	if err != nil {
+		return nil, err
+	}
+
+	// 提取令牌中的用户ID与会话ID
+	userIDStr, ok := claims["user_id"].(string)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	userID, err := uuid.Parse(userIDStr)
+	if err != nil {
+		return nil, err
+	}
+	sessionIDStr, ok := claims["sid"].(string)
+	if !ok {
+		return nil, errors.New("invalid token claims")
+	}
+	sessionID, err := uuid.Parse(sessionIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AccessClaims{UserID: userID, SessionID: sessionID}
+	if exp, ok := claims["exp"].(float64); ok {
+		result.ExpiresAt = time.Unix(int64(exp), 0)
+	}
+	return result, nil
+}
+
+// parseJWT 校验签名、有效期与令牌用途，返回令牌声明
+func parseJWT(tokenString, tokenType string) (jwt.MapClaims, error) {
+	if len(jwtSecret) == 0 {
+		return nil, errors.New("jwt secret is not configured")
+	}
+
 	// 解析并验证令牌
 	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 		// 确认签名方法是否为HMAC
@@ -37,23 +153,16 @@ func ValidateJWT(tokenString string) (uuid.UUID, error) {
 		}
 		return jwtSecret, nil
 	})
-
 	if err != nil {
-		return uuid.Nil, err
+		return nil, err
 	}
 
-	// 提取令牌中的用户ID
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		userIDStr, ok := claims["user_id"].(string)
-		if !ok {
-			return uuid.Nil, errors.New("invalid token claims")
-		}
-		userID, err := uuid.Parse(userIDStr)
-		if err != nil {
-			return uuid.Nil, err
-		}
-		return userID, nil
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
 	}
-
-	return uuid.Nil, errors.New("invalid token")
+	if typ, _ := claims["typ"].(string); typ != tokenType {
+		return nil, errors.New("unexpected token type")
+	}
+	return claims, nil
 }
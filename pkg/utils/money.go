@@ -0,0 +1,8 @@
+package utils
+
+import "math"
+
+// RoundCents 金额统一保留两位小数
+func RoundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}